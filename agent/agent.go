@@ -0,0 +1,258 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-19 10:36:04
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-08-09 10:22:41
+ * @Description: 智能体，循环执行"调用模型 → 执行工具 → 追加工具结果"直到模型给出最终回复
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package agent
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/Mrzhouyl/go-aisdk/errors"
+	"github.com/Mrzhouyl/go-aisdk/httpclient"
+	"github.com/Mrzhouyl/go-aisdk/models"
+)
+
+const (
+	defaultMaxSteps = 10 // 默认最大步数
+)
+
+// Client 聊天客户端，*aisdk.SDKClient 实现了该接口
+type Client interface {
+	CreateChatCompletion(ctx context.Context, request models.ChatRequest, opts ...httpclient.HTTPClientOption) (response models.ChatResponse, err error)
+}
+
+// EventType 事件类型
+type EventType string
+
+const (
+	EventStepStart     EventType = "step_start"     // 开始一个步骤（调用模型前）
+	EventModelResponse EventType = "model_response" // 收到模型响应
+	EventToolCall      EventType = "tool_call"      // 开始执行工具调用
+	EventToolResult    EventType = "tool_result"    // 工具调用执行完成
+	EventFinish        EventType = "finish"         // 模型给出最终回复
+)
+
+// Event 智能体事件
+type Event struct {
+	Type     EventType            // 事件类型
+	Step     int                  // 步数，从 1 开始
+	Response *models.ChatResponse // 模型响应，仅 model_response 和 finish 事件
+	ToolCall *models.ToolCalls    // 工具调用，仅 tool_call 和 tool_result 事件
+	Result   string               // 工具结果，仅 tool_result 事件
+	Err      error                // 工具错误，仅 tool_result 事件
+}
+
+// EventHandler 事件处理函数，同一次运行中的事件按顺序串行回调
+type EventHandler func(event Event)
+
+// AgentConfig 智能体配置
+type AgentConfig struct {
+	Registry        *ToolRegistry                 // 工具注册表
+	MaxSteps        int                           // 最大步数（调用模型的次数）
+	StopOnToolError bool                          // 工具执行失败时是否停止运行，默认将错误信息作为工具结果返回给模型
+	OnEvent         EventHandler                  // 事件处理函数
+	Opts            []httpclient.HTTPClientOption // 调用模型时的 HTTP 客户端选项
+}
+
+// Agent 智能体
+type Agent struct {
+	config AgentConfig
+}
+
+// RunResult 运行结果
+type RunResult struct {
+	Response models.ChatResponse  // 最后一次模型响应
+	Messages []models.ChatMessage // 完整的对话消息，包含模型回复和工具结果
+	Steps    int                  // 已执行的步数
+	Usage    models.ChatUsage     // 累计的 token 用量
+}
+
+// NewAgent 新建智能体
+func NewAgent(config AgentConfig) (a *Agent) {
+	// 设置默认值
+	if config.Registry == nil {
+		config.Registry, _ = NewToolRegistry()
+	}
+	if config.MaxSteps <= 0 {
+		config.MaxSteps = defaultMaxSteps
+	}
+	return &Agent{config: config}
+}
+
+// Run 运行智能体，注册表中的工具会追加到请求的工具列表中（同名工具以请求为准）。
+// 请求设置了 ParallelToolCalls 时并发执行同一次响应中的多个工具调用，超出最大步数时返回 errors.ErrMaxStepsExceeded
+func (a *Agent) Run(ctx context.Context, client Client, request models.ChatRequest) (result RunResult, err error) {
+	run := &agentRun{agent: a}
+	request.Tools = a.mergeTools(request.Tools)
+	request.Messages = slices.Clone(request.Messages)
+	defer func() {
+		result.Messages = request.Messages
+	}()
+
+	for step := 1; step <= a.config.MaxSteps; step++ {
+		result.Steps = step
+		run.emit(Event{Type: EventStepStart, Step: step})
+		// 调用模型
+		var response models.ChatResponse
+		if response, err = client.CreateChatCompletion(ctx, request, a.config.Opts...); err != nil {
+			return
+		}
+		result.Response = response
+		addUsage(&result.Usage, response.Usage)
+		run.emit(Event{Type: EventModelResponse, Step: step, Response: &response})
+		if len(response.Choices) == 0 || response.Choices[0].Message == nil {
+			err = fmt.Errorf("agent step %d: model returned no message", step)
+			return
+		}
+		// 追加模型回复
+		message := response.Choices[0].Message
+		request.Messages = append(request.Messages, assistantMessage(message))
+		if len(message.ToolCalls) == 0 {
+			run.emit(Event{Type: EventFinish, Step: step, Response: &response})
+			return
+		}
+		// 执行工具调用并追加工具结果
+		var toolMessages []models.ChatMessage
+		if toolMessages, err = run.callTools(ctx, step, message.ToolCalls, models.BoolValue(request.ParallelToolCalls)); err != nil {
+			return
+		}
+		request.Messages = append(request.Messages, toolMessages...)
+	}
+	err = fmt.Errorf("agent stopped after %d steps: %w", a.config.MaxSteps, errors.ErrMaxStepsExceeded)
+	return
+}
+
+// mergeTools 合并请求中的工具和注册表中的工具
+func (a *Agent) mergeTools(tools []models.ChatTool) (merged []models.ChatTool) {
+	merged = slices.Clone(tools)
+	for _, tool := range a.config.Registry.ChatTools() {
+		if !slices.ContainsFunc(merged, func(t models.ChatTool) bool {
+			return t.Function != nil && t.Function.Name == tool.Function.Name
+		}) {
+			merged = append(merged, tool)
+		}
+	}
+	return
+}
+
+// agentRun 单次运行的状态
+type agentRun struct {
+	agent   *Agent
+	eventMu sync.Mutex // 保证事件串行回调
+}
+
+// emit 发送事件
+func (r *agentRun) emit(event Event) {
+	if r.agent.config.OnEvent == nil {
+		return
+	}
+	r.eventMu.Lock()
+	defer r.eventMu.Unlock()
+
+	r.agent.config.OnEvent(event)
+}
+
+// callTools 执行工具调用，返回的工具消息与工具调用的顺序一致
+func (r *agentRun) callTools(ctx context.Context, step int, toolCalls []models.ToolCalls, parallel bool) (messages []models.ChatMessage, err error) {
+	var (
+		results = make([]string, len(toolCalls))
+		errs    = make([]error, len(toolCalls))
+	)
+	if parallel && len(toolCalls) > 1 {
+		var wg sync.WaitGroup
+		for i := range toolCalls {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				results[i], errs[i] = r.callTool(ctx, step, &toolCalls[i])
+			}()
+		}
+		wg.Wait()
+	} else {
+		for i := range toolCalls {
+			results[i], errs[i] = r.callTool(ctx, step, &toolCalls[i])
+		}
+	}
+
+	messages = make([]models.ChatMessage, 0, len(toolCalls))
+	for i, toolCall := range toolCalls {
+		content := results[i]
+		if errs[i] != nil {
+			if r.agent.config.StopOnToolError {
+				return nil, errs[i]
+			}
+			content = fmt.Sprintf("error: %v", errs[i])
+		}
+		messages = append(messages, &models.ToolMessage{
+			Content:    content,
+			ToolCallID: toolCall.ID,
+		})
+	}
+	return
+}
+
+// callTool 执行单个工具调用，工具处理函数 panic 时转换为错误
+func (r *agentRun) callTool(ctx context.Context, step int, toolCall *models.ToolCalls) (result string, err error) {
+	r.emit(Event{Type: EventToolCall, Step: step, ToolCall: toolCall})
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("tool panic: %v", p)
+		}
+		r.emit(Event{Type: EventToolResult, Step: step, ToolCall: toolCall, Result: result, Err: err})
+	}()
+
+	return r.agent.config.Registry.Call(ctx, *toolCall)
+}
+
+// assistantMessage 将模型回复转换为 assistant 消息，工具调用的索引仅用于流式传输，不回传给模型
+func assistantMessage(message *models.ChatCompletionMessage) (msg *models.AssistantMessage) {
+	msg = &models.AssistantMessage{
+		Content: message.Content,
+		Refusal: message.Refusal,
+	}
+	for _, toolCall := range message.ToolCalls {
+		toolCall.Index = 0
+		msg.ToolCalls = append(msg.ToolCalls, toolCall)
+	}
+	return
+}
+
+// addUsage 累加 token 用量，包括提示词与补全的明细
+func addUsage(total *models.ChatUsage, usage *models.ChatUsage) {
+	if usage == nil {
+		return
+	}
+	total.PromptTokens += usage.PromptTokens
+	total.CompletionTokens += usage.CompletionTokens
+	total.TotalTokens += usage.TotalTokens
+	total.PromptCacheHitTokens += usage.PromptCacheHitTokens
+	total.PromptCacheMissTokens += usage.PromptCacheMissTokens
+	if usage.CompletionTokensDetails != nil {
+		if total.CompletionTokensDetails == nil {
+			total.CompletionTokensDetails = &models.CompletionTokensDetails{}
+		}
+		total.CompletionTokensDetails.TextTokens += usage.CompletionTokensDetails.TextTokens
+		total.CompletionTokensDetails.AudioTokens += usage.CompletionTokensDetails.AudioTokens
+		total.CompletionTokensDetails.ReasoningTokens += usage.CompletionTokensDetails.ReasoningTokens
+		total.CompletionTokensDetails.AcceptedPredictionTokens += usage.CompletionTokensDetails.AcceptedPredictionTokens
+		total.CompletionTokensDetails.RejectedPredictionTokens += usage.CompletionTokensDetails.RejectedPredictionTokens
+	}
+	if usage.PromptTokensDetails != nil {
+		if total.PromptTokensDetails == nil {
+			total.PromptTokensDetails = &models.PromptTokensDetails{}
+		}
+		total.PromptTokensDetails.AudioTokens += usage.PromptTokensDetails.AudioTokens
+		total.PromptTokensDetails.CachedTokens += usage.PromptTokensDetails.CachedTokens
+		total.PromptTokensDetails.TextTokens += usage.PromptTokensDetails.TextTokens
+		total.PromptTokensDetails.ImageTokens += usage.PromptTokensDetails.ImageTokens
+		total.PromptTokensDetails.VideoTokens += usage.PromptTokensDetails.VideoTokens
+	}
+}
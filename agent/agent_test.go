@@ -0,0 +1,161 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-19 14:21:40
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-08-09 10:22:41
+ * @Description:
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package agent
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Mrzhouyl/go-aisdk/errors"
+	"github.com/Mrzhouyl/go-aisdk/httpclient"
+	"github.com/Mrzhouyl/go-aisdk/models"
+)
+
+// fakeClient 按顺序返回预设响应的客户端
+type fakeClient struct {
+	responses []*models.ChatCompletionMessage
+	requests  []models.ChatRequest
+}
+
+func (c *fakeClient) CreateChatCompletion(ctx context.Context, request models.ChatRequest, opts ...httpclient.HTTPClientOption) (response models.ChatResponse, err error) {
+	message := c.responses[min(len(c.requests), len(c.responses)-1)]
+	c.requests = append(c.requests, request)
+	response.Choices = []models.ChatChoice{{Message: message}}
+	response.Usage = &models.ChatUsage{
+		PromptTokens:            1,
+		CompletionTokens:        2,
+		TotalTokens:             3,
+		CompletionTokensDetails: &models.CompletionTokensDetails{ReasoningTokens: 1},
+		PromptTokensDetails:     &models.PromptTokensDetails{CachedTokens: 1},
+	}
+	return
+}
+
+func newToolCall(id, name, arguments string) (toolCall models.ToolCalls) {
+	return models.ToolCalls{ID: id, Type: models.ToolTypeFunction, Function: &models.ToolCallsFunction{Name: name, Arguments: arguments}}
+}
+
+type weatherArgs struct {
+	City string `json:"city"`
+}
+
+func newTestRegistry(t *testing.T, delay time.Duration, running *atomic.Int32, maxRunning *atomic.Int32) (r *ToolRegistry) {
+	r, err := NewToolRegistry(NewTool("get_weather", "获取天气", nil, func(ctx context.Context, args weatherArgs) (result any, err error) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			m := maxRunning.Load()
+			if n <= m || maxRunning.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(delay)
+		return map[string]string{"city": args.City, "weather": "sunny"}, nil
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return
+}
+
+func TestAgent_Run(t *testing.T) {
+	tests := []struct {
+		name        string
+		parallel    bool
+		wantMaxRuns int32
+	}{
+		{name: "sequential", parallel: false, wantMaxRuns: 1},
+		{name: "parallel", parallel: true, wantMaxRuns: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				running, maxRunning atomic.Int32
+				events              []EventType
+				client              = &fakeClient{responses: []*models.ChatCompletionMessage{
+					{Role: "assistant", ToolCalls: []models.ToolCalls{
+						newToolCall("call_1", "get_weather", `{"city":"SH"}`),
+						newToolCall("call_2", "get_weather", `{"city":"BJ"}`),
+						newToolCall("call_3", "unknown", `{}`),
+					}},
+					{Role: "assistant", Content: "done"},
+				}}
+				a = NewAgent(AgentConfig{
+					Registry: newTestRegistry(t, 20*time.Millisecond, &running, &maxRunning),
+					OnEvent: func(event Event) {
+						events = append(events, event.Type)
+					},
+				})
+			)
+			result, err := a.Run(context.Background(), client, models.ChatRequest{
+				Messages:          []models.ChatMessage{&models.UserMessage{Content: "weather?"}},
+				ParallelToolCalls: models.Bool(tt.parallel),
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if maxRunning.Load() != tt.wantMaxRuns {
+				t.Errorf("expected max %d concurrent tools, got %d", tt.wantMaxRuns, maxRunning.Load())
+			}
+			if result.Steps != 2 || result.Usage.TotalTokens != 6 || result.Response.Choices[0].Message.Content != "done" {
+				t.Errorf("unexpected result: %+v", result)
+			}
+			if result.Usage.CompletionTokensDetails.ReasoningTokens != 2 || result.Usage.PromptTokensDetails.CachedTokens != 2 {
+				t.Errorf("unexpected usage details: %+v, %+v", result.Usage.CompletionTokensDetails, result.Usage.PromptTokensDetails)
+			}
+			// user + assistant + 3 tool + assistant
+			if len(result.Messages) != 6 {
+				t.Fatalf("expected 6 messages, got %d", len(result.Messages))
+			}
+			if tool := result.Messages[2].(*models.ToolMessage); tool.ToolCallID != "call_1" || !strings.Contains(tool.Content, `"city":"SH"`) {
+				t.Errorf("unexpected tool message: %+v", tool)
+			}
+			if tool := result.Messages[4].(*models.ToolMessage); !strings.Contains(tool.Content, "tool [unknown] not found") {
+				t.Errorf("unexpected tool message: %+v", tool)
+			}
+			if len(client.requests[0].Tools) != 1 || client.requests[0].Tools[0].Function.Name != "get_weather" ||
+				client.requests[0].Tools[0].Function.Parameters["required"].([]string)[0] != "city" {
+				t.Errorf("expected registry tools in request, got %+v", client.requests[0].Tools)
+			}
+			if events[0] != EventStepStart || events[len(events)-1] != EventFinish || len(events) != 11 {
+				t.Errorf("unexpected events: %v", events)
+			}
+		})
+	}
+}
+
+func TestAgent_RunMaxSteps(t *testing.T) {
+	var running, maxRunning atomic.Int32
+	client := &fakeClient{responses: []*models.ChatCompletionMessage{
+		{Role: "assistant", ToolCalls: []models.ToolCalls{newToolCall("call_1", "get_weather", `{"city":"SH"}`)}},
+	}}
+	a := NewAgent(AgentConfig{Registry: newTestRegistry(t, 0, &running, &maxRunning), MaxSteps: 3})
+	result, err := a.Run(context.Background(), client, models.ChatRequest{})
+	if !errors.IsMaxStepsExceededError(err) || result.Steps != 3 || len(client.requests) != 3 {
+		t.Errorf("expected ErrMaxStepsExceeded after 3 steps, got %v (steps: %d)", err, result.Steps)
+	}
+}
+
+func TestToolRegistry_Register(t *testing.T) {
+	r, _ := NewToolRegistry()
+	handler := func(ctx context.Context, arguments string) (result string, err error) { return }
+	if err := r.Register(Tool{Name: "bad name", Handler: handler}); err == nil {
+		t.Error("expected invalid name error")
+	}
+	if err := r.Register(Tool{Name: "ok"}); err == nil {
+		t.Error("expected nil handler error")
+	}
+	if err := r.Register(Tool{Name: "ok", Handler: handler}, Tool{Name: "ok", Handler: handler}); err == nil {
+		t.Error("expected duplicate name error")
+	}
+}
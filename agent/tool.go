@@ -0,0 +1,172 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-19 09:52:18
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-20 15:34:26
+ * @Description: 工具注册表，将 Go 函数注册为模型可调用的工具
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/Mrzhouyl/go-aisdk/errors"
+	"github.com/Mrzhouyl/go-aisdk/models"
+	"github.com/Mrzhouyl/go-aisdk/schema"
+)
+
+// toolNamePattern 工具名称规则，必须是 a-z, A-Z, 0-9 或者包含下划线和破折号，最大长度为 64
+var toolNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// ToolHandler 工具处理函数，arguments 为模型生成的 JSON 参数，返回的 result 作为工具消息的内容
+type ToolHandler func(ctx context.Context, arguments string) (result string, err error)
+
+// Tool 工具
+type Tool struct {
+	Name        string         // 函数名称
+	Description string         // 函数描述，用于帮助模型决定何时以及如何调用函数
+	Parameters  map[string]any // 函数接受的参数，描述为一个 JSON Schema 对象
+	Strict      *bool          // 是否启用严格模式
+	Handler     ToolHandler    // 工具处理函数
+}
+
+// NewTool 通过带类型参数的 Go 函数新建工具，模型生成的参数会解析为 A，返回值为字符串时直接作为结果，否则序列化为 JSON。
+// parameters 为 nil 时根据 A 的字段标签生成参数的 JSON Schema（见 schema.Generate）
+func NewTool[A any](name, description string, parameters map[string]any, fn func(ctx context.Context, args A) (result any, err error)) (tool Tool) {
+	if parameters == nil {
+		// 生成失败时使用空对象，由模型自由生成参数
+		parameters, _ = schema.For[A](schema.Config{})
+	}
+	return Tool{
+		Name:        name,
+		Description: description,
+		Parameters:  parameters,
+		Handler: func(ctx context.Context, arguments string) (result string, err error) {
+			var args A
+			if strings.TrimSpace(arguments) != "" {
+				if err = json.Unmarshal([]byte(arguments), &args); err != nil {
+					return "", fmt.Errorf("invalid arguments for tool [%s]: %w", name, err)
+				}
+			}
+			var value any
+			if value, err = fn(ctx, args); err != nil {
+				return
+			}
+			return formatToolResult(value)
+		},
+	}
+}
+
+// formatToolResult 格式化工具结果
+func formatToolResult(value any) (result string, err error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case []byte:
+		return string(v), nil
+	case fmt.Stringer:
+		return v.String(), nil
+	}
+	var b []byte
+	if b, err = json.Marshal(value); err != nil {
+		return
+	}
+	return string(b), nil
+}
+
+// ToolRegistry 工具注册表（并发安全）
+type ToolRegistry struct {
+	mu    sync.RWMutex
+	tools map[string]Tool // 键为工具名称
+	order []string        // 注册顺序
+}
+
+// NewToolRegistry 新建工具注册表
+func NewToolRegistry(tools ...Tool) (r *ToolRegistry, err error) {
+	r = &ToolRegistry{
+		tools: make(map[string]Tool),
+	}
+	if err = r.Register(tools...); err != nil {
+		return nil, err
+	}
+	return
+}
+
+// Register 注册工具，名称不合法、处理函数为空或名称重复时返回错误
+func (r *ToolRegistry) Register(tools ...Tool) (err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.tools == nil {
+		r.tools = make(map[string]Tool)
+	}
+	for _, tool := range tools {
+		if !toolNamePattern.MatchString(tool.Name) {
+			return fmt.Errorf("invalid tool name [%s]", tool.Name)
+		}
+		if tool.Handler == nil {
+			return fmt.Errorf("tool [%s] handler is nil", tool.Name)
+		}
+		if _, ok := r.tools[tool.Name]; ok {
+			return fmt.Errorf("tool [%s] is already registered", tool.Name)
+		}
+		r.tools[tool.Name] = tool
+		r.order = append(r.order, tool.Name)
+	}
+	return
+}
+
+// Get 获取工具
+func (r *ToolRegistry) Get(name string) (tool Tool, ok bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	tool, ok = r.tools[name]
+	return
+}
+
+// ChatTools 获取按注册顺序排列的工具定义，用于设置 ChatRequest.Tools
+func (r *ToolRegistry) ChatTools() (tools []models.ChatTool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	tools = make([]models.ChatTool, 0, len(r.order))
+	for _, name := range r.order {
+		tool := r.tools[name]
+		parameters := tool.Parameters
+		if parameters == nil {
+			parameters = map[string]any{"type": "object", "properties": map[string]any{}}
+		}
+		tools = append(tools, models.ChatTool{
+			Type: models.ToolTypeFunction,
+			Function: &models.ChatToolFunction{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  parameters,
+				Strict:      tool.Strict,
+			},
+		})
+	}
+	return
+}
+
+// Call 执行工具调用
+func (r *ToolRegistry) Call(ctx context.Context, call models.ToolCalls) (result string, err error) {
+	if call.Function == nil {
+		return "", errors.WrapToolNotFound("")
+	}
+	tool, ok := r.Get(call.Function.Name)
+	if !ok {
+		return "", errors.WrapToolNotFound(call.Function.Name)
+	}
+	return tool.Handler(ctx, call.Function.Arguments)
+}
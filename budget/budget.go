@@ -0,0 +1,77 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-26 09:42:17
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-26 14:05:33
+ * @Description: 预算额度与重置周期
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package budget
+
+import (
+	"fmt"
+	"time"
+)
+
+// Scope 预算的范围
+type Scope string
+
+const (
+	ScopeUser   Scope = "user"   // 终端用户，即 models.UserInfo.User
+	ScopeTenant Scope = "tenant" // 租户或项目，通过 cost.WithTenant 设置到上下文
+)
+
+// Window 预算的重置周期
+type Window string
+
+const (
+	WindowDaily   Window = "daily"   // 每天重置
+	WindowMonthly Window = "monthly" // 每月重置
+)
+
+// Period 获取 now 所在周期的开始时间和结束时间（下一次重置的时间）
+func (w Window) Period(now time.Time) (start, end time.Time) {
+	year, month, day := now.Date()
+	switch w {
+	case WindowMonthly:
+		start = time.Date(year, month, 1, 0, 0, 0, 0, now.Location())
+		end = start.AddDate(0, 1, 0)
+	default:
+		start = time.Date(year, month, day, 0, 0, 0, 0, now.Location())
+		end = start.AddDate(0, 0, 1)
+	}
+	return
+}
+
+// Limit 预算额度
+type Limit struct {
+	Scope  Scope   `json:"scope"`          // 范围
+	ID     string  `json:"id,omitempty"`   // 用户ID或租户ID，为空表示该范围内未单独设置额度的每个用户（租户）各自的默认额度
+	Window Window  `json:"window"`         // 重置周期
+	Soft   float64 `json:"soft,omitempty"` // 软限制，累计费用达到后调用 OnSoftLimit，不影响请求，0 表示不设置
+	Hard   float64 `json:"hard,omitempty"` // 硬限制，剩余预算不足以支付预估费用时降级模型或拒绝请求，0 表示不设置
+}
+
+// Status 当前周期的预算使用情况
+type Status struct {
+	Limit    Limit     `json:"limit"`    // 预算额度
+	ID       string    `json:"id"`       // 用户ID或租户ID
+	Start    time.Time `json:"start"`    // 周期开始时间
+	ResetAt  time.Time `json:"reset_at"` // 下一次重置的时间
+	Spent    float64   `json:"spent"`    // 已花费
+	Currency string    `json:"currency"` // 货币
+}
+
+// Remaining 获取剩余的硬限制预算，未设置硬限制时返回 -1
+func (s Status) Remaining() (remaining float64) {
+	if s.Limit.Hard <= 0 {
+		return -1
+	}
+	return max(s.Limit.Hard-s.Spent, 0)
+}
+
+// storeKey 获取预算在存储中的键，键中包含周期开始时间，进入新的周期后自动使用新的键
+func storeKey(scope Scope, id string, window Window, start time.Time) (key string) {
+	return fmt.Sprintf("%s:%s:%s:%s", scope, window, start.Format(time.DateOnly), id)
+}
@@ -0,0 +1,287 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-26 15:40:09
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-08-09 09:47:20
+ * @Description:
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package budget
+
+import (
+	"context"
+	"io"
+	"math"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Mrzhouyl/go-aisdk/catalog"
+	"github.com/Mrzhouyl/go-aisdk/consts"
+	"github.com/Mrzhouyl/go-aisdk/cost"
+	"github.com/Mrzhouyl/go-aisdk/errors"
+	"github.com/Mrzhouyl/go-aisdk/httpclient"
+	"github.com/Mrzhouyl/go-aisdk/internal/utils"
+	"github.com/Mrzhouyl/go-aisdk/models"
+)
+
+// almostEqual 判断浮点数是否近似相等
+func almostEqual(a, b float64) (ok bool) {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestWindowPeriod(t *testing.T) {
+	now := time.Date(2025, 12, 31, 15, 4, 5, 0, time.UTC)
+	start, end := WindowDaily.Period(now)
+	if !start.Equal(time.Date(2025, 12, 31, 0, 0, 0, 0, time.UTC)) || !end.Equal(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("daily period = %v - %v", start, end)
+	}
+	start, end = WindowMonthly.Period(now)
+	if !start.Equal(time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC)) || !end.Equal(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("monthly period = %v - %v", start, end)
+	}
+	if storeKey(ScopeUser, "alice", WindowDaily, now) == storeKey(ScopeUser, "alice", WindowDaily, now.AddDate(0, 0, 1)) {
+		t.Error("store key should change in a new period")
+	}
+}
+
+// newTestMiddleware 创建测试用的预算中间件，gpt-4o 每个输出 token 1 美元，gpt-4o-mini 每个输出 token 0.1 美元
+func newTestMiddleware(config BudgetMiddlewareConfig) (m *BudgetMiddleware) {
+	config.Catalog = catalog.New(
+		catalog.ModelSpec{Provider: consts.OpenAI, Model: consts.OpenAIGPT4o, Pricing: catalog.Pricing{Output: 1e6, Currency: catalog.CurrencyUSD}},
+		catalog.ModelSpec{Provider: consts.OpenAI, Model: consts.OpenAIGPT4oMini, Pricing: catalog.Pricing{Output: 1e5, Currency: catalog.CurrencyUSD}},
+	)
+	return NewBudgetMiddleware(config)
+}
+
+// chatRequest 创建最大输出 token 数为 maxTokens 的聊天请求
+func chatRequest(model string, maxTokens int) (request models.ChatRequest) {
+	return models.ChatRequest{
+		Model:               model,
+		Messages:            []models.ChatMessage{&models.UserMessage{Content: "hello"}},
+		MaxCompletionTokens: models.Int(maxTokens),
+	}
+}
+
+// execute 通过中间件链执行请求，返回最终处理函数收到的模型
+func execute(m *BudgetMiddleware, ctx context.Context, user string, request models.ChatRequest, handler func(ctx context.Context) any) (model string, response any, err error) {
+	ctx = httpclient.SetRequestInfo(ctx, &httpclient.RequestInfo{
+		Provider: string(consts.OpenAI),
+		Model:    request.Model,
+		User:     user,
+	})
+	response, err = httpclient.NewChain(m).Execute(ctx, request, func(ctx context.Context, req any) (any, error) {
+		model = req.(models.ChatRequest).Model
+		if model != httpclient.GetRequestInfo(ctx).Model {
+			panic("request info model not updated")
+		}
+		return handler(ctx), nil
+	})
+	return
+}
+
+// chatResponse 创建输出 token 数为 completionTokens 的聊天响应
+func chatResponse(completionTokens int) (handler func(ctx context.Context) any) {
+	return func(ctx context.Context) any {
+		return models.ChatResponse{ChatBaseResponse: models.ChatBaseResponse{Usage: &models.ChatUsage{CompletionTokens: completionTokens}}}
+	}
+}
+
+func TestBudgetMiddleware(t *testing.T) {
+	var (
+		softEvents []Status
+		downgrades []string
+	)
+	m := newTestMiddleware(BudgetMiddlewareConfig{
+		Limits: []Limit{
+			{Scope: ScopeUser, Window: WindowDaily, Soft: 8, Hard: 15},
+			{Scope: ScopeUser, ID: "vip", Window: WindowDaily, Hard: 1000},
+			{Scope: ScopeTenant, ID: "search-team", Window: WindowMonthly, Hard: 5},
+		},
+		Downgrades:  map[string]string{consts.OpenAIGPT4o: consts.OpenAIGPT4oMini},
+		OnSoftLimit: func(ctx context.Context, status Status) { softEvents = append(softEvents, status) },
+		OnDowngrade: func(ctx context.Context, from, to string) { downgrades = append(downgrades, from+"->"+to) },
+		OnError:     func(err error) { t.Errorf("unexpected error: %v", err) },
+	})
+	ctx := context.Background()
+
+	// 预估 10 美元，未超出 15 美元的额度，实际花费 9 美元并达到软限制
+	model, _, err := execute(m, ctx, "alice", chatRequest(consts.OpenAIGPT4o, 10), chatResponse(9))
+	if err != nil || model != consts.OpenAIGPT4o {
+		t.Fatalf("first request: model = %s, err = %v", model, err)
+	}
+	if len(softEvents) != 1 || softEvents[0].ID != "alice" || !almostEqual(softEvents[0].Spent, 9) {
+		t.Errorf("unexpected soft limit events: %+v", softEvents)
+	}
+
+	// 剩余 6 美元不足以支付 10 美元，降级为 gpt-4o-mini（预估 1 美元）
+	if model, _, err = execute(m, ctx, "alice", chatRequest(consts.OpenAIGPT4o, 10), chatResponse(2)); err != nil || model != consts.OpenAIGPT4oMini {
+		t.Fatalf("downgraded request: model = %s, err = %v", model, err)
+	}
+	if len(downgrades) != 1 || downgrades[0] != consts.OpenAIGPT4o+"->"+consts.OpenAIGPT4oMini {
+		t.Errorf("unexpected downgrades: %v", downgrades)
+	}
+
+	// 流式传输在结束时按最后的用量计入
+	body := "data: {\"choices\":[{\"delta\":{\"content\":\"hi\"}}]}\n\n" +
+		"data: {\"choices\":[],\"usage\":{\"prompt_tokens\":5,\"completion_tokens\":3}}\n\n" +
+		"data: [DONE]\n\n"
+	_, response, err := execute(m, ctx, "alice", chatRequest(consts.OpenAIGPT4oMini, 10), func(ctx context.Context) any {
+		return models.ChatResponseStream{StreamReader: httpclient.NewStreamReader[models.ChatBaseResponse](
+			io.NopCloser(strings.NewReader(body)), nil, httpclient.HTTPClientConfig{ResponseDecoder: utils.NewDeserializer("", true)},
+		)}
+	})
+	if err != nil {
+		t.Fatalf("stream request error = %v", err)
+	}
+	stream := response.(models.ChatResponseStream)
+	for {
+		_, isFinished, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("Recv() error = %v", err)
+		}
+		if isFinished {
+			break
+		}
+	}
+
+	// 9 + 0.2 + 0.3 已花费，预估 10 美元超出额度且没有可用的降级模型
+	_, _, err = execute(m, ctx, "alice", chatRequest(consts.OpenAIGPT4oMini, 100), chatResponse(1))
+	if !errors.IsBudgetExceededError(err) {
+		t.Fatalf("expected ErrBudgetExceeded, got %v", err)
+	}
+	statuses, err := m.Status(ctx, "alice", "")
+	if err != nil || len(statuses) != 1 || !almostEqual(statuses[0].Spent, 9.5) || !almostEqual(statuses[0].Remaining(), 5.5) {
+		t.Errorf("unexpected status: %+v, err = %v", statuses, err)
+	}
+
+	// 单独设置了额度的用户不使用默认额度
+	if model, _, err = execute(m, ctx, "vip", chatRequest(consts.OpenAIGPT4o, 100), chatResponse(100)); err != nil || model != consts.OpenAIGPT4o {
+		t.Errorf("vip request: model = %s, err = %v", model, err)
+	}
+
+	// 匿名请求不使用用户的默认额度，不会共用同一个预算
+	for range 2 {
+		if model, _, err = execute(m, ctx, "", chatRequest(consts.OpenAIGPT4o, 100), chatResponse(100)); err != nil || model != consts.OpenAIGPT4o {
+			t.Errorf("anonymous request: model = %s, err = %v", model, err)
+		}
+	}
+	if statuses, _ = m.Status(ctx, "", ""); len(statuses) != 0 {
+		t.Errorf("unexpected anonymous status: %+v", statuses)
+	}
+
+	// 租户的月度额度为 5 美元，需要降级
+	tenantCtx := cost.WithTenant(ctx, "search-team")
+	if model, _, err = execute(m, tenantCtx, "bob", chatRequest(consts.OpenAIGPT4o, 10), chatResponse(10)); err != nil || model != consts.OpenAIGPT4oMini {
+		t.Errorf("tenant request: model = %s, err = %v", model, err)
+	}
+	if statuses, _ = m.Status(ctx, "bob", "search-team"); len(statuses) != 2 || !almostEqual(statuses[1].Spent, 1) {
+		t.Errorf("unexpected tenant status: %+v", statuses)
+	}
+
+	if len(m.reserved) != 0 {
+		t.Errorf("reservations not released: %v", m.reserved)
+	}
+	if metrics := m.GetMetrics(); metrics["budget_rejected"] != int64(1) || metrics["budget_downgraded"] != int64(2) {
+		t.Errorf("unexpected metrics: %+v", metrics)
+	}
+}
+
+func TestBudgetMiddleware_Reservation(t *testing.T) {
+	m := newTestMiddleware(BudgetMiddlewareConfig{
+		Limits: []Limit{{Scope: ScopeUser, Window: WindowMonthly, Hard: 15}},
+	})
+	ctx := context.Background()
+
+	// 第一个请求进行中时预留了 10 美元，同一用户的并发请求被拒绝
+	var inner error
+	_, _, err := execute(m, ctx, "alice", chatRequest(consts.OpenAIGPT4o, 10), func(ctx context.Context) any {
+		_, _, inner = execute(m, context.Background(), "alice", chatRequest(consts.OpenAIGPT4o, 10), chatResponse(10))
+		return models.ChatResponse{}
+	})
+	if err != nil {
+		t.Fatalf("outer request error = %v", err)
+	}
+	if !errors.IsBudgetExceededError(inner) {
+		t.Errorf("expected concurrent request to be rejected, got %v", inner)
+	}
+	// 没有用量信息时按预估的费用计入
+	if statuses, _ := m.Status(ctx, "alice", ""); len(statuses) != 1 || !almostEqual(statuses[0].Spent, 10) {
+		t.Errorf("unexpected status: %+v", statuses)
+	}
+	// 被合并的请求共享其他请求的上游响应，不计入费用
+	if _, _, err = execute(m, ctx, "alice", chatRequest(consts.OpenAIGPT4o, 1), func(ctx context.Context) any {
+		httpclient.GetRequestInfo(ctx).Shared = true
+		return chatResponse(1)(ctx)
+	}); err != nil {
+		t.Fatalf("shared request error = %v", err)
+	}
+	if statuses, _ := m.Status(ctx, "alice", ""); len(statuses) != 1 || !almostEqual(statuses[0].Spent, 10) {
+		t.Errorf("shared request should not be charged: %+v", statuses)
+	}
+}
+
+// reservedStore 记录计入费用时仍然预留的额度
+type reservedStore struct {
+	*MemoryStore
+	m        *BudgetMiddleware
+	reserved []float64
+}
+
+// Add 累加花费
+func (s *reservedStore) Add(ctx context.Context, key string, amount float64, expiresAt time.Time) (spent float64, err error) {
+	s.m.mu.Lock()
+	s.reserved = append(s.reserved, s.m.reserved[key])
+	s.m.mu.Unlock()
+	return s.MemoryStore.Add(ctx, key, amount, expiresAt)
+}
+
+func TestBudgetMiddleware_ChargeBeforeRelease(t *testing.T) {
+	store := &reservedStore{MemoryStore: NewMemoryStore()}
+	store.m = newTestMiddleware(BudgetMiddlewareConfig{
+		Limits: []Limit{{Scope: ScopeUser, Window: WindowMonthly, Hard: 15}},
+		Store:  store,
+	})
+	if _, _, err := execute(store.m, context.Background(), "alice", chatRequest(consts.OpenAIGPT4o, 10), chatResponse(5)); err != nil {
+		t.Fatalf("execute() error = %v", err)
+	}
+	// 计入实际费用时预留额度尚未释放，并发请求始终能看到本次请求的费用
+	if len(store.reserved) != 1 || !almostEqual(store.reserved[0], 10) {
+		t.Errorf("reservation released before charge: %v", store.reserved)
+	}
+	if len(store.m.reserved) != 0 {
+		t.Errorf("reservations not released: %v", store.m.reserved)
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "budget", "spent.json")
+	ctx := context.Background()
+	store, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("NewFileStore() error = %v", err)
+	}
+	expiresAt := time.Now().Add(time.Hour)
+	if _, err = store.Add(ctx, "a", 1.5, expiresAt); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	if spent, _ := store.Add(ctx, "a", 2, expiresAt); !almostEqual(spent, 3.5) {
+		t.Errorf("Add() spent = %v, want 3.5", spent)
+	}
+	if _, err = store.Add(ctx, "expired", 1, time.Now().Add(-time.Second)); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+
+	// 重新打开后保留未过期的花费
+	if store, err = NewFileStore(path); err != nil {
+		t.Fatalf("NewFileStore() error = %v", err)
+	}
+	if spent, _ := store.Spent(ctx, "a"); !almostEqual(spent, 3.5) {
+		t.Errorf("Spent() = %v, want 3.5", spent)
+	}
+	if spent, _ := store.Spent(ctx, "expired"); spent != 0 {
+		t.Errorf("expired Spent() = %v, want 0", spent)
+	}
+}
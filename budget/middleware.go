@@ -0,0 +1,362 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-26 11:08:36
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-08-09 09:47:20
+ * @Description: 预算中间件
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package budget
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Mrzhouyl/go-aisdk/catalog"
+	"github.com/Mrzhouyl/go-aisdk/consts"
+	"github.com/Mrzhouyl/go-aisdk/cost"
+	"github.com/Mrzhouyl/go-aisdk/errors"
+	"github.com/Mrzhouyl/go-aisdk/httpclient"
+	"github.com/Mrzhouyl/go-aisdk/models"
+	"github.com/Mrzhouyl/go-aisdk/tokenizer"
+)
+
+// BudgetMiddlewareConfig 预算中间件配置
+type BudgetMiddlewareConfig struct {
+	Limits                 []Limit                                    // 预算额度
+	Store                  Store                                      // 预算存储，默认为 MemoryStore
+	Catalog                *catalog.Catalog                           // 模型目录，用于获取价格和最大输出 token 数，默认使用内置的模型目录
+	Currency               string                                     // 预算的货币，默认为 USD
+	ExchangeRates          map[string]float64                         // 其他货币兑换为预算货币的汇率（如 {"CNY": 0.14}），没有汇率的费用按原值计入
+	Downgrades             map[string]string                          // 预算不足时的降级模型，键为原模型，可以链式降级，降级模型需要属于同一提供商
+	Location               *time.Location                             // 计算重置周期使用的时区，默认为 time.Local
+	DefaultMaxOutputTokens int                                        // 请求和模型目录都没有最大输出 token 数时使用的值，默认 4096
+	OnSoftLimit            func(ctx context.Context, status Status)   // 累计费用达到软限制时的回调，每个周期只调用一次
+	OnDowngrade            func(ctx context.Context, from, to string) // 降级模型时的回调
+	OnError                func(err error)                            // 估算 token 数或保存花费失败时的回调，失败不影响请求结果
+}
+
+// BudgetMiddleware 预算中间件，按用户和租户限制每天或每月的花费
+//
+// 请求执行前根据 prompt 的 token 数和 MaxCompletionTokens 估算聊天的最大费用，剩余预算不足时按 Downgrades 降级模型，
+// 没有可用的降级模型时返回 errors.ErrBudgetExceeded。估算的费用在请求结束前作为预留额度占用预算，避免并发请求超支；
+// 请求结束后按响应的用量计入实际费用，流式传输在结束时计入，没有用量信息时按估算的费用计入
+type BudgetMiddleware struct {
+	config     BudgetMiddlewareConfig
+	mu         sync.Mutex
+	reserved   map[string]float64 // 进行中的请求预留的额度
+	rejected   atomic.Int64       // 拒绝的请求数
+	downgraded atomic.Int64       // 降级的请求数
+}
+
+// NewBudgetMiddleware 创建预算中间件
+func NewBudgetMiddleware(config BudgetMiddlewareConfig) (bm *BudgetMiddleware) {
+	// 设置默认值
+	if config.Store == nil {
+		config.Store = NewMemoryStore()
+	}
+	if config.Catalog == nil {
+		config.Catalog = catalog.NewDefault()
+	}
+	if config.Currency == "" {
+		config.Currency = catalog.CurrencyUSD
+	}
+	if config.Location == nil {
+		config.Location = time.Local
+	}
+	if config.DefaultMaxOutputTokens <= 0 {
+		config.DefaultMaxOutputTokens = 4096
+	}
+	return &BudgetMiddleware{
+		config:   config,
+		reserved: make(map[string]float64),
+	}
+}
+
+// bucket 一次请求适用的预算
+type bucket struct {
+	limit      Limit
+	id         string
+	key        string
+	start, end time.Time
+}
+
+// Process 处理请求
+func (m *BudgetMiddleware) Process(ctx context.Context, request any, next httpclient.MWHandler) (response any, err error) {
+	requestInfo := httpclient.GetRequestInfo(ctx)
+	buckets := m.buckets(requestInfo.User, cost.TenantFromContext(ctx), time.Now())
+	if len(buckets) == 0 {
+		return next(ctx, request)
+	}
+	// 获取已花费的金额
+	spent := make([]float64, len(buckets))
+	for i, b := range buckets {
+		if spent[i], err = m.config.Store.Spent(ctx, b.key); err != nil {
+			return nil, fmt.Errorf("failed to get budget spent: %w", err)
+		}
+	}
+	// 检查预算，不足时依次尝试降级模型
+	var (
+		provider        = consts.Provider(requestInfo.Provider)
+		model           = requestInfo.Model
+		chatReq, isChat = request.(models.ChatRequest)
+		estimate        float64
+		visited         = map[string]bool{}
+	)
+	for {
+		visited[model] = true
+		if isChat {
+			estimate = m.estimate(provider, model, chatReq)
+		}
+		i, used := m.reserve(buckets, spent, estimate)
+		if i < 0 {
+			break
+		}
+		downgrade, ok := m.config.Downgrades[model]
+		if !isChat || !ok || visited[downgrade] {
+			m.rejected.Add(1)
+			b := buckets[i]
+			return nil, errors.WrapBudgetExceeded(string(b.limit.Scope), b.id, string(b.limit.Window), b.limit.Hard, used, estimate, m.config.Currency)
+		}
+		model = downgrade
+	}
+	if model != requestInfo.Model {
+		m.downgraded.Add(1)
+		if m.config.OnDowngrade != nil {
+			m.config.OnDowngrade(ctx, requestInfo.Model, model)
+		}
+		chatReq.Model, requestInfo.Model = model, model
+		request = chatReq
+	}
+
+	if response, err = next(ctx, request); err != nil {
+		m.release(buckets, estimate)
+		return
+	}
+	// 流式传输在结束时计入费用，中间件自行包装接收函数，以便在流结束时释放本次请求的预留额度
+	if stream, ok := response.(httpclient.WrappableStream); ok {
+		stream.WrapRecv(func(next httpclient.StreamRecvFunc) (recv httpclient.StreamRecvFunc) {
+			return cost.WrapStreamUsage(next, func(usage cost.Usage, missing bool, responseModel string) {
+				m.charge(ctx, buckets, m.actual(ctx, usage, missing, responseModel, estimate))
+				m.release(buckets, estimate)
+			})
+		})
+		return
+	}
+	// 先计入实际费用再释放预留额度，避免并发请求在两者之间看到偏低的已用额度
+	if usage, missing, responseModel, ok := cost.ResponseUsage(request, response); ok {
+		m.charge(ctx, buckets, m.actual(ctx, usage, missing, responseModel, estimate))
+	}
+	m.release(buckets, estimate)
+	return
+}
+
+// buckets 获取用户和租户适用的预算，单独设置了额度的用户（租户）不再使用同一周期的默认额度，
+// 用户（租户）为空时不检查用户（租户）预算，避免所有匿名请求共用同一个预算
+func (m *BudgetMiddleware) buckets(user, tenant string, now time.Time) (buckets []bucket) {
+	now = now.In(m.config.Location)
+	for _, limit := range m.config.Limits {
+		var id string
+		switch limit.Scope {
+		case ScopeUser:
+			if user == "" {
+				continue
+			}
+			id = user
+		case ScopeTenant:
+			if tenant == "" {
+				continue
+			}
+			id = tenant
+		default:
+			continue
+		}
+		if limit.ID != id && (limit.ID != "" || m.hasOwnLimit(limit.Scope, id, limit.Window)) {
+			continue
+		}
+		start, end := limit.Window.Period(now)
+		buckets = append(buckets, bucket{
+			limit: limit,
+			id:    id,
+			key:   storeKey(limit.Scope, id, limit.Window, start),
+			start: start,
+			end:   end,
+		})
+	}
+	return
+}
+
+// hasOwnLimit 判断用户（租户）是否单独设置了该周期的额度
+func (m *BudgetMiddleware) hasOwnLimit(scope Scope, id string, window Window) (ok bool) {
+	for _, limit := range m.config.Limits {
+		if limit.Scope == scope && limit.Window == window && limit.ID != "" && limit.ID == id {
+			return true
+		}
+	}
+	return
+}
+
+// estimate 估算聊天请求的最大费用，模型没有价格时返回 0
+func (m *BudgetMiddleware) estimate(provider consts.Provider, model string, request models.ChatRequest) (amount float64) {
+	pricing, ok := m.config.Catalog.Price(provider, model)
+	if !ok {
+		return
+	}
+	promptTokens, err := tokenizer.CountChatTokens(provider, model, request.Messages, tokenizer.WithTools(request.Tools...), tokenizer.WithEstimate())
+	if err != nil {
+		m.onError(fmt.Errorf("failed to count prompt tokens: %w", err))
+	}
+	maxOutputTokens := m.config.DefaultMaxOutputTokens
+	if n := models.IntValue(request.MaxCompletionTokens); n > 0 {
+		maxOutputTokens = n
+	} else if spec, ok := m.config.Catalog.Get(provider, model); ok && spec.MaxOutputTokens > 0 {
+		maxOutputTokens = spec.MaxOutputTokens
+	}
+	usage := cost.Usage{
+		InputTokens:  promptTokens,
+		OutputTokens: maxOutputTokens * max(models.IntValue(request.N), 1),
+	}
+	// 推理 token 价格更高时按推理 token 估算
+	if pricing.Reasoning > pricing.Output {
+		usage.ReasoningTokens = usage.OutputTokens
+	}
+	return m.convert(cost.Calculate(pricing, usage), pricing.Currency)
+}
+
+// actual 计算实际费用，命中缓存或被合并时为 0，没有用量信息时使用估算的费用
+func (m *BudgetMiddleware) actual(ctx context.Context, usage cost.Usage, missing bool, responseModel string, estimate float64) (amount float64) {
+	requestInfo := httpclient.GetRequestInfo(ctx)
+	// 命中缓存或被合并的请求没有产生上游费用
+	if requestInfo.CacheHit || requestInfo.Shared {
+		return
+	}
+	if missing {
+		return estimate
+	}
+	pricing, ok := cost.PriceFor(m.config.Catalog, consts.Provider(requestInfo.Provider), requestInfo.Model, responseModel)
+	if !ok {
+		return
+	}
+	return m.convert(cost.Calculate(pricing, usage), pricing.Currency)
+}
+
+// convert 将费用兑换为预算货币
+func (m *BudgetMiddleware) convert(amount float64, currency string) (converted float64) {
+	if currency == "" || currency == m.config.Currency {
+		return amount
+	}
+	if rate, ok := m.config.ExchangeRates[currency]; ok {
+		return amount * rate
+	}
+	return amount
+}
+
+// reserve 检查剩余预算并预留估算的费用，预算不足时返回第一个不足的预算的索引和已使用（含预留）的金额，否则返回 -1
+func (m *BudgetMiddleware) reserve(buckets []bucket, spent []float64, estimate float64) (index int, used float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, b := range buckets {
+		if b.limit.Hard <= 0 {
+			continue
+		}
+		used = spent[i] + m.reserved[b.key]
+		if used >= b.limit.Hard || used+estimate > b.limit.Hard {
+			return i, used
+		}
+	}
+	if estimate > 0 {
+		for _, b := range buckets {
+			m.reserved[b.key] += estimate
+		}
+	}
+	return -1, 0
+}
+
+// release 释放预留的额度
+func (m *BudgetMiddleware) release(buckets []bucket, estimate float64) {
+	if estimate <= 0 {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, b := range buckets {
+		if m.reserved[b.key] -= estimate; m.reserved[b.key] <= 1e-12 {
+			delete(m.reserved, b.key)
+		}
+	}
+}
+
+// charge 计入实际费用，累计费用首次达到软限制时调用 OnSoftLimit
+func (m *BudgetMiddleware) charge(ctx context.Context, buckets []bucket, amount float64) {
+	if amount <= 0 {
+		return
+	}
+	for _, b := range buckets {
+		spent, err := m.config.Store.Add(context.WithoutCancel(ctx), b.key, amount, b.end)
+		if err != nil {
+			m.onError(fmt.Errorf("failed to add budget spent: %w", err))
+			continue
+		}
+		if b.limit.Soft > 0 && spent >= b.limit.Soft && spent-amount < b.limit.Soft && m.config.OnSoftLimit != nil {
+			m.config.OnSoftLimit(ctx, Status{
+				Limit:    b.limit,
+				ID:       b.id,
+				Start:    b.start,
+				ResetAt:  b.end,
+				Spent:    spent,
+				Currency: m.config.Currency,
+			})
+		}
+	}
+}
+
+// onError 调用错误回调
+func (m *BudgetMiddleware) onError(err error) {
+	if m.config.OnError != nil {
+		m.config.OnError(err)
+	}
+}
+
+// Status 获取用户和租户当前周期的预算使用情况，租户为空时只返回用户的预算
+func (m *BudgetMiddleware) Status(ctx context.Context, user, tenant string) (statuses []Status, err error) {
+	for _, b := range m.buckets(user, tenant, time.Now()) {
+		var spent float64
+		if spent, err = m.config.Store.Spent(ctx, b.key); err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, Status{
+			Limit:    b.limit,
+			ID:       b.id,
+			Start:    b.start,
+			ResetAt:  b.end,
+			Spent:    spent,
+			Currency: m.config.Currency,
+		})
+	}
+	return
+}
+
+// Name 返回中间件名称
+func (m *BudgetMiddleware) Name() (name string) {
+	return "budget"
+}
+
+// Priority 返回中间件优先级
+func (m *BudgetMiddleware) Priority() (priority int) {
+	return 11 // 预算中间件在监控之后、费用统计之前执行，降级后的模型对后续中间件可见
+}
+
+// GetMetrics 获取预算指标数据
+func (m *BudgetMiddleware) GetMetrics() (metrics map[string]any) {
+	return map[string]any{
+		"budget_rejected":   m.rejected.Load(),
+		"budget_downgraded": m.downgraded.Load(),
+	}
+}
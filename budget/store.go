@@ -0,0 +1,161 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-26 10:20:48
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-26 13:47:02
+ * @Description: 预算存储
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package budget
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Store 预算存储，保存每个周期已花费的金额
+type Store interface {
+	Spent(ctx context.Context, key string) (spent float64, err error)                                    // 获取已花费的金额
+	Add(ctx context.Context, key string, amount float64, expiresAt time.Time) (spent float64, err error) // 累加花费并返回累加后的金额，过期后可以删除
+}
+
+// storeEntry 存储条目
+type storeEntry struct {
+	Spent     float64   `json:"spent"`      // 已花费的金额
+	ExpiresAt time.Time `json:"expires_at"` // 过期时间
+}
+
+// entries 存储条目集合
+type entries map[string]storeEntry
+
+// spent 获取未过期的已花费金额
+func (e entries) spent(key string, now time.Time) (spent float64) {
+	if entry, ok := e[key]; ok && now.Before(entry.ExpiresAt) {
+		return entry.Spent
+	}
+	return
+}
+
+// add 累加花费，新建条目时删除已过期的条目
+func (e entries) add(key string, amount float64, expiresAt, now time.Time) (spent float64) {
+	entry, ok := e[key]
+	if !ok {
+		for k, v := range e {
+			if !now.Before(v.ExpiresAt) {
+				delete(e, k)
+			}
+		}
+	}
+	entry.Spent += amount
+	entry.ExpiresAt = expiresAt
+	e[key] = entry
+	return entry.Spent
+}
+
+// MemoryStore 内存存储（并发安全）
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries entries
+}
+
+// NewMemoryStore 创建内存存储
+func NewMemoryStore() (s *MemoryStore) {
+	return &MemoryStore{entries: make(entries)}
+}
+
+// Spent 获取已花费的金额
+func (s *MemoryStore) Spent(ctx context.Context, key string) (spent float64, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.entries.spent(key, time.Now()), nil
+}
+
+// Add 累加花费
+func (s *MemoryStore) Add(ctx context.Context, key string, amount float64, expiresAt time.Time) (spent float64, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.entries.add(key, amount, expiresAt, time.Now()), nil
+}
+
+// FileStore 文件存储，所有条目以 JSON 格式保存在一个文件中，每次累加花费后写入文件，进程重启后预算不会丢失（并发安全）
+//
+// 同一个文件只能由一个进程使用，多个进程共享预算时请使用基于数据库的 Store 实现
+type FileStore struct {
+	mu      sync.Mutex
+	path    string
+	entries entries
+}
+
+// NewFileStore 创建文件存储，文件存在时加载已保存的条目，目录不存在时自动创建
+func NewFileStore(path string) (s *FileStore, err error) {
+	if path == "" {
+		return nil, errors.New("budget file path is empty")
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create budget dir: %w", err)
+	}
+
+	s = &FileStore{path: path, entries: make(entries)}
+	var data []byte
+	if data, err = os.ReadFile(path); err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("failed to read budget file: %w", err)
+	}
+	if len(data) > 0 {
+		if err = json.Unmarshal(data, &s.entries); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal budget file: %w", err)
+		}
+	}
+	return
+}
+
+// Spent 获取已花费的金额
+func (s *FileStore) Spent(ctx context.Context, key string) (spent float64, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.entries.spent(key, time.Now()), nil
+}
+
+// Add 累加花费并写入文件
+func (s *FileStore) Add(ctx context.Context, key string, amount float64, expiresAt time.Time) (spent float64, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	spent = s.entries.add(key, amount, expiresAt, time.Now())
+	return spent, s.save()
+}
+
+// save 写入文件，先写临时文件再重命名，避免写入中断导致文件损坏
+func (s *FileStore) save() (err error) {
+	var data []byte
+	if data, err = json.Marshal(s.entries); err != nil {
+		return
+	}
+
+	var tmp *os.File
+	if tmp, err = os.CreateTemp(filepath.Dir(s.path), "budget-*.tmp"); err != nil {
+		return
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return
+	}
+	if err = tmp.Close(); err != nil {
+		return
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
@@ -0,0 +1,23 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-12 10:05:48
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-12 17:22:16
+ * @Description: 响应缓存，提供可插拔的缓存存储接口及内存、文件系统实现
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// Cache 缓存存储接口
+type Cache interface {
+	Get(ctx context.Context, key string) (value []byte, ok bool, err error)           // 获取缓存，ok 为 false 表示未命中或已过期
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) (err error) // 设置缓存，ttl 小于等于0表示永不过期
+	Delete(ctx context.Context, key string) (err error)                               // 删除缓存
+	Clear(ctx context.Context) (err error)                                            // 清空缓存
+}
@@ -0,0 +1,172 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-12 15:33:09
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-08-08 11:03:29
+ * @Description:
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Mrzhouyl/go-aisdk/consts"
+	"github.com/Mrzhouyl/go-aisdk/httpclient"
+	"github.com/Mrzhouyl/go-aisdk/httpclient/test/checks"
+	"github.com/Mrzhouyl/go-aisdk/models"
+)
+
+func TestMemoryCache(t *testing.T) {
+	ctx := context.Background()
+	c := NewMemoryCache(2)
+	checks.NoError(t, c.Set(ctx, "a", []byte("1"), 0))
+	checks.NoError(t, c.Set(ctx, "b", []byte("2"), 0))
+	// 访问 a，使 b 成为最近最少使用的条目
+	if _, ok, _ := c.Get(ctx, "a"); !ok {
+		t.Fatal("expected a to be cached")
+	}
+	checks.NoError(t, c.Set(ctx, "c", []byte("3"), 0))
+	if _, ok, _ := c.Get(ctx, "b"); ok {
+		t.Error("expected b to be evicted")
+	}
+	// 过期条目不会被命中
+	checks.NoError(t, c.Set(ctx, "d", []byte("4"), 10*time.Millisecond))
+	time.Sleep(20 * time.Millisecond)
+	if _, ok, _ := c.Get(ctx, "d"); ok {
+		t.Error("expected d to be expired")
+	}
+}
+
+func TestFileCache(t *testing.T) {
+	ctx := context.Background()
+	c, err := NewFileCache(t.TempDir())
+	checks.NoErrorF(t, err)
+	checks.NoError(t, c.Set(ctx, "key", []byte("value"), 0))
+	value, ok, err := c.Get(ctx, "key")
+	checks.NoError(t, err)
+	if !ok || string(value) != "value" {
+		t.Fatalf("expected value, got %q (hit: %v)", value, ok)
+	}
+	checks.NoError(t, c.Delete(ctx, "key"))
+	if _, ok, _ = c.Get(ctx, "key"); ok {
+		t.Error("expected key to be deleted")
+	}
+	checks.NoError(t, c.Set(ctx, "expired", []byte("value"), time.Nanosecond))
+	time.Sleep(time.Millisecond)
+	if _, ok, _ = c.Get(ctx, "expired"); ok {
+		t.Error("expected key to be expired")
+	}
+}
+
+func TestChatRequestKey(t *testing.T) {
+	request := models.ChatRequest{
+		Provider:    consts.OpenAI,
+		Model:       consts.OpenAIGPT4o,
+		Messages:    []models.ChatMessage{&models.UserMessage{Content: "hello"}},
+		Temperature: models.Float32(0),
+	}
+	key1, err := ChatRequestKey(request)
+	checks.NoErrorF(t, err)
+	// 流式请求与非流式请求生成相同的键
+	request.Stream = models.Bool(true)
+	key2, err := ChatRequestKey(request)
+	checks.NoErrorF(t, err)
+	if key1 != key2 {
+		t.Error("expected stream and non-stream requests to share a key")
+	}
+	// 提供商会忽略的字段不影响缓存键
+	request.TopK = models.Int(10)
+	key3, err := ChatRequestKey(request)
+	checks.NoErrorF(t, err)
+	if key1 != key3 {
+		t.Error("expected fields dropped by the provider not to change the key")
+	}
+	request.Messages = []models.ChatMessage{&models.UserMessage{Content: "hi"}}
+	key4, err := ChatRequestKey(request)
+	checks.NoErrorF(t, err)
+	if key1 == key4 {
+		t.Error("expected different messages to produce different keys")
+	}
+}
+
+func TestCacheMiddleware_Process(t *testing.T) {
+	var (
+		cm      = NewCacheMiddleware(CacheMiddlewareConfig{})
+		calls   int
+		request = models.ChatRequest{
+			Provider:    consts.DeepSeek,
+			Model:       consts.DeepSeekChat,
+			Messages:    []models.ChatMessage{&models.UserMessage{Content: "hello"}},
+			Temperature: models.Float32(0),
+		}
+		handler = func(ctx context.Context, req any) (resp any, err error) {
+			calls++
+			return models.ChatResponse{
+				ChatBaseResponse: models.ChatBaseResponse{
+					ID:    "chatcmpl-1",
+					Model: consts.DeepSeekChat,
+					Choices: []models.ChatChoice{{
+						FinishReason: models.ChatFinishReasonStop,
+						Message:      &models.ChatCompletionMessage{Role: "assistant", Content: "world"},
+					}},
+					Usage: &models.ChatUsage{PromptTokens: 1, CompletionTokens: 1, TotalTokens: 2},
+				},
+			}, nil
+		}
+	)
+	newCtx := func(method string) (ctx context.Context, info *httpclient.RequestInfo) {
+		info = &httpclient.RequestInfo{Provider: "deepseek", Model: consts.DeepSeekChat, Method: method, RequestID: "req-2"}
+		return httpclient.SetRequestInfo(context.Background(), info), info
+	}
+	// 第一次请求未命中缓存
+	ctx, info := newCtx(methodChatCompletion)
+	_, err := cm.Process(ctx, request, handler)
+	checks.NoErrorF(t, err)
+	if info.CacheHit {
+		t.Error("expected first request to miss the cache")
+	}
+	// 第二次请求命中缓存
+	ctx, info = newCtx(methodChatCompletion)
+	resp, err := cm.Process(ctx, request, handler)
+	checks.NoErrorF(t, err)
+	chatResp := resp.(models.ChatResponse)
+	if !info.CacheHit || !IsCacheHit(chatResp.HttpHeader) || calls != 1 {
+		t.Fatalf("expected second request to hit the cache, calls: %d", calls)
+	}
+	if chatResp.RequestID() != "req-2" || chatResp.Choices[0].Message.Content != "world" {
+		t.Errorf("unexpected cached response: %+v", chatResp)
+	}
+	// 流式请求回放缓存
+	ctx, info = newCtx(methodChatCompletionStream)
+	resp, err = cm.Process(ctx, request, handler)
+	checks.NoErrorF(t, err)
+	stream := resp.(models.ChatResponseStream)
+	chunk, finished, err := stream.Recv()
+	checks.NoErrorF(t, err)
+	if finished || chunk.Choices[0].Delta.Content != "world" || chunk.Usage.TotalTokens != 2 {
+		t.Errorf("unexpected replayed chunk: %+v", chunk)
+	}
+	if _, finished, err = stream.Recv(); err != nil || !finished {
+		t.Errorf("expected stream to finish, err: %v", err)
+	}
+	if !info.CacheHit || calls != 1 {
+		t.Errorf("expected stream request to hit the cache, calls: %d", calls)
+	}
+	// 默认不缓存采样请求
+	request.Temperature = models.Float32(0.7)
+	for range 2 {
+		ctx, info = newCtx(methodChatCompletion)
+		_, err = cm.Process(ctx, request, handler)
+		checks.NoErrorF(t, err)
+		if info.CacheHit {
+			t.Error("expected sampled request to miss the cache")
+		}
+	}
+	if calls != 3 {
+		t.Errorf("expected 3 upstream calls, got %d", calls)
+	}
+}
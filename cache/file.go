@@ -0,0 +1,136 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-12 11:02:44
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-12 16:55:10
+ * @Description: 文件系统缓存
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	fileCacheExt = ".cache.json" // 缓存文件扩展名
+)
+
+// fileEntry 文件缓存条目
+type fileEntry struct {
+	Key       string    `json:"key"`                  // 缓存键
+	Value     []byte    `json:"value"`                // 缓存值
+	ExpiresAt time.Time `json:"expires_at,omitempty"` // 过期时间，零值表示永不过期
+}
+
+// FileCache 文件系统缓存，每个缓存条目保存为目录下的一个文件
+type FileCache struct {
+	dir string // 缓存目录
+}
+
+// NewFileCache 创建文件系统缓存，目录不存在时自动创建
+func NewFileCache(dir string) (c *FileCache, err error) {
+	if dir == "" {
+		return nil, errors.New("cache dir is empty")
+	}
+	if err = os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create cache dir: %w", err)
+	}
+	return &FileCache{dir: dir}, nil
+}
+
+// Get 获取缓存
+func (c *FileCache) Get(ctx context.Context, key string) (value []byte, ok bool, err error) {
+	var data []byte
+	if data, err = os.ReadFile(c.path(key)); err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return
+	}
+
+	var entry fileEntry
+	if err = json.Unmarshal(data, &entry); err != nil {
+		// 文件损坏视为未命中，并删除该文件
+		_ = os.Remove(c.path(key))
+		return nil, false, nil
+	}
+	// 已过期则删除
+	if !entry.ExpiresAt.IsZero() && time.Now().After(entry.ExpiresAt) {
+		_ = os.Remove(c.path(key))
+		return
+	}
+	return entry.Value, true, nil
+}
+
+// Set 设置缓存
+func (c *FileCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) (err error) {
+	entry := fileEntry{
+		Key:   key,
+		Value: value,
+	}
+	if ttl > 0 {
+		entry.ExpiresAt = time.Now().Add(ttl)
+	}
+
+	var data []byte
+	if data, err = json.Marshal(entry); err != nil {
+		return
+	}
+	// 先写临时文件再重命名，避免并发读取到不完整的文件
+	var tmp *os.File
+	if tmp, err = os.CreateTemp(c.dir, "tmp-*"); err != nil {
+		return
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return
+	}
+	if err = tmp.Close(); err != nil {
+		return
+	}
+	return os.Rename(tmp.Name(), c.path(key))
+}
+
+// Delete 删除缓存
+func (c *FileCache) Delete(ctx context.Context, key string) (err error) {
+	if err = os.Remove(c.path(key)); err != nil && os.IsNotExist(err) {
+		err = nil
+	}
+	return
+}
+
+// Clear 清空缓存
+func (c *FileCache) Clear(ctx context.Context) (err error) {
+	var entries []os.DirEntry
+	if entries, err = os.ReadDir(c.dir); err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), fileCacheExt) {
+			continue
+		}
+		if err = os.Remove(filepath.Join(c.dir, entry.Name())); err != nil && !os.IsNotExist(err) {
+			return
+		}
+	}
+	return nil
+}
+
+// path 获取缓存文件路径，使用键的哈希值作为文件名
+func (c *FileCache) path(key string) (path string) {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+fileCacheExt)
+}
@@ -0,0 +1,133 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-12 10:17:03
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-12 16:41:29
+ * @Description: 内存缓存（LRU + TTL）
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package cache
+
+import (
+	"container/list"
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMemoryCacheMaxEntries = 1000 // 默认内存缓存最大条目数
+)
+
+// memoryEntry 内存缓存条目
+type memoryEntry struct {
+	key       string    // 缓存键
+	value     []byte    // 缓存值
+	expiresAt time.Time // 过期时间，零值表示永不过期
+}
+
+// MemoryCache 内存缓存，超出最大条目数时淘汰最近最少使用的条目
+type MemoryCache struct {
+	mu         sync.Mutex
+	maxEntries int                      // 最大条目数
+	ll         *list.List               // LRU 链表，表头为最近使用的条目
+	items      map[string]*list.Element // 缓存条目
+}
+
+// NewMemoryCache 创建内存缓存，maxEntries 小于等于0时使用默认值
+func NewMemoryCache(maxEntries int) (c *MemoryCache) {
+	if maxEntries <= 0 {
+		maxEntries = defaultMemoryCacheMaxEntries
+	}
+	return &MemoryCache{
+		maxEntries: maxEntries,
+		ll:         list.New(),
+		items:      make(map[string]*list.Element),
+	}
+}
+
+// Get 获取缓存
+func (c *MemoryCache) Get(ctx context.Context, key string) (value []byte, ok bool, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, exists := c.items[key]
+	if !exists {
+		return
+	}
+	entry := elem.Value.(*memoryEntry)
+	// 已过期则删除
+	if !entry.expiresAt.IsZero() && time.Now().After(entry.expiresAt) {
+		c.removeElement(elem)
+		return
+	}
+	c.ll.MoveToFront(elem)
+	return slices.Clone(entry.value), true, nil
+}
+
+// Set 设置缓存
+func (c *MemoryCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) (err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var expiresAt time.Time
+	if ttl > 0 {
+		expiresAt = time.Now().Add(ttl)
+	}
+	// 已存在则更新
+	if elem, exists := c.items[key]; exists {
+		entry := elem.Value.(*memoryEntry)
+		entry.value = slices.Clone(value)
+		entry.expiresAt = expiresAt
+		c.ll.MoveToFront(elem)
+		return
+	}
+	// 新增条目
+	c.items[key] = c.ll.PushFront(&memoryEntry{
+		key:       key,
+		value:     slices.Clone(value),
+		expiresAt: expiresAt,
+	})
+	// 超出最大条目数，淘汰最近最少使用的条目
+	for c.ll.Len() > c.maxEntries {
+		c.removeElement(c.ll.Back())
+	}
+	return
+}
+
+// Delete 删除缓存
+func (c *MemoryCache) Delete(ctx context.Context, key string) (err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, exists := c.items[key]; exists {
+		c.removeElement(elem)
+	}
+	return
+}
+
+// Clear 清空缓存
+func (c *MemoryCache) Clear(ctx context.Context) (err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.ll.Init()
+	c.items = make(map[string]*list.Element)
+	return
+}
+
+// Len 获取缓存条目数（包含尚未清理的过期条目）
+func (c *MemoryCache) Len() (n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.ll.Len()
+}
+
+// removeElement 删除条目（调用方需持有锁）
+func (c *MemoryCache) removeElement(elem *list.Element) {
+	c.ll.Remove(elem)
+	delete(c.items, elem.Value.(*memoryEntry).key)
+}
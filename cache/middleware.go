@@ -0,0 +1,316 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-12 13:48:20
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-08-08 11:03:29
+ * @Description: 响应缓存中间件
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package cache
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/Mrzhouyl/go-aisdk/httpclient"
+	"github.com/Mrzhouyl/go-aisdk/internal/utils"
+	"github.com/Mrzhouyl/go-aisdk/models"
+)
+
+const (
+	HeaderKey   = "X-AISDK-Cache" // 缓存命中标识的响应头键
+	HeaderValue = "HIT"           // 缓存命中标识的响应头值
+)
+
+const (
+	methodChatCompletion       = "CreateChatCompletion"       // 创建聊天
+	methodChatCompletionStream = "CreateChatCompletionStream" // 创建流式聊天
+)
+
+// CacheCondition 缓存条件函数，返回 true 表示该请求可以使用缓存
+type CacheCondition func(request models.ChatRequest) (ok bool)
+
+// CacheMiddlewareConfig 缓存中间件配置
+type CacheMiddlewareConfig struct {
+	Cache     Cache          // 缓存存储
+	TTL       time.Duration  // 缓存有效期，小于等于0表示永不过期
+	KeyPrefix string         // 缓存键前缀，可用于区分不同的业务或版本
+	Condition CacheCondition // 缓存条件，默认仅缓存确定性请求（CacheConditions.Deterministic），CacheConditions.Always 需要显式设置
+}
+
+// entry 缓存条目
+type entry struct {
+	Response  *models.ChatBaseResponse  `json:"response,omitempty"` // 非流式响应
+	Chunks    []models.ChatBaseResponse `json:"chunks,omitempty"`   // 流式响应数据块
+	Header    http.Header               `json:"header,omitempty"`   // 响应头
+	CreatedAt time.Time                 `json:"created_at"`         // 缓存时间
+}
+
+// CacheMiddleware 缓存中间件
+type CacheMiddleware struct {
+	config CacheMiddlewareConfig
+	hits   atomic.Int64 // 命中次数
+	misses atomic.Int64 // 未命中次数
+}
+
+// NewCacheMiddleware 创建缓存中间件
+func NewCacheMiddleware(config CacheMiddlewareConfig) (cm *CacheMiddleware) {
+	// 设置缓存存储
+	if config.Cache == nil {
+		config.Cache = NewMemoryCache(defaultMemoryCacheMaxEntries)
+	}
+	// 设置缓存条件
+	if config.Condition == nil {
+		config.Condition = CacheConditions.Deterministic
+	}
+	return &CacheMiddleware{
+		config: config,
+	}
+}
+
+// Process 处理请求
+func (m *CacheMiddleware) Process(ctx context.Context, request any, next httpclient.MWHandler) (response any, err error) {
+	// 从上下文中获取请求信息
+	requestInfo := httpclient.GetRequestInfo(ctx)
+	// 仅缓存聊天请求
+	chatReq, ok := request.(models.ChatRequest)
+	if !ok || (requestInfo.Method != methodChatCompletion && requestInfo.Method != methodChatCompletionStream) {
+		return next(ctx, request)
+	}
+	if !m.config.Condition(chatReq) {
+		return next(ctx, request)
+	}
+	// 生成缓存键
+	var key string
+	if key, err = ChatRequestKey(chatReq); err != nil {
+		return next(ctx, request)
+	}
+	key = m.config.KeyPrefix + key
+	isStream := requestInfo.Method == methodChatCompletionStream
+	// 查询缓存
+	if e, hit := m.lookup(ctx, key, isStream); hit {
+		m.hits.Add(1)
+		requestInfo.CacheHit = true
+		if isStream {
+			return replayStream(e, requestInfo.RequestID), nil
+		}
+		return replayResponse(e, requestInfo.RequestID), nil
+	}
+	m.misses.Add(1)
+	// 执行下一个处理器
+	if response, err = next(ctx, request); err != nil {
+		return
+	}
+	// 写入缓存
+	switch resp := response.(type) {
+	case models.ChatResponse:
+		base := resp.ChatBaseResponse
+		base.StreamStats = nil
+		m.store(ctx, key, &entry{
+			Response:  &base,
+			Header:    resp.Header().Clone(),
+			CreatedAt: time.Now(),
+		})
+	case models.ChatResponseStream:
+		recordStream(resp, func(e *entry) {
+			m.store(ctx, key, e)
+		})
+	}
+	return
+}
+
+// Name 返回中间件名称
+func (m *CacheMiddleware) Name() (name string) {
+	return "cache"
+}
+
+// Priority 返回中间件优先级
+func (m *CacheMiddleware) Priority() (priority int) {
+	return 15 // 缓存中间件在监控之后、重试之前执行，命中缓存时跳过后续中间件
+}
+
+// GetMetrics 获取缓存指标数据
+func (m *CacheMiddleware) GetMetrics() (metrics map[string]any) {
+	return map[string]any{
+		"cache_hits":   m.hits.Load(),
+		"cache_misses": m.misses.Load(),
+	}
+}
+
+// lookup 查询缓存
+func (m *CacheMiddleware) lookup(ctx context.Context, key string, isStream bool) (e *entry, hit bool) {
+	value, ok, err := m.config.Cache.Get(ctx, key)
+	if err != nil || !ok {
+		return
+	}
+	return decodeEntry(value, isStream)
+}
+
+// decodeEntry 解码缓存条目，并判断是否可用于当前请求
+func decodeEntry(value []byte, isStream bool) (e *entry, ok bool) {
+	if err := json.Unmarshal(value, &e); err != nil || e == nil {
+		return nil, false
+	}
+	// 非流式请求只能使用非流式响应的缓存，流式请求两者均可回放
+	if e.Response == nil && (!isStream || len(e.Chunks) == 0) {
+		return nil, false
+	}
+	return e, true
+}
+
+// store 写入缓存，写入失败不影响请求结果
+func (m *CacheMiddleware) store(ctx context.Context, key string, e *entry) {
+	value, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	_ = m.config.Cache.Set(context.WithoutCancel(ctx), key, value, m.config.TTL)
+}
+
+// recordStream 记录流式响应数据块，流式传输正常结束后回调 onFinish
+func recordStream(stream models.ChatResponseStream, onFinish func(e *entry)) {
+	if stream.StreamReader == nil {
+		return
+	}
+
+	var (
+		header = stream.Header().Clone()
+		chunks []models.ChatBaseResponse
+	)
+	stream.AddRecvHook(func(chunk models.ChatBaseResponse, isFinished bool, err error) {
+		if err != nil {
+			chunks = nil
+			return
+		}
+		if !isFinished {
+			chunk.StreamStats = nil
+			chunks = append(chunks, chunk)
+			return
+		}
+		if len(chunks) > 0 {
+			onFinish(&entry{
+				Chunks:    chunks,
+				Header:    header,
+				CreatedAt: time.Now(),
+			})
+		}
+	})
+}
+
+// replayResponse 回放非流式响应
+func replayResponse(e *entry, requestId string) (response models.ChatResponse) {
+	response = models.ChatResponse{
+		ChatBaseResponse: *e.Response,
+		HttpHeader:       replayHeader(e, requestId),
+	}
+	return
+}
+
+// replayStream 回放流式响应，将缓存的数据块重新编码为 SSE 数据并生成合成的流
+func replayStream(e *entry, requestId string) (response models.ChatResponseStream) {
+	chunks := e.Chunks
+	if len(chunks) == 0 {
+		chunks = []models.ChatBaseResponse{toChunk(*e.Response)}
+	}
+
+	var body bytes.Buffer
+	for _, chunk := range chunks {
+		data, err := json.Marshal(chunk)
+		if err != nil {
+			continue
+		}
+		body.WriteString("data: ")
+		body.Write(data)
+		body.WriteString("\n\n")
+	}
+	body.WriteString("data: [DONE]\n\n")
+
+	header := replayHeader(e, requestId)
+	stream := httpclient.NewStreamReader[models.ChatBaseResponse](io.NopCloser(&body), header.Header(), httpclient.HTTPClientConfig{
+		ResponseDecoder: utils.NewDeserializer("", true),
+	})
+	return models.ChatResponseStream{
+		StreamReader: stream,
+	}
+}
+
+// replayHeader 获取回放响应的响应头
+func replayHeader(e *entry, requestId string) (header httpclient.HttpHeader) {
+	header = httpclient.HttpHeader(e.Header.Clone())
+	header.SetRequestID(requestId)
+	header.Header().Set(HeaderKey, HeaderValue)
+	return
+}
+
+// toChunk 将非流式响应转换为单个流式数据块
+func toChunk(response models.ChatBaseResponse) (chunk models.ChatBaseResponse) {
+	chunk = response
+	chunk.Object = "chat.completion.chunk"
+	chunk.Choices = make([]models.ChatChoice, len(response.Choices))
+	for i, choice := range response.Choices {
+		chunk.Choices[i] = models.ChatChoice{
+			FinishReason: choice.FinishReason,
+			Index:        choice.Index,
+			LogProbs:     choice.LogProbs,
+			Delta:        choice.Message,
+		}
+	}
+	return
+}
+
+// ChatRequestKey 根据提供商序列化后的聊天请求生成规范化的缓存键，流式与非流式请求生成相同的键
+func ChatRequestKey(request models.ChatRequest) (key string, err error) {
+	request.Stream = nil
+	request.StreamOptions = nil
+	// 序列化后的请求体为 map 结构，json.Marshal 会按键排序，保证结果稳定
+	var body []byte
+	if body, err = json.Marshal(request); err != nil {
+		return
+	}
+
+	h := sha256.New()
+	h.Write([]byte(request.Provider))
+	h.Write([]byte{0})
+	h.Write([]byte(request.Model))
+	h.Write([]byte{0})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// IsCacheHit 判断响应是否来自缓存
+func IsCacheHit(header httpclient.HttpHeader) (ok bool) {
+	return header.Header().Get(HeaderKey) == HeaderValue
+}
+
+// CacheConditions 预定义的缓存条件
+var CacheConditions = struct {
+	// Always 总是使用缓存
+	Always CacheCondition
+	// Deterministic 仅缓存确定性请求（temperature 为 0 或指定了 seed）
+	Deterministic CacheCondition
+}{
+	Always: func(request models.ChatRequest) (ok bool) {
+		return true
+	},
+	Deterministic: func(request models.ChatRequest) (ok bool) {
+		return (request.Temperature != nil && *request.Temperature == 0) || request.Seed != nil
+	},
+}
+
+// DefaultCacheConfig 默认缓存配置
+func DefaultCacheConfig() (config CacheMiddlewareConfig) {
+	return CacheMiddlewareConfig{
+		Cache:     NewMemoryCache(defaultMemoryCacheMaxEntries),
+		TTL:       1 * time.Hour,
+		KeyPrefix: "",
+		Condition: CacheConditions.Deterministic,
+	}
+}
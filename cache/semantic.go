@@ -0,0 +1,265 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-13 15:20:09
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-08-08 11:09:52
+ * @Description: 语义缓存中间件，对最后一条用户消息做向量嵌入，在本地向量索引中检索相似请求的响应
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/Mrzhouyl/go-aisdk/consts"
+	"github.com/Mrzhouyl/go-aisdk/errors"
+	"github.com/Mrzhouyl/go-aisdk/httpclient"
+	"github.com/Mrzhouyl/go-aisdk/models"
+)
+
+const (
+	SimilarityHeaderKey = "X-AISDK-Cache-Similarity" // 语义缓存命中时相似度的响应头键
+)
+
+const (
+	defaultSemanticCacheThreshold = 0.95 // 默认相似度阈值
+)
+
+// Embedder 文本向量嵌入接口
+type Embedder interface {
+	Embed(ctx context.Context, text string) (vector []float32, err error) // 将文本转换为向量
+}
+
+// EmbedderFunc 文本向量嵌入函数
+type EmbedderFunc func(ctx context.Context, text string) (vector []float32, err error)
+
+// Embed 将文本转换为向量
+func (f EmbedderFunc) Embed(ctx context.Context, text string) (vector []float32, err error) {
+	return f(ctx, text)
+}
+
+// EmbeddingClient 嵌入向量客户端接口，*aisdk.SDKClient 实现了该接口
+type EmbeddingClient interface {
+	CreateEmbeddings(ctx context.Context, request models.EmbeddingRequest, opts ...httpclient.HTTPClientOption) (response models.EmbeddingResponse, err error) // 创建嵌入向量
+}
+
+// NewProviderEmbedder 创建使用提供商嵌入接口的向量嵌入器，通过客户端调用，嵌入请求同样经过中间件链（重试、限流、熔断、成本统计等）
+func NewProviderEmbedder(client EmbeddingClient, provider consts.Provider, model string) (embedder Embedder) {
+	return EmbedderFunc(func(ctx context.Context, text string) (vector []float32, err error) {
+		var response models.EmbeddingResponse
+		if response, err = client.CreateEmbeddings(ctx, models.EmbeddingRequest{
+			Provider: provider,
+			Input:    []string{text},
+			Model:    model,
+		}); err != nil {
+			return
+		}
+		if len(response.Data) == 0 {
+			return nil, errors.ErrEmptyEmbedding
+		}
+		return response.Data[0].Embedding, nil
+	})
+}
+
+// SemanticCacheMiddlewareConfig 语义缓存中间件配置
+type SemanticCacheMiddlewareConfig struct {
+	Embedder  Embedder       // 向量嵌入器，为空时不启用语义缓存
+	Index     *VectorIndex   // 向量索引
+	Threshold float64        // 相似度阈值，余弦相似度不低于该值时命中缓存
+	Condition CacheCondition // 缓存条件，默认仅缓存确定性请求（CacheConditions.Deterministic），CacheConditions.Always 需要显式设置
+}
+
+// SemanticCacheMiddleware 语义缓存中间件
+type SemanticCacheMiddleware struct {
+	config      SemanticCacheMiddlewareConfig
+	hits        atomic.Int64 // 命中次数
+	misses      atomic.Int64 // 未命中次数
+	embedErrors atomic.Int64 // 向量嵌入失败次数
+}
+
+// NewSemanticCacheMiddleware 创建语义缓存中间件
+func NewSemanticCacheMiddleware(config SemanticCacheMiddlewareConfig) (m *SemanticCacheMiddleware) {
+	// 设置向量索引
+	if config.Index == nil {
+		config.Index, _ = NewVectorIndex(VectorIndexConfig{})
+	}
+	// 设置相似度阈值
+	if config.Threshold <= 0 {
+		config.Threshold = defaultSemanticCacheThreshold
+	}
+	// 设置缓存条件
+	if config.Condition == nil {
+		config.Condition = CacheConditions.Deterministic
+	}
+	return &SemanticCacheMiddleware{
+		config: config,
+	}
+}
+
+// Process 处理请求
+func (m *SemanticCacheMiddleware) Process(ctx context.Context, request any, next httpclient.MWHandler) (response any, err error) {
+	// 从上下文中获取请求信息
+	requestInfo := httpclient.GetRequestInfo(ctx)
+	// 仅缓存聊天请求
+	chatReq, ok := request.(models.ChatRequest)
+	if !ok || m.config.Embedder == nil || (requestInfo.Method != methodChatCompletion && requestInfo.Method != methodChatCompletionStream) {
+		return next(ctx, request)
+	}
+	if !m.config.Condition(chatReq) {
+		return next(ctx, request)
+	}
+	// 对最后一条用户消息做向量嵌入，嵌入失败不影响请求
+	text := lastUserText(chatReq.Messages)
+	if text == "" {
+		return next(ctx, request)
+	}
+	var vector []float32
+	if vector, err = m.config.Embedder.Embed(ctx, text); err != nil || len(vector) == 0 {
+		m.embedErrors.Add(1)
+		return next(ctx, request)
+	}
+	scope := SemanticScope(chatReq)
+	isStream := requestInfo.Method == methodChatCompletionStream
+	// 查询向量索引
+	if value, score, hit := m.config.Index.Search(scope, vector, m.config.Threshold); hit {
+		if e, ok := decodeEntry(value, isStream); ok {
+			m.hits.Add(1)
+			requestInfo.CacheHit = true
+			e.Header = e.Header.Clone()
+			if e.Header == nil {
+				e.Header = make(map[string][]string)
+			}
+			e.Header.Set(SimilarityHeaderKey, strconv.FormatFloat(score, 'f', 4, 64))
+			if isStream {
+				return replayStream(e, requestInfo.RequestID), nil
+			}
+			return replayResponse(e, requestInfo.RequestID), nil
+		}
+	}
+	m.misses.Add(1)
+	// 执行下一个处理器
+	if response, err = next(ctx, request); err != nil {
+		return
+	}
+	// 写入向量索引
+	switch resp := response.(type) {
+	case models.ChatResponse:
+		base := resp.ChatBaseResponse
+		base.StreamStats = nil
+		m.store(scope, vector, &entry{
+			Response:  &base,
+			Header:    resp.Header().Clone(),
+			CreatedAt: time.Now(),
+		})
+	case models.ChatResponseStream:
+		recordStream(resp, func(e *entry) {
+			m.store(scope, vector, e)
+		})
+	}
+	return
+}
+
+// Name 返回中间件名称
+func (m *SemanticCacheMiddleware) Name() (name string) {
+	return "semantic_cache"
+}
+
+// Priority 返回中间件优先级
+func (m *SemanticCacheMiddleware) Priority() (priority int) {
+	return 16 // 语义缓存中间件在精确缓存之后执行，精确缓存未命中时再做相似度检索
+}
+
+// GetMetrics 获取语义缓存指标数据
+func (m *SemanticCacheMiddleware) GetMetrics() (metrics map[string]any) {
+	return map[string]any{
+		"semantic_cache_hits":         m.hits.Load(),
+		"semantic_cache_misses":       m.misses.Load(),
+		"semantic_cache_embed_errors": m.embedErrors.Load(),
+		"semantic_cache_entries":      m.config.Index.Len(),
+	}
+}
+
+// Save 将向量索引保存到持久化文件
+func (m *SemanticCacheMiddleware) Save() (err error) {
+	return m.config.Index.Save()
+}
+
+// store 写入向量索引，写入失败不影响请求结果
+func (m *SemanticCacheMiddleware) store(scope string, vector []float32, e *entry) {
+	value, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	_ = m.config.Index.Add(scope, vector, value)
+}
+
+// SemanticScope 根据提供商、模型、工具、响应格式和系统提示词生成语义缓存的作用域，只有作用域相同的请求才会相互命中
+func SemanticScope(request models.ChatRequest) (scope string) {
+	h := sha256.New()
+	h.Write([]byte(request.Provider))
+	h.Write([]byte{0})
+	h.Write([]byte(request.Model))
+	// 工具和响应格式不同时，相似的问题也会得到不同的响应
+	for _, v := range []any{request.Tools, request.ToolChoice, request.ResponseFormat} {
+		b, _ := json.Marshal(v)
+		h.Write([]byte{0})
+		h.Write(b)
+	}
+	for _, message := range request.Messages {
+		var content string
+		switch msg := message.(type) {
+		case *models.SystemMessage:
+			content = msg.Content
+		case *models.DeveloperMessage:
+			content = msg.Content
+		default:
+			continue
+		}
+		h.Write([]byte{0})
+		h.Write([]byte(content))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// lastUserText 获取最后一条用户消息的文本内容
+func lastUserText(messages []models.ChatMessage) (text string) {
+	for i := len(messages) - 1; i >= 0; i-- {
+		msg, ok := messages[i].(*models.UserMessage)
+		if !ok {
+			continue
+		}
+		if msg.Content != "" {
+			return msg.Content
+		}
+		var parts []string
+		for _, part := range msg.MultimodalContent {
+			if part.Text != "" {
+				parts = append(parts, part.Text)
+			}
+		}
+		return strings.Join(parts, "\n")
+	}
+	return
+}
+
+// DefaultSemanticCacheConfig 默认语义缓存配置，需要另外设置向量嵌入器
+func DefaultSemanticCacheConfig() (config SemanticCacheMiddlewareConfig) {
+	index, _ := NewVectorIndex(VectorIndexConfig{
+		MaxEntries:     defaultVectorIndexMaxEntries,
+		TTL:            1 * time.Hour,
+		EvictionPolicy: EvictionPolicyLRU,
+	})
+	return SemanticCacheMiddlewareConfig{
+		Index:     index,
+		Threshold: defaultSemanticCacheThreshold,
+		Condition: CacheConditions.Deterministic,
+	}
+}
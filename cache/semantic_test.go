@@ -0,0 +1,199 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-13 16:30:42
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-08-08 11:09:52
+ * @Description:
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package cache
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Mrzhouyl/go-aisdk/consts"
+	"github.com/Mrzhouyl/go-aisdk/httpclient"
+	"github.com/Mrzhouyl/go-aisdk/httpclient/test/checks"
+	"github.com/Mrzhouyl/go-aisdk/models"
+)
+
+func TestVectorIndex_Search(t *testing.T) {
+	idx, err := NewVectorIndex(VectorIndexConfig{})
+	checks.NoErrorF(t, err)
+	checks.NoError(t, idx.Add("a", []float32{1, 0}, []byte("x")))
+	checks.NoError(t, idx.Add("a", []float32{0, 1}, []byte("y")))
+	// 余弦相似度与向量长度无关
+	value, score, ok := idx.Search("a", []float32{10, 1}, 0.9)
+	if !ok || string(value) != "x" || score < 0.99 {
+		t.Fatalf("expected x, got %q (score: %f, hit: %v)", value, score, ok)
+	}
+	if _, _, ok = idx.Search("a", []float32{1, 1}, 0.9); ok {
+		t.Error("expected similarity below threshold to miss")
+	}
+	if _, _, ok = idx.Search("b", []float32{1, 0}, 0.9); ok {
+		t.Error("expected different scope to miss")
+	}
+	if err = idx.Add("a", []float32{0, 0}, nil); err == nil {
+		t.Error("expected zero vector to be rejected")
+	}
+}
+
+func TestVectorIndex_Eviction(t *testing.T) {
+	tests := []struct {
+		policy  EvictionPolicy
+		evicted string
+	}{
+		{EvictionPolicyLRU, "b"},
+		{EvictionPolicyLFU, "b"},
+		{EvictionPolicyFIFO, "a"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			idx, err := NewVectorIndex(VectorIndexConfig{MaxEntries: 2, EvictionPolicy: tt.policy})
+			checks.NoErrorF(t, err)
+			checks.NoError(t, idx.Add("s", []float32{1, 0, 0}, []byte("a")))
+			checks.NoError(t, idx.Add("s", []float32{0, 1, 0}, []byte("b")))
+			// 访问 a，使其命中次数增加、访问时间更新
+			if _, _, ok := idx.Search("s", []float32{1, 0, 0}, 0.99); !ok {
+				t.Fatal("expected a to be found")
+			}
+			checks.NoError(t, idx.Add("s", []float32{0, 0, 1}, []byte("c")))
+			if idx.Len() != 2 {
+				t.Fatalf("expected 2 entries, got %d", idx.Len())
+			}
+			vectors := map[string][]float32{"a": {1, 0, 0}, "b": {0, 1, 0}}
+			if _, _, ok := idx.Search("s", vectors[tt.evicted], 0.99); ok {
+				t.Errorf("expected %s to be evicted", tt.evicted)
+			}
+		})
+	}
+}
+
+func TestVectorIndex_Persistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index", "semantic.json")
+	idx, err := NewVectorIndex(VectorIndexConfig{Path: path, SaveEvery: 1})
+	checks.NoErrorF(t, err)
+	checks.NoError(t, idx.Add("s", []float32{3, 4}, []byte("v")))
+
+	loaded, err := NewVectorIndex(VectorIndexConfig{Path: path})
+	checks.NoErrorF(t, err)
+	value, _, ok := loaded.Search("s", []float32{3, 4}, 0.99)
+	if !ok || string(value) != "v" {
+		t.Fatalf("expected persisted entry, got %q (hit: %v)", value, ok)
+	}
+}
+
+func TestSemanticCacheMiddleware_Process(t *testing.T) {
+	var (
+		// 包含 "weather" 的文本嵌入为同一方向的向量，其余文本嵌入为正交向量
+		embedder = EmbedderFunc(func(ctx context.Context, text string) (vector []float32, err error) {
+			if strings.Contains(text, "weather") {
+				return []float32{1, 0.01 * float32(len(text))}, nil
+			}
+			return []float32{0, 1}, nil
+		})
+		sm      = NewSemanticCacheMiddleware(SemanticCacheMiddlewareConfig{Embedder: embedder, Threshold: 0.9})
+		calls   int
+		handler = func(ctx context.Context, req any) (resp any, err error) {
+			calls++
+			return models.ChatResponse{
+				ChatBaseResponse: models.ChatBaseResponse{
+					ID: "chatcmpl-1",
+					Choices: []models.ChatChoice{{
+						FinishReason: models.ChatFinishReasonStop,
+						Message:      &models.ChatCompletionMessage{Role: "assistant", Content: "sunny"},
+					}},
+				},
+			}, nil
+		}
+		newRequest = func(system, user string) (request models.ChatRequest) {
+			return models.ChatRequest{
+				Provider: consts.OpenAI,
+				Model:    consts.OpenAIGPT4o,
+				Messages: []models.ChatMessage{
+					&models.SystemMessage{Content: system},
+					&models.UserMessage{Content: user},
+				},
+				Seed: models.Int(42),
+			}
+		}
+		process = func(request models.ChatRequest) (info *httpclient.RequestInfo, resp models.ChatResponse) {
+			info = &httpclient.RequestInfo{Provider: "openai", Model: consts.OpenAIGPT4o, Method: methodChatCompletion, RequestID: "req-1"}
+			response, err := sm.Process(httpclient.SetRequestInfo(context.Background(), info), request, handler)
+			checks.NoErrorF(t, err)
+			return info, response.(models.ChatResponse)
+		}
+	)
+	process(newRequest("sys", "what's the weather today?"))
+	// 相似问题命中缓存
+	info, resp := process(newRequest("sys", "how is the weather today"))
+	if !info.CacheHit || calls != 1 || resp.Choices[0].Message.Content != "sunny" {
+		t.Fatalf("expected similar request to hit the cache, calls: %d", calls)
+	}
+	if resp.Header().Get(SimilarityHeaderKey) == "" {
+		t.Error("expected similarity header to be set")
+	}
+	// 不同系统提示词不会命中
+	if info, _ = process(newRequest("other", "how is the weather today")); info.CacheHit {
+		t.Error("expected different system prompt to miss the cache")
+	}
+	// 不相似问题不会命中
+	if info, _ = process(newRequest("sys", "tell me a joke")); info.CacheHit {
+		t.Error("expected dissimilar request to miss the cache")
+	}
+	// 不同工具或响应格式不会命中
+	withTools := newRequest("sys", "how is the weather today")
+	withTools.Tools = []models.ChatTool{{Type: models.ToolTypeFunction, Function: &models.ChatToolFunction{Name: "get_weather"}}}
+	if info, _ = process(withTools); info.CacheHit {
+		t.Error("expected request with tools to miss the cache")
+	}
+	withFormat := newRequest("sys", "how is the weather today")
+	withFormat.ResponseFormat = &models.ChatResponseFormat{Type: models.ChatResponseFormatTypeJSONObject}
+	if info, _ = process(withFormat); info.CacheHit {
+		t.Error("expected request with response format to miss the cache")
+	}
+	// 默认不缓存采样请求
+	sampled := newRequest("sys", "how is the weather today")
+	sampled.Seed = nil
+	if info, _ = process(sampled); info.CacheHit {
+		t.Error("expected sampled request to miss the cache")
+	}
+	if calls != 6 {
+		t.Errorf("expected 6 upstream calls, got %d", calls)
+	}
+}
+
+// embeddingClient 记录嵌入请求的客户端
+type embeddingClient struct {
+	requests []models.EmbeddingRequest
+}
+
+// CreateEmbeddings 创建嵌入向量
+func (c *embeddingClient) CreateEmbeddings(ctx context.Context, request models.EmbeddingRequest, opts ...httpclient.HTTPClientOption) (response models.EmbeddingResponse, err error) {
+	c.requests = append(c.requests, request)
+	if request.Input[0] == "" {
+		return
+	}
+	response.Data = []models.EmbeddingData{{Embedding: []float32{1, 2}}}
+	return
+}
+
+func TestNewProviderEmbedder(t *testing.T) {
+	var (
+		client   = &embeddingClient{}
+		embedder = NewProviderEmbedder(client, consts.OpenAI, consts.OpenAITextEmbedding3Small)
+	)
+	vector, err := embedder.Embed(context.Background(), "hello")
+	checks.NoErrorF(t, err)
+	if len(vector) != 2 || len(client.requests) != 1 || client.requests[0].Provider != consts.OpenAI || client.requests[0].Model != consts.OpenAITextEmbedding3Small {
+		t.Fatalf("unexpected embedding: %v, requests: %+v", vector, client.requests)
+	}
+	// 空响应返回错误
+	if _, err = embedder.Embed(context.Background(), ""); err == nil {
+		t.Error("expected empty embedding to return error")
+	}
+}
@@ -0,0 +1,306 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-13 11:03:25
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-13 15:12:48
+ * @Description: 进程内向量索引，基于余弦相似度检索，支持淘汰策略与本地文件持久化
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	defaultVectorIndexMaxEntries = 1000 // 默认向量索引最大条目数
+	vectorIndexFileVersion       = 1    // 向量索引文件版本
+)
+
+// EvictionPolicy 淘汰策略
+type EvictionPolicy string
+
+const (
+	EvictionPolicyLRU  EvictionPolicy = "lru"  // 淘汰最近最少使用的条目
+	EvictionPolicyLFU  EvictionPolicy = "lfu"  // 淘汰命中次数最少的条目
+	EvictionPolicyFIFO EvictionPolicy = "fifo" // 淘汰最早写入的条目
+)
+
+// VectorIndexConfig 向量索引配置
+type VectorIndexConfig struct {
+	MaxEntries     int            // 最大条目数
+	TTL            time.Duration  // 条目有效期，小于等于0表示永不过期
+	EvictionPolicy EvictionPolicy // 超出最大条目数时的淘汰策略
+	Path           string         // 持久化文件路径，为空表示不持久化
+	SaveEvery      int            // 每写入多少个条目自动保存一次，小于等于0表示仅在调用 Save 时保存
+}
+
+// vectorEntry 向量索引条目
+type vectorEntry struct {
+	Scope        string    `json:"scope"`                // 作用域
+	Vector       []float32 `json:"vector"`               // 归一化后的向量
+	Value        []byte    `json:"value"`                // 条目值
+	Hits         int64     `json:"hits"`                 // 命中次数
+	CreatedAt    time.Time `json:"created_at"`           // 写入时间
+	LastAccessAt time.Time `json:"last_access_at"`       // 最近访问时间
+	ExpiresAt    time.Time `json:"expires_at,omitempty"` // 过期时间，零值表示永不过期
+}
+
+// expired 判断条目是否已过期
+func (e *vectorEntry) expired(now time.Time) (ok bool) {
+	return !e.ExpiresAt.IsZero() && now.After(e.ExpiresAt)
+}
+
+// vectorIndexFile 向量索引持久化文件
+type vectorIndexFile struct {
+	Version int            `json:"version"` // 文件版本
+	Entries []*vectorEntry `json:"entries"` // 条目列表
+}
+
+// VectorIndex 进程内向量索引，按作用域隔离，线性扫描计算余弦相似度
+type VectorIndex struct {
+	mu      sync.Mutex
+	config  VectorIndexConfig
+	entries []*vectorEntry // 条目列表
+	unsaved int            // 上次保存后写入的条目数
+}
+
+// NewVectorIndex 创建向量索引，配置了持久化文件且文件存在时从文件加载
+func NewVectorIndex(config VectorIndexConfig) (idx *VectorIndex, err error) {
+	// 设置最大条目数
+	if config.MaxEntries <= 0 {
+		config.MaxEntries = defaultVectorIndexMaxEntries
+	}
+	// 设置淘汰策略
+	if config.EvictionPolicy == "" {
+		config.EvictionPolicy = EvictionPolicyLRU
+	}
+	switch config.EvictionPolicy {
+	case EvictionPolicyLRU, EvictionPolicyLFU, EvictionPolicyFIFO:
+	default:
+		return nil, fmt.Errorf("unknown eviction policy: %s", config.EvictionPolicy)
+	}
+
+	idx = &VectorIndex{
+		config: config,
+	}
+	if config.Path != "" {
+		if err = idx.load(); err != nil {
+			return nil, err
+		}
+	}
+	return
+}
+
+// Add 写入条目
+func (idx *VectorIndex) Add(scope string, vector []float32, value []byte) (err error) {
+	normalized := normalize(vector)
+	if normalized == nil {
+		return errors.New("invalid vector: empty or zero vector")
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	now := time.Now()
+	e := &vectorEntry{
+		Scope:        scope,
+		Vector:       normalized,
+		Value:        slices.Clone(value),
+		CreatedAt:    now,
+		LastAccessAt: now,
+	}
+	if idx.config.TTL > 0 {
+		e.ExpiresAt = now.Add(idx.config.TTL)
+	}
+	idx.entries = append(idx.entries, e)
+	idx.evict(now)
+	// 自动保存
+	idx.unsaved++
+	if idx.config.Path != "" && idx.config.SaveEvery > 0 && idx.unsaved >= idx.config.SaveEvery {
+		return idx.save()
+	}
+	return
+}
+
+// Search 在作用域内检索与 vector 最相似的条目，相似度低于 threshold 时视为未命中
+func (idx *VectorIndex) Search(scope string, vector []float32, threshold float64) (value []byte, score float64, ok bool) {
+	normalized := normalize(vector)
+	if normalized == nil {
+		return
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	var (
+		now  = time.Now()
+		best *vectorEntry
+	)
+	score = math.Inf(-1)
+	for _, e := range idx.entries {
+		if e.Scope != scope || len(e.Vector) != len(normalized) || e.expired(now) {
+			continue
+		}
+		if s := dot(e.Vector, normalized); s > score {
+			best, score = e, s
+		}
+	}
+	if best == nil || score < threshold {
+		return nil, 0, false
+	}
+	best.Hits++
+	best.LastAccessAt = now
+	return slices.Clone(best.Value), score, true
+}
+
+// Len 获取条目数（包含尚未清理的过期条目）
+func (idx *VectorIndex) Len() (n int) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	return len(idx.entries)
+}
+
+// Clear 清空索引
+func (idx *VectorIndex) Clear() {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.entries = nil
+	idx.unsaved = 0
+}
+
+// Save 将索引保存到持久化文件，未配置持久化文件时不做任何操作
+func (idx *VectorIndex) Save() (err error) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if idx.config.Path == "" {
+		return
+	}
+	return idx.save()
+}
+
+// evict 清理过期条目，并按淘汰策略淘汰超出最大条目数的条目（调用方需持有锁）
+func (idx *VectorIndex) evict(now time.Time) {
+	idx.entries = slices.DeleteFunc(idx.entries, func(e *vectorEntry) bool {
+		return e.expired(now)
+	})
+	for len(idx.entries) > idx.config.MaxEntries {
+		victim := 0
+		for i, e := range idx.entries[1:] {
+			if idx.less(e, idx.entries[victim]) {
+				victim = i + 1
+			}
+		}
+		idx.entries = slices.Delete(idx.entries, victim, victim+1)
+	}
+}
+
+// less 判断条目 a 是否比条目 b 更应该被淘汰
+func (idx *VectorIndex) less(a, b *vectorEntry) (ok bool) {
+	switch idx.config.EvictionPolicy {
+	case EvictionPolicyLFU:
+		if a.Hits != b.Hits {
+			return a.Hits < b.Hits
+		}
+		return a.LastAccessAt.Before(b.LastAccessAt)
+	case EvictionPolicyFIFO:
+		return a.CreatedAt.Before(b.CreatedAt)
+	default:
+		return a.LastAccessAt.Before(b.LastAccessAt)
+	}
+}
+
+// load 从持久化文件加载索引
+func (idx *VectorIndex) load() (err error) {
+	var data []byte
+	if data, err = os.ReadFile(idx.config.Path); err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return
+	}
+
+	var file vectorIndexFile
+	if err = json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("failed to load vector index: %w", err)
+	}
+	if file.Version != vectorIndexFileVersion {
+		return fmt.Errorf("unsupported vector index version: %d", file.Version)
+	}
+	idx.entries = file.Entries
+	idx.evict(time.Now())
+	return
+}
+
+// save 将索引写入持久化文件（调用方需持有锁）
+func (idx *VectorIndex) save() (err error) {
+	var data []byte
+	if data, err = json.Marshal(vectorIndexFile{
+		Version: vectorIndexFileVersion,
+		Entries: idx.entries,
+	}); err != nil {
+		return
+	}
+
+	dir := filepath.Dir(idx.config.Path)
+	if err = os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create vector index dir: %w", err)
+	}
+	// 先写临时文件再重命名，避免进程退出时留下不完整的文件
+	var tmp *os.File
+	if tmp, err = os.CreateTemp(dir, "tmp-*"); err != nil {
+		return
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return
+	}
+	if err = tmp.Close(); err != nil {
+		return
+	}
+	if err = os.Rename(tmp.Name(), idx.config.Path); err != nil {
+		return
+	}
+	idx.unsaved = 0
+	return
+}
+
+// normalize 归一化向量，零向量返回 nil
+func normalize(vector []float32) (normalized []float32) {
+	var sum float64
+	for _, v := range vector {
+		sum += float64(v) * float64(v)
+	}
+	if sum == 0 || math.IsNaN(sum) || math.IsInf(sum, 0) {
+		return
+	}
+
+	norm := math.Sqrt(sum)
+	normalized = make([]float32, len(vector))
+	for i, v := range vector {
+		normalized[i] = float32(float64(v) / norm)
+	}
+	return
+}
+
+// dot 计算两个向量的点积，对归一化向量而言即余弦相似度
+func dot(a, b []float32) (sum float64) {
+	for i := range a {
+		sum += float64(a[i]) * float64(b[i])
+	}
+	return
+}
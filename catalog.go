@@ -0,0 +1,42 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-24 14:03:57
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-24 15:20:11
+ * @Description:
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package aisdk
+
+import (
+	"encoding/json"
+
+	"github.com/Mrzhouyl/go-aisdk/catalog"
+	"github.com/Mrzhouyl/go-aisdk/consts"
+	"github.com/Mrzhouyl/go-aisdk/errors"
+)
+
+// GetModelInfo 获取模型信息
+func (c *SDKClient) GetModelInfo(provider consts.Provider, model string) (spec catalog.ModelSpec, err error) {
+	var ok bool
+	if spec, ok = c.catalog.Get(provider, model); !ok {
+		err = errors.WrapModelInfoNotFound(provider, model)
+	}
+	return
+}
+
+// FindModels 查找满足过滤条件的模型，按提供商和模型名称排序
+func (c *SDKClient) FindModels(filter catalog.Filter) (specs []catalog.ModelSpec) {
+	return c.catalog.Find(filter)
+}
+
+// UpdateModelInfo 在运行时覆盖或扩展模型信息，格式与配置文件的 models 字段相同
+func (c *SDKClient) UpdateModelInfo(items ...json.RawMessage) (err error) {
+	return c.catalog.Override(items...)
+}
+
+// Catalog 获取模型目录
+func (c *SDKClient) Catalog() (modelCatalog *catalog.Catalog) {
+	return c.catalog
+}
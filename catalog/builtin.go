@@ -0,0 +1,373 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-24 10:26:03
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-08-05 16:03:29
+ * @Description: 内置的模型信息，价格以各提供商 2025 年 7 月公布的标准价格为准，可通过配置覆盖
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package catalog
+
+import (
+	"slices"
+
+	"github.com/Mrzhouyl/go-aisdk/consts"
+)
+
+// 常用的模态组合
+var (
+	textOnly       = []Modality{ModalityText}
+	textImage      = []Modality{ModalityText, ModalityImage}
+	textAudio      = []Modality{ModalityText, ModalityAudio}
+	textImageVideo = []Modality{ModalityText, ModalityImage, ModalityVideo}
+	omni           = []Modality{ModalityText, ModalityImage, ModalityAudio, ModalityVideo}
+)
+
+// builtinSpec 内置模型信息，names 中的模型共用同一份信息
+type builtinSpec struct {
+	names        []string
+	spec         ModelSpec
+	noTextOutput bool // 输出不是文本（如向量、审核结果），未设置输出模态时不默认为文本
+}
+
+// usd 美元价格
+func usd(input, output, cachedInput float64) (p Pricing) {
+	return Pricing{Input: input, Output: output, CachedInput: cachedInput, Currency: CurrencyUSD}
+}
+
+// cny 人民币价格
+func cny(input, output, cachedInput float64) (p Pricing) {
+	return Pricing{Input: input, Output: output, CachedInput: cachedInput, Currency: CurrencyCNY}
+}
+
+// withAudio 设置音频 token 的价格
+func withAudio(p Pricing, audioInput, audioOutput float64) (pricing Pricing) {
+	p.AudioInput, p.AudioOutput = audioInput, audioOutput
+	return p
+}
+
+// openAIBuiltin OpenAI 内置模型信息，快照版本未列出时继承基础模型
+var openAIBuiltin = []builtinSpec{
+	// o 系列
+	{names: []string{consts.OpenAIO1, consts.OpenAIO1_20241217}, spec: ModelSpec{
+		ContextWindow: 200000, MaxOutputTokens: 100000, Pricing: usd(15, 60, 7.5),
+		InputModalities: textImage, SupportsTools: true, SupportsJSONSchema: true,
+	}},
+	{names: []string{consts.OpenAIO1Pro}, spec: ModelSpec{
+		ContextWindow: 200000, MaxOutputTokens: 100000, Pricing: usd(150, 600, 0),
+		InputModalities: textImage, SupportsTools: true, SupportsJSONSchema: true,
+	}},
+	{names: []string{consts.OpenAIO1Mini}, spec: ModelSpec{
+		ContextWindow: 128000, MaxOutputTokens: 65536, Pricing: usd(1.1, 4.4, 0.55),
+		InputModalities: textOnly,
+	}},
+	{names: []string{consts.OpenAIO1Preview}, spec: ModelSpec{
+		ContextWindow: 128000, MaxOutputTokens: 32768, Pricing: usd(15, 60, 7.5),
+		InputModalities: textOnly, DeprecationDate: "2025-07-28",
+	}},
+	{names: []string{consts.OpenAIO3}, spec: ModelSpec{
+		ContextWindow: 200000, MaxOutputTokens: 100000, Pricing: usd(2, 8, 0.5),
+		InputModalities: textImage, SupportsTools: true, SupportsJSONSchema: true,
+	}},
+	{names: []string{consts.OpenAIO3Mini}, spec: ModelSpec{
+		ContextWindow: 200000, MaxOutputTokens: 100000, Pricing: usd(1.1, 4.4, 0.55),
+		InputModalities: textOnly, SupportsTools: true, SupportsJSONSchema: true,
+	}},
+	{names: []string{consts.OpenAIO4Mini}, spec: ModelSpec{
+		ContextWindow: 200000, MaxOutputTokens: 100000, Pricing: usd(1.1, 4.4, 0.275),
+		InputModalities: textImage, SupportsTools: true, SupportsJSONSchema: true,
+	}},
+	// GPT-4.1
+	{names: []string{consts.OpenAIGPT4Dot1}, spec: ModelSpec{
+		ContextWindow: 1047576, MaxOutputTokens: 32768, Pricing: usd(2, 8, 0.5),
+		InputModalities: textImage, SupportsTools: true, SupportsJSONSchema: true,
+	}},
+	{names: []string{consts.OpenAIGPT4Dot1Mini}, spec: ModelSpec{
+		ContextWindow: 1047576, MaxOutputTokens: 32768, Pricing: usd(0.4, 1.6, 0.1),
+		InputModalities: textImage, SupportsTools: true, SupportsJSONSchema: true,
+	}},
+	{names: []string{consts.OpenAIGPT4Dot1Nano}, spec: ModelSpec{
+		ContextWindow: 1047576, MaxOutputTokens: 32768, Pricing: usd(0.1, 0.4, 0.025),
+		InputModalities: textImage, SupportsTools: true, SupportsJSONSchema: true,
+	}},
+	{names: []string{consts.OpenAIGPT4Dot5Preview}, spec: ModelSpec{
+		ContextWindow: 128000, MaxOutputTokens: 16384, Pricing: usd(75, 150, 37.5),
+		InputModalities: textImage, SupportsTools: true, SupportsJSONSchema: true, DeprecationDate: "2025-07-14",
+	}},
+	// GPT-4o
+	{names: []string{consts.OpenAIGPT4o}, spec: ModelSpec{
+		ContextWindow: 128000, MaxOutputTokens: 16384, Pricing: usd(2.5, 10, 1.25),
+		InputModalities: textImage, SupportsTools: true, SupportsJSONSchema: true,
+	}},
+	{names: []string{consts.OpenAIGPT4o20240513}, spec: ModelSpec{
+		ContextWindow: 128000, MaxOutputTokens: 4096, Pricing: usd(5, 15, 0),
+		InputModalities: textImage, SupportsTools: true,
+	}},
+	{names: []string{consts.OpenAIChatGPT4oLatest}, spec: ModelSpec{
+		ContextWindow: 128000, MaxOutputTokens: 16384, Pricing: usd(5, 15, 0),
+		InputModalities: textImage,
+	}},
+	{names: []string{consts.OpenAIGPT4oMini}, spec: ModelSpec{
+		ContextWindow: 128000, MaxOutputTokens: 16384, Pricing: usd(0.15, 0.6, 0.075),
+		InputModalities: textImage, SupportsTools: true, SupportsJSONSchema: true,
+	}},
+	{names: []string{consts.OpenAIGPT4oSearchPreview}, spec: ModelSpec{
+		ContextWindow: 128000, MaxOutputTokens: 16384, Pricing: usd(2.5, 10, 0),
+		InputModalities: textOnly, SupportsJSONSchema: true, SupportsWebSearch: true,
+	}},
+	{names: []string{consts.OpenAIGPT4oMiniSearchPreview}, spec: ModelSpec{
+		ContextWindow: 128000, MaxOutputTokens: 16384, Pricing: usd(0.15, 0.6, 0),
+		InputModalities: textOnly, SupportsJSONSchema: true, SupportsWebSearch: true,
+	}},
+	{names: []string{consts.OpenAIGPT4oAudioPreview}, spec: ModelSpec{
+		ContextWindow: 128000, MaxOutputTokens: 16384, Pricing: withAudio(usd(2.5, 10, 0), 40, 80),
+		InputModalities: textAudio, OutputModalities: textAudio, SupportsTools: true,
+	}},
+	{names: []string{consts.OpenAIGPT4oMiniAudioPreview}, spec: ModelSpec{
+		ContextWindow: 128000, MaxOutputTokens: 16384, Pricing: withAudio(usd(0.15, 0.6, 0), 10, 20),
+		InputModalities: textAudio, OutputModalities: textAudio, SupportsTools: true,
+	}},
+	{names: []string{consts.OpenAIGPT4oRealtimePreview}, spec: ModelSpec{
+		ContextWindow: 128000, MaxOutputTokens: 4096, Pricing: withAudio(usd(5, 20, 2.5), 40, 80),
+		InputModalities: textAudio, OutputModalities: textAudio, SupportsTools: true,
+	}},
+	{names: []string{consts.OpenAIGPT4oMiniRealtimePreview}, spec: ModelSpec{
+		ContextWindow: 128000, MaxOutputTokens: 4096, Pricing: withAudio(usd(0.6, 2.4, 0.3), 10, 20),
+		InputModalities: textAudio, OutputModalities: textAudio, SupportsTools: true,
+	}},
+	// GPT-4 与 GPT-3.5
+	{names: []string{consts.OpenAIGPT4Turbo}, spec: ModelSpec{
+		ContextWindow: 128000, MaxOutputTokens: 4096, Pricing: usd(10, 30, 0),
+		InputModalities: textImage, SupportsTools: true,
+	}},
+	{names: []string{consts.OpenAIGPT4TurboPreview, consts.OpenAIGPT4_0125Preview, consts.OpenAIGPT4_1106Preview}, spec: ModelSpec{
+		ContextWindow: 128000, MaxOutputTokens: 4096, Pricing: usd(10, 30, 0),
+		InputModalities: textOnly, SupportsTools: true,
+	}},
+	{names: []string{consts.OpenAIGPT4VisionPreview}, spec: ModelSpec{
+		ContextWindow: 128000, MaxOutputTokens: 4096, Pricing: usd(10, 30, 0),
+		InputModalities: textImage, DeprecationDate: "2024-12-06",
+	}},
+	{names: []string{consts.OpenAIGPT4}, spec: ModelSpec{
+		ContextWindow: 8192, MaxOutputTokens: 8192, Pricing: usd(30, 60, 0),
+		InputModalities: textOnly, SupportsTools: true,
+	}},
+	{names: []string{consts.OpenAIGPT4_32K}, spec: ModelSpec{
+		ContextWindow: 32768, MaxOutputTokens: 8192, Pricing: usd(60, 120, 0),
+		InputModalities: textOnly, DeprecationDate: "2025-06-06",
+	}},
+	{names: []string{consts.OpenAIGPT3Dot5Turbo, consts.OpenAIGPT3Dot5Turbo0125}, spec: ModelSpec{
+		ContextWindow: 16385, MaxOutputTokens: 4096, Pricing: usd(0.5, 1.5, 0),
+		InputModalities: textOnly, SupportsTools: true,
+	}},
+	{names: []string{consts.OpenAIGPT3Dot5Turbo1106}, spec: ModelSpec{
+		ContextWindow: 16385, MaxOutputTokens: 4096, Pricing: usd(1, 2, 0),
+		InputModalities: textOnly, SupportsTools: true,
+	}},
+	{names: []string{consts.OpenAIGPT3Dot5Turbo0613, consts.OpenAIGPT3Dot5Turbo0301}, spec: ModelSpec{
+		ContextWindow: 4096, MaxOutputTokens: 4096, Pricing: usd(1.5, 2, 0),
+		InputModalities: textOnly, DeprecationDate: "2024-09-13",
+	}},
+	{names: []string{consts.OpenAIGPT3Dot5Turbo16k, consts.OpenAIGPT3Dot5Turbo16K0613}, spec: ModelSpec{
+		ContextWindow: 16385, MaxOutputTokens: 4096, Pricing: usd(3, 4, 0),
+		InputModalities: textOnly, DeprecationDate: "2024-09-13",
+	}},
+	{names: []string{consts.OpenAIGPT3Dot5TurboInstruct}, spec: ModelSpec{
+		ContextWindow: 4096, MaxOutputTokens: 4096, Pricing: usd(1.5, 2, 0),
+		InputModalities: textOnly,
+	}},
+	{names: []string{consts.OpenAIDavinci002}, spec: ModelSpec{
+		ContextWindow: 16384, MaxOutputTokens: 16384, Pricing: usd(2, 2, 0),
+		InputModalities: textOnly,
+	}},
+	{names: []string{consts.OpenAIBabbage002}, spec: ModelSpec{
+		ContextWindow: 16384, MaxOutputTokens: 16384, Pricing: usd(0.4, 0.4, 0),
+		InputModalities: textOnly,
+	}},
+	// 图像、音频、向量和审核
+	{names: []string{consts.OpenAIGPTImage1}, spec: ModelSpec{
+		Pricing:         Pricing{Input: 5, CachedInput: 1.25, ImageInput: 10, Output: 40, Currency: CurrencyUSD},
+		InputModalities: textImage, OutputModalities: []Modality{ModalityImage},
+	}},
+	{names: []string{consts.OpenAIDallE2}, spec: ModelSpec{
+		Pricing: Pricing{PerImage: map[string]float64{
+			"256x256": 0.016, "512x512": 0.018, "1024x1024": 0.02,
+		}, Currency: CurrencyUSD},
+		InputModalities: textOnly, OutputModalities: []Modality{ModalityImage},
+	}},
+	{names: []string{consts.OpenAIDallE3}, spec: ModelSpec{
+		Pricing: Pricing{PerImage: map[string]float64{
+			"1024x1024": 0.04, "1792x1024": 0.08, "1024x1792": 0.08,
+			"hd:1024x1024": 0.08, "hd:1792x1024": 0.12, "hd:1024x1792": 0.12,
+		}, Currency: CurrencyUSD},
+		InputModalities: textOnly, OutputModalities: []Modality{ModalityImage},
+	}},
+	{names: []string{consts.OpenAIGPT4oTranscribe}, spec: ModelSpec{
+		ContextWindow: 16000, MaxOutputTokens: 2000, Pricing: withAudio(usd(2.5, 10, 0), 6, 0),
+		InputModalities: textAudio, OutputModalities: textOnly,
+	}},
+	{names: []string{consts.OpenAIGPT4oMiniTranscribe}, spec: ModelSpec{
+		ContextWindow: 16000, MaxOutputTokens: 2000, Pricing: withAudio(usd(1.25, 5, 0), 3, 0),
+		InputModalities: textAudio, OutputModalities: textOnly,
+	}},
+	{names: []string{consts.OpenAIWhisper1}, spec: ModelSpec{
+		InputModalities: []Modality{ModalityAudio}, OutputModalities: textOnly,
+	}},
+	{names: []string{consts.OpenAIGPT4oMiniTTS}, spec: ModelSpec{
+		ContextWindow: 2000, Pricing: withAudio(usd(0.6, 0, 0), 0, 12),
+		InputModalities: textOnly, OutputModalities: []Modality{ModalityAudio},
+	}},
+	{names: []string{consts.OpenAITTS1, consts.OpenAITTS1HD}, spec: ModelSpec{
+		InputModalities: textOnly, OutputModalities: []Modality{ModalityAudio},
+	}},
+	{names: []string{consts.OpenAITextEmbedding3Small}, spec: ModelSpec{
+		ContextWindow: 8191, Pricing: usd(0.02, 0, 0), InputModalities: textOnly,
+	}, noTextOutput: true},
+	{names: []string{consts.OpenAITextEmbedding3Large}, spec: ModelSpec{
+		ContextWindow: 8191, Pricing: usd(0.13, 0, 0), InputModalities: textOnly,
+	}, noTextOutput: true},
+	{names: []string{consts.OpenAITextEmbeddingAda002}, spec: ModelSpec{
+		ContextWindow: 8191, Pricing: usd(0.1, 0, 0), InputModalities: textOnly,
+	}, noTextOutput: true},
+	{names: []string{consts.OpenAIOmniModerationLatest}, spec: ModelSpec{
+		InputModalities: textImage,
+	}, noTextOutput: true},
+}
+
+// deepSeekBuiltin DeepSeek 内置模型信息
+var deepSeekBuiltin = []builtinSpec{
+	{names: []string{consts.DeepSeekChat}, spec: ModelSpec{
+		ContextWindow: 65536, MaxOutputTokens: 8192, Pricing: usd(0.27, 1.1, 0.07),
+		InputModalities: textOnly, SupportsTools: true,
+	}},
+	{names: []string{consts.DeepSeekReasoner}, spec: ModelSpec{
+		ContextWindow: 65536, MaxOutputTokens: 65536, Pricing: usd(0.55, 2.19, 0.14),
+		InputModalities: textOnly,
+	}},
+}
+
+// aliBLBuiltin 阿里百炼内置模型信息，-latest 和日期快照未列出时继承基础模型
+var aliBLBuiltin = []builtinSpec{
+	// 商业版
+	{names: []string{consts.AliBLQwenMax}, spec: ModelSpec{
+		ContextWindow: 32768, MaxOutputTokens: 8192, Pricing: cny(2.4, 9.6, 0),
+		InputModalities: textOnly, SupportsTools: true, SupportsWebSearch: true,
+	}},
+	{names: []string{consts.AliBLQwenPlus}, spec: ModelSpec{
+		ContextWindow: 131072, MaxOutputTokens: 16384, Pricing: cny(0.8, 2, 0),
+		InputModalities: textOnly, SupportsTools: true, SupportsWebSearch: true,
+	}},
+	{names: []string{consts.AliBLQwenTurbo}, spec: ModelSpec{
+		ContextWindow: 1000000, MaxOutputTokens: 16384, Pricing: cny(0.3, 0.6, 0),
+		InputModalities: textOnly, SupportsTools: true, SupportsWebSearch: true,
+	}},
+	{names: []string{consts.AliBLQwenLong}, spec: ModelSpec{
+		ContextWindow: 10000000, MaxOutputTokens: 8192, Pricing: cny(0.5, 2, 0),
+		InputModalities: textOnly,
+	}},
+	{names: []string{consts.AliBLQwqPlus}, spec: ModelSpec{
+		ContextWindow: 131072, MaxOutputTokens: 8192, Pricing: cny(1.6, 4, 0),
+		InputModalities: textOnly, SupportsTools: true, SupportsWebSearch: true,
+	}},
+	{names: []string{consts.AliBLQvqMax}, spec: ModelSpec{
+		ContextWindow: 131072, MaxOutputTokens: 8192, Pricing: cny(8, 32, 0),
+		InputModalities: textImageVideo,
+	}},
+	{names: []string{consts.AliBLQvqPlus}, spec: ModelSpec{
+		ContextWindow: 131072, MaxOutputTokens: 8192, Pricing: cny(2, 5, 0),
+		InputModalities: textImageVideo,
+	}},
+	{names: []string{consts.AliBLQwenVlMax}, spec: ModelSpec{
+		ContextWindow: 131072, MaxOutputTokens: 8192, Pricing: cny(3, 9, 0),
+		InputModalities: textImageVideo, SupportsTools: true,
+	}},
+	{names: []string{consts.AliBLQwenVlPlus}, spec: ModelSpec{
+		ContextWindow: 131072, MaxOutputTokens: 8192, Pricing: cny(1.5, 4.5, 0),
+		InputModalities: textImageVideo,
+	}},
+	{names: []string{consts.AliBLQwenVlOcr}, spec: ModelSpec{
+		ContextWindow: 34096, MaxOutputTokens: 4096, Pricing: cny(5, 5, 0),
+		InputModalities: textImage,
+	}},
+	{names: []string{consts.AliBLQwenOmniTurbo}, spec: ModelSpec{
+		ContextWindow: 32768, MaxOutputTokens: 2048, Pricing: cny(0.4, 1.6, 0),
+		InputModalities: omni, OutputModalities: textAudio,
+	}},
+	{names: []string{consts.AliBLQwenAudioTurbo}, spec: ModelSpec{
+		ContextWindow: 8000, MaxOutputTokens: 1500, Pricing: cny(1.6, 10, 0),
+		InputModalities: textAudio,
+	}},
+	{names: []string{consts.AliBLQwenMathPlus}, spec: ModelSpec{
+		ContextWindow: 4096, MaxOutputTokens: 3072, Pricing: cny(4, 12, 0),
+		InputModalities: textOnly,
+	}},
+	{names: []string{consts.AliBLQwenMathTurbo}, spec: ModelSpec{
+		ContextWindow: 4096, MaxOutputTokens: 3072, Pricing: cny(2, 6, 0),
+		InputModalities: textOnly,
+	}},
+	{names: []string{consts.AliBLQwenCoderPlus}, spec: ModelSpec{
+		ContextWindow: 131072, MaxOutputTokens: 8192, Pricing: cny(3.5, 7, 0),
+		InputModalities: textOnly, SupportsTools: true,
+	}},
+	{names: []string{consts.AliBLQwenCoderTurbo}, spec: ModelSpec{
+		ContextWindow: 131072, MaxOutputTokens: 8192, Pricing: cny(2, 6, 0),
+		InputModalities: textOnly, SupportsTools: true,
+	}},
+	// 开源版
+	{names: []string{consts.AliBLQwen3_235bA22b, consts.AliBLQwen3_32b}, spec: ModelSpec{
+		ContextWindow: 131072, MaxOutputTokens: 16384, Pricing: cny(2, 8, 0),
+		InputModalities: textOnly, SupportsTools: true,
+	}},
+	{names: []string{consts.AliBLQwen3_30bA3b}, spec: ModelSpec{
+		ContextWindow: 131072, MaxOutputTokens: 16384, Pricing: cny(0.75, 3, 0),
+		InputModalities: textOnly, SupportsTools: true,
+	}},
+	{names: []string{consts.AliBLQwen3_14b}, spec: ModelSpec{
+		ContextWindow: 131072, MaxOutputTokens: 8192, Pricing: cny(1, 4, 0),
+		InputModalities: textOnly, SupportsTools: true,
+	}},
+	{names: []string{consts.AliBLQwen3_8b}, spec: ModelSpec{
+		ContextWindow: 131072, MaxOutputTokens: 8192, Pricing: cny(0.5, 2, 0),
+		InputModalities: textOnly, SupportsTools: true,
+	}},
+	{names: []string{consts.AliBLQwen3_4b, consts.AliBLQwen3_17b, consts.AliBLQwen3_06b}, spec: ModelSpec{
+		ContextWindow: 32768, MaxOutputTokens: 8192, Pricing: cny(0.3, 1.2, 0),
+		InputModalities: textOnly, SupportsTools: true,
+	}},
+	{names: []string{consts.AliBLQwq32b}, spec: ModelSpec{
+		ContextWindow: 131072, MaxOutputTokens: 8192, Pricing: cny(2, 6, 0),
+		InputModalities: textOnly, SupportsTools: true,
+	}},
+	{names: []string{consts.AliBLQwen2Dot5_72bInstruct}, spec: ModelSpec{
+		ContextWindow: 131072, MaxOutputTokens: 8192, Pricing: cny(4, 12, 0),
+		InputModalities: textOnly, SupportsTools: true,
+	}},
+}
+
+// Builtin 获取内置的模型信息
+//
+// 价格仅供估算参考，以提供商的账单为准；价格调整后可以通过配置文件的 models 字段覆盖，无需升级 SDK
+func Builtin() (specs []ModelSpec) {
+	for _, group := range []struct {
+		provider consts.Provider
+		items    []builtinSpec
+	}{
+		{provider: consts.OpenAI, items: openAIBuiltin},
+		{provider: consts.DeepSeek, items: deepSeekBuiltin},
+		{provider: consts.AliBL, items: aliBLBuiltin},
+	} {
+		for _, item := range group.items {
+			for _, name := range item.names {
+				spec := item.spec.clone()
+				spec.Provider, spec.Model = group.provider, name
+				// 未设置输出模态的模型只输出文本
+				if spec.OutputModalities == nil && !item.noTextOutput {
+					spec.OutputModalities = slices.Clone(textOnly)
+				}
+				specs = append(specs, spec)
+			}
+		}
+	}
+	return
+}
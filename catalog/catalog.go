@@ -0,0 +1,353 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-24 09:41:26
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-25 16:05:44
+ * @Description: 模型目录，记录上下文窗口、价格、模态和能力等模型信息
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package catalog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Mrzhouyl/go-aisdk/consts"
+)
+
+// DateLayout 弃用日期的格式
+const DateLayout = "2006-01-02"
+
+// Modality 模态
+type Modality string
+
+const (
+	ModalityText  Modality = "text"  // 文本
+	ModalityImage Modality = "image" // 图像
+	ModalityAudio Modality = "audio" // 音频
+	ModalityVideo Modality = "video" // 视频
+)
+
+// 货币
+const (
+	CurrencyUSD = "USD" // 美元
+	CurrencyCNY = "CNY" // 人民币
+)
+
+// Pricing 价格，token 价格的单位为每百万 token
+type Pricing struct {
+	Input       float64            `json:"input,omitempty"`        // 输入价格
+	Output      float64            `json:"output,omitempty"`       // 输出价格
+	CachedInput float64            `json:"cached_input,omitempty"` // 命中缓存的输入价格，为 0 时按输入价格计算
+	Reasoning   float64            `json:"reasoning,omitempty"`    // 推理 token 的价格，为 0 时按输出价格计算
+	AudioInput  float64            `json:"audio_input,omitempty"`  // 音频输入 token 的价格，为 0 时按输入价格计算
+	AudioOutput float64            `json:"audio_output,omitempty"` // 音频输出 token 的价格，为 0 时按输出价格计算
+	ImageInput  float64            `json:"image_input,omitempty"`  // 图像输入 token 的价格（图像生成），为 0 时按输入价格计算
+	ImageOutput float64            `json:"image_output,omitempty"` // 图像输出 token 的价格（图像生成），为 0 时按输出价格计算
+	PerImage    map[string]float64 `json:"per_image,omitempty"`    // 按张计价的图像价格，键为 "<quality>:<size>" 或 "<size>"
+	Currency    string             `json:"currency,omitempty"`     // 货币，默认为 USD
+}
+
+// ImagePrice 获取每张图像的价格，优先匹配 "<quality>:<size>"，其次匹配 "<size>"
+func (p Pricing) ImagePrice(quality, size string) (price float64, ok bool) {
+	if quality != "" {
+		if price, ok = p.PerImage[quality+":"+size]; ok {
+			return
+		}
+	}
+	price, ok = p.PerImage[size]
+	return
+}
+
+// ModelSpec 模型信息
+type ModelSpec struct {
+	Provider           consts.Provider     `json:"provider"`                       // 提供商
+	Model              string              `json:"model"`                          // 模型
+	ModelTypes         []consts.ModelType  `json:"model_types,omitempty"`          // 模型类型
+	Features           consts.ModelFeature `json:"features,omitempty"`             // 模型特性
+	ContextWindow      int                 `json:"context_window,omitempty"`       // 上下文窗口，单位为 token
+	MaxOutputTokens    int                 `json:"max_output_tokens,omitempty"`    // 最大输出 token 数
+	Pricing            Pricing             `json:"pricing"`                        // 价格
+	InputModalities    []Modality          `json:"input_modalities,omitempty"`     // 支持的输入模态
+	OutputModalities   []Modality          `json:"output_modalities,omitempty"`    // 支持的输出模态
+	SupportsTools      bool                `json:"supports_tools,omitempty"`       // 是否支持工具调用
+	SupportsJSONSchema bool                `json:"supports_json_schema,omitempty"` // 是否支持 JSON Schema 结构化输出
+	SupportsWebSearch  bool                `json:"supports_web_search,omitempty"`  // 是否支持联网搜索
+	DeprecationDate    string              `json:"deprecation_date,omitempty"`     // 弃用日期，格式为 2006-01-02
+}
+
+// IsDeprecated 判断模型在 now 时是否已弃用
+func (s ModelSpec) IsDeprecated(now time.Time) (deprecated bool) {
+	if s.DeprecationDate == "" {
+		return false
+	}
+	date, err := time.Parse(DateLayout, s.DeprecationDate)
+	return err == nil && !now.Before(date)
+}
+
+// HasModelType 判断模型是否属于指定的模型类型
+func (s ModelSpec) HasModelType(modelType consts.ModelType) (ok bool) {
+	return slices.Contains(s.ModelTypes, modelType)
+}
+
+// SupportsInput 判断模型是否支持指定的输入模态
+func (s ModelSpec) SupportsInput(modality Modality) (ok bool) {
+	return slices.Contains(s.InputModalities, modality)
+}
+
+// clone 深拷贝模型信息
+func (s ModelSpec) clone() (dest ModelSpec) {
+	dest = s
+	dest.ModelTypes = slices.Clone(s.ModelTypes)
+	dest.InputModalities = slices.Clone(s.InputModalities)
+	dest.OutputModalities = slices.Clone(s.OutputModalities)
+	dest.Pricing.PerImage = maps.Clone(s.Pricing.PerImage)
+	return
+}
+
+// Filter 模型过滤条件，零值表示不限制
+type Filter struct {
+	Provider          consts.Provider  // 提供商
+	ModelType         consts.ModelType // 模型类型
+	MinContextWindow  int              // 最小上下文窗口
+	MinOutputTokens   int              // 最小输出 token 数
+	InputModalities   []Modality       // 需要支持的输入模态
+	OutputModalities  []Modality       // 需要支持的输出模态
+	Tools             bool             // 需要支持工具调用
+	JSONSchema        bool             // 需要支持 JSON Schema 结构化输出
+	WebSearch         bool             // 需要支持联网搜索
+	Reasoning         bool             // 需要是推理模型
+	MaxInputPrice     float64          // 每百万输入 token 的最高价格，不区分货币
+	MaxOutputPrice    float64          // 每百万输出 token 的最高价格，不区分货币
+	IncludeDeprecated bool             // 是否包含已弃用的模型
+	Now               time.Time        // 判断是否弃用的时间，默认为当前时间
+}
+
+// Match 判断模型是否满足过滤条件
+func (f Filter) Match(s ModelSpec) (ok bool) {
+	switch {
+	case f.Provider != "" && s.Provider != f.Provider,
+		f.ModelType != "" && !s.HasModelType(f.ModelType),
+		s.ContextWindow < f.MinContextWindow,
+		s.MaxOutputTokens < f.MinOutputTokens,
+		f.Tools && !s.SupportsTools,
+		f.JSONSchema && !s.SupportsJSONSchema,
+		f.WebSearch && !s.SupportsWebSearch,
+		f.Reasoning && !s.Features.IsReasoningModel(),
+		f.MaxInputPrice > 0 && s.Pricing.Input > f.MaxInputPrice,
+		f.MaxOutputPrice > 0 && s.Pricing.Output > f.MaxOutputPrice:
+		return false
+	}
+	for _, m := range f.InputModalities {
+		if !s.SupportsInput(m) {
+			return false
+		}
+	}
+	for _, m := range f.OutputModalities {
+		if !slices.Contains(s.OutputModalities, m) {
+			return false
+		}
+	}
+	if !f.IncludeDeprecated {
+		now := f.Now
+		if now.IsZero() {
+			now = time.Now()
+		}
+		if s.IsDeprecated(now) {
+			return false
+		}
+	}
+	return true
+}
+
+// modelKey 模型的唯一标识
+type modelKey struct {
+	provider consts.Provider
+	model    string
+}
+
+// Catalog 模型目录（并发安全）
+type Catalog struct {
+	mu    sync.RWMutex
+	specs map[modelKey]ModelSpec
+}
+
+// New 新建模型目录
+func New(specs ...ModelSpec) (c *Catalog) {
+	c = &Catalog{specs: make(map[modelKey]ModelSpec, len(specs))}
+	c.Set(specs...)
+	return
+}
+
+// NewDefault 新建包含内置模型信息的模型目录
+func NewDefault() (c *Catalog) {
+	return New(Builtin()...)
+}
+
+// Get 获取模型信息
+func (c *Catalog) Get(provider consts.Provider, model string) (spec ModelSpec, ok bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if spec, ok = c.specs[modelKey{provider, model}]; ok {
+		spec = spec.clone()
+	}
+	return
+}
+
+// Price 获取模型价格，目录中没有该模型时使用最长前缀的基础模型价格（如 gpt-4o-2024-08-06 使用 gpt-4o 的价格），实现 cost.PriceTable 接口
+func (c *Catalog) Price(provider consts.Provider, model string) (pricing Pricing, ok bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	spec, ok := c.specs[modelKey{provider, model}]
+	if !ok {
+		spec = baseSpec(c.specs, provider, model)
+		ok = spec.Model != ""
+	}
+	return spec.clone().Pricing, ok
+}
+
+// Set 添加或替换模型信息
+func (c *Catalog) Set(specs ...ModelSpec) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, spec := range specs {
+		spec = spec.clone()
+		if spec.Pricing.Currency == "" {
+			spec.Pricing.Currency = CurrencyUSD
+		}
+		c.specs[modelKey{spec.Provider, spec.Model}] = spec
+	}
+}
+
+// Delete 删除模型信息
+func (c *Catalog) Delete(provider consts.Provider, model string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.specs, modelKey{provider, model})
+}
+
+// Register 根据提供商支持的模型补充模型类型和特性，目录中没有的模型继承最长前缀的基础模型信息（如 qwen-max-latest 继承 qwen-max）
+func (c *Catalog) Register(provider consts.Provider, supportedModels map[consts.ModelType]map[string]consts.ModelFeature) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for modelType, modelMap := range supportedModels {
+		for model, feature := range modelMap {
+			key := modelKey{provider, model}
+			spec, ok := c.specs[key]
+			if !ok {
+				spec = baseSpec(c.specs, provider, model)
+				spec.Provider, spec.Model, spec.ModelTypes = provider, model, nil
+			}
+			if !spec.HasModelType(modelType) {
+				spec.ModelTypes = append(spec.ModelTypes, modelType)
+				slices.Sort(spec.ModelTypes)
+			}
+			spec.Features |= feature
+			if spec.Pricing.Currency == "" {
+				spec.Pricing.Currency = CurrencyUSD
+			}
+			c.specs[key] = spec
+		}
+	}
+}
+
+// baseSpec 获取以 "<name>-" 为前缀的最长基础模型信息
+func baseSpec(specs map[modelKey]ModelSpec, provider consts.Provider, model string) (spec ModelSpec) {
+	best := ""
+	for key, s := range specs {
+		if key.provider == provider && len(key.model) > len(best) && strings.HasPrefix(model, key.model+"-") {
+			best, spec = key.model, s
+		}
+	}
+	return spec.clone()
+}
+
+// Override 使用 JSON 覆盖或扩展模型信息，每一项为 ModelSpec 的 JSON 对象或对象数组，按 provider 和 model 合并到已有的模型信息中，只覆盖出现的字段
+func (c *Catalog) Override(items ...json.RawMessage) (err error) {
+	var raws []json.RawMessage
+	for _, item := range items {
+		if trimmed := bytes.TrimSpace(item); len(trimmed) > 0 && trimmed[0] == '[' {
+			var list []json.RawMessage
+			if err = json.Unmarshal(trimmed, &list); err != nil {
+				return fmt.Errorf("unmarshal model overrides failed: %w", err)
+			}
+			raws = append(raws, list...)
+			continue
+		}
+		raws = append(raws, item)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// 在副本上合并，任一项出错时不修改目录；后面的项可以继承前面新增的模型
+	specs := maps.Clone(c.specs)
+	for i, raw := range raws {
+		var key struct {
+			Provider consts.Provider `json:"provider"`
+			Model    string          `json:"model"`
+		}
+		if err = json.Unmarshal(raw, &key); err != nil {
+			return fmt.Errorf("unmarshal model override %d failed: %w", i, err)
+		}
+		if key.Provider == "" || key.Model == "" {
+			return fmt.Errorf("model override %d requires provider and model", i)
+		}
+		spec, ok := specs[modelKey{key.Provider, key.Model}]
+		if !ok {
+			spec = baseSpec(specs, key.Provider, key.Model)
+		}
+		// 切片字段在反序列化时会复用底层数组，先深拷贝
+		spec = spec.clone()
+		if err = json.Unmarshal(raw, &spec); err != nil {
+			return fmt.Errorf("unmarshal model override %d failed: %w", i, err)
+		}
+		if spec.DeprecationDate != "" {
+			if _, err = time.Parse(DateLayout, spec.DeprecationDate); err != nil {
+				return fmt.Errorf("model override %d has invalid deprecation date: %w", i, err)
+			}
+		}
+		if spec.Pricing.Currency == "" {
+			spec.Pricing.Currency = CurrencyUSD
+		}
+		specs[modelKey{spec.Provider, spec.Model}] = spec
+	}
+	c.specs = specs
+	return
+}
+
+// Find 查找满足过滤条件的模型，按提供商和模型名称排序
+func (c *Catalog) Find(filter Filter) (specs []ModelSpec) {
+	c.mu.RLock()
+	for _, spec := range c.specs {
+		if filter.Match(spec) {
+			specs = append(specs, spec.clone())
+		}
+	}
+	c.mu.RUnlock()
+
+	slices.SortFunc(specs, func(a, b ModelSpec) int {
+		if n := strings.Compare(string(a.Provider), string(b.Provider)); n != 0 {
+			return n
+		}
+		return strings.Compare(a.Model, b.Model)
+	})
+	return
+}
+
+// All 获取所有模型信息，按提供商和模型名称排序
+func (c *Catalog) All() (specs []ModelSpec) {
+	return c.Find(Filter{IncludeDeprecated: true})
+}
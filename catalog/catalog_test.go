@@ -0,0 +1,171 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-24 15:24:40
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-08-05 16:03:29
+ * @Description:
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package catalog
+
+import (
+	"encoding/json"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Mrzhouyl/go-aisdk/consts"
+)
+
+// names 获取模型名称
+func names(specs []ModelSpec) (result []string) {
+	for _, spec := range specs {
+		result = append(result, string(spec.Provider)+"/"+spec.Model)
+	}
+	return
+}
+
+func TestCatalog_Register(t *testing.T) {
+	c := NewDefault()
+	c.Register(consts.AliBL, map[consts.ModelType]map[string]consts.ModelFeature{
+		consts.ChatModel: {
+			consts.AliBLQwenMax:         consts.ModelFeatureNone,
+			consts.AliBLQwenMaxLatest:   consts.ModelFeatureNone,
+			consts.AliBLQwenVlMax:       consts.ModelFeatureMultimodal,
+			consts.AliBLQwenVlMaxLatest: consts.ModelFeatureMultimodal,
+			"unknown-model":             consts.ModelFeatureNone,
+		},
+	})
+
+	spec, ok := c.Get(consts.AliBL, consts.AliBLQwenMaxLatest)
+	if !ok {
+		t.Fatal("qwen-max-latest not registered")
+	}
+	if spec.ContextWindow != 32768 || spec.Pricing.Input != 2.4 || spec.Pricing.Currency != CurrencyCNY || !spec.HasModelType(consts.ChatModel) {
+		t.Errorf("qwen-max-latest should inherit qwen-max: %+v", spec)
+	}
+	// 继承最长前缀，qwen-vl-max-latest 继承 qwen-vl-max 而不是其他模型
+	if spec, _ = c.Get(consts.AliBL, consts.AliBLQwenVlMaxLatest); spec.Pricing.Input != 3 || !spec.Features.IsMultimodal() {
+		t.Errorf("qwen-vl-max-latest should inherit qwen-vl-max: %+v", spec)
+	}
+	if spec, ok = c.Get(consts.AliBL, "unknown-model"); !ok || spec.ContextWindow != 0 || spec.Pricing.Currency != CurrencyUSD {
+		t.Errorf("unknown model should be registered without details: %+v", spec)
+	}
+	// 返回的是副本
+	spec, _ = c.Get(consts.AliBL, consts.AliBLQwenMax)
+	spec.InputModalities[0] = ModalityVideo
+	if spec, _ = c.Get(consts.AliBL, consts.AliBLQwenMax); spec.InputModalities[0] != ModalityText {
+		t.Error("Get() should return a copy")
+	}
+}
+
+func TestCatalog_Override(t *testing.T) {
+	c := NewDefault()
+	err := c.Override(
+		json.RawMessage(`{"provider":"openai","model":"gpt-4o","pricing":{"input":2,"cached_input":1},"input_modalities":["text"]}`),
+		json.RawMessage(`[{"provider":"openai","model":"gpt-4o-2025-08-01","deprecation_date":"2026-01-01"},
+			{"provider":"custom","model":"my-model","model_types":["chat"],"context_window":4096,"supports_tools":true}]`),
+	)
+	if err != nil {
+		t.Fatalf("Override() error = %v", err)
+	}
+
+	spec, _ := c.Get(consts.OpenAI, consts.OpenAIGPT4o)
+	want := Pricing{Input: 2, Output: 10, CachedInput: 1, Currency: CurrencyUSD}
+	if !reflect.DeepEqual(spec.Pricing, want) || spec.ContextWindow != 128000 || !reflect.DeepEqual(spec.InputModalities, []Modality{ModalityText}) {
+		t.Errorf("override should only replace the given fields: %+v", spec)
+	}
+	// 内置的共享切片不受影响
+	if spec, _ = c.Get(consts.OpenAI, consts.OpenAIGPT4Dot1); len(spec.InputModalities) != 2 {
+		t.Errorf("override modified another model: %+v", spec.InputModalities)
+	}
+	if spec, _ = c.Get(consts.OpenAI, "gpt-4o-2025-08-01"); spec.ContextWindow != 128000 || spec.Pricing.Input != 2 || spec.DeprecationDate != "2026-01-01" {
+		t.Errorf("new snapshot should inherit gpt-4o: %+v", spec)
+	}
+	if spec, _ = c.Get("custom", "my-model"); !spec.SupportsTools || !spec.HasModelType(consts.ChatModel) {
+		t.Errorf("custom model not added: %+v", spec)
+	}
+
+	// 任一项出错时不修改目录
+	for _, raw := range []string{
+		`{"model":"gpt-4o"}`,
+		`{"provider":"openai","model":"gpt-4o","deprecation_date":"2026/01/01"}`,
+		`{"provider":"openai","model":"gpt-4o","context_window":"big"}`,
+	} {
+		if err = c.Override(json.RawMessage(`{"provider":"openai","model":"gpt-4o","context_window":1}`), json.RawMessage(raw)); err == nil {
+			t.Errorf("Override(%s) expected error", raw)
+		}
+	}
+	if spec, _ = c.Get(consts.OpenAI, consts.OpenAIGPT4o); spec.ContextWindow != 128000 {
+		t.Errorf("failed override modified the catalog: %d", spec.ContextWindow)
+	}
+}
+
+func TestCatalog_Find(t *testing.T) {
+	now := time.Date(2025, 7, 24, 0, 0, 0, 0, time.UTC)
+	c := NewDefault()
+	c.Register(consts.DeepSeek, map[consts.ModelType]map[string]consts.ModelFeature{
+		consts.ChatModel: {consts.DeepSeekChat: consts.ModelFeatureNone, consts.DeepSeekReasoner: consts.ModelFeatureReasoning},
+	})
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []string
+	}{
+		{
+			name:   "provider and model type",
+			filter: Filter{Provider: consts.DeepSeek, ModelType: consts.ChatModel, Now: now},
+			want:   []string{"deepseek/deepseek-chat", "deepseek/deepseek-reasoner"},
+		},
+		{
+			name:   "reasoning",
+			filter: Filter{Provider: consts.DeepSeek, Reasoning: true, Now: now},
+			want:   []string{"deepseek/deepseek-reasoner"},
+		},
+		{
+			name:   "web search with max price",
+			filter: Filter{Provider: consts.OpenAI, WebSearch: true, MaxInputPrice: 1, Now: now},
+			want:   []string{"openai/gpt-4o-mini-search-preview"},
+		},
+		{
+			name:   "million token context with images and json schema",
+			filter: Filter{MinContextWindow: 1000000, InputModalities: []Modality{ModalityImage}, JSONSchema: true, Tools: true, Now: now},
+			want:   []string{"openai/gpt-4.1", "openai/gpt-4.1-mini", "openai/gpt-4.1-nano"},
+		},
+		{
+			name:   "text output",
+			filter: Filter{Provider: consts.DeepSeek, OutputModalities: []Modality{ModalityText}, Now: now},
+			want:   []string{"deepseek/deepseek-chat", "deepseek/deepseek-reasoner"},
+		},
+		{
+			name:   "audio output",
+			filter: Filter{Provider: consts.AliBL, OutputModalities: []Modality{ModalityAudio}, Now: now},
+			want:   []string{"alibl/qwen-omni-turbo"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := names(c.Find(tt.filter)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Find() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// 向量模型不输出文本
+	if got := names(c.Find(Filter{Provider: consts.OpenAI, OutputModalities: []Modality{ModalityText}, Now: now})); !slices.Contains(got, "openai/gpt-4o") || slices.Contains(got, "openai/text-embedding-3-small") {
+		t.Errorf("Find() = %v, text output should include chat models only", got)
+	}
+
+	// 默认不包含已弃用的模型
+	filter := Filter{Provider: consts.OpenAI, MaxInputPrice: 100, Tools: true, Now: now}
+	if got := names(c.Find(filter)); slices.Contains(got, "openai/gpt-4.5-preview") || !slices.Contains(got, "openai/gpt-4o") {
+		t.Errorf("Find() = %v, deprecated model should be excluded", got)
+	}
+	filter.IncludeDeprecated = true
+	if got := names(c.Find(filter)); !slices.Contains(got, "openai/gpt-4.5-preview") {
+		t.Errorf("Find() = %v, deprecated model should be included", got)
+	}
+}
@@ -2,7 +2,7 @@
  * @Author: liusuxian 382185882@qq.com
  * @Date: 2025-04-15 18:09:20
  * @LastEditors: liusuxian 382185882@qq.com
- * @LastEditTime: 2025-07-09 15:49:53
+ * @LastEditTime: 2025-07-28 16:58:31
  * @Description:
  *
  * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
@@ -14,6 +14,7 @@ import (
 	"sort"
 	"time"
 
+	"github.com/Mrzhouyl/go-aisdk/catalog"
 	"github.com/Mrzhouyl/go-aisdk/conf"
 	"github.com/Mrzhouyl/go-aisdk/consts"
 	"github.com/Mrzhouyl/go-aisdk/core"
@@ -27,8 +28,10 @@ import (
 // SDKClient SDK客户端
 type SDKClient struct {
 	configManager   *conf.SDKConfigManager // 配置管理器
+	catalog         *catalog.Catalog       // 模型目录
 	flakeInstance   *flake.Flake           // 分布式唯一ID生成器
 	middlewareChain *httpclient.Chain      // 中间件链
+	validation      ValidationConfig       // 请求参数预检配置
 	noCheckMethods  map[string]bool        // 不需要检查模型支持的方法
 }
 
@@ -38,6 +41,8 @@ type SDKClientOption func(c *clientOption)
 // clientOption 客户端选项
 type clientOption struct {
 	middlewares []httpclient.Middleware
+	catalog     *catalog.Catalog // 模型目录，供需要价格的中间件使用
+	validation  ValidationConfig // 请求参数预检配置
 }
 
 // NewSDKClient 创建一个SDK客户端
@@ -54,6 +59,8 @@ func NewSDKClient(configPath string, opts ...SDKClientOption) (client *SDKClient
 		err = errors.WrapFailedToCreateFlakeInstance(err.Error())
 		return
 	}
+	// 创建模型目录
+	modelCatalog := catalog.NewDefault()
 	// 初始化所有提供商
 	for _, provider := range core.ListProviders() {
 		// 获取提供商
@@ -66,9 +73,16 @@ func NewSDKClient(configPath string, opts ...SDKClientOption) (client *SDKClient
 		providerConfig := configManager.GetProviderConfig(provider)
 		// 初始化提供商配置
 		ps.InitializeProviderConfig(&providerConfig)
+		// 将支持的模型注册到模型目录
+		modelCatalog.Register(provider, ps.GetSupportedModels())
+	}
+	// 使用配置覆盖模型目录
+	if err = modelCatalog.Override(configManager.GetModelsConfig()...); err != nil {
+		err = errors.WrapFailedToCreateConfigManager(err.Error())
+		return
 	}
 	// 处理选项
-	cliOpt := &clientOption{}
+	cliOpt := &clientOption{catalog: modelCatalog, validation: DefaultValidationConfig()}
 	for _, opt := range opts {
 		opt(cliOpt)
 	}
@@ -81,8 +95,10 @@ func NewSDKClient(configPath string, opts ...SDKClientOption) (client *SDKClient
 	// 创建SDK客户端
 	client = &SDKClient{
 		configManager:   configManager,
+		catalog:         modelCatalog,
 		flakeInstance:   flakeInstance,
 		middlewareChain: middlewareChain,
+		validation:      cliOpt.validation,
 		noCheckMethods: map[string]bool{
 			"ListModels": true,
 		},
@@ -134,6 +150,11 @@ func (c *SDKClient) handlerRequest(
 		RequestID: requestId,
 		User:      userInfo.User,
 	})
+	// 请求参数预检，日志器可以从上下文中获取请求信息
+	if err = c.validateRequest(ctx, modelInfo, method, request); err != nil {
+		err = &errors.SDKError{RequestID: requestId, Err: err}
+		return
+	}
 	// 定义最终处理函数
 	finalHandler := func(ctx context.Context, req any) (resp any, err error) {
 		// 获取提供商
@@ -144,8 +165,10 @@ func (c *SDKClient) handlerRequest(
 		// 根据方法名称决定是否需要判断模型支持
 		var e error
 		if !c.noCheckMethods[method] {
-			// 判断模型是否支持
-			if e = c.isModelSupported(ps, modelInfo); e != nil {
+			// 判断模型是否支持，中间件（如预算降级）可能修改了请求的模型
+			checkInfo := modelInfo
+			checkInfo.Model = httpclient.GetRequestInfo(ctx).Model
+			if e = c.isModelSupported(ps, checkInfo); e != nil {
 				return nil, e
 			}
 		}
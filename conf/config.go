@@ -2,7 +2,7 @@
  * @Author: liusuxian 382185882@qq.com
  * @Date: 2025-04-15 19:09:15
  * @LastEditors: liusuxian 382185882@qq.com
- * @LastEditTime: 2025-06-16 15:42:03
+ * @LastEditTime: 2025-07-24 15:16:32
  * @Description:
  *
  * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
@@ -34,7 +34,8 @@ type ProviderConfig struct {
 
 // SDKConfig SDK整体配置
 type SDKConfig struct {
-	Providers map[string]ProviderConfig `json:"providers"` // AI服务提供商的配置
+	Providers map[string]ProviderConfig `json:"providers"`        // AI服务提供商的配置
+	Models    []json.RawMessage         `json:"models,omitempty"` // 模型目录的覆盖和扩展，每一项为 catalog.ModelSpec 的 JSON，按 provider 和 model 合并到内置的模型信息中
 }
 
 // SDKConfigManager SDK配置管理器
@@ -97,6 +98,7 @@ func (m *SDKConfigManager) GetConfig() (configCopy SDKConfig) {
 	for k, v := range m.config.Providers {
 		configCopy.Providers[k] = cloneProviderConfig(v)
 	}
+	configCopy.Models = m.GetModelsConfig()
 	return
 }
 
@@ -111,6 +113,18 @@ func (m *SDKConfigManager) GetProviderConfig(provider fmt.Stringer) (config Prov
 	return
 }
 
+// GetModelsConfig 获取模型目录的覆盖配置
+func (m *SDKConfigManager) GetModelsConfig() (models []json.RawMessage) {
+	if m.config.Models == nil {
+		return
+	}
+	models = make([]json.RawMessage, 0, len(m.config.Models))
+	for _, raw := range m.config.Models {
+		models = append(models, slices.Clone(raw))
+	}
+	return
+}
+
 // cloneProviderConfig 深拷贝 ProviderConfig
 func cloneProviderConfig(source ProviderConfig) (dest ProviderConfig) {
 	var extraCopy map[string]string
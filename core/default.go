@@ -2,7 +2,7 @@
  * @Author: liusuxian 382185882@qq.com
  * @Date: 2025-06-19 17:59:35
  * @LastEditors: liusuxian 382185882@qq.com
- * @LastEditTime: 2025-07-07 22:23:18
+ * @LastEditTime: 2025-07-13 10:06:12
  * @Description:
  *
  * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
@@ -57,3 +57,9 @@ func (s *DefaultProviderService) CreateImageVariation(ctx context.Context, reque
 	err = errors.WrapMethodNotSupported(request.Provider, consts.ImageModel, request.Model, "CreateImageVariation")
 	return
 }
+
+// CreateEmbeddings 创建嵌入向量
+func (s *DefaultProviderService) CreateEmbeddings(ctx context.Context, request models.EmbeddingRequest, opts ...httpclient.HTTPClientOption) (response models.EmbeddingResponse, err error) {
+	err = errors.WrapMethodNotSupported(request.Provider, consts.EmbedModel, request.Model, "CreateEmbeddings")
+	return
+}
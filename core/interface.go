@@ -2,7 +2,7 @@
  * @Author: liusuxian 382185882@qq.com
  * @Date: 2025-04-15 18:45:51
  * @LastEditors: liusuxian 382185882@qq.com
- * @LastEditTime: 2025-07-09 15:49:29
+ * @LastEditTime: 2025-07-13 10:06:12
  * @Description:
  *
  * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
@@ -37,6 +37,9 @@ type ProviderService interface {
 	CreateImageEdit(ctx context.Context, request models.ImageEditRequest, opts ...httpclient.HTTPClientOption) (response models.ImageResponse, err error)           // 编辑图像
 	CreateImageVariation(ctx context.Context, request models.ImageVariationRequest, opts ...httpclient.HTTPClientOption) (response models.ImageResponse, err error) // 变换图像
 
+	// 嵌入相关
+	CreateEmbeddings(ctx context.Context, request models.EmbeddingRequest, opts ...httpclient.HTTPClientOption) (response models.EmbeddingResponse, err error) // 创建嵌入向量
+
 	// TODO 视频相关
 
 	// TODO 音频相关
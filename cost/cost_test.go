@@ -0,0 +1,324 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-25 15:40:21
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-08-04 14:21:50
+ * @Description:
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package cost
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Mrzhouyl/go-aisdk/catalog"
+	"github.com/Mrzhouyl/go-aisdk/consts"
+	"github.com/Mrzhouyl/go-aisdk/httpclient"
+	"github.com/Mrzhouyl/go-aisdk/internal/utils"
+	"github.com/Mrzhouyl/go-aisdk/models"
+)
+
+// almostEqual 判断浮点数是否近似相等
+func almostEqual(a, b float64) (ok bool) {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestCalculate(t *testing.T) {
+	tests := []struct {
+		name    string
+		pricing catalog.Pricing
+		usage   Usage
+		want    float64
+	}{
+		{
+			name:    "cached and reasoning",
+			pricing: catalog.Pricing{Input: 2, CachedInput: 0.5, Output: 8, Reasoning: 10},
+			usage:   Usage{InputTokens: 1000000, CachedInputTokens: 400000, OutputTokens: 500000, ReasoningTokens: 200000},
+			// 0.6*2 + 0.4*0.5 + 0.3*8 + 0.2*10
+			want: 5.8,
+		},
+		{
+			name:    "fallback prices",
+			pricing: catalog.Pricing{Input: 1, Output: 4},
+			usage:   Usage{InputTokens: 1000000, CachedInputTokens: 500000, OutputTokens: 1000000, ReasoningTokens: 500000},
+			want:    5,
+		},
+		{
+			name:    "audio",
+			pricing: catalog.Pricing{Input: 2.5, Output: 10, AudioInput: 40, AudioOutput: 80},
+			usage:   Usage{InputTokens: 300000, AudioInputTokens: 100000, OutputTokens: 200000, AudioOutputTokens: 100000},
+			// 0.2*2.5 + 0.1*40 + 0.1*10 + 0.1*80
+			want: 13.5,
+		},
+		{
+			name:    "image tokens",
+			pricing: catalog.Pricing{Input: 5, ImageInput: 10, Output: 40},
+			usage:   Usage{InputTokens: 300000, ImageInputTokens: 200000, OutputTokens: 100000, ImageOutputTokens: 100000, Images: 1},
+			// 0.1*5 + 0.2*10 + 0.1*40
+			want: 6.5,
+		},
+		{
+			name:    "per image",
+			pricing: catalog.Pricing{PerImage: map[string]float64{"1024x1024": 0.04, "hd:1024x1024": 0.08}},
+			usage:   Usage{Images: 2, ImageSize: "1024x1024", ImageQuality: "hd"},
+			want:    0.16,
+		},
+		{
+			name:    "per image fallback to size",
+			pricing: catalog.Pricing{PerImage: map[string]float64{"1024x1024": 0.04}},
+			usage:   Usage{Images: 3, ImageSize: "1024x1024", ImageQuality: "hd"},
+			want:    0.12,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Calculate(tt.pricing, tt.usage); !almostEqual(got, tt.want) {
+				t.Errorf("Calculate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChatUsage(t *testing.T) {
+	got := ChatUsage(&models.ChatUsage{
+		PromptTokens:            100,
+		CompletionTokens:        50,
+		PromptCacheHitTokens:    30,
+		PromptTokensDetails:     &models.PromptTokensDetails{CachedTokens: 20, AudioTokens: 10},
+		CompletionTokensDetails: &models.CompletionTokensDetails{ReasoningTokens: 15, AudioTokens: 5},
+	})
+	want := Usage{InputTokens: 100, CachedInputTokens: 30, AudioInputTokens: 10, OutputTokens: 50, ReasoningTokens: 15, AudioOutputTokens: 5}
+	if got != want {
+		t.Errorf("ChatUsage() = %+v, want %+v", got, want)
+	}
+}
+
+// execute 通过中间件链执行请求
+func execute(t *testing.T, m *CostMiddleware, ctx context.Context, info httpclient.RequestInfo, request, response any) (resp any) {
+	t.Helper()
+	ctx = httpclient.SetRequestInfo(ctx, &info)
+	resp, err := httpclient.NewChain(m).Execute(ctx, request, func(ctx context.Context, req any) (any, error) {
+		if info.CacheHit {
+			httpclient.GetRequestInfo(ctx).CacheHit = true
+		}
+		return response, nil
+	})
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	return
+}
+
+func TestCostMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+	memory := NewMemorySink()
+	m := NewCostMiddleware(CostMiddlewareConfig{Sink: MultiSink(memory, NewJSONLWriterSink(&buf))})
+	ctx := WithTenant(context.Background(), "search-team")
+	chatInfo := httpclient.RequestInfo{
+		Provider:  string(consts.OpenAI),
+		ModelType: string(consts.ChatModel),
+		Model:     consts.OpenAIGPT4o,
+		Method:    "CreateChatCompletion",
+		RequestID: "req-1",
+		User:      "alice",
+	}
+
+	// 非流式聊天
+	response := models.ChatResponse{ChatBaseResponse: models.ChatBaseResponse{
+		Model: "gpt-4o-2024-08-06",
+		Usage: &models.ChatUsage{
+			PromptTokens:        1000,
+			CompletionTokens:    100,
+			PromptTokensDetails: &models.PromptTokensDetails{CachedTokens: 400},
+		},
+	}}
+	execute(t, m, ctx, chatInfo, models.ChatRequest{}, response)
+
+	// 流式聊天，用量信息在最后一个数据块中
+	body := "data: {\"model\":\"gpt-4o\",\"choices\":[{\"delta\":{\"content\":\"hi\"}}]}\n\n" +
+		"data: {\"model\":\"gpt-4o\",\"choices\":[],\"usage\":{\"prompt_tokens\":2000,\"completion_tokens\":200}}\n\n" +
+		"data: [DONE]\n\n"
+	stream := models.ChatResponseStream{StreamReader: httpclient.NewStreamReader[models.ChatBaseResponse](
+		io.NopCloser(strings.NewReader(body)), nil, httpclient.HTTPClientConfig{ResponseDecoder: utils.NewDeserializer("", true)},
+	)}
+	streamInfo := chatInfo
+	streamInfo.Method, streamInfo.RequestID, streamInfo.User = "CreateChatCompletionStream", "req-2", "bob"
+	stream = execute(t, m, ctx, streamInfo, models.ChatRequest{}, stream).(models.ChatResponseStream)
+	for {
+		_, isFinished, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("Recv() error = %v", err)
+		}
+		if isFinished {
+			break
+		}
+	}
+
+	// 命中缓存
+	cachedInfo := chatInfo
+	cachedInfo.RequestID, cachedInfo.CacheHit = "req-3", true
+	execute(t, m, ctx, cachedInfo, models.ChatRequest{}, response)
+
+	// 按张计价的图像
+	imageInfo := httpclient.RequestInfo{
+		Provider:  string(consts.OpenAI),
+		ModelType: string(consts.ImageModel),
+		Model:     consts.OpenAIDallE3,
+		Method:    "CreateImage",
+		RequestID: "req-4",
+		User:      "alice",
+	}
+	execute(t, m, ctx, imageInfo, models.ImageRequest{Quality: models.ImageQualityHD, Size: models.ImageSize1792x1024, N: 2},
+		models.ImageResponse{Data: []models.ImageResponseData{{URL: "a"}, {URL: "b"}}})
+
+	// 未知模型
+	unknownInfo := chatInfo
+	unknownInfo.Model, unknownInfo.RequestID = "unknown", "req-5"
+	execute(t, m, context.Background(), unknownInfo, models.ChatRequest{}, models.ChatResponse{})
+
+	var records []UsageRecord
+	if err := ReadJSONL(&buf, func(record UsageRecord) (err error) {
+		records = append(records, record)
+		return
+	}); err != nil {
+		t.Fatalf("ReadJSONL() error = %v", err)
+	}
+	if len(records) != 5 {
+		t.Fatalf("got %d records, want 5", len(records))
+	}
+	wantCosts := []float64{
+		(600*2.5 + 400*1.25 + 100*10) / 1e6,
+		(2000*2.5 + 200*10) / 1e6,
+		0,
+		2 * 0.12,
+		0,
+	}
+	for i, record := range records {
+		if !almostEqual(record.Cost, wantCosts[i]) {
+			t.Errorf("record %d cost = %v, want %v", i, record.Cost, wantCosts[i])
+		}
+	}
+	if r := records[0]; r.Tenant != "search-team" || r.User != "alice" || r.Currency != catalog.CurrencyUSD || !r.Priced || r.Usage.CachedInputTokens != 400 {
+		t.Errorf("unexpected chat record: %+v", r)
+	}
+	if r := records[1]; !r.Stream || r.MissingUsage || r.User != "bob" {
+		t.Errorf("unexpected stream record: %+v", r)
+	}
+	if r := records[2]; !r.CacheHit {
+		t.Errorf("unexpected cache hit record: %+v", r)
+	}
+	if r := records[4]; r.Priced || !r.MissingUsage {
+		t.Errorf("unexpected unknown model record: %+v", r)
+	}
+
+	// 内存聚合
+	costs := memory.Costs(func(key AggregateKey) bool { return key.Tenant == "search-team" && key.User == "alice" })
+	if want := wantCosts[0] + wantCosts[3]; !almostEqual(costs[catalog.CurrencyUSD], want) {
+		t.Errorf("alice costs = %v, want %v", costs, want)
+	}
+	aggregates := memory.Aggregates()
+	if len(aggregates) != 4 || aggregates[2].User != "alice" || aggregates[2].Model != consts.OpenAIGPT4o || aggregates[2].Requests != 2 {
+		t.Errorf("unexpected aggregates: %+v", aggregates)
+	}
+	if metrics := m.GetMetrics(); metrics["cost_records"] != int64(5) {
+		t.Errorf("unexpected metrics: %+v", metrics)
+	}
+}
+
+func TestCostMiddleware_Shared(t *testing.T) {
+	var (
+		buf     bytes.Buffer
+		sf      = httpclient.NewSingleflightMiddleware(httpclient.SingleflightMiddlewareConfig{})
+		chain   = httpclient.NewChain(NewCostMiddleware(CostMiddlewareConfig{Sink: NewJSONLWriterSink(&buf)}), sf)
+		release = make(chan struct{})
+		handler = func(ctx context.Context, req any) (any, error) {
+			<-release
+			return models.ChatResponse{ChatBaseResponse: models.ChatBaseResponse{Model: "gpt-4o", Usage: &models.ChatUsage{PromptTokens: 1000, CompletionTokens: 100}}}, nil
+		}
+		wg sync.WaitGroup
+	)
+	for _, requestID := range []string{"req-1", "req-2"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx := httpclient.SetRequestInfo(context.Background(), &httpclient.RequestInfo{
+				Provider:  string(consts.OpenAI),
+				ModelType: string(consts.ChatModel),
+				Model:     consts.OpenAIGPT4o,
+				Method:    "CreateChatCompletion",
+				RequestID: requestID,
+			})
+			if _, err := chain.Execute(ctx, models.ChatRequest{Provider: consts.OpenAI, Model: consts.OpenAIGPT4o}, handler); err != nil {
+				t.Errorf("Execute() error = %v", err)
+			}
+		}()
+	}
+	// 等待两个请求合并后再放行
+	for sf.GetMetrics()["singleflight_shared"] != int64(1) {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+
+	var records []UsageRecord
+	if err := ReadJSONL(&buf, func(record UsageRecord) (err error) {
+		records = append(records, record)
+		return
+	}); err != nil {
+		t.Fatalf("ReadJSONL() error = %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("got %d records, want 2", len(records))
+	}
+	// 上游只调用一次，只有发起方计费
+	var total float64
+	for _, record := range records {
+		if record.Shared && record.Cost != 0 {
+			t.Errorf("shared record should cost nothing: %+v", record)
+		}
+		total += record.Cost
+	}
+	if want := (1000*2.5 + 100*10) / 1e6; !almostEqual(total, want) {
+		t.Errorf("total cost = %v, want %v", total, want)
+	}
+}
+
+func TestJSONLSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.jsonl")
+	for i := range 2 {
+		sink, err := NewJSONLSink(path)
+		if err != nil {
+			t.Fatalf("NewJSONLSink() error = %v", err)
+		}
+		if err = sink.Record(context.Background(), UsageRecord{RequestID: string(rune('a' + i)), Cost: 1}); err != nil {
+			t.Fatalf("Record() error = %v", err)
+		}
+		if err = sink.Close(); err != nil {
+			t.Fatalf("Close() error = %v", err)
+		}
+	}
+
+	memory := NewMemorySink()
+	data, _ := os.ReadFile(path)
+	if err := ReadJSONL(bytes.NewReader(data), func(record UsageRecord) (err error) {
+		return memory.Record(context.Background(), record)
+	}); err != nil {
+		t.Fatalf("ReadJSONL() error = %v", err)
+	}
+	if costs := memory.Costs(nil); costs[""] != 2 {
+		t.Errorf("appended records not read back: %v", costs)
+	}
+	if err := ReadJSONL(strings.NewReader("{bad}\n"), func(UsageRecord) error { return nil }); err == nil {
+		t.Error("expected error for invalid line")
+	}
+}
@@ -0,0 +1,213 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-25 13:20:05
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-08-04 14:21:50
+ * @Description: 费用统计中间件
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package cost
+
+import (
+	"context"
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/Mrzhouyl/go-aisdk/catalog"
+	"github.com/Mrzhouyl/go-aisdk/consts"
+	"github.com/Mrzhouyl/go-aisdk/httpclient"
+	"github.com/Mrzhouyl/go-aisdk/models"
+)
+
+// defaultImageSize 未指定尺寸时按张计价使用的图像尺寸
+const defaultImageSize = string(models.ImageSize1024x1024)
+
+// CostMiddlewareConfig 费用统计中间件配置
+type CostMiddlewareConfig struct {
+	Prices  PriceTable      // 价格表，默认使用内置的模型目录
+	Sink    UsageSink       // 用量记录的存储，默认为 MemorySink
+	OnError func(err error) // 保存用量记录失败时的回调，保存失败不影响请求结果
+}
+
+// CostMiddleware 费用统计中间件，按价格表计算每次调用的费用并保存用量记录
+//
+// 流式传输在结束时根据最后一个数据块的用量信息计费，OpenAI 和 DeepSeek 需要设置 StreamOptions.IncludeUsage
+type CostMiddleware struct {
+	config CostMiddlewareConfig
+	mu     sync.Mutex
+	totals map[string]float64 // 按货币累计的费用
+	count  int64              // 用量记录数
+}
+
+// NewCostMiddleware 创建费用统计中间件
+func NewCostMiddleware(config CostMiddlewareConfig) (cm *CostMiddleware) {
+	// 设置默认值
+	if config.Prices == nil {
+		config.Prices = catalog.NewDefault()
+	}
+	if config.Sink == nil {
+		config.Sink = NewMemorySink()
+	}
+	return &CostMiddleware{
+		config: config,
+		totals: make(map[string]float64),
+	}
+}
+
+// Process 处理请求
+func (m *CostMiddleware) Process(ctx context.Context, request any, next httpclient.MWHandler) (response any, err error) {
+	if response, err = next(ctx, request); err != nil {
+		return
+	}
+	// 流式传输在 WrapStream 中计费
+	if usage, missing, responseModel, ok := ResponseUsage(request, response); ok {
+		m.record(ctx, usage, missing, false, responseModel)
+	}
+	return
+}
+
+// WrapStream 包装流式数据接收函数，流结束时根据最后出现的用量信息计费
+func (m *CostMiddleware) WrapStream(ctx context.Context, next httpclient.StreamRecvFunc) (recv httpclient.StreamRecvFunc) {
+	return WrapStreamUsage(next, func(usage Usage, missing bool, responseModel string) {
+		m.record(ctx, usage, missing, true, responseModel)
+	})
+}
+
+// record 计算费用并保存用量记录
+func (m *CostMiddleware) record(ctx context.Context, usage Usage, missing, stream bool, responseModel string) {
+	requestInfo := httpclient.GetRequestInfo(ctx)
+	record := UsageRecord{
+		RequestID:    requestInfo.RequestID,
+		Time:         time.Now(),
+		Tenant:       TenantFromContext(ctx),
+		User:         requestInfo.User,
+		Provider:     requestInfo.Provider,
+		ModelType:    requestInfo.ModelType,
+		Model:        requestInfo.Model,
+		Method:       requestInfo.Method,
+		Stream:       stream,
+		CacheHit:     requestInfo.CacheHit,
+		Shared:       requestInfo.Shared,
+		MissingUsage: missing,
+		Usage:        usage,
+	}
+	pricing, ok := PriceFor(m.config.Prices, consts.Provider(record.Provider), record.Model, responseModel)
+	record.Priced, record.Currency = ok, pricing.Currency
+	// 命中缓存或被合并的请求没有产生上游费用，只由发起合并请求的调用方计费
+	if ok && !record.CacheHit && !record.Shared {
+		record.Cost = Calculate(pricing, usage)
+	}
+
+	m.mu.Lock()
+	m.totals[record.Currency] += record.Cost
+	m.count++
+	m.mu.Unlock()
+
+	if err := m.config.Sink.Record(context.WithoutCancel(ctx), record); err != nil && m.config.OnError != nil {
+		m.config.OnError(err)
+	}
+}
+
+// Name 返回中间件名称
+func (m *CostMiddleware) Name() (name string) {
+	return "cost"
+}
+
+// Priority 返回中间件优先级
+func (m *CostMiddleware) Priority() (priority int) {
+	return 12 // 费用统计中间件在监控之后、缓存之前执行，命中缓存或被合并的请求记为零费用
+}
+
+// GetMetrics 获取费用指标数据
+func (m *CostMiddleware) GetMetrics() (metrics map[string]any) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return map[string]any{
+		"cost_records": m.count,
+		"cost_totals":  maps.Clone(m.totals),
+	}
+}
+
+// ResponseUsage 获取非流式响应的用量，ok 为 false 表示响应不可计费（如流式响应，需要使用 WrapStreamUsage）
+func ResponseUsage(request, response any) (usage Usage, missing bool, responseModel string, ok bool) {
+	switch resp := response.(type) {
+	case models.ChatResponse:
+		return ChatUsage(resp.Usage), resp.Usage == nil, resp.Model, true
+	case models.EmbeddingResponse:
+		if resp.Usage != nil {
+			usage.InputTokens = resp.Usage.PromptTokens
+		}
+		return usage, resp.Usage == nil, resp.Model, true
+	case models.ImageResponse:
+		size, quality, n := imageOptions(request)
+		if len(resp.Data) > 0 {
+			n = len(resp.Data)
+		}
+		return ImageUsage(resp.Usage, n, size, quality), false, "", true
+	}
+	return
+}
+
+// WrapStreamUsage 包装流式数据接收函数，流结束（正常结束或出错）时以最后出现的用量信息调用一次 onEnd
+func WrapStreamUsage(next httpclient.StreamRecvFunc, onEnd func(usage Usage, missing bool, responseModel string)) (recv httpclient.StreamRecvFunc) {
+	var (
+		usage         *models.ChatUsage
+		responseModel string
+		ended         bool
+	)
+	return func() (chunk any, isFinished bool, err error) {
+		chunk, isFinished, err = next()
+		if ended {
+			return
+		}
+		if c, ok := chunk.(models.ChatBaseResponse); ok && err == nil && !isFinished {
+			if c.Usage != nil {
+				usage = c.Usage
+			}
+			if c.Model != "" {
+				responseModel = c.Model
+			}
+			return
+		}
+		ended = true
+		onEnd(ChatUsage(usage), usage == nil, responseModel)
+		return
+	}
+}
+
+// PriceFor 获取模型价格，优先使用请求的模型价格，其次使用响应中的模型（如日期快照）价格
+func PriceFor(prices PriceTable, provider consts.Provider, model, responseModel string) (pricing catalog.Pricing, ok bool) {
+	if pricing, ok = prices.Price(provider, model); !ok && responseModel != "" {
+		pricing, ok = prices.Price(provider, responseModel)
+	}
+	return
+}
+
+// imageOptions 获取图像请求的尺寸、质量和数量
+func imageOptions(request any) (size, quality string, n int) {
+	switch req := request.(type) {
+	case models.ImageRequest:
+		size, quality, n = string(req.Size), string(req.Quality), req.N
+	case models.ImageEditRequest:
+		size, quality, n = string(req.Size), string(req.Quality), req.N
+	case models.ImageVariationRequest:
+		size, n = string(req.Size), req.N
+	}
+	if size == "" || size == string(models.ImageSizeAuto) {
+		size = defaultImageSize
+	}
+	if quality == string(models.ImageQualityAuto) || quality == string(models.ImageQualityStandard) {
+		quality = ""
+	}
+	return size, quality, max(n, 1)
+}
+
+// DefaultCostConfig 默认费用统计配置，价格表为空时由 NewCostMiddleware 使用内置的模型目录
+func DefaultCostConfig() (config CostMiddlewareConfig) {
+	return CostMiddlewareConfig{
+		Sink: NewMemorySink(),
+	}
+}
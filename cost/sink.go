@@ -0,0 +1,209 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-25 10:48:30
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-25 15:09:16
+ * @Description: 用量记录的存储
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package cost
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sync"
+)
+
+// UsageSink 用量记录的存储
+type UsageSink interface {
+	Record(ctx context.Context, record UsageRecord) (err error) // 保存用量记录
+}
+
+// UsageSinkFunc 用量记录的存储函数
+type UsageSinkFunc func(ctx context.Context, record UsageRecord) (err error)
+
+// Record 保存用量记录
+func (f UsageSinkFunc) Record(ctx context.Context, record UsageRecord) (err error) {
+	return f(ctx, record)
+}
+
+// MultiSink 将用量记录依次保存到多个存储，返回所有存储的错误
+func MultiSink(sinks ...UsageSink) (sink UsageSink) {
+	return UsageSinkFunc(func(ctx context.Context, record UsageRecord) (err error) {
+		var errs []error
+		for _, s := range sinks {
+			if e := s.Record(ctx, record); e != nil {
+				errs = append(errs, e)
+			}
+		}
+		return errors.Join(errs...)
+	})
+}
+
+// AggregateKey 聚合的维度
+type AggregateKey struct {
+	Tenant   string `json:"tenant,omitempty"`   // 租户
+	User     string `json:"user,omitempty"`     // 终端用户
+	Provider string `json:"provider"`           // 提供商
+	Model    string `json:"model"`              // 模型
+	Currency string `json:"currency,omitempty"` // 货币
+}
+
+// Aggregate 聚合的用量和费用
+type Aggregate struct {
+	AggregateKey
+	Requests int64   `json:"requests"` // 请求数
+	Usage    Usage   `json:"usage"`    // 累计用量
+	Cost     float64 `json:"cost"`     // 累计费用
+}
+
+// MemorySink 内存存储，按租户、用户、提供商、模型和货币聚合用量和费用（并发安全）
+type MemorySink struct {
+	mu         sync.RWMutex
+	aggregates map[AggregateKey]*Aggregate
+}
+
+// NewMemorySink 创建内存存储
+func NewMemorySink() (s *MemorySink) {
+	return &MemorySink{aggregates: make(map[AggregateKey]*Aggregate)}
+}
+
+// Record 保存用量记录
+func (s *MemorySink) Record(ctx context.Context, record UsageRecord) (err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := AggregateKey{
+		Tenant:   record.Tenant,
+		User:     record.User,
+		Provider: record.Provider,
+		Model:    record.Model,
+		Currency: record.Currency,
+	}
+	a, ok := s.aggregates[key]
+	if !ok {
+		a = &Aggregate{AggregateKey: key}
+		s.aggregates[key] = a
+	}
+	a.Requests++
+	a.Usage.Add(record.Usage)
+	a.Cost += record.Cost
+	return
+}
+
+// Aggregates 获取所有聚合结果，按租户、用户、提供商、模型和货币排序
+func (s *MemorySink) Aggregates() (aggregates []Aggregate) {
+	s.mu.RLock()
+	aggregates = make([]Aggregate, 0, len(s.aggregates))
+	for _, a := range s.aggregates {
+		aggregates = append(aggregates, *a)
+	}
+	s.mu.RUnlock()
+
+	slices.SortFunc(aggregates, func(a, b Aggregate) int {
+		return cmp.Or(
+			cmp.Compare(a.Tenant, b.Tenant),
+			cmp.Compare(a.User, b.User),
+			cmp.Compare(a.Provider, b.Provider),
+			cmp.Compare(a.Model, b.Model),
+			cmp.Compare(a.Currency, b.Currency),
+		)
+	})
+	return
+}
+
+// Costs 按货币汇总满足条件的费用，match 为 nil 时汇总全部
+func (s *MemorySink) Costs(match func(key AggregateKey) bool) (costs map[string]float64) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	costs = make(map[string]float64)
+	for key, a := range s.aggregates {
+		if match == nil || match(key) {
+			costs[key.Currency] += a.Cost
+		}
+	}
+	return
+}
+
+// Reset 清空聚合结果
+func (s *MemorySink) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.aggregates = make(map[AggregateKey]*Aggregate)
+}
+
+// JSONLSink JSONL 文件存储，每条用量记录写为一行 JSON（并发安全）
+type JSONLSink struct {
+	mu     sync.Mutex
+	w      io.Writer
+	closer io.Closer
+}
+
+// NewJSONLSink 创建 JSONL 文件存储，以追加方式写入 path，文件不存在时自动创建
+func NewJSONLSink(path string) (s *JSONLSink, err error) {
+	var f *os.File
+	if f, err = os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644); err != nil {
+		return nil, fmt.Errorf("failed to open usage file: %w", err)
+	}
+	return &JSONLSink{w: f, closer: f}, nil
+}
+
+// NewJSONLWriterSink 创建写入 w 的 JSONL 存储
+func NewJSONLWriterSink(w io.Writer) (s *JSONLSink) {
+	return &JSONLSink{w: w}
+}
+
+// Record 保存用量记录
+func (s *JSONLSink) Record(ctx context.Context, record UsageRecord) (err error) {
+	var data []byte
+	if data, err = json.Marshal(record); err != nil {
+		return
+	}
+	data = append(data, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err = s.w.Write(data)
+	return
+}
+
+// Close 关闭文件
+func (s *JSONLSink) Close() (err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closer != nil {
+		return s.closer.Close()
+	}
+	return
+}
+
+// ReadJSONL 读取 JSONL 格式的用量记录，每读取一条调用一次 fn
+func ReadJSONL(r io.Reader, fn func(record UsageRecord) (err error)) (err error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var record UsageRecord
+		if err = json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return fmt.Errorf("unmarshal usage record at line %d failed: %w", line, err)
+		}
+		if err = fn(record); err != nil {
+			return
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,148 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-25 09:35:12
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-08-04 14:21:50
+ * @Description: 用量记录与费用计算
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package cost
+
+import (
+	"context"
+	"time"
+
+	"github.com/Mrzhouyl/go-aisdk/catalog"
+	"github.com/Mrzhouyl/go-aisdk/consts"
+	"github.com/Mrzhouyl/go-aisdk/models"
+)
+
+// tenantKey 租户在上下文中的键
+type tenantKey struct{}
+
+// WithTenant 设置租户（如团队、部门）到上下文，用于按租户分摊费用
+func WithTenant(ctx context.Context, tenant string) (newCtx context.Context) {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// TenantFromContext 从上下文中获取租户
+func TenantFromContext(ctx context.Context) (tenant string) {
+	tenant, _ = ctx.Value(tenantKey{}).(string)
+	return
+}
+
+// PriceTable 价格表，*catalog.Catalog 实现了该接口
+type PriceTable interface {
+	Price(provider consts.Provider, model string) (pricing catalog.Pricing, ok bool) // 获取模型价格
+}
+
+// Usage 用量，各类 token 数量包含在输入或输出 token 总数中
+type Usage struct {
+	InputTokens       int    `json:"input_tokens,omitempty"`        // 输入 token 总数
+	CachedInputTokens int    `json:"cached_input_tokens,omitempty"` // 命中缓存的输入 token 数
+	AudioInputTokens  int    `json:"audio_input_tokens,omitempty"`  // 音频输入 token 数
+	ImageInputTokens  int    `json:"image_input_tokens,omitempty"`  // 图像输入 token 数（图像生成）
+	OutputTokens      int    `json:"output_tokens,omitempty"`       // 输出 token 总数
+	ReasoningTokens   int    `json:"reasoning_tokens,omitempty"`    // 推理 token 数
+	AudioOutputTokens int    `json:"audio_output_tokens,omitempty"` // 音频输出 token 数
+	ImageOutputTokens int    `json:"image_output_tokens,omitempty"` // 图像输出 token 数（图像生成）
+	Images            int    `json:"images,omitempty"`              // 生成的图像数量
+	ImageSize         string `json:"image_size,omitempty"`          // 图像尺寸，用于按张计价
+	ImageQuality      string `json:"image_quality,omitempty"`       // 图像质量，用于按张计价
+}
+
+// Add 累加用量，图像尺寸和质量不累加
+func (u *Usage) Add(other Usage) {
+	u.InputTokens += other.InputTokens
+	u.CachedInputTokens += other.CachedInputTokens
+	u.AudioInputTokens += other.AudioInputTokens
+	u.ImageInputTokens += other.ImageInputTokens
+	u.OutputTokens += other.OutputTokens
+	u.ReasoningTokens += other.ReasoningTokens
+	u.AudioOutputTokens += other.AudioOutputTokens
+	u.ImageOutputTokens += other.ImageOutputTokens
+	u.Images += other.Images
+}
+
+// ChatUsage 将聊天用量转换为 Usage，各提供商的用量字段由 models.ChatUsage.Normalized 归一化
+func ChatUsage(usage *models.ChatUsage) (u Usage) {
+	n := usage.Normalized()
+	return Usage{
+		InputTokens:       n.InputTokens,
+		CachedInputTokens: n.CachedInputTokens,
+		AudioInputTokens:  n.AudioInputTokens,
+		OutputTokens:      n.OutputTokens,
+		ReasoningTokens:   n.ReasoningTokens,
+		AudioOutputTokens: n.AudioOutputTokens,
+	}
+}
+
+// ImageUsage 将图像生成用量转换为 Usage
+func ImageUsage(usage *models.ImageUsage, images int, size, quality string) (u Usage) {
+	u.Images, u.ImageSize, u.ImageQuality = images, size, quality
+	if usage == nil {
+		return
+	}
+	u.InputTokens = usage.InputTokens
+	u.OutputTokens = usage.OutputTokens
+	u.ImageOutputTokens = usage.OutputTokens
+	if d := usage.InputTokensDetails; d != nil {
+		u.ImageInputTokens = d.ImageTokens
+	}
+	return
+}
+
+// Calculate 按价格计算费用，返回值的货币为 pricing.Currency
+//
+// 有输出 token 时按 token 计价，否则按 PerImage 计算每张图像的价格
+func Calculate(pricing catalog.Pricing, usage Usage) (cost float64) {
+	// 输入
+	textInput := max(usage.InputTokens-usage.CachedInputTokens-usage.AudioInputTokens-usage.ImageInputTokens, 0)
+	cost += float64(textInput) * pricing.Input
+	cost += float64(usage.CachedInputTokens) * or(pricing.CachedInput, pricing.Input)
+	cost += float64(usage.AudioInputTokens) * or(pricing.AudioInput, pricing.Input)
+	cost += float64(usage.ImageInputTokens) * or(pricing.ImageInput, pricing.Input)
+	// 输出
+	textOutput := max(usage.OutputTokens-usage.ReasoningTokens-usage.AudioOutputTokens-usage.ImageOutputTokens, 0)
+	cost += float64(textOutput) * pricing.Output
+	cost += float64(usage.ReasoningTokens) * or(pricing.Reasoning, pricing.Output)
+	cost += float64(usage.AudioOutputTokens) * or(pricing.AudioOutput, pricing.Output)
+	cost += float64(usage.ImageOutputTokens) * or(pricing.ImageOutput, pricing.Output)
+	cost /= 1e6
+	// 按张计价的图像
+	if usage.Images > 0 && usage.OutputTokens == 0 {
+		if price, ok := pricing.ImagePrice(usage.ImageQuality, usage.ImageSize); ok {
+			cost += float64(usage.Images) * price
+		}
+	}
+	return
+}
+
+// or 返回第一个非零的价格
+func or(price, fallback float64) (p float64) {
+	if price != 0 {
+		return price
+	}
+	return fallback
+}
+
+// UsageRecord 一次调用的用量记录
+type UsageRecord struct {
+	RequestID    string    `json:"request_id"`              // 请求ID
+	Time         time.Time `json:"time"`                    // 记录时间
+	Tenant       string    `json:"tenant,omitempty"`        // 租户
+	User         string    `json:"user,omitempty"`          // 终端用户
+	Provider     string    `json:"provider"`                // 提供商
+	ModelType    string    `json:"model_type,omitempty"`    // 模型类型
+	Model        string    `json:"model"`                   // 模型
+	Method       string    `json:"method"`                  // 方法名称
+	Stream       bool      `json:"stream,omitempty"`        // 是否是流式传输
+	CacheHit     bool      `json:"cache_hit,omitempty"`     // 是否命中 SDK 的响应缓存，命中时费用为 0
+	Shared       bool      `json:"shared,omitempty"`        // 是否与其他相同的并发请求合并，共享发起方的上游响应，合并时费用为 0
+	MissingUsage bool      `json:"missing_usage,omitempty"` // 响应中没有用量信息（如流式传输未设置 include_usage 或中途出错）
+	Priced       bool      `json:"priced"`                  // 是否找到了模型价格
+	Usage        Usage     `json:"usage"`                   // 用量
+	Cost         float64   `json:"cost"`                    // 费用
+	Currency     string    `json:"currency,omitempty"`      // 货币
+}
@@ -0,0 +1,41 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-13 10:14:27
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-13 10:16:45
+ * @Description:
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package aisdk
+
+import (
+	"context"
+
+	"github.com/Mrzhouyl/go-aisdk/consts"
+	"github.com/Mrzhouyl/go-aisdk/core"
+	"github.com/Mrzhouyl/go-aisdk/httpclient"
+	"github.com/Mrzhouyl/go-aisdk/models"
+)
+
+// CreateEmbeddings 创建嵌入向量
+func (c *SDKClient) CreateEmbeddings(ctx context.Context, request models.EmbeddingRequest, opts ...httpclient.HTTPClientOption) (response models.EmbeddingResponse, err error) {
+	// 定义处理函数
+	handler := func(ctx context.Context, ps core.ProviderService, req any) (resp any, err error) {
+		embeddingReq := req.(models.EmbeddingRequest)
+		// 创建嵌入向量
+		return ps.CreateEmbeddings(ctx, embeddingReq, opts...)
+	}
+	// 处理请求
+	var resp any
+	if resp, err = c.handlerRequest(ctx, models.ModelInfo{
+		Provider:  request.Provider,
+		ModelType: consts.EmbedModel,
+		Model:     request.Model,
+	}, request.UserInfo, "CreateEmbeddings", request, handler); err != nil {
+		return
+	}
+	// 返回结果
+	response = resp.(models.EmbeddingResponse)
+	return
+}
@@ -2,7 +2,7 @@
  * @Author: liusuxian 382185882@qq.com
  * @Date: 2025-07-07 21:01:34
  * @LastEditors: liusuxian 382185882@qq.com
- * @LastEditTime: 2025-07-07 23:16:15
+ * @LastEditTime: 2025-07-30 16:12:08
  * @Description:
  *
  * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
@@ -14,6 +14,8 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"strings"
+	"time"
 
 	"github.com/Mrzhouyl/go-aisdk/httpclient"
 )
@@ -28,6 +30,35 @@ var (
 	ErrCompletionStreamNotSupported = errors.New("streaming is not supported with this method, please use CreateChatCompletionStream") // 流式传输不支持
 	ErrTooManyEmptyStreamMessages   = httpclient.ErrTooManyEmptyStreamMessages                                                         // 流式传输发送了太多空消息
 	ErrStreamReturnIntervalTimeout  = httpclient.ErrStreamReturnIntervalTimeout                                                        // 流式传输返回间隔超时
+	ErrCircuitOpen                  = httpclient.ErrCircuitOpen                                                                        // 熔断器处于打开状态
+	ErrRateLimited                  = httpclient.ErrRateLimited                                                                        // 超出客户端限流
+	ErrStreamClosed                 = httpclient.ErrStreamClosed                                                                       // 流式传输在结束前被关闭
+	ErrEmptyEmbedding               = errors.New("embedding response is empty")                                                        // 嵌入向量响应为空
+	ErrToolNotFound                 = errors.New("tool not found")                                                                     // 工具不存在
+	ErrMaxStepsExceeded             = errors.New("agent exceeded the maximum number of steps")                                         // 智能体超出最大步数
+	ErrRefusal                      = errors.New("model refused to respond")                                                           // 模型拒绝响应
+	ErrInvalidStructuredOutput      = errors.New("invalid structured output")                                                          // 结构化输出不合法
+	ErrEncodingNotFound             = errors.New("tokenizer encoding not found")                                                       // 分词编码不存在
+	ErrModelInfoNotFound            = errors.New("model info not found")                                                               // 模型信息不存在
+	ErrBudgetExceeded               = errors.New("budget exceeded")                                                                    // 超出预算
+	ErrUnsupportedParameter         = errors.New("unsupported parameter")                                                              // 参数不支持
+)
+
+// ErrorKind 错误分类
+type ErrorKind = httpclient.ErrorKind
+
+// 错误分类，可以作为 errors.Is 的目标判断 SDK 返回的错误，如 errors.Is(err, errors.ErrRateLimit)
+var (
+	ErrAuth                  error = httpclient.ErrorKindAuth                  // 认证失败
+	ErrPermission            error = httpclient.ErrorKindPermission            // 无权限访问
+	ErrRateLimit             error = httpclient.ErrorKindRateLimit             // 请求频率超出限制
+	ErrQuotaExhausted        error = httpclient.ErrorKindQuotaExhausted        // 额度用尽或余额不足
+	ErrContextLengthExceeded error = httpclient.ErrorKindContextLengthExceeded // 超出模型上下文长度
+	ErrContentFiltered       error = httpclient.ErrorKindContentFiltered       // 内容审核不通过
+	ErrInvalidRequest        error = httpclient.ErrorKindInvalidRequest        // 请求参数错误
+	ErrModelNotFound         error = httpclient.ErrorKindModelNotFound         // 模型不存在
+	ErrServerError           error = httpclient.ErrorKindServerError           // 服务端错误
+	ErrTimeout               error = httpclient.ErrorKindTimeout               // 请求超时
 )
 
 // WrapFailedToCreateConfigManager 包装创建配置管理器失败错误
@@ -68,6 +99,31 @@ func WrapMethodNotSupportedByProvider(provider fmt.Stringer, method string) (err
 	return fmt.Errorf("provider [%s] does not support the [%s] method: %w", provider.String(), method, ErrMethodNotSupported)
 }
 
+// WrapToolNotFound 包装工具不存在错误
+func WrapToolNotFound(name string) (err error) {
+	return fmt.Errorf("tool [%s] not found: %w", name, ErrToolNotFound)
+}
+
+// WrapEncodingNotFound 包装分词编码不存在错误
+func WrapEncodingNotFound(name string) (err error) {
+	return fmt.Errorf("tokenizer encoding [%s] not found: %w", name, ErrEncodingNotFound)
+}
+
+// WrapModelInfoNotFound 包装模型信息不存在错误
+func WrapModelInfoNotFound(provider fmt.Stringer, model string) (err error) {
+	return fmt.Errorf("provider [%s] model [%s] info not found: %w", provider.String(), model, ErrModelInfoNotFound)
+}
+
+// WrapBudgetExceeded 包装超出预算错误
+func WrapBudgetExceeded(scope, id, window string, limit, spent, estimate float64, currency string) (err error) {
+	return fmt.Errorf("%s [%s] %s budget %.6f %s exhausted (spent %.6f, estimated %.6f): %w", scope, id, window, limit, currency, spent, estimate, ErrBudgetExceeded)
+}
+
+// WrapUnsupportedParameter 包装参数不支持错误
+func WrapUnsupportedParameter(provider fmt.Stringer, model string, issues []string) (err error) {
+	return fmt.Errorf("provider [%s] model [%s] does not support: %s: %w", provider.String(), model, strings.Join(issues, "; "), ErrUnsupportedParameter)
+}
+
 // IsFailedToCreateConfigManagerError 判断是否是创建配置管理器失败错误
 func IsFailedToCreateConfigManagerError(err error) (is bool) {
 	return errors.Is(err, ErrFailedToCreateConfigManager)
@@ -113,6 +169,61 @@ func IsStreamReturnIntervalTimeoutError(err error) (is bool) {
 	return errors.Is(err, ErrStreamReturnIntervalTimeout)
 }
 
+// IsCircuitOpenError 判断是否是熔断器打开错误
+func IsCircuitOpenError(err error) (is bool) {
+	return errors.Is(err, ErrCircuitOpen)
+}
+
+// IsRateLimitedError 判断是否是超出客户端限流错误
+func IsRateLimitedError(err error) (is bool) {
+	return errors.Is(err, ErrRateLimited)
+}
+
+// IsStreamClosedError 判断是否是流式传输在结束前被关闭错误
+func IsStreamClosedError(err error) (is bool) {
+	return errors.Is(err, ErrStreamClosed)
+}
+
+// IsToolNotFoundError 判断是否是工具不存在错误
+func IsToolNotFoundError(err error) (is bool) {
+	return errors.Is(err, ErrToolNotFound)
+}
+
+// IsMaxStepsExceededError 判断是否是智能体超出最大步数错误
+func IsMaxStepsExceededError(err error) (is bool) {
+	return errors.Is(err, ErrMaxStepsExceeded)
+}
+
+// IsRefusalError 判断是否是模型拒绝响应错误
+func IsRefusalError(err error) (is bool) {
+	return errors.Is(err, ErrRefusal)
+}
+
+// IsInvalidStructuredOutputError 判断是否是结构化输出不合法错误
+func IsInvalidStructuredOutputError(err error) (is bool) {
+	return errors.Is(err, ErrInvalidStructuredOutput)
+}
+
+// IsEncodingNotFoundError 判断是否是分词编码不存在错误
+func IsEncodingNotFoundError(err error) (is bool) {
+	return errors.Is(err, ErrEncodingNotFound)
+}
+
+// IsModelInfoNotFoundError 判断是否是模型信息不存在错误
+func IsModelInfoNotFoundError(err error) (is bool) {
+	return errors.Is(err, ErrModelInfoNotFound)
+}
+
+// IsBudgetExceededError 判断是否是超出预算错误
+func IsBudgetExceededError(err error) (is bool) {
+	return errors.Is(err, ErrBudgetExceeded)
+}
+
+// IsUnsupportedParameterError 判断是否是参数不支持错误
+func IsUnsupportedParameterError(err error) (is bool) {
+	return errors.Is(err, ErrUnsupportedParameter)
+}
+
 // IsCanceledError 判断是否是取消错误
 func IsCanceledError(err error) (is bool) {
 	return errors.Is(err, context.Canceled)
@@ -140,6 +251,32 @@ func (e *SDKError) Error() (errStr string) {
 	return fmt.Sprintf("request_id: %s, error: %v", e.RequestID, e.Err)
 }
 
+// Unwrap 解包错误
+func (e *SDKError) Unwrap() (err error) {
+	return e.Err
+}
+
+// Is 判断原始错误的分类是否与目标一致，使超时等没有实现 Is 方法的错误也可以通过 errors.Is 判断分类
+func (e *SDKError) Is(target error) (ok bool) {
+	kind, isKind := target.(ErrorKind)
+	return isKind && httpclient.ClassifyError(e.Err) == kind
+}
+
+// Is 判断错误链中是否存在与目标一致的错误，等同于标准库的 errors.Is，目标为错误分类时按分类判断
+func Is(err, target error) (is bool) {
+	return errors.Is(err, target)
+}
+
+// RetryAfter 获取提供商通过 Retry-After 或 x-ratelimit-reset-* 响应头建议的重试等待时间，没有时返回 0
+func RetryAfter(err error) (delay time.Duration) {
+	return httpclient.RetryAfter(err)
+}
+
+// Kind 获取错误分类，如认证失败、超出限流、额度用尽、超出上下文长度、内容审核不通过等，无法识别时返回 "unknown"
+func Kind(err error) (kind ErrorKind) {
+	return httpclient.ClassifyError(err)
+}
+
 // RequestID 获取请求ID
 func RequestID(err error) (requestId string) {
 	if err == nil {
@@ -2,7 +2,7 @@
  * @Author: liusuxian 382185882@qq.com
  * @Date: 2025-05-28 17:56:51
  * @LastEditors: liusuxian 382185882@qq.com
- * @LastEditTime: 2025-07-07 22:22:27
+ * @LastEditTime: 2025-07-30 16:12:08
  * @Description:
  *
  * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
@@ -13,24 +13,31 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
 	"strings"
 )
 
 var (
 	ErrTooManyEmptyStreamMessages  = errors.New("stream has sent too many empty messages") // 流式传输发送了太多空消息
 	ErrStreamReturnIntervalTimeout = errors.New("stream return interval timeout")          // 流式传输返回间隔超时
+	ErrCircuitOpen                 = errors.New("circuit breaker is open")                 // 熔断器处于打开状态
+	ErrRateLimited                 = errors.New("client rate limit exceeded")              // 超出客户端限流
+	ErrStreamClosed                = errors.New("stream closed before completion")         // 流式传输在结束前被关闭
 )
 
 // APIError API错误信息
 type APIError struct {
-	Code           any         `json:"code,omitempty"`
-	Message        string      `json:"message"`
-	RequestId      string      `json:"request_id,omitempty"`
-	Param          *string     `json:"param,omitempty"`
-	Type           string      `json:"type"`
-	HTTPStatus     string      `json:"-"`
-	HTTPStatusCode int         `json:"-"`
-	InnerError     *InnerError `json:"innererror,omitempty"`
+	Code           any            `json:"code,omitempty"`
+	Message        string         `json:"message"`
+	RequestId      string         `json:"request_id,omitempty"`
+	Param          *string        `json:"param,omitempty"`
+	Type           string         `json:"type"`
+	HTTPStatus     string         `json:"-"`
+	HTTPStatusCode int            `json:"-"`
+	InnerError     *InnerError    `json:"innererror,omitempty"`
+	Provider       string         `json:"-"` // 返回错误的提供商，用于匹配提供商的错误码映射规则
+	Header         http.Header    `json:"-"` // 响应头
+	RateLimit      *RateLimitInfo `json:"-"` // 响应头中的限流信息，没有时为 nil
 }
 
 // InnerError 内部错误信息
@@ -87,10 +94,13 @@ type ContentFilterResults struct {
 
 // RequestError 请求错误
 type RequestError struct {
-	HTTPStatus     string // HTTP 状态描述
-	HTTPStatusCode int    // HTTP 状态码
-	Err            error  // 错误信息
-	Body           []byte // 响应体
+	HTTPStatus     string         // HTTP 状态描述
+	HTTPStatusCode int            // HTTP 状态码
+	Err            error          // 错误信息
+	Body           []byte         // 响应体
+	Provider       string         // 返回错误的提供商
+	Header         http.Header    // 响应头
+	RateLimit      *RateLimitInfo // 响应头中的限流信息，没有时为 nil
 }
 
 // ErrorResponse 错误响应
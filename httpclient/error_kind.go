@@ -0,0 +1,208 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-29 10:26:47
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-29 15:08:12
+ * @Description: 统一错误分类
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+)
+
+// ErrorKind 错误分类，实现了 error 接口，可以作为 errors.Is 的目标
+type ErrorKind string
+
+const (
+	ErrorKindUnknown               ErrorKind = "unknown"                 // 未知错误
+	ErrorKindAuth                  ErrorKind = "auth"                    // 认证失败，如 API Key 无效
+	ErrorKindPermission            ErrorKind = "permission"              // 无权限访问，如模型未开通、地区不支持
+	ErrorKindRateLimit             ErrorKind = "rate_limit"              // 请求频率超出限制
+	ErrorKindQuotaExhausted        ErrorKind = "quota_exhausted"         // 额度用尽或余额不足
+	ErrorKindContextLengthExceeded ErrorKind = "context_length_exceeded" // 超出模型上下文长度
+	ErrorKindContentFiltered       ErrorKind = "content_filtered"        // 内容审核不通过
+	ErrorKindInvalidRequest        ErrorKind = "invalid_request"         // 请求参数错误
+	ErrorKindModelNotFound         ErrorKind = "model_not_found"         // 模型不存在
+	ErrorKindServerError           ErrorKind = "server_error"            // 服务端错误
+	ErrorKindTimeout               ErrorKind = "timeout"                 // 请求超时
+)
+
+// Error 实现 error 接口的方法
+func (k ErrorKind) Error() (s string) {
+	return string(k)
+}
+
+// Retryable 是否为临时性错误，稍后重试可能成功
+func (k ErrorKind) Retryable() (ok bool) {
+	switch k {
+	case ErrorKindRateLimit, ErrorKindServerError, ErrorKindTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// ErrorRule 提供商错误码映射规则，Code 和 Message 同时设置时需同时匹配
+type ErrorRule struct {
+	Code    string    // 错误码，不区分大小写地匹配 code、type 或 innererror.code，也匹配以 "Code." 开头的子错误码
+	Message string    // 错误信息中包含的文本，不区分大小写
+	Kind    ErrorKind // 错误分类
+}
+
+var (
+	errorRulesMu sync.RWMutex
+	errorRules   = map[string][]ErrorRule{} // 提供商 -> 错误码映射规则
+)
+
+// commonErrorRules 各提供商通用的错误码映射规则，在提供商规则之后匹配
+var commonErrorRules = []ErrorRule{
+	{Code: "context_length_exceeded", Kind: ErrorKindContextLengthExceeded},
+	{Message: "maximum context length", Kind: ErrorKindContextLengthExceeded},
+	{Code: "content_filter", Kind: ErrorKindContentFiltered},
+	{Code: "insufficient_quota", Kind: ErrorKindQuotaExhausted},
+	{Code: "invalid_api_key", Kind: ErrorKindAuth},
+	{Code: "model_not_found", Kind: ErrorKindModelNotFound},
+	{Code: "rate_limit_exceeded", Kind: ErrorKindRateLimit},
+}
+
+// RegisterErrorRules 注册提供商的错误码映射规则，按注册顺序匹配
+func RegisterErrorRules(provider string, rules ...ErrorRule) {
+	errorRulesMu.Lock()
+	defer errorRulesMu.Unlock()
+	errorRules[provider] = append(errorRules[provider], rules...)
+}
+
+// ClassifyError 获取错误分类，无法识别时返回 ErrorKindUnknown
+func ClassifyError(err error) (kind ErrorKind) {
+	if err == nil {
+		return ErrorKindUnknown
+	}
+	// 提供商返回的错误
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Kind()
+	}
+	var requestErr *RequestError
+	if errors.As(err, &requestErr) && requestErr.HTTPStatusCode > 0 {
+		return requestErr.Kind()
+	}
+	// 客户端错误
+	if errors.Is(err, ErrRateLimited) {
+		return ErrorKindRateLimit
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ErrStreamReturnIntervalTimeout) {
+		return ErrorKindTimeout
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return ErrorKindTimeout
+	}
+	return ErrorKindUnknown
+}
+
+// Kind 获取错误分类，依次按提供商规则、通用规则和 HTTP 状态码判断
+func (e *APIError) Kind() (kind ErrorKind) {
+	var codes []string
+	if e.Code != nil {
+		codes = append(codes, fmt.Sprint(e.Code))
+	}
+	if e.Type != "" {
+		codes = append(codes, e.Type)
+	}
+	if e.InnerError != nil && e.InnerError.Code != "" {
+		codes = append(codes, e.InnerError.Code)
+	}
+
+	errorRulesMu.RLock()
+	providerRules := errorRules[e.Provider]
+	errorRulesMu.RUnlock()
+	for _, rules := range [][]ErrorRule{providerRules, commonErrorRules} {
+		for _, rule := range rules {
+			if rule.match(codes, e.Message) {
+				return rule.Kind
+			}
+		}
+	}
+	return kindFromStatus(e.HTTPStatusCode)
+}
+
+// Is 判断错误分类是否与目标一致
+func (e *APIError) Is(target error) (ok bool) {
+	kind, isKind := target.(ErrorKind)
+	return isKind && e.Kind() == kind
+}
+
+// Kind 获取错误分类，按 HTTP 状态码判断
+func (e *RequestError) Kind() (kind ErrorKind) {
+	return kindFromStatus(e.HTTPStatusCode)
+}
+
+// Is 判断错误分类是否与目标一致
+func (e *RequestError) Is(target error) (ok bool) {
+	kind, isKind := target.(ErrorKind)
+	return isKind && e.HTTPStatusCode > 0 && e.Kind() == kind
+}
+
+// match 判断错误码和错误信息是否匹配规则
+func (r ErrorRule) match(codes []string, message string) (ok bool) {
+	if r.Code == "" && r.Message == "" {
+		return false
+	}
+	if r.Code != "" && !matchCode(codes, r.Code) {
+		return false
+	}
+	if r.Message != "" && !strings.Contains(strings.ToLower(message), strings.ToLower(r.Message)) {
+		return false
+	}
+	return true
+}
+
+// matchCode 判断错误码是否匹配，支持 "Throttling.RateQuota" 这类带子错误码的形式
+func matchCode(codes []string, code string) (ok bool) {
+	for _, c := range codes {
+		if strings.EqualFold(c, code) {
+			return true
+		}
+		if len(c) > len(code) && c[len(code)] == '.' && strings.EqualFold(c[:len(code)], code) {
+			return true
+		}
+	}
+	return false
+}
+
+// kindFromStatus 根据 HTTP 状态码获取错误分类
+func kindFromStatus(statusCode int) (kind ErrorKind) {
+	switch statusCode {
+	case 401:
+		return ErrorKindAuth
+	case 402:
+		return ErrorKindQuotaExhausted
+	case 403:
+		return ErrorKindPermission
+	case 404:
+		return ErrorKindModelNotFound
+	case 408, 504, 522, 524, 598, 599:
+		return ErrorKindTimeout
+	case 429, 509:
+		return ErrorKindRateLimit
+	// 代理认证、资源冲突、资源锁定、依赖失败等临时性错误按服务端错误处理
+	case 407, 409, 421, 423, 424, 425, 449, 511:
+		return ErrorKindServerError
+	}
+	switch {
+	case statusCode >= 500:
+		return ErrorKindServerError
+	case statusCode >= 400:
+		return ErrorKindInvalidRequest
+	default:
+		return ErrorKindUnknown
+	}
+}
@@ -0,0 +1,202 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-29 14:40:18
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-29 15:21:36
+ * @Description:
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package httpclient_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Mrzhouyl/go-aisdk/errors"
+	"github.com/Mrzhouyl/go-aisdk/httpclient"
+	_ "github.com/Mrzhouyl/go-aisdk/providers"
+)
+
+// sendError 请求返回指定状态码和响应体的服务，返回错误
+func sendError(t *testing.T, provider string, statusCode int, body string) (err error) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(statusCode)
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	client := httpclient.NewHTTPClient(server.URL)
+	ctx := httpclient.SetRequestInfo(context.Background(), &httpclient.RequestInfo{Provider: provider})
+	req, err := client.NewRequest(ctx, http.MethodPost, server.URL)
+	if err != nil {
+		t.Fatalf("NewRequest() error = %v", err)
+	}
+	if _, err = client.SendRequestRaw(req); err == nil {
+		t.Fatal("expected error but got nil")
+	}
+	return
+}
+
+func TestErrorKind(t *testing.T) {
+	tests := []struct {
+		name       string
+		provider   string
+		statusCode int
+		body       string
+		want       httpclient.ErrorKind
+		retryable  bool
+	}{
+		{
+			name:       "openai invalid api key",
+			provider:   "openai",
+			statusCode: 401,
+			body:       `{"error":{"message":"Incorrect API key provided","type":"invalid_request_error","param":null,"code":"invalid_api_key"}}`,
+			want:       httpclient.ErrorKindAuth,
+		},
+		{
+			name:       "openai insufficient quota is not retried",
+			provider:   "openai",
+			statusCode: 429,
+			body:       `{"error":{"message":"You exceeded your current quota","type":"insufficient_quota","param":null,"code":"insufficient_quota"}}`,
+			want:       httpclient.ErrorKindQuotaExhausted,
+		},
+		{
+			name:       "openai rate limit",
+			provider:   "openai",
+			statusCode: 429,
+			body:       `{"error":{"message":"Rate limit reached for requests","type":"requests","param":null,"code":"rate_limit_exceeded"}}`,
+			want:       httpclient.ErrorKindRateLimit,
+			retryable:  true,
+		},
+		{
+			name:       "openai context length",
+			provider:   "openai",
+			statusCode: 400,
+			body:       `{"error":{"message":"This model's maximum context length is 128000 tokens.","type":"invalid_request_error","param":"messages","code":"context_length_exceeded"}}`,
+			want:       httpclient.ErrorKindContextLengthExceeded,
+		},
+		{
+			name:       "openai content filter",
+			provider:   "openai",
+			statusCode: 400,
+			body:       `{"error":{"message":"Your request was rejected","type":"invalid_request_error","param":null,"code":"content_policy_violation"}}`,
+			want:       httpclient.ErrorKindContentFiltered,
+		},
+		{
+			name:       "deepseek insufficient balance",
+			provider:   "deepseek",
+			statusCode: 402,
+			body:       `{"error":{"message":"Insufficient Balance","type":"unknown_error","param":null,"code":"invalid_request_error"}}`,
+			want:       httpclient.ErrorKindQuotaExhausted,
+		},
+		{
+			name:       "deepseek content risk",
+			provider:   "deepseek",
+			statusCode: 400,
+			body:       `{"error":{"message":"Content Exists Risk","type":"invalid_request_error","param":null,"code":"invalid_request_error"}}`,
+			want:       httpclient.ErrorKindContentFiltered,
+		},
+		{
+			name:       "deepseek server overloaded",
+			provider:   "deepseek",
+			statusCode: 503,
+			body:       `{"error":{"message":"Server overloaded","type":"service_unavailable_error","param":null,"code":"service_unavailable"}}`,
+			want:       httpclient.ErrorKindServerError,
+			retryable:  true,
+		},
+		{
+			name:       "alibl data inspection",
+			provider:   "alibl",
+			statusCode: 400,
+			body:       `{"code":"DataInspectionFailed","message":"Input data may contain inappropriate content.","request_id":"b1a2"}`,
+			want:       httpclient.ErrorKindContentFiltered,
+		},
+		{
+			name:       "alibl compatible mode data inspection",
+			provider:   "alibl",
+			statusCode: 400,
+			body:       `{"error":{"code":"data_inspection_failed","message":"Input data may contain inappropriate content.","type":"data_inspection_failed"},"request_id":"c3d4"}`,
+			want:       httpclient.ErrorKindContentFiltered,
+		},
+		{
+			name:       "alibl throttling sub code",
+			provider:   "alibl",
+			statusCode: 429,
+			body:       `{"code":"Throttling.RateQuota","message":"Requests rate limit exceeded, please try again later.","request_id":"e5f6"}`,
+			want:       httpclient.ErrorKindRateLimit,
+			retryable:  true,
+		},
+		{
+			name:       "alibl arrearage",
+			provider:   "alibl",
+			statusCode: 400,
+			body:       `{"code":"Arrearage","message":"Access denied, please make sure your account is in good standing.","request_id":"a7b8"}`,
+			want:       httpclient.ErrorKindQuotaExhausted,
+		},
+		{
+			name:       "alibl input length",
+			provider:   "alibl",
+			statusCode: 400,
+			body:       `{"code":"InvalidParameter","message":"Range of input length should be [1, 30720]","request_id":"c9d0"}`,
+			want:       httpclient.ErrorKindContextLengthExceeded,
+		},
+		{
+			name:       "unparsable body falls back to status",
+			provider:   "openai",
+			statusCode: 504,
+			body:       "gateway timeout",
+			want:       httpclient.ErrorKindTimeout,
+			retryable:  true,
+		},
+		{
+			name:       "unknown provider falls back to status",
+			provider:   "unknown",
+			statusCode: 403,
+			body:       `{"error":{"message":"forbidden","type":"forbidden"}}`,
+			want:       httpclient.ErrorKindPermission,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 模拟重试中间件和 SDK 对错误的包装
+			err := sendError(t, tt.provider, tt.statusCode, tt.body)
+			err = &errors.SDKError{RequestID: "test", Err: fmt.Errorf("after 0 attempts, last error: %w", err)}
+			if got := errors.Kind(err); got != tt.want {
+				t.Errorf("Kind() = %s, want %s", got, tt.want)
+			}
+			if !errors.Is(err, tt.want) {
+				t.Errorf("errors.Is(err, %s) = false", tt.want)
+			}
+			if errors.Is(err, errors.ErrInvalidRequest) != (tt.want == httpclient.ErrorKindInvalidRequest) {
+				t.Errorf("errors.Is(err, ErrInvalidRequest) mismatch for %s", tt.want)
+			}
+			if got := httpclient.DefaultRetryCondition(0, err); got != tt.retryable {
+				t.Errorf("DefaultRetryCondition() = %v, want %v", got, tt.retryable)
+			}
+			if got := httpclient.DefaultCircuitBreakerCondition(err); got != tt.retryable {
+				t.Errorf("DefaultCircuitBreakerCondition() = %v, want %v", got, tt.retryable)
+			}
+		})
+	}
+}
+
+func TestErrorKind_ClientErrors(t *testing.T) {
+	deadline := &errors.SDKError{Err: context.DeadlineExceeded}
+	if errors.Kind(deadline) != httpclient.ErrorKindTimeout || !errors.Is(deadline, errors.ErrTimeout) {
+		t.Errorf("deadline exceeded should be classified as timeout, got %s", errors.Kind(deadline))
+	}
+	// 客户端限流归为 rate_limit，但不由重试中间件重试
+	if errors.Kind(httpclient.ErrRateLimited) != httpclient.ErrorKindRateLimit || httpclient.DefaultRetryCondition(0, httpclient.ErrRateLimited) {
+		t.Error("client rate limit should be classified as rate_limit and not retried")
+	}
+	if kind := errors.Kind(fmt.Errorf("boom")); kind != httpclient.ErrorKindUnknown {
+		t.Errorf("Kind() = %s, want unknown", kind)
+	}
+	if errors.Is(&errors.SDKError{Err: context.Canceled}, errors.ErrTimeout) {
+		t.Error("canceled should not be classified as timeout")
+	}
+}
@@ -2,7 +2,7 @@
  * @Author: liusuxian 382185882@qq.com
  * @Date: 2025-05-28 17:56:51
  * @LastEditors: liusuxian 382185882@qq.com
- * @LastEditTime: 2025-07-03 16:42:12
+ * @LastEditTime: 2025-07-31 17:32:45
  * @Description:
  *
  * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
@@ -10,12 +10,12 @@
 package httpclient
 
 import (
-	"bufio"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"slices"
 	"strings"
 	"time"
 )
@@ -65,6 +65,18 @@ func (doer *DefaultHTTPDoer) Do(req *http.Request) (resp *http.Response, err err
 	return doer.client.Do(req)
 }
 
+// RoundTripFunc 发送 HTTP 请求的函数
+type RoundTripFunc func(req *http.Request) (resp *http.Response, err error)
+
+// RoundTripInterceptor HTTP 往返拦截器，可以在每次发送 HTTP 请求前后执行操作，如链路追踪、注入请求头
+type RoundTripInterceptor func(req *http.Request, next RoundTripFunc) (resp *http.Response, err error)
+
+// WithRoundTripInterceptor 将 HTTP 往返拦截器添加到上下文中，使用该上下文发送的请求都会经过拦截器，先添加的拦截器位于外层
+func WithRoundTripInterceptor(ctx context.Context, interceptor RoundTripInterceptor) (newCtx context.Context) {
+	interceptors, _ := ctx.Value(roundTripInterceptorsKey).([]RoundTripInterceptor)
+	return context.WithValue(ctx, roundTripInterceptorsKey, append(slices.Clip(interceptors), interceptor))
+}
+
 // ResponseDecoder 响应数据解码器接口
 type ResponseDecoder interface {
 	Decode(body io.Reader, v any) (err error) // 解码响应数据
@@ -154,6 +166,14 @@ func (h *HttpHeader) RequestID() (requestID string) {
 	return h.Header().Get(requestIdHeaderKey)
 }
 
+// SetRequestID 设置请求ID
+func (h *HttpHeader) SetRequestID(requestID string) {
+	if *h == nil {
+		*h = make(HttpHeader)
+	}
+	h.Header().Set(requestIdHeaderKey, requestID)
+}
+
 // RawResponse 原始响应
 type RawResponse struct {
 	io.ReadCloser
@@ -257,7 +277,9 @@ func (c *HTTPClient) SendRequest(req *http.Request, v Response) (err error) {
 	}
 
 	var resp *http.Response
-	if resp, err = c.config.HTTPClient.Do(req); err != nil {
+	resp, err = c.do(req)
+	c.recordRateLimit(req, resp)
+	if err != nil {
 		return
 	}
 	defer resp.Body.Close()
@@ -277,7 +299,9 @@ func (c *HTTPClient) SendRequest(req *http.Request, v Response) (err error) {
 // SendRequestRaw 发送请求
 func (c *HTTPClient) SendRequestRaw(req *http.Request) (response RawResponse, err error) {
 	var resp *http.Response
-	if resp, err = c.config.HTTPClient.Do(req); err != nil {
+	resp, err = c.do(req)
+	c.recordRateLimit(req, resp)
+	if err != nil {
 		return
 	}
 
@@ -307,7 +331,9 @@ func SendRequestStream[T Streamable](client *HTTPClient, req *http.Request) (str
 	}
 
 	var resp *http.Response
-	if resp, err = client.config.HTTPClient.Do(req); err != nil {
+	resp, err = client.do(req)
+	client.recordRateLimit(req, resp)
+	if err != nil {
 		stream = &StreamReader[T]{}
 		return
 	}
@@ -319,19 +345,27 @@ func SendRequestStream[T Streamable](client *HTTPClient, req *http.Request) (str
 	}
 
 	client.setRequestID(req, resp)
-	stream = &StreamReader[T]{
-		emptyMessagesLimit:          client.config.EmptyMessagesLimit,
-		reader:                      bufio.NewReader(resp.Body),
-		response:                    resp,
-		streamReturnIntervalTimeout: client.config.StreamReturnIntervalTimeout,
-		errAccumulator:              NewErrorAccumulator(),
-		responseDecoder:             client.config.ResponseDecoder,
-		startTime:                   time.Now(),
-		HttpHeader:                  HttpHeader(resp.Header),
-	}
+	stream = NewStreamReader[T](resp.Body, resp.Header, client.config)
+	stream.response = resp
 	return
 }
 
+// do 发送 HTTP 请求，依次经过上下文中的 HTTP 往返拦截器
+func (c *HTTPClient) do(req *http.Request) (resp *http.Response, err error) {
+	interceptors, _ := req.Context().Value(roundTripInterceptorsKey).([]RoundTripInterceptor)
+	next := c.config.HTTPClient.Do
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		var (
+			interceptor = interceptors[i]
+			inner       = next
+		)
+		next = func(req *http.Request) (resp *http.Response, err error) {
+			return interceptor(req, inner)
+		}
+	}
+	return next(req)
+}
+
 // isFailureStatusCode 是否失败状态码
 func isFailureStatusCode(resp *http.Response) (ok bool) {
 	return resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusBadRequest
@@ -359,11 +393,20 @@ func (c *HTTPClient) handleErrorResp(resp *http.Response) (err error) {
 	if body, err = io.ReadAll(resp.Body); err != nil {
 		return fmt.Errorf("error, reading response body: %w", err)
 	}
+	// 获取提供商，用于错误分类
+	var provider string
+	if resp.Request != nil {
+		provider = GetRequestInfo(resp.Request.Context()).Provider
+	}
+	rateLimit := ParseRateLimitInfo(resp.Header)
 	// 尝试解析为 ErrorResponse
 	var errRes ErrorResponse
 	if err = json.Unmarshal(body, &errRes); err == nil && errRes.Error != nil {
 		errRes.Error.HTTPStatus = resp.Status
 		errRes.Error.HTTPStatusCode = resp.StatusCode
+		errRes.Error.Provider = provider
+		errRes.Error.Header = resp.Header
+		errRes.Error.RateLimit = rateLimit
 		return errRes.Error
 	}
 	// 尝试解析为 APIError
@@ -371,6 +414,9 @@ func (c *HTTPClient) handleErrorResp(resp *http.Response) (err error) {
 	if err = json.Unmarshal(body, &apiErr); err == nil && apiErr != nil {
 		apiErr.HTTPStatus = resp.Status
 		apiErr.HTTPStatusCode = resp.StatusCode
+		apiErr.Provider = provider
+		apiErr.Header = resp.Header
+		apiErr.RateLimit = rateLimit
 		return apiErr
 	}
 	// 如果都解析失败，返回包含解析错误的 RequestError
@@ -379,5 +425,8 @@ func (c *HTTPClient) handleErrorResp(resp *http.Response) (err error) {
 		HTTPStatusCode: resp.StatusCode,
 		Err:            fmt.Errorf("failed to parse error response"),
 		Body:           body,
+		Provider:       provider,
+		Header:         resp.Header,
+		RateLimit:      rateLimit,
 	}
 }
@@ -0,0 +1,422 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-10 10:21:37
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-08-06 10:14:32
+ * @Description: 熔断中间件
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// CircuitState 熔断器状态
+type CircuitState int
+
+const (
+	CircuitStateClosed   CircuitState = iota // 关闭状态，请求正常通过
+	CircuitStateOpen                         // 打开状态，请求直接被拒绝
+	CircuitStateHalfOpen                     // 半开状态，允许少量探测请求通过
+)
+
+// String 实现 fmt.Stringer 接口
+func (s CircuitState) String() (str string) {
+	switch s {
+	case CircuitStateClosed:
+		return "closed"
+	case CircuitStateOpen:
+		return "open"
+	case CircuitStateHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// CircuitBreakerCondition 熔断失败判定函数，返回 true 表示该错误计入失败
+type CircuitBreakerCondition func(err error) (ok bool)
+
+// CircuitStateChangeCallback 熔断器状态变更回调函数
+type CircuitStateChangeCallback func(ctx context.Context, key string, from, to CircuitState)
+
+// CircuitBreakerMiddlewareConfig 熔断中间件配置
+type CircuitBreakerMiddlewareConfig struct {
+	FailureRatio        float64                    // 失败率阈值（范围0-1），统计窗口内失败率达到该值时打开熔断器
+	MinRequests         int                        // 失败率生效的最小请求数，统计窗口内请求数不足时不按失败率判断
+	ConsecutiveFailures int                        // 连续失败次数阈值，达到该值时打开熔断器
+	Interval            time.Duration              // 关闭状态下的统计窗口，窗口结束后清空计数
+	CoolDown            time.Duration              // 打开状态的冷却时间，冷却结束后进入半开状态
+	HalfOpenMaxRequests int                        // 半开状态下允许通过的最大探测请求数，全部成功后关闭熔断器
+	Condition           CircuitBreakerCondition    // 熔断失败判定条件
+	OnStateChange       CircuitStateChangeCallback // 状态变更回调函数（同步执行，建议仅用于轻量级操作）
+}
+
+// circuitCounts 熔断器计数
+type circuitCounts struct {
+	Requests             int64 `json:"requests"`              // 请求数
+	Successes            int64 `json:"successes"`             // 成功数
+	Failures             int64 `json:"failures"`              // 失败数
+	ConsecutiveSuccesses int64 `json:"consecutive_successes"` // 连续成功数
+	ConsecutiveFailures  int64 `json:"consecutive_failures"`  // 连续失败数
+}
+
+// circuitTransition 熔断器状态变更
+type circuitTransition struct {
+	from CircuitState
+	to   CircuitState
+}
+
+// circuitBreaker 单个提供商/模型的熔断器
+type circuitBreaker struct {
+	mu               sync.Mutex
+	state            CircuitState        // 当前状态
+	generation       uint64              // 状态（窗口）代数，状态变更或窗口重置时递增，旧代数的请求结果不再计入
+	counts           circuitCounts       // 当前窗口计数
+	expiry           time.Time           // 当前状态（窗口）的过期时间
+	halfOpenInFlight int                 // 半开状态下正在进行的探测请求数
+	rejected         int64               // 累计拒绝的请求数
+	transitions      int64               // 累计状态变更次数
+	lastChanged      time.Time           // 最后一次状态变更时间
+	pending          []circuitTransition // 尚未回调的状态变更，释放锁后回调
+}
+
+// CircuitBreakerMiddleware 熔断中间件
+type CircuitBreakerMiddleware struct {
+	config   CircuitBreakerMiddlewareConfig
+	mu       sync.RWMutex
+	breakers map[string]*circuitBreaker // 按 提供商:模型 划分的熔断器
+}
+
+// NewCircuitBreakerMiddleware 创建熔断中间件
+func NewCircuitBreakerMiddleware(config CircuitBreakerMiddlewareConfig) (cb *CircuitBreakerMiddleware) {
+	// 设置失败率阈值
+	if config.FailureRatio <= 0 || config.FailureRatio > 1 {
+		config.FailureRatio = 0.5
+	}
+	// 设置失败率生效的最小请求数
+	if config.MinRequests <= 0 {
+		config.MinRequests = 10
+	}
+	// 设置连续失败次数阈值
+	if config.ConsecutiveFailures <= 0 {
+		config.ConsecutiveFailures = 5
+	}
+	// 设置统计窗口
+	if config.Interval <= 0 {
+		config.Interval = 60 * time.Second
+	}
+	// 设置冷却时间
+	if config.CoolDown <= 0 {
+		config.CoolDown = 30 * time.Second
+	}
+	// 设置半开状态下允许通过的最大探测请求数
+	if config.HalfOpenMaxRequests <= 0 {
+		config.HalfOpenMaxRequests = 1
+	}
+	// 设置熔断失败判定条件
+	if config.Condition == nil {
+		config.Condition = DefaultCircuitBreakerCondition
+	}
+	return &CircuitBreakerMiddleware{
+		config:   config,
+		breakers: make(map[string]*circuitBreaker),
+	}
+}
+
+// Process 处理请求
+func (m *CircuitBreakerMiddleware) Process(ctx context.Context, request any, next MWHandler) (response any, err error) {
+	// 从上下文中获取请求信息
+	requestInfo := GetRequestInfo(ctx)
+	key := m.getKey(requestInfo.Provider, requestInfo.Model)
+	breaker := m.getBreaker(key)
+	// 判断是否允许请求通过
+	var generation uint64
+	if generation, err = m.beforeRequest(ctx, key, breaker); err != nil {
+		return
+	}
+	// 执行下一个处理器
+	response, err = next(ctx, request)
+	// 记录请求结果
+	m.afterRequest(ctx, key, breaker, generation, err == nil || !m.config.Condition(err))
+	return
+}
+
+// Name 返回中间件名称
+func (m *CircuitBreakerMiddleware) Name() (name string) {
+	return "circuit_breaker"
+}
+
+// Priority 返回中间件优先级
+func (m *CircuitBreakerMiddleware) Priority() (priority int) {
+	return 30 // 熔断中间件在重试之后执行，每次重试都会经过熔断判断
+}
+
+// State 获取指定提供商、模型的熔断器状态
+func (m *CircuitBreakerMiddleware) State(provider, model string) (state CircuitState) {
+	m.mu.RLock()
+	breaker, ok := m.breakers[m.getKey(provider, model)]
+	m.mu.RUnlock()
+	if !ok {
+		return CircuitStateClosed
+	}
+
+	breaker.mu.Lock()
+	breaker.refresh(m, time.Now())
+	state = breaker.state
+	transitions := breaker.takePending()
+	breaker.mu.Unlock()
+	m.notify(context.Background(), m.getKey(provider, model), transitions)
+	return
+}
+
+// GetMetrics 获取熔断器指标数据
+func (m *CircuitBreakerMiddleware) GetMetrics() (metrics map[string]any) {
+	m.mu.RLock()
+	var (
+		now         = time.Now()
+		states      = make(map[string]any, len(m.breakers))
+		transitions = make(map[string][]circuitTransition)
+	)
+	for key, breaker := range m.breakers {
+		breaker.mu.Lock()
+		breaker.refresh(m, now)
+		states[key] = map[string]any{
+			"state":        breaker.state.String(),
+			"counts":       breaker.counts,
+			"rejected":     breaker.rejected,
+			"transitions":  breaker.transitions,
+			"last_changed": breaker.lastChanged,
+		}
+		if pending := breaker.takePending(); len(pending) > 0 {
+			transitions[key] = pending
+		}
+		breaker.mu.Unlock()
+	}
+	m.mu.RUnlock()
+	// 释放锁后回调状态变更，回调中可以安全地调用 State、GetMetrics
+	for key, pending := range transitions {
+		m.notify(context.Background(), key, pending)
+	}
+
+	metrics = map[string]any{
+		"circuit_breakers": states,
+	}
+	return
+}
+
+// Reset 重置所有熔断器
+func (m *CircuitBreakerMiddleware) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.breakers = make(map[string]*circuitBreaker)
+}
+
+// getKey 获取熔断器键值
+func (m *CircuitBreakerMiddleware) getKey(provider, model string) (key string) {
+	return fmt.Sprintf("%s:%s", provider, model)
+}
+
+// getBreaker 获取熔断器，不存在时创建
+func (m *CircuitBreakerMiddleware) getBreaker(key string) (breaker *circuitBreaker) {
+	m.mu.RLock()
+	breaker, ok := m.breakers[key]
+	m.mu.RUnlock()
+	if ok {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if breaker, ok = m.breakers[key]; ok {
+		return
+	}
+	now := time.Now()
+	breaker = &circuitBreaker{
+		state:       CircuitStateClosed,
+		expiry:      now.Add(m.config.Interval),
+		lastChanged: now,
+	}
+	m.breakers[key] = breaker
+	return
+}
+
+// beforeRequest 请求前判断是否允许通过，返回当前的状态代数
+func (m *CircuitBreakerMiddleware) beforeRequest(ctx context.Context, key string, breaker *circuitBreaker) (generation uint64, err error) {
+	breaker.mu.Lock()
+	generation, err = breaker.allow(m, key, time.Now())
+	transitions := breaker.takePending()
+	breaker.mu.Unlock()
+
+	m.notify(ctx, key, transitions)
+	return
+}
+
+// afterRequest 请求后记录结果
+func (m *CircuitBreakerMiddleware) afterRequest(ctx context.Context, key string, breaker *circuitBreaker, generation uint64, success bool) {
+	breaker.mu.Lock()
+	breaker.record(m, generation, success, time.Now())
+	transitions := breaker.takePending()
+	breaker.mu.Unlock()
+
+	m.notify(ctx, key, transitions)
+}
+
+// notify 回调状态变更（调用方不能持有锁）
+func (m *CircuitBreakerMiddleware) notify(ctx context.Context, key string, transitions []circuitTransition) {
+	if m.config.OnStateChange == nil {
+		return
+	}
+	for _, t := range transitions {
+		m.config.OnStateChange(ctx, key, t.from, t.to)
+	}
+}
+
+// allow 判断是否允许请求通过（调用方需持有锁）
+func (b *circuitBreaker) allow(m *CircuitBreakerMiddleware, key string, now time.Time) (generation uint64, err error) {
+	b.refresh(m, now)
+	switch b.state {
+	case CircuitStateOpen:
+		b.rejected++
+		return 0, fmt.Errorf("circuit breaker [%s] will retry after %s: %w", key, b.expiry.Sub(now).Round(time.Millisecond), ErrCircuitOpen)
+	case CircuitStateHalfOpen:
+		if b.halfOpenInFlight >= m.config.HalfOpenMaxRequests {
+			b.rejected++
+			return 0, fmt.Errorf("circuit breaker [%s] is probing: %w", key, ErrCircuitOpen)
+		}
+		b.halfOpenInFlight++
+	}
+	b.counts.Requests++
+	return b.generation, nil
+}
+
+// record 记录请求结果，请求开始后状态已变更（或窗口已重置）时忽略该结果（调用方需持有锁）
+func (b *circuitBreaker) record(m *CircuitBreakerMiddleware, generation uint64, success bool, now time.Time) {
+	b.refresh(m, now)
+	if generation != b.generation {
+		return
+	}
+	if b.state == CircuitStateHalfOpen && b.halfOpenInFlight > 0 {
+		b.halfOpenInFlight--
+	}
+
+	if success {
+		b.counts.Successes++
+		b.counts.ConsecutiveSuccesses++
+		b.counts.ConsecutiveFailures = 0
+		// 半开状态下探测请求全部成功，关闭熔断器
+		if b.state == CircuitStateHalfOpen && b.counts.ConsecutiveSuccesses >= int64(m.config.HalfOpenMaxRequests) {
+			b.setState(m, CircuitStateClosed, now)
+		}
+		return
+	}
+
+	b.counts.Failures++
+	b.counts.ConsecutiveFailures++
+	b.counts.ConsecutiveSuccesses = 0
+	switch b.state {
+	case CircuitStateClosed:
+		if m.readyToTrip(b.counts) {
+			b.setState(m, CircuitStateOpen, now)
+		}
+	case CircuitStateHalfOpen:
+		// 半开状态下任何失败都重新打开熔断器
+		b.setState(m, CircuitStateOpen, now)
+	}
+}
+
+// readyToTrip 判断是否需要打开熔断器
+func (m *CircuitBreakerMiddleware) readyToTrip(counts circuitCounts) (ok bool) {
+	if counts.ConsecutiveFailures >= int64(m.config.ConsecutiveFailures) {
+		return true
+	}
+	if counts.Requests < int64(m.config.MinRequests) {
+		return false
+	}
+	return float64(counts.Failures)/float64(counts.Requests) >= m.config.FailureRatio
+}
+
+// refresh 根据过期时间刷新熔断器状态（调用方需持有锁）
+func (b *circuitBreaker) refresh(m *CircuitBreakerMiddleware, now time.Time) {
+	switch b.state {
+	case CircuitStateClosed:
+		// 统计窗口结束，清空计数
+		if !b.expiry.IsZero() && b.expiry.Before(now) {
+			b.counts = circuitCounts{}
+			b.expiry = now.Add(m.config.Interval)
+			b.generation++
+		}
+	case CircuitStateOpen:
+		// 冷却结束，进入半开状态
+		if b.expiry.Before(now) {
+			b.setState(m, CircuitStateHalfOpen, now)
+		}
+	}
+}
+
+// takePending 取出尚未回调的状态变更（调用方需持有锁）
+func (b *circuitBreaker) takePending() (transitions []circuitTransition) {
+	transitions, b.pending = b.pending, nil
+	return
+}
+
+// setState 设置熔断器状态，状态变更在释放锁后回调（调用方需持有锁）
+func (b *circuitBreaker) setState(m *CircuitBreakerMiddleware, state CircuitState, now time.Time) {
+	if b.state == state {
+		return
+	}
+
+	prev := b.state
+	b.state = state
+	b.generation++
+	b.counts = circuitCounts{}
+	b.halfOpenInFlight = 0
+	b.transitions++
+	b.lastChanged = now
+	switch state {
+	case CircuitStateClosed:
+		b.expiry = now.Add(m.config.Interval)
+	case CircuitStateOpen:
+		b.expiry = now.Add(m.config.CoolDown)
+	default:
+		b.expiry = time.Time{}
+	}
+	// 如果状态变更回调不为空，则记录状态变更，释放锁后回调
+	if m.config.OnStateChange != nil {
+		b.pending = append(b.pending, circuitTransition{from: prev, to: state})
+	}
+}
+
+// DefaultCircuitBreakerCondition 默认熔断失败判定条件
+func DefaultCircuitBreakerCondition(err error) (ok bool) {
+	if err == nil {
+		return false
+	}
+	// 调用方主动取消的请求不计入失败
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	// 超时、网络错误以及限流、服务端错误等临时性的HTTP错误计入失败，认证失败、请求参数错误等调用方错误不计入
+	return ClassifyError(err) == ErrorKindTimeout || isNetworkError(err) || isRetryableHTTPError(err)
+}
+
+// DefaultCircuitBreakerConfig 默认熔断配置
+func DefaultCircuitBreakerConfig() (config CircuitBreakerMiddlewareConfig) {
+	return CircuitBreakerMiddlewareConfig{
+		FailureRatio:        0.5,
+		MinRequests:         10,
+		ConsecutiveFailures: 5,
+		Interval:            60 * time.Second,
+		CoolDown:            30 * time.Second,
+		HalfOpenMaxRequests: 1,
+		Condition:           DefaultCircuitBreakerCondition,
+		OnStateChange:       nil,
+	}
+}
@@ -0,0 +1,180 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-10 15:06:42
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-08-06 10:14:32
+ * @Description:
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Mrzhouyl/go-aisdk/httpclient/test/checks"
+)
+
+func TestCircuitBreakerMiddleware_Process(t *testing.T) {
+	var transitions []string
+	cb := NewCircuitBreakerMiddleware(CircuitBreakerMiddlewareConfig{
+		ConsecutiveFailures: 2,
+		CoolDown:            50 * time.Millisecond,
+		OnStateChange: func(ctx context.Context, key string, from, to CircuitState) {
+			transitions = append(transitions, from.String()+"->"+to.String())
+		},
+	})
+	ctx := SetRequestInfo(context.Background(), &RequestInfo{Provider: "openai", Model: "gpt-4o"})
+	failHandler := func(ctx context.Context, request any) (response any, err error) {
+		return nil, &APIError{HTTPStatusCode: 503, Message: "service unavailable"}
+	}
+	okHandler := func(ctx context.Context, request any) (response any, err error) {
+		return "ok", nil
+	}
+	// 连续失败达到阈值后打开熔断器
+	for range 2 {
+		_, err := cb.Process(ctx, nil, failHandler)
+		checks.ErrorIsNot(t, err, ErrCircuitOpen, "should not be rejected before tripping")
+	}
+	if state := cb.State("openai", "gpt-4o"); state != CircuitStateOpen {
+		t.Fatalf("expected state open, got %s", state)
+	}
+	// 打开状态下请求直接被拒绝
+	_, err := cb.Process(ctx, nil, okHandler)
+	checks.ErrorIs(t, err, ErrCircuitOpen, "should be rejected while open")
+	// 其他模型不受影响
+	otherCtx := SetRequestInfo(context.Background(), &RequestInfo{Provider: "openai", Model: "gpt-4o-mini"})
+	_, err = cb.Process(otherCtx, nil, okHandler)
+	checks.NoError(t, err, "other model should not be affected")
+	// 冷却结束后进入半开状态，探测成功后关闭熔断器
+	time.Sleep(60 * time.Millisecond)
+	_, err = cb.Process(ctx, nil, okHandler)
+	checks.NoError(t, err, "probe request should pass")
+	if state := cb.State("openai", "gpt-4o"); state != CircuitStateClosed {
+		t.Fatalf("expected state closed, got %s", state)
+	}
+
+	expected := []string{"closed->open", "open->half-open", "half-open->closed"}
+	if len(transitions) != len(expected) {
+		t.Fatalf("expected transitions %v, got %v", expected, transitions)
+	}
+	for i := range expected {
+		if transitions[i] != expected[i] {
+			t.Errorf("expected transition %s, got %s", expected[i], transitions[i])
+		}
+	}
+}
+
+func TestCircuitBreakerMiddleware_StaleResult(t *testing.T) {
+	var (
+		cb          *CircuitBreakerMiddleware
+		transitions []CircuitState
+	)
+	cb = NewCircuitBreakerMiddleware(CircuitBreakerMiddlewareConfig{
+		ConsecutiveFailures: 1,
+		CoolDown:            20 * time.Millisecond,
+		HalfOpenMaxRequests: 1,
+		OnStateChange: func(ctx context.Context, key string, from, to CircuitState) {
+			// 回调在释放锁后执行，可以查询熔断器状态
+			transitions = append(transitions, cb.State("openai", "gpt-4o"))
+		},
+	})
+	ctx := SetRequestInfo(context.Background(), &RequestInfo{Provider: "openai", Model: "gpt-4o"})
+	// call 发起请求，返回等待结果的函数
+	call := func(result error) (wait func() error) {
+		var (
+			started = make(chan struct{})
+			release = make(chan struct{})
+			done    = make(chan error, 1)
+		)
+		go func() {
+			_, err := cb.Process(ctx, nil, func(ctx context.Context, request any) (response any, err error) {
+				close(started)
+				<-release
+				return nil, result
+			})
+			done <- err
+		}()
+		select {
+		case <-started:
+		case err := <-done:
+			return func() error { return err }
+		}
+		return func() error {
+			close(release)
+			return <-done
+		}
+	}
+
+	// 熔断器打开前发出的慢请求
+	stale := call(nil)
+	checks.HasError(t, call(&APIError{HTTPStatusCode: 500})(), "failed request should return error")
+	time.Sleep(30 * time.Millisecond)
+	// 半开状态下的探测请求
+	probe := call(nil)
+	// 旧请求的结果不计入半开状态的探测
+	checks.NoError(t, stale(), "stale request should succeed")
+	if state := cb.State("openai", "gpt-4o"); state != CircuitStateHalfOpen {
+		t.Fatalf("expected state half-open after stale result, got %s", state)
+	}
+	if err := call(nil)(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected probe slot to stay occupied, got %v", err)
+	}
+	checks.NoError(t, probe(), "probe should succeed")
+	if state := cb.State("openai", "gpt-4o"); state != CircuitStateClosed {
+		t.Fatalf("expected state closed, got %s", state)
+	}
+	expected := []CircuitState{CircuitStateOpen, CircuitStateHalfOpen, CircuitStateClosed}
+	if len(transitions) != len(expected) {
+		t.Fatalf("expected transitions %v, got %v", expected, transitions)
+	}
+	for i := range expected {
+		if transitions[i] != expected[i] {
+			t.Errorf("expected transition %s, got %s", expected[i], transitions[i])
+		}
+	}
+}
+
+func TestCircuitBreakerMiddleware_FailureRatio(t *testing.T) {
+	cb := NewCircuitBreakerMiddleware(CircuitBreakerMiddlewareConfig{
+		FailureRatio:        0.5,
+		MinRequests:         4,
+		ConsecutiveFailures: 100,
+	})
+	ctx := SetRequestInfo(context.Background(), &RequestInfo{Provider: "deepseek", Model: "deepseek-chat"})
+	results := []error{nil, &RequestError{HTTPStatusCode: 502}, nil, &RequestError{HTTPStatusCode: 502}}
+	for _, result := range results {
+		_, _ = cb.Process(ctx, nil, func(ctx context.Context, request any) (response any, err error) {
+			return nil, result
+		})
+	}
+	if state := cb.State("deepseek", "deepseek-chat"); state != CircuitStateOpen {
+		t.Fatalf("expected state open, got %s", state)
+	}
+}
+
+func TestDefaultCircuitBreakerCondition(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{name: "nil", err: nil, expected: false},
+		{name: "canceled", err: context.Canceled, expected: false},
+		{name: "deadline exceeded", err: context.DeadlineExceeded, expected: true},
+		{name: "server error", err: &APIError{HTTPStatusCode: 500}, expected: true},
+		{name: "bad request", err: &APIError{HTTPStatusCode: 400}, expected: false},
+		{name: "unknown", err: errors.New("unknown"), expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DefaultCircuitBreakerCondition(tt.err); got != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
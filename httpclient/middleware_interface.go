@@ -2,7 +2,7 @@
  * @Author: liusuxian 382185882@qq.com
  * @Date: 2025-05-30 15:14:05
  * @LastEditors: liusuxian 382185882@qq.com
- * @LastEditTime: 2025-07-01 00:27:50
+ * @LastEditTime: 2025-08-04 14:21:50
  * @Description: 中间件接口定义
  *
  * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
@@ -25,6 +25,12 @@ type Middleware interface {
 	Priority() (priority int)                                                           // 返回中间件优先级，数值越小优先级越高
 }
 
+// StreamMiddleware 流式中间件接口（可选），中间件实现该接口后可观察或修改流式响应的每个数据块及最终错误
+type StreamMiddleware interface {
+	Middleware
+	WrapStream(ctx context.Context, next StreamRecvFunc) (recv StreamRecvFunc) // 包装流式数据接收函数
+}
+
 // Chain 中间件链
 type Chain struct {
 	middlewares []Middleware
@@ -50,7 +56,18 @@ func (c *Chain) Execute(ctx context.Context, request any, finalHandler MWHandler
 			nextHandler = handler
 		)
 		handler = func(ctx context.Context, request any) (response any, err error) {
-			return mw.Process(ctx, request, nextHandler)
+			if response, err = mw.Process(ctx, request, nextHandler); err != nil {
+				return
+			}
+			// 流式中间件包装流式响应的接收函数，内层中间件先包装，外层中间件位于外层
+			if smw, ok := mw.(StreamMiddleware); ok {
+				if stream, ok := response.(WrappableStream); ok {
+					stream.WrapRecv(func(next StreamRecvFunc) (recv StreamRecvFunc) {
+						return smw.WrapStream(ctx, next)
+					})
+				}
+			}
+			return
 		}
 	}
 	return handler(ctx, request)
@@ -63,26 +80,30 @@ func (c *Chain) GetMiddlewares() (middlewares []Middleware) {
 
 // RequestInfo 请求信息
 type RequestInfo struct {
-	Provider        string    `json:"provider"`          // 提供商
-	ModelType       string    `json:"model_type"`        // 模型类型
-	Model           string    `json:"model"`             // 模型名称
-	Method          string    `json:"method"`            // 方法名称
-	StartTime       time.Time `json:"start_time"`        // 请求开始时间
-	EndTime         time.Time `json:"end_time"`          // 最后一次的请求结束时间（重试过程中会更新）
-	TotalDurationMs int64     `json:"total_duration_ms"` // 累计请求耗时（包含所有重试）
-	IsSuccess       bool      `json:"is_success"`        // 最后一次的请求状态（重试过程中会更新，最终表示是否成功）
-	Error           error     `json:"error"`             // 最后一次的错误信息（重试过程中会更新）
-	RequestID       string    `json:"request_id"`        // 请求ID
-	User            string    `json:"user"`              // 代表你的终端用户的唯一标识符
-	Attempt         int       `json:"attempt"`           // 第几次重试
-	MaxAttempts     int       `json:"max_attempts"`      // 最大重试次数
+	Provider        string         `json:"provider"`             // 提供商
+	ModelType       string         `json:"model_type"`           // 模型类型
+	Model           string         `json:"model"`                // 模型名称
+	Method          string         `json:"method"`               // 方法名称
+	StartTime       time.Time      `json:"start_time"`           // 请求开始时间
+	EndTime         time.Time      `json:"end_time"`             // 最后一次的请求结束时间（重试过程中会更新）
+	TotalDurationMs int64          `json:"total_duration_ms"`    // 累计请求耗时（包含所有重试）
+	IsSuccess       bool           `json:"is_success"`           // 最后一次的请求状态（重试过程中会更新，最终表示是否成功）
+	Error           error          `json:"error"`                // 最后一次的错误信息（重试过程中会更新）
+	RequestID       string         `json:"request_id"`           // 请求ID
+	User            string         `json:"user"`                 // 代表你的终端用户的唯一标识符
+	Attempt         int            `json:"attempt"`              // 第几次重试
+	MaxAttempts     int            `json:"max_attempts"`         // 最大重试次数
+	CacheHit        bool           `json:"cache_hit"`            // 是否命中缓存
+	Shared          bool           `json:"shared"`               // 是否与其他相同的并发请求合并，共享发起方的上游响应
+	RateLimit       *RateLimitInfo `json:"rate_limit,omitempty"` // 最后一次响应的限流信息（重试过程中会更新）
 }
 
 // ContextKey 上下文键类型
 type ContextKey string
 
 const (
-	RequestInfoKey ContextKey = "go_aisdk_middleware_request_info" // 请求信息在上下文中的键
+	RequestInfoKey           ContextKey = "go_aisdk_middleware_request_info" // 请求信息在上下文中的键
+	roundTripInterceptorsKey ContextKey = "go_aisdk_round_trip_interceptors" // HTTP 往返拦截器在上下文中的键
 )
 
 // GetRequestInfo 从上下文中获取请求信息
@@ -125,6 +146,13 @@ func deepCopyRequestInfo(original *RequestInfo) (requestInfo *RequestInfo) {
 		User:            original.User,
 		Attempt:         original.Attempt,
 		MaxAttempts:     original.MaxAttempts,
+		CacheHit:        original.CacheHit,
+		Shared:          original.Shared,
+	}
+	// 拷贝限流信息
+	if original.RateLimit != nil {
+		rateLimit := *original.RateLimit
+		requestInfo.RateLimit = &rateLimit
 	}
 	// 深度拷贝 error 类型（如果不为 nil）
 	if original.Error != nil {
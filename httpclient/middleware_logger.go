@@ -2,7 +2,7 @@
  * @Author: liusuxian 382185882@qq.com
  * @Date: 2025-05-30 15:14:39
  * @LastEditors: liusuxian 382185882@qq.com
- * @LastEditTime: 2025-07-01 18:30:23
+ * @LastEditTime: 2025-08-08 10:12:44
  * @Description: 日志中间件
  *
  * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
@@ -14,6 +14,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"log/slog"
 	"os"
 	"runtime"
 	"strings"
@@ -105,12 +106,12 @@ func (l *DefaultLogger) Error(ctx context.Context, format string, args ...any) {
 
 // LoggingMiddlewareConfig 日志中间件配置
 type LoggingMiddlewareConfig struct {
-	Logger          Logger   // 日志器
+	Logger          Logger   // 日志器，实现了 StructuredLogger 接口（如 SlogLogger）时以结构化属性记录日志
 	LogRequest      bool     // 是否记录请求
 	LogResponse     bool     // 是否记录响应
 	LogError        bool     // 是否记录错误
 	SkipSuccessLog  bool     // 是否跳过成功请求的日志
-	SensitiveFields []string // 敏感字段，会被脱敏
+	SensitiveFields []string // 敏感字段，会被脱敏（结构化日志中同样适用于属性名和属性值）
 }
 
 // LoggingMiddleware 日志中间件
@@ -143,7 +144,10 @@ func (m *LoggingMiddleware) Process(ctx context.Context, request any, next MWHan
 	requestInfo.TotalDurationMs = requestInfo.EndTime.Sub(requestInfo.StartTime).Milliseconds()
 	requestInfo.IsSuccess = err == nil
 	requestInfo.Error = err
-	// 记录请求结束日志
+	// 记录请求结束日志，流式响应在流结束时记录（WrapStream）
+	if _, ok := response.(WrappableStream); ok && err == nil {
+		return
+	}
 	m.logRequestEnd(ctx, processingStartTime, response, err, requestInfo)
 	return
 }
@@ -158,10 +162,27 @@ func (m *LoggingMiddleware) Priority() (priority int) {
 	return 100 // 日志中间件优先级较低，在其他中间件执行后记录
 }
 
+// WrapStream 包装流式数据接收函数，流结束时记录流式传输日志
+func (m *LoggingMiddleware) WrapStream(ctx context.Context, next StreamRecvFunc) (recv StreamRecvFunc) {
+	requestInfo := GetRequestInfo(ctx)
+	return TrackStream(requestInfo.StartTime, next, func(metrics StreamMetrics, err error) {
+		m.logStreamEnd(ctx, metrics, err, requestInfo)
+	})
+}
+
 // logRequestStart 记录请求开始日志
 func (m *LoggingMiddleware) logRequestStart(ctx context.Context, request any, requestInfo *RequestInfo) {
 	// 是否记录请求
 	if m.config.LogRequest {
+		// 结构化日志
+		if logger, ok := m.config.Logger.(StructuredLogger); ok {
+			attrs := requestAttrs(requestInfo)
+			if request != nil {
+				attrs = append(attrs, slog.Any("request", request))
+			}
+			m.logAttrs(ctx, logger, LogLevelInfo, "request started", attrs)
+			return
+		}
 		// 创建一个别名结构体
 		type Alias RequestInfo
 		startTemp := struct {
@@ -189,6 +210,30 @@ func (m *LoggingMiddleware) logRequestStart(ctx context.Context, request any, re
 
 // logRequestEnd 记录请求结束日志
 func (m *LoggingMiddleware) logRequestEnd(ctx context.Context, processingStartTime time.Time, response any, err error, requestInfo *RequestInfo) {
+	// 结构化日志
+	if logger, ok := m.config.Logger.(StructuredLogger); ok {
+		if (err != nil && !m.config.LogError) || (err == nil && m.config.SkipSuccessLog) {
+			return
+		}
+		attrs := append(requestAttrs(requestInfo),
+			slog.Int64("duration_ms", requestInfo.EndTime.Sub(processingStartTime).Milliseconds()),
+			slog.Int64("total_duration_ms", requestInfo.TotalDurationMs),
+		)
+		if err != nil {
+			m.logAttrs(ctx, logger, LogLevelError, "request failed", append(attrs, errorAttrs(err)...))
+			return
+		}
+		if reporter, ok := response.(TokenUsageReporter); ok {
+			if usage, ok := reporter.TokenUsage(); ok {
+				attrs = append(attrs, usageAttr(usage))
+			}
+		}
+		if m.config.LogResponse && response != nil {
+			attrs = append(attrs, slog.Any("response", response))
+		}
+		m.logAttrs(ctx, logger, LogLevelInfo, "request completed", attrs)
+		return
+	}
 	if err != nil {
 		// 是否记录错误
 		if m.config.LogError {
@@ -237,6 +282,60 @@ func (m *LoggingMiddleware) logRequestEnd(ctx context.Context, processingStartTi
 	}
 }
 
+// logStreamEnd 记录流式传输结束日志
+func (m *LoggingMiddleware) logStreamEnd(ctx context.Context, metrics StreamMetrics, err error, requestInfo *RequestInfo) {
+	if err != nil && !m.config.LogError {
+		return
+	}
+	if err == nil && m.config.SkipSuccessLog {
+		return
+	}
+	// 结构化日志
+	if logger, ok := m.config.Logger.(StructuredLogger); ok {
+		attrs := append(requestAttrs(requestInfo), slog.Group("stream",
+			slog.Int64("ttft_ms", metrics.TTFTMs),
+			slog.Float64("avg_inter_token_latency_ms", metrics.AvgInterTokenLatencyMs),
+			slog.Int64("max_inter_token_latency_ms", metrics.MaxInterTokenLatencyMs),
+			slog.Int("chunk_count", metrics.ChunkCount),
+			slog.Int64("duration_ms", metrics.DurationMs),
+		))
+		if metrics.Usage != nil {
+			attrs = append(attrs, usageAttr(*metrics.Usage))
+		}
+		if err != nil {
+			m.logAttrs(ctx, logger, LogLevelError, "stream failed", append(attrs, errorAttrs(err)...))
+			return
+		}
+		if metrics.Closed {
+			m.logAttrs(ctx, logger, LogLevelInfo, "stream closed", attrs)
+			return
+		}
+		m.logAttrs(ctx, logger, LogLevelInfo, "stream completed", attrs)
+		return
+	}
+	// 创建一个别名结构体
+	type Alias RequestInfo
+	endTemp := struct {
+		Stream StreamMetrics `json:"stream"`
+		Error  string        `json:"error,omitempty"`
+		Alias
+	}{
+		Stream: metrics,
+		Alias:  Alias(*requestInfo),
+	}
+	if err != nil {
+		endTemp.Error = err.Error()
+		m.config.Logger.Error(ctx, "stream failed: %s", MustString(endTemp))
+		return
+	}
+	// 调用方主动关闭流不视为失败
+	if metrics.Closed {
+		m.config.Logger.Info(ctx, "stream closed: %s", MustString(endTemp))
+		return
+	}
+	m.config.Logger.Info(ctx, "stream completed: %s", MustString(endTemp))
+}
+
 // sanitizeData 脱敏数据
 func (m *LoggingMiddleware) sanitizeData(data any) (newData any) {
 	if data == nil {
@@ -270,14 +369,7 @@ func (m *LoggingMiddleware) sanitizeValue(value any, depth int) (newValue any) {
 		result := make(map[string]any)
 		for key, val := range v {
 			// 检查是否为敏感字段
-			isSensitive := false
-			for _, field := range m.config.SensitiveFields {
-				if strings.Contains(strings.ToLower(key), strings.ToLower(field)) {
-					isSensitive = true
-					break
-				}
-			}
-			if isSensitive {
+			if m.isSensitive(key) {
 				result[key] = "***"
 			} else {
 				result[key] = m.sanitizeValue(val, depth+1) // 递归处理，深度+1
@@ -295,6 +387,81 @@ func (m *LoggingMiddleware) sanitizeValue(value any, depth int) (newValue any) {
 	}
 }
 
+// isSensitive 是否为敏感字段
+func (m *LoggingMiddleware) isSensitive(key string) (ok bool) {
+	for _, field := range m.config.SensitiveFields {
+		if strings.Contains(strings.ToLower(key), strings.ToLower(field)) {
+			return true
+		}
+	}
+	return false
+}
+
+// logAttrs 脱敏结构化属性后记录日志
+func (m *LoggingMiddleware) logAttrs(ctx context.Context, logger StructuredLogger, level LogLevel, msg string, attrs []slog.Attr) {
+	for i, attr := range attrs {
+		attrs[i] = m.sanitizeAttr(attr)
+	}
+	logger.LogAttrs(ctx, level, msg, attrs...)
+}
+
+// sanitizeAttr 脱敏结构化属性，敏感字段的属性值替换为 "***"，请求和响应等复杂类型的属性值递归脱敏
+func (m *LoggingMiddleware) sanitizeAttr(attr slog.Attr) (newAttr slog.Attr) {
+	if m.isSensitive(attr.Key) {
+		return slog.String(attr.Key, "***")
+	}
+	value := attr.Value.Resolve()
+	switch value.Kind() {
+	case slog.KindGroup:
+		group := value.Group()
+		attrs := make([]any, 0, len(group))
+		for _, a := range group {
+			attrs = append(attrs, m.sanitizeAttr(a))
+		}
+		return slog.Group(attr.Key, attrs...)
+	case slog.KindAny:
+		return slog.Any(attr.Key, m.sanitizeData(value.Any()))
+	default:
+		return slog.Attr{Key: attr.Key, Value: value}
+	}
+}
+
+// requestAttrs 获取请求信息的结构化属性
+func requestAttrs(requestInfo *RequestInfo) (attrs []slog.Attr) {
+	attrs = []slog.Attr{
+		slog.String("request_id", requestInfo.RequestID),
+		slog.String("provider", requestInfo.Provider),
+		slog.String("model_type", requestInfo.ModelType),
+		slog.String("model", requestInfo.Model),
+		slog.String("method", requestInfo.Method),
+		slog.Int("attempt", requestInfo.Attempt),
+	}
+	if requestInfo.User != "" {
+		attrs = append(attrs, slog.String("user", requestInfo.User))
+	}
+	if requestInfo.CacheHit {
+		attrs = append(attrs, slog.Bool("cache_hit", true))
+	}
+	return
+}
+
+// errorAttrs 获取错误的结构化属性
+func errorAttrs(err error) (attrs []slog.Attr) {
+	return []slog.Attr{
+		slog.String("error", err.Error()),
+		slog.String("error_kind", string(ClassifyError(err))),
+	}
+}
+
+// usageAttr 获取token使用信息的结构化属性
+func usageAttr(usage TokenUsage) (attr slog.Attr) {
+	return slog.Group("usage",
+		slog.Int("prompt_tokens", usage.PromptTokens),
+		slog.Int("completion_tokens", usage.CompletionTokens),
+		slog.Int("total_tokens", usage.TotalTokens),
+	)
+}
+
 // DefaultLoggingConfig 默认日志配置
 func DefaultLoggingConfig() (config LoggingMiddlewareConfig) {
 	return LoggingMiddlewareConfig{
@@ -2,7 +2,7 @@
  * @Author: liusuxian 382185882@qq.com
  * @Date: 2025-06-17 18:24:31
  * @LastEditors: liusuxian 382185882@qq.com
- * @LastEditTime: 2025-06-19 11:22:45
+ * @LastEditTime: 2025-08-08 10:12:44
  * @Description: 监控中间件
  *
  * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
@@ -41,6 +41,18 @@ type MetricsCollector interface {
 	Reset()
 }
 
+// StreamMetricsCollector 流式传输指标收集器接口（可选），指标收集器实现该接口后会记录流式传输指标
+type StreamMetricsCollector interface {
+	// 记录流式传输结束
+	RecordStream(provider, modelType, model, method string, metrics StreamMetrics, success bool)
+}
+
+// UsageMetricsCollector token使用信息指标收集器接口（可选），指标收集器实现该接口后会记录非流式响应的token使用信息，流式传输的token使用信息通过 StreamMetrics 记录
+type UsageMetricsCollector interface {
+	// 记录token使用信息
+	RecordUsage(provider, modelType, model, method string, usage TokenUsage)
+}
+
 // DefaultMetricsCollector 默认指标收集器
 type DefaultMetricsCollector struct {
 	mu sync.RWMutex
@@ -56,6 +68,13 @@ type DefaultMetricsCollector struct {
 	retryCounts map[string]int64 // 重试计数
 	// 活跃请求数
 	activeRequests map[string]int64 // 当前活跃请求数
+	// 流式传输统计
+	streamCounts           map[string]int64   // 结束的流式传输数
+	streamTTFTSums         map[string]int64   // 首个数据块耗时之和（毫秒）
+	streamInterTokenSums   map[string]float64 // 平均数据块间隔之和（毫秒）
+	streamChunks           map[string]int64   // 数据块总数
+	streamPromptTokens     map[string]int64   // 输入token总数
+	streamCompletionTokens map[string]int64   // 输出token总数
 	// 时间范围内的统计
 	startTime time.Time // 统计开始时间
 }
@@ -63,14 +82,20 @@ type DefaultMetricsCollector struct {
 // NewDefaultMetricsCollector 创建默认指标收集器
 func NewDefaultMetricsCollector() (metricsCollector *DefaultMetricsCollector) {
 	return &DefaultMetricsCollector{
-		totalRequests:   make(map[string]int64),
-		successRequests: make(map[string]int64),
-		failedRequests:  make(map[string]int64),
-		responseTimes:   make(map[string][]int64),
-		errorCounts:     make(map[string]int64),
-		retryCounts:     make(map[string]int64),
-		activeRequests:  make(map[string]int64),
-		startTime:       time.Now(),
+		totalRequests:          make(map[string]int64),
+		successRequests:        make(map[string]int64),
+		failedRequests:         make(map[string]int64),
+		responseTimes:          make(map[string][]int64),
+		errorCounts:            make(map[string]int64),
+		retryCounts:            make(map[string]int64),
+		activeRequests:         make(map[string]int64),
+		startTime:              time.Now(),
+		streamCounts:           make(map[string]int64),
+		streamTTFTSums:         make(map[string]int64),
+		streamInterTokenSums:   make(map[string]float64),
+		streamChunks:           make(map[string]int64),
+		streamPromptTokens:     make(map[string]int64),
+		streamCompletionTokens: make(map[string]int64),
 	}
 }
 
@@ -130,6 +155,22 @@ func (c *DefaultMetricsCollector) RecordRetry(provider, modelType, model, method
 	c.retryCounts[key] += int64(retryCount)
 }
 
+// RecordStream 记录流式传输结束
+func (c *DefaultMetricsCollector) RecordStream(provider, modelType, model, method string, metrics StreamMetrics, success bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := c.getKey(provider, modelType, model, method)
+	c.streamCounts[key]++
+	c.streamTTFTSums[key] += metrics.TTFTMs
+	c.streamInterTokenSums[key] += metrics.AvgInterTokenLatencyMs
+	c.streamChunks[key] += int64(metrics.ChunkCount)
+	if metrics.Usage != nil {
+		c.streamPromptTokens[key] += int64(metrics.Usage.PromptTokens)
+		c.streamCompletionTokens[key] += int64(metrics.Usage.CompletionTokens)
+	}
+}
+
 // GetMetrics 获取指标数据
 func (c *DefaultMetricsCollector) GetMetrics() (metrics map[string]any) {
 	c.mu.RLock()
@@ -183,6 +224,29 @@ func (c *DefaultMetricsCollector) GetMetrics() (metrics map[string]any) {
 	metrics["success_rates"] = successRates
 	metrics["avg_response_times"] = avgResponseTimes
 	metrics["uptime_seconds"] = time.Since(c.startTime).Seconds()
+	// 流式传输指标
+	streamCounts := make(map[string]int64)
+	maps.Copy(streamCounts, c.streamCounts)
+	metrics["stream_counts"] = streamCounts
+	avgTTFTs := make(map[string]float64)
+	avgInterTokenLatencies := make(map[string]float64)
+	for key, count := range c.streamCounts {
+		if count > 0 {
+			avgTTFTs[key] = float64(c.streamTTFTSums[key]) / float64(count)
+			avgInterTokenLatencies[key] = c.streamInterTokenSums[key] / float64(count)
+		}
+	}
+	metrics["avg_ttft_ms"] = avgTTFTs
+	metrics["avg_inter_token_latency_ms"] = avgInterTokenLatencies
+	streamChunks := make(map[string]int64)
+	maps.Copy(streamChunks, c.streamChunks)
+	metrics["stream_chunks"] = streamChunks
+	streamPromptTokens := make(map[string]int64)
+	maps.Copy(streamPromptTokens, c.streamPromptTokens)
+	metrics["stream_prompt_tokens"] = streamPromptTokens
+	streamCompletionTokens := make(map[string]int64)
+	maps.Copy(streamCompletionTokens, c.streamCompletionTokens)
+	metrics["stream_completion_tokens"] = streamCompletionTokens
 	return
 }
 
@@ -198,6 +262,12 @@ func (c *DefaultMetricsCollector) Reset() {
 	c.errorCounts = make(map[string]int64)
 	c.retryCounts = make(map[string]int64)
 	c.activeRequests = make(map[string]int64)
+	c.streamCounts = make(map[string]int64)
+	c.streamTTFTSums = make(map[string]int64)
+	c.streamInterTokenSums = make(map[string]float64)
+	c.streamChunks = make(map[string]int64)
+	c.streamPromptTokens = make(map[string]int64)
+	c.streamCompletionTokens = make(map[string]int64)
 	c.startTime = time.Now()
 }
 
@@ -250,6 +320,20 @@ func (m *MetricsMiddleware) Process(ctx context.Context, request any, next MWHan
 	)
 	// 执行下一个处理器
 	response, err = next(ctx, request)
+	// 记录重试次数
+	if requestInfo.Attempt > 0 {
+		m.config.Collector.RecordRetry(
+			requestInfo.Provider,
+			requestInfo.ModelType,
+			requestInfo.Model,
+			requestInfo.Method,
+			requestInfo.Attempt,
+		)
+	}
+	// 流式响应在流结束时记录请求完成、错误和token使用信息（WrapStream）
+	if _, ok := response.(WrappableStream); ok && err == nil {
+		return
+	}
 	// 记录请求完成
 	m.config.Collector.RecordRequestComplete(
 		requestInfo.Provider,
@@ -270,15 +354,19 @@ func (m *MetricsMiddleware) Process(ctx context.Context, request any, next MWHan
 			errorType,
 		)
 	}
-	// 记录重试次数
-	if requestInfo.Attempt > 0 {
-		m.config.Collector.RecordRetry(
-			requestInfo.Provider,
-			requestInfo.ModelType,
-			requestInfo.Model,
-			requestInfo.Method,
-			requestInfo.Attempt,
-		)
+	// 记录token使用信息
+	if collector, ok := m.config.Collector.(UsageMetricsCollector); ok && err == nil {
+		if reporter, ok := response.(TokenUsageReporter); ok {
+			if usage, ok := reporter.TokenUsage(); ok {
+				collector.RecordUsage(
+					requestInfo.Provider,
+					requestInfo.ModelType,
+					requestInfo.Model,
+					requestInfo.Method,
+					usage,
+				)
+			}
+		}
 	}
 	return
 }
@@ -293,16 +381,67 @@ func (m *MetricsMiddleware) Priority() (priority int) {
 	return 10 // 监控中间件优先级较高，尽早执行
 }
 
+// WrapStream 包装流式数据接收函数，流结束时记录请求完成（耗时包含整个流式传输）和流式传输指标
+func (m *MetricsMiddleware) WrapStream(ctx context.Context, next StreamRecvFunc) (recv StreamRecvFunc) {
+	requestInfo := GetRequestInfo(ctx)
+	return TrackStream(requestInfo.StartTime, next, func(metrics StreamMetrics, err error) {
+		// 记录请求完成
+		m.config.Collector.RecordRequestComplete(
+			requestInfo.Provider,
+			requestInfo.ModelType,
+			requestInfo.Model,
+			requestInfo.Method,
+			metrics.DurationMs,
+			err == nil,
+		)
+		// 记录错误
+		if err != nil {
+			m.config.Collector.RecordError(
+				requestInfo.Provider,
+				requestInfo.ModelType,
+				requestInfo.Model,
+				requestInfo.Method,
+				m.classifyError(err),
+			)
+		}
+		// 记录流式传输指标，流式传输指标中已包含token使用信息
+		switch collector := m.config.Collector.(type) {
+		case StreamMetricsCollector:
+			collector.RecordStream(
+				requestInfo.Provider,
+				requestInfo.ModelType,
+				requestInfo.Model,
+				requestInfo.Method,
+				metrics,
+				err == nil,
+			)
+		case UsageMetricsCollector:
+			if metrics.Usage != nil {
+				collector.RecordUsage(
+					requestInfo.Provider,
+					requestInfo.ModelType,
+					requestInfo.Model,
+					requestInfo.Method,
+					*metrics.Usage,
+				)
+			}
+		}
+	})
+}
+
 // GetMetrics 获取指标数据
 func (m *MetricsMiddleware) GetMetrics() (metrics map[string]any) {
 	return m.config.Collector.GetMetrics()
 }
 
-// classifyError 分类错误类型
+// classifyError 分类错误类型，优先使用错误分类（如 rate_limit、timeout），无法识别时按错误来源分类
 func (m *MetricsMiddleware) classifyError(err error) (errorType string) {
 	if err == nil {
 		return "none"
 	}
+	if kind := ClassifyError(err); kind != ErrorKindUnknown {
+		return string(kind)
+	}
 	var netErr net.Error
 	if errors.As(err, &netErr) {
 		return "net_err"
@@ -0,0 +1,408 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-11 09:36:12
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-08-05 14:40:55
+ * @Description: 限流中间件
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// RateLimitScope 限流范围
+type RateLimitScope string
+
+const (
+	RateLimitScopeGlobal   RateLimitScope = "global"   // 全局
+	RateLimitScopeProvider RateLimitScope = "provider" // 按提供商
+	RateLimitScopeModel    RateLimitScope = "model"    // 按提供商+模型
+	RateLimitScopeUser     RateLimitScope = "user"     // 按终端用户（RequestInfo.User 为空时不限流）
+)
+
+// RateLimitRule 限流规则
+type RateLimitRule struct {
+	Scope             RateLimitScope // 限流范围
+	RequestsPerSecond float64        // 每秒允许的请求数，小于等于0表示不限制请求速率
+	Burst             int            // 令牌桶容量（允许的突发请求数），小于等于0时取 ceil(RequestsPerSecond)
+	MaxConcurrent     int            // 最大并发请求数，流式请求在流结束或关闭前一直占用许可，小于等于0表示不限制并发
+}
+
+// RateLimitMiddlewareConfig 限流中间件配置
+type RateLimitMiddlewareConfig struct {
+	Rules                 []RateLimitRule // 限流规则，按顺序依次获取许可
+	Wait                  bool            // 超出限制时是否等待（等待时间受 ctx 约束），为 false 时立即返回 ErrRateLimited
+	MaxWait               time.Duration   // 最大等待时间，仅在 Wait 为 true 时生效，零值表示仅受 ctx 约束
+	FollowResponseHeaders bool            // 是否根据提供商返回的 Retry-After 和 x-ratelimit-* 响应头，在额度重置前暂停按提供商和模型限流的请求（按 API Key 冷却时仅在所有 API Key 都冷却时暂停）
+	IdleTimeout           time.Duration   // 限流器空闲超过该时间后被回收，避免按用户等范围限流时限流器无限增长，小于等于0时使用默认值 10 分钟
+}
+
+// tokenBucket 令牌桶
+type tokenBucket struct {
+	mu       sync.Mutex
+	rate     float64   // 每秒生成的令牌数
+	capacity float64   // 令牌桶容量
+	tokens   float64   // 当前令牌数
+	last     time.Time // 最后一次更新时间
+}
+
+// reserve 尝试获取一个令牌，失败时返回需要等待的时间
+func (b *tokenBucket) reserve(now time.Time) (wait time.Duration, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.tokens = min(b.capacity, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0, true
+	}
+	return time.Duration(math.Ceil((1 - b.tokens) / b.rate * float64(time.Second))), false
+}
+
+// rateLimiter 单个限流键的限流器
+type rateLimiter struct {
+	bucket    *tokenBucket  // 令牌桶，为 nil 表示不限制请求速率
+	semaphore chan struct{} // 并发信号量，为 nil 表示不限制并发
+	mu        sync.Mutex
+	resumeAt  time.Time // 提供商限流额度耗尽时的恢复时间
+	lastUsed  time.Time // 最后一次使用时间，由 RateLimitMiddleware.mu 保护
+}
+
+// pause 暂停请求直到恢复时间，仅延长暂停时间
+func (l *rateLimiter) pause(resumeAt time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if resumeAt.After(l.resumeAt) {
+		l.resumeAt = resumeAt
+	}
+}
+
+// pausedUntil 获取恢复时间
+func (l *rateLimiter) pausedUntil() (resumeAt time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.resumeAt
+}
+
+// RateLimitMiddleware 限流中间件
+type RateLimitMiddleware struct {
+	config    RateLimitMiddlewareConfig
+	mu        sync.Mutex
+	limiters  map[string]*rateLimiter // 限流器，键为 规则序号:范围:键值
+	lastSweep time.Time               // 最后一次回收空闲限流器的时间
+}
+
+// NewRateLimitMiddleware 创建限流中间件
+func NewRateLimitMiddleware(config RateLimitMiddlewareConfig) (rl *RateLimitMiddleware) {
+	rules := make([]RateLimitRule, 0, len(config.Rules))
+	for _, rule := range config.Rules {
+		// 跳过无效规则
+		if rule.RequestsPerSecond <= 0 && rule.MaxConcurrent <= 0 {
+			continue
+		}
+		// 设置限流范围
+		if rule.Scope == "" {
+			rule.Scope = RateLimitScopeGlobal
+		}
+		// 设置令牌桶容量
+		if rule.RequestsPerSecond > 0 && rule.Burst <= 0 {
+			rule.Burst = int(math.Ceil(rule.RequestsPerSecond))
+		}
+		rules = append(rules, rule)
+	}
+	config.Rules = rules
+	// 设置最大等待时间
+	if config.MaxWait < 0 {
+		config.MaxWait = 0
+	}
+	// 设置限流器空闲回收时间
+	if config.IdleTimeout <= 0 {
+		config.IdleTimeout = defaultRateLimitIdleTimeout
+	}
+	return &RateLimitMiddleware{
+		config:    config,
+		limiters:  make(map[string]*rateLimiter),
+		lastSweep: time.Now(),
+	}
+}
+
+// Process 处理请求
+func (m *RateLimitMiddleware) Process(ctx context.Context, request any, next MWHandler) (response any, err error) {
+	// 从上下文中获取请求信息
+	requestInfo := GetRequestInfo(ctx)
+	// 设置最大等待时间
+	waitCtx := ctx
+	if m.config.Wait && m.config.MaxWait > 0 {
+		var cancel context.CancelFunc
+		waitCtx, cancel = context.WithTimeout(ctx, m.config.MaxWait)
+		defer cancel()
+	}
+	// 依次获取许可
+	var (
+		release  []func()
+		followed []*rateLimiter
+	)
+	defer func() {
+		for _, fn := range release {
+			fn()
+		}
+	}()
+	for i, rule := range m.config.Rules {
+		key, ok := m.getKey(rule.Scope, requestInfo)
+		if !ok {
+			continue
+		}
+		limiter := m.getLimiter(i, rule, key)
+		// 等待提供商限流额度恢复
+		if m.config.FollowResponseHeaders && (rule.Scope == RateLimitScopeProvider || rule.Scope == RateLimitScopeModel) {
+			if err = m.waitResume(waitCtx, limiter, key); err != nil {
+				return
+			}
+			followed = append(followed, limiter)
+		}
+		// 获取速率许可
+		if limiter.bucket != nil {
+			if err = m.acquireToken(waitCtx, limiter.bucket, key); err != nil {
+				return
+			}
+		}
+		// 获取并发许可
+		if limiter.semaphore != nil {
+			if err = m.acquireSlot(waitCtx, limiter.semaphore, key); err != nil {
+				return
+			}
+			release = append(release, func() { <-limiter.semaphore })
+		}
+	}
+	// 执行下一个处理器
+	response, err = next(ctx, request)
+	// 提供商返回的限流额度耗尽时，暂停后续请求直到额度恢复，按 API Key 冷却时仅在所有 API Key 都冷却时暂停
+	if resumeAt := requestInfo.RateLimit.PauseUntil(); !resumeAt.IsZero() {
+		for _, limiter := range followed {
+			limiter.pause(resumeAt)
+		}
+	}
+	// 流式响应在流结束、出错或关闭时才释放并发许可
+	if stream, ok := response.(WrappableStream); ok && err == nil && len(release) > 0 {
+		fns := release
+		release = nil
+		stream.WrapRecv(func(next StreamRecvFunc) (recv StreamRecvFunc) {
+			return releaseOnStreamEnd(next, fns)
+		})
+	}
+	return
+}
+
+// releaseOnStreamEnd 包装流式数据接收函数，流结束、出错或关闭时释放许可（仅释放一次）
+func releaseOnStreamEnd(next StreamRecvFunc, release []func()) (recv StreamRecvFunc) {
+	var released bool
+	return func() (chunk any, isFinished bool, err error) {
+		chunk, isFinished, err = next()
+		if (isFinished || err != nil) && !released {
+			released = true
+			for _, fn := range release {
+				fn()
+			}
+		}
+		return
+	}
+}
+
+// Name 返回中间件名称
+func (m *RateLimitMiddleware) Name() (name string) {
+	return "rate_limit"
+}
+
+// Priority 返回中间件优先级
+func (m *RateLimitMiddleware) Priority() (priority int) {
+	return 25 // 限流中间件在重试之后、熔断之前执行，每次重试都会重新获取许可
+}
+
+// GetMetrics 获取限流指标数据
+func (m *RateLimitMiddleware) GetMetrics() (metrics map[string]any) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var (
+		inFlight = make(map[string]int, len(m.limiters))
+		paused   = make(map[string]time.Time)
+		now      = time.Now()
+	)
+	for key, limiter := range m.limiters {
+		if limiter.semaphore != nil {
+			inFlight[key] = len(limiter.semaphore)
+		}
+		if resumeAt := limiter.pausedUntil(); resumeAt.After(now) {
+			paused[key] = resumeAt
+		}
+	}
+	metrics = map[string]any{
+		"rate_limit_in_flight":    inFlight,
+		"rate_limit_paused_until": paused,
+	}
+	return
+}
+
+// getKey 获取限流键值，返回 false 表示该规则不适用于当前请求
+func (m *RateLimitMiddleware) getKey(scope RateLimitScope, requestInfo *RequestInfo) (key string, ok bool) {
+	switch scope {
+	case RateLimitScopeGlobal:
+		return string(scope), true
+	case RateLimitScopeProvider:
+		return fmt.Sprintf("%s:%s", scope, requestInfo.Provider), true
+	case RateLimitScopeModel:
+		return fmt.Sprintf("%s:%s:%s", scope, requestInfo.Provider, requestInfo.Model), true
+	case RateLimitScopeUser:
+		if requestInfo.User == "" {
+			return "", false
+		}
+		return fmt.Sprintf("%s:%s", scope, requestInfo.User), true
+	default:
+		return "", false
+	}
+}
+
+// getLimiter 获取限流器，不存在时创建
+func (m *RateLimitMiddleware) getLimiter(ruleIndex int, rule RateLimitRule, key string) (limiter *rateLimiter) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	m.sweepLocked(now)
+	// 同一范围可能配置多条规则，使用规则序号区分
+	limiterKey := fmt.Sprintf("%d:%s", ruleIndex, key)
+	if limiter, ok := m.limiters[limiterKey]; ok {
+		limiter.lastUsed = now
+		return limiter
+	}
+
+	limiter = &rateLimiter{lastUsed: now}
+	if rule.RequestsPerSecond > 0 {
+		limiter.bucket = &tokenBucket{
+			rate:     rule.RequestsPerSecond,
+			capacity: float64(rule.Burst),
+			tokens:   float64(rule.Burst),
+			last:     now,
+		}
+	}
+	if rule.MaxConcurrent > 0 {
+		limiter.semaphore = make(chan struct{}, rule.MaxConcurrent)
+	}
+	m.limiters[limiterKey] = limiter
+	return
+}
+
+// sweepLocked 回收空闲的限流器，每个空闲回收周期最多执行一次（调用方需持有 mu）
+// 仍有请求占用并发许可或处于暂停中的限流器不回收
+func (m *RateLimitMiddleware) sweepLocked(now time.Time) {
+	if now.Sub(m.lastSweep) < m.config.IdleTimeout {
+		return
+	}
+	m.lastSweep = now
+	for key, limiter := range m.limiters {
+		if now.Sub(limiter.lastUsed) < m.config.IdleTimeout {
+			continue
+		}
+		if limiter.semaphore != nil && len(limiter.semaphore) > 0 {
+			continue
+		}
+		if limiter.pausedUntil().After(now) {
+			continue
+		}
+		delete(m.limiters, key)
+	}
+}
+
+// acquireToken 获取速率许可
+func (m *RateLimitMiddleware) acquireToken(ctx context.Context, bucket *tokenBucket, key string) (err error) {
+	for {
+		wait, ok := bucket.reserve(time.Now())
+		if ok {
+			return
+		}
+		if !m.config.Wait {
+			return fmt.Errorf("rate limit [%s] exceeded, retry after %s: %w", key, wait, ErrRateLimited)
+		}
+		// 如果等待时间超过上下文截止时间，直接返回
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			return fmt.Errorf("rate limit [%s] exceeded, wait %s exceeds deadline: %w", key, wait, ErrRateLimited)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("rate limit [%s] wait interrupted: %w: %w", key, ErrRateLimited, ctx.Err())
+		case <-timer.C:
+			// 继续尝试获取令牌
+		}
+	}
+}
+
+// waitResume 等待提供商限流额度恢复
+func (m *RateLimitMiddleware) waitResume(ctx context.Context, limiter *rateLimiter, key string) (err error) {
+	wait := time.Until(limiter.pausedUntil())
+	if wait <= 0 {
+		return
+	}
+	if !m.config.Wait {
+		return fmt.Errorf("rate limit [%s] paused by provider, retry after %s: %w", key, wait, ErrRateLimited)
+	}
+	// 如果等待时间超过上下文截止时间，直接返回
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+		return fmt.Errorf("rate limit [%s] paused by provider, wait %s exceeds deadline: %w", key, wait, ErrRateLimited)
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("rate limit [%s] wait interrupted: %w: %w", key, ErrRateLimited, ctx.Err())
+	case <-timer.C:
+		return
+	}
+}
+
+// acquireSlot 获取并发许可
+func (m *RateLimitMiddleware) acquireSlot(ctx context.Context, semaphore chan struct{}, key string) (err error) {
+	select {
+	case semaphore <- struct{}{}:
+		return
+	default:
+	}
+
+	if !m.config.Wait {
+		return fmt.Errorf("concurrency limit [%s] of %d exceeded: %w", key, cap(semaphore), ErrRateLimited)
+	}
+	select {
+	case semaphore <- struct{}{}:
+		return
+	case <-ctx.Done():
+		return fmt.Errorf("concurrency limit [%s] wait interrupted: %w: %w", key, ErrRateLimited, ctx.Err())
+	}
+}
+
+// defaultRateLimitIdleTimeout 默认限流器空闲回收时间
+const defaultRateLimitIdleTimeout = 10 * time.Minute
+
+// DefaultRateLimitConfig 默认限流配置
+func DefaultRateLimitConfig() (config RateLimitMiddlewareConfig) {
+	return RateLimitMiddlewareConfig{
+		Rules: []RateLimitRule{
+			{Scope: RateLimitScopeGlobal, RequestsPerSecond: 50, Burst: 50, MaxConcurrent: 100},
+			{Scope: RateLimitScopeModel, RequestsPerSecond: 10, Burst: 20, MaxConcurrent: 20},
+		},
+		Wait:                  true,
+		MaxWait:               30 * time.Second,
+		FollowResponseHeaders: true,
+		IdleTimeout:           defaultRateLimitIdleTimeout,
+	}
+}
@@ -0,0 +1,190 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-11 14:02:55
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-08-05 14:40:55
+ * @Description:
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package httpclient
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Mrzhouyl/go-aisdk/httpclient/test/checks"
+)
+
+func okMWHandler(ctx context.Context, request any) (response any, err error) {
+	return "ok", nil
+}
+
+func TestRateLimitMiddleware_RejectFast(t *testing.T) {
+	rl := NewRateLimitMiddleware(RateLimitMiddlewareConfig{
+		Rules: []RateLimitRule{
+			{Scope: RateLimitScopeUser, RequestsPerSecond: 1, Burst: 1},
+		},
+	})
+	userCtx := SetRequestInfo(context.Background(), &RequestInfo{Provider: "openai", Model: "gpt-4o", User: "user-1"})
+	_, err := rl.Process(userCtx, nil, okMWHandler)
+	checks.NoError(t, err, "first request should pass")
+	_, err = rl.Process(userCtx, nil, okMWHandler)
+	checks.ErrorIs(t, err, ErrRateLimited, "second request should be rejected")
+	// 其他用户不受影响
+	otherCtx := SetRequestInfo(context.Background(), &RequestInfo{Provider: "openai", Model: "gpt-4o", User: "user-2"})
+	_, err = rl.Process(otherCtx, nil, okMWHandler)
+	checks.NoError(t, err, "other user should not be limited")
+	// 未设置用户时不按用户限流
+	anonymousCtx := SetRequestInfo(context.Background(), &RequestInfo{Provider: "openai", Model: "gpt-4o"})
+	for range 3 {
+		_, err = rl.Process(anonymousCtx, nil, okMWHandler)
+		checks.NoError(t, err, "anonymous request should not be limited")
+	}
+}
+
+func TestRateLimitMiddleware_Wait(t *testing.T) {
+	rl := NewRateLimitMiddleware(RateLimitMiddlewareConfig{
+		Rules: []RateLimitRule{
+			{Scope: RateLimitScopeModel, RequestsPerSecond: 20, Burst: 1},
+		},
+		Wait: true,
+	})
+	ctx := SetRequestInfo(context.Background(), &RequestInfo{Provider: "deepseek", Model: "deepseek-chat"})
+	start := time.Now()
+	for range 3 {
+		_, err := rl.Process(ctx, nil, okMWHandler)
+		checks.NoError(t, err, "request should wait for a token")
+	}
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("expected requests to be throttled, elapsed %s", elapsed)
+	}
+	// 等待时间受 ctx 约束
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	_, err := rl.Process(timeoutCtx, nil, okMWHandler)
+	checks.ErrorIs(t, err, ErrRateLimited, "request should be rejected when deadline is too short")
+}
+
+func TestRateLimitMiddleware_MaxConcurrent(t *testing.T) {
+	rl := NewRateLimitMiddleware(RateLimitMiddlewareConfig{
+		Rules: []RateLimitRule{
+			{Scope: RateLimitScopeGlobal, MaxConcurrent: 1},
+		},
+	})
+	var (
+		ctx     = SetRequestInfo(context.Background(), &RequestInfo{Provider: "openai", Model: "gpt-4o"})
+		started = make(chan struct{})
+		finish  = make(chan struct{})
+		done    = make(chan error)
+	)
+	go func() {
+		_, err := rl.Process(ctx, nil, func(ctx context.Context, request any) (response any, err error) {
+			close(started)
+			<-finish
+			return "ok", nil
+		})
+		done <- err
+	}()
+	<-started
+	_, err := rl.Process(ctx, nil, okMWHandler)
+	checks.ErrorIs(t, err, ErrRateLimited, "concurrent request should be rejected")
+	close(finish)
+	checks.NoError(t, <-done, "in-flight request should succeed")
+	_, err = rl.Process(ctx, nil, okMWHandler)
+	checks.NoError(t, err, "slot should be released")
+}
+
+func TestRateLimitMiddleware_FollowResponseHeaders(t *testing.T) {
+	rl := NewRateLimitMiddleware(RateLimitMiddlewareConfig{
+		Rules: []RateLimitRule{
+			{Scope: RateLimitScopeModel, MaxConcurrent: 10},
+		},
+		FollowResponseHeaders: true,
+	})
+	// 提供商返回剩余请求数为 0，暂停该模型的请求直到额度重置
+	exhausted := func(ctx context.Context, request any) (response any, err error) {
+		GetRequestInfo(ctx).RateLimit = &RateLimitInfo{RemainingRequests: 0, RemainingTokens: rateLimitUnknown, ResetRequests: time.Hour, ReceivedAt: time.Now()}
+		return "ok", nil
+	}
+	ctx := SetRequestInfo(context.Background(), &RequestInfo{Provider: "openai", Model: "gpt-4o"})
+	_, err := rl.Process(ctx, nil, exhausted)
+	checks.NoError(t, err, "first request should pass")
+	_, err = rl.Process(SetRequestInfo(context.Background(), &RequestInfo{Provider: "openai", Model: "gpt-4o"}), nil, okMWHandler)
+	checks.ErrorIs(t, err, ErrRateLimited, "request should be paused until the provider quota resets")
+	if paused := rl.GetMetrics()["rate_limit_paused_until"].(map[string]time.Time); len(paused) != 1 {
+		t.Errorf("expected 1 paused limiter, got %v", paused)
+	}
+	// 其他模型不受影响
+	_, err = rl.Process(SetRequestInfo(context.Background(), &RequestInfo{Provider: "openai", Model: "gpt-4o-mini"}), nil, okMWHandler)
+	checks.NoError(t, err, "other model should not be paused")
+
+	// 按 API Key 冷却时，仍有未冷却的 API Key 则不暂停
+	keyExhausted := func(ctx context.Context, request any) (response any, err error) {
+		GetRequestInfo(ctx).RateLimit = &RateLimitInfo{RemainingRequests: 0, RemainingTokens: rateLimitUnknown, ResetRequests: time.Hour, ReceivedAt: time.Now(), KeyPool: true}
+		return "ok", nil
+	}
+	ctx = SetRequestInfo(context.Background(), &RequestInfo{Provider: "deepseek", Model: "deepseek-chat"})
+	_, err = rl.Process(ctx, nil, keyExhausted)
+	checks.NoError(t, err, "first request should pass")
+	_, err = rl.Process(ctx, nil, okMWHandler)
+	checks.NoError(t, err, "request should not be paused while other API keys are available")
+}
+
+func TestRateLimitMiddleware_StreamSlot(t *testing.T) {
+	rl := NewRateLimitMiddleware(RateLimitMiddlewareConfig{
+		Rules: []RateLimitRule{
+			{Scope: RateLimitScopeGlobal, MaxConcurrent: 1},
+		},
+	})
+	var (
+		ctx     = SetRequestInfo(context.Background(), &RequestInfo{Provider: "openai", Model: "gpt-4o"})
+		handler = func(ctx context.Context, request any) (response any, err error) {
+			return newTestStream(streamTestBody), nil
+		}
+	)
+	// 流式响应读取完毕前一直占用并发许可
+	response, err := rl.Process(ctx, nil, handler)
+	checks.NoError(t, err, "stream request should pass")
+	_, err = rl.Process(ctx, nil, okMWHandler)
+	checks.ErrorIs(t, err, ErrRateLimited, "slot should be held while the stream is open")
+	stream := response.(*StreamReader[streamTestChunk])
+	for {
+		_, isFinished, err := stream.Recv()
+		checks.NoError(t, err, "Recv() should succeed")
+		if isFinished {
+			break
+		}
+	}
+	_, err = rl.Process(ctx, nil, okMWHandler)
+	checks.NoError(t, err, "slot should be released after the stream ends")
+	// 提前关闭流同样释放并发许可
+	response, err = rl.Process(ctx, nil, handler)
+	checks.NoError(t, err, "stream request should pass")
+	checks.NoError(t, response.(*StreamReader[streamTestChunk]).Close(), "Close() should succeed")
+	_, err = rl.Process(ctx, nil, okMWHandler)
+	checks.NoError(t, err, "slot should be released after the stream is closed")
+}
+
+func TestRateLimitMiddleware_IdleTimeout(t *testing.T) {
+	rl := NewRateLimitMiddleware(RateLimitMiddlewareConfig{
+		Rules: []RateLimitRule{
+			{Scope: RateLimitScopeUser, RequestsPerSecond: 100},
+		},
+		IdleTimeout: 20 * time.Millisecond,
+	})
+	for _, user := range []string{"user-1", "user-2", "user-3"} {
+		_, err := rl.Process(SetRequestInfo(context.Background(), &RequestInfo{User: user}), nil, okMWHandler)
+		checks.NoError(t, err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	// 空闲的限流器被回收
+	_, err := rl.Process(SetRequestInfo(context.Background(), &RequestInfo{User: "user-4"}), nil, okMWHandler)
+	checks.NoError(t, err)
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	if len(rl.limiters) != 1 {
+		t.Errorf("expected idle limiters to be evicted, got %d", len(rl.limiters))
+	}
+}
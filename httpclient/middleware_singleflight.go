@@ -0,0 +1,285 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-14 09:51:33
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-08-08 14:26:07
+ * @Description: 请求合并中间件，相同的并发非流式请求只向上游发送一次
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package httpclient
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+// SingleflightMiddlewareConfig 请求合并中间件配置
+type SingleflightMiddlewareConfig struct {
+	Methods []string // 允许合并的方法名称，请求体必须可以稳定序列化，流式方法不可合并
+}
+
+// singleflightCall 正在执行的合并请求
+type singleflightCall struct {
+	done     chan struct{}      // 请求完成时关闭
+	response any                // 响应
+	err      error              // 错误
+	waiters  int                // 等待结果的调用方数量
+	cancel   context.CancelFunc // 取消上游请求
+	info     *RequestInfo       // 上游请求使用的请求信息，与调用方的请求信息相互独立
+}
+
+// SingleflightMiddleware 请求合并中间件
+type SingleflightMiddleware struct {
+	config  SingleflightMiddlewareConfig
+	methods map[string]bool              // 允许合并的方法名称
+	mu      sync.Mutex                   // 保护 calls
+	calls   map[string]*singleflightCall // 正在执行的请求，键为请求键
+	shared  atomic.Int64                 // 被合并的请求数
+}
+
+// NewSingleflightMiddleware 创建请求合并中间件
+func NewSingleflightMiddleware(config SingleflightMiddlewareConfig) (m *SingleflightMiddleware) {
+	// 设置允许合并的方法名称
+	if len(config.Methods) == 0 {
+		config.Methods = DefaultSingleflightConfig().Methods
+	}
+	methods := make(map[string]bool, len(config.Methods))
+	for _, method := range config.Methods {
+		methods[method] = true
+	}
+	return &SingleflightMiddleware{
+		config:  config,
+		methods: methods,
+		calls:   make(map[string]*singleflightCall),
+	}
+}
+
+// Process 处理请求
+func (m *SingleflightMiddleware) Process(ctx context.Context, request any, next MWHandler) (response any, err error) {
+	// 从上下文中获取请求信息
+	requestInfo := GetRequestInfo(ctx)
+	if !m.methods[requestInfo.Method] {
+		return next(ctx, request)
+	}
+	// 生成请求键，无法序列化的请求不合并
+	var key string
+	if key, err = singleflightKey(requestInfo, request); err != nil {
+		return next(ctx, request)
+	}
+	// 加入或发起请求
+	m.mu.Lock()
+	c, ok := m.calls[key]
+	if ok {
+		m.shared.Add(1)
+		requestInfo.Shared = true
+	} else {
+		// 上游请求不随发起方取消，仅在所有调用方都离开后取消
+		callCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		c = &singleflightCall{
+			done:   make(chan struct{}),
+			cancel: cancel,
+			info:   deepCopyRequestInfo(requestInfo),
+		}
+		// 上游请求使用独立的请求信息，避免与发起方外层中间件并发读写
+		callCtx = SetRequestInfo(callCtx, c.info)
+		m.calls[key] = c
+		go m.do(callCtx, key, c, request, next)
+	}
+	c.waiters++
+	m.mu.Unlock()
+	// 等待结果
+	select {
+	case <-c.done:
+		c.copyRequestInfo(requestInfo)
+		if c.err != nil {
+			return nil, c.err
+		}
+		return withRequestID(c.response, requestInfo.RequestID), nil
+	case <-ctx.Done():
+		m.leave(key, c)
+		return nil, ctx.Err()
+	}
+}
+
+// Name 返回中间件名称
+func (m *SingleflightMiddleware) Name() (name string) {
+	return "singleflight"
+}
+
+// Priority 返回中间件优先级
+func (m *SingleflightMiddleware) Priority() (priority int) {
+	return 18 // 请求合并中间件在缓存之后、重试之前执行，合并后的请求只重试、限流一次
+}
+
+// GetMetrics 获取请求合并指标数据
+func (m *SingleflightMiddleware) GetMetrics() (metrics map[string]any) {
+	m.mu.Lock()
+	inFlight := len(m.calls)
+	m.mu.Unlock()
+
+	return map[string]any{
+		"singleflight_shared":    m.shared.Load(),
+		"singleflight_in_flight": inFlight,
+	}
+}
+
+// do 执行上游请求
+func (m *SingleflightMiddleware) do(ctx context.Context, key string, c *singleflightCall, request any, next MWHandler) {
+	defer func() {
+		if r := recover(); r != nil {
+			c.err = fmt.Errorf("singleflight: panic in handler: %v", r)
+		}
+		m.mu.Lock()
+		if m.calls[key] == c {
+			delete(m.calls, key)
+		}
+		m.mu.Unlock()
+		close(c.done)
+		c.cancel()
+	}()
+	c.response, c.err = next(ctx, request)
+}
+
+// copyRequestInfo 将上游请求的执行结果复制到调用方的请求信息
+func (c *singleflightCall) copyRequestInfo(requestInfo *RequestInfo) {
+	requestInfo.Attempt = c.info.Attempt
+	requestInfo.MaxAttempts = c.info.MaxAttempts
+	requestInfo.EndTime = c.info.EndTime
+	requestInfo.IsSuccess = c.info.IsSuccess
+	requestInfo.Error = c.info.Error
+	requestInfo.RateLimit = nil
+	if c.info.RateLimit != nil {
+		rateLimit := *c.info.RateLimit
+		requestInfo.RateLimit = &rateLimit
+	}
+}
+
+// leave 调用方取消等待，所有调用方都离开后取消上游请求
+func (m *SingleflightMiddleware) leave(key string, c *singleflightCall) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c.waiters--
+	if c.waiters > 0 {
+		return
+	}
+	// 后续相同的请求重新发起，不再加入已取消的请求
+	if m.calls[key] == c {
+		delete(m.calls, key)
+	}
+	c.cancel()
+}
+
+// singleflightKey 根据提供商、模型、方法和规范化序列化后的请求体生成请求键
+func singleflightKey(requestInfo *RequestInfo, request any) (key string, err error) {
+	var body []byte
+	if body, err = json.Marshal(request); err != nil {
+		return
+	}
+
+	h := sha256.New()
+	h.Write([]byte(requestInfo.Provider))
+	h.Write([]byte{0})
+	h.Write([]byte(requestInfo.Model))
+	h.Write([]byte{0})
+	h.Write([]byte(requestInfo.Method))
+	h.Write([]byte{0})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// headerResponse 携带响应头的响应
+type headerResponse interface {
+	Header() (header http.Header)
+	SetHeader(header http.Header)
+}
+
+// withRequestID 深拷贝响应并将响应头中的请求ID替换为调用方自己的请求ID，调用方之间不共享响应数据（未导出字段为浅拷贝）
+func withRequestID(response any, requestID string) (result any) {
+	value := reflect.ValueOf(response)
+	if !value.IsValid() || value.Kind() != reflect.Struct {
+		return response
+	}
+
+	cp := reflect.New(value.Type())
+	cp.Elem().Set(deepCopyValue(value))
+	resp, ok := cp.Interface().(headerResponse)
+	if !ok {
+		return response
+	}
+	header := resp.Header().Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	header.Set(requestIdHeaderKey, requestID)
+	resp.SetHeader(header)
+	return cp.Elem().Interface()
+}
+
+// deepCopyValue 深拷贝值，复制指针、切片、映射、接口和结构体的导出字段指向的数据，未导出字段保持原值
+func deepCopyValue(value reflect.Value) (cp reflect.Value) {
+	switch value.Kind() {
+	case reflect.Pointer:
+		if value.IsNil() {
+			return value
+		}
+		cp = reflect.New(value.Type().Elem())
+		cp.Elem().Set(deepCopyValue(value.Elem()))
+		return
+	case reflect.Interface:
+		if value.IsNil() {
+			return value
+		}
+		cp = reflect.New(value.Type()).Elem()
+		cp.Set(deepCopyValue(value.Elem()))
+		return
+	case reflect.Slice:
+		if value.IsNil() {
+			return value
+		}
+		cp = reflect.MakeSlice(value.Type(), value.Len(), value.Len())
+		for i := range value.Len() {
+			cp.Index(i).Set(deepCopyValue(value.Index(i)))
+		}
+		return
+	case reflect.Map:
+		if value.IsNil() {
+			return value
+		}
+		cp = reflect.MakeMapWithSize(value.Type(), value.Len())
+		iter := value.MapRange()
+		for iter.Next() {
+			cp.SetMapIndex(iter.Key(), deepCopyValue(iter.Value()))
+		}
+		return
+	case reflect.Struct:
+		cp = reflect.New(value.Type()).Elem()
+		cp.Set(value)
+		for i := range value.NumField() {
+			if field := cp.Field(i); field.CanSet() {
+				field.Set(deepCopyValue(value.Field(i)))
+			}
+		}
+		return
+	}
+	return value
+}
+
+// DefaultSingleflightConfig 默认请求合并配置，图像生成结果不确定，不合并
+func DefaultSingleflightConfig() (config SingleflightMiddlewareConfig) {
+	return SingleflightMiddlewareConfig{
+		Methods: []string{
+			"CreateChatCompletion",
+			"CreateEmbeddings",
+			"ListModels",
+		},
+	}
+}
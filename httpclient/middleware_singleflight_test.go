@@ -0,0 +1,153 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-14 13:40:17
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-08-08 14:26:07
+ * @Description:
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Mrzhouyl/go-aisdk/httpclient/test/checks"
+)
+
+type singleflightTestRequest struct {
+	Prompt string `json:"prompt"`
+}
+
+type singleflightTestResponse struct {
+	Text  string
+	Parts []*string
+	HttpHeader
+}
+
+func newSingleflightCtx(ctx context.Context, requestId string) (newCtx context.Context) {
+	return SetRequestInfo(ctx, &RequestInfo{Provider: "openai", Model: "gpt-4o", Method: "CreateChatCompletion", RequestID: requestId})
+}
+
+func TestSingleflightMiddleware_Dedup(t *testing.T) {
+	var (
+		sf      = NewSingleflightMiddleware(SingleflightMiddlewareConfig{})
+		calls   atomic.Int32
+		release = make(chan struct{})
+		handler = func(ctx context.Context, request any) (response any, err error) {
+			calls.Add(1)
+			<-release
+			GetRequestInfo(ctx).Attempt = 2
+			part := "part"
+			resp := singleflightTestResponse{Text: "hello", Parts: []*string{&part}}
+			resp.SetRequestID(GetRequestInfo(ctx).RequestID)
+			return resp, nil
+		}
+		wg        sync.WaitGroup
+		responses = make([]singleflightTestResponse, 3)
+		infos     = make([]*RequestInfo, len(responses))
+	)
+	for i := range responses {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx := newSingleflightCtx(context.Background(), fmt.Sprintf("req-%d", i))
+			infos[i] = GetRequestInfo(ctx)
+			resp, err := sf.Process(ctx, singleflightTestRequest{Prompt: "hi"}, handler)
+			checks.NoError(t, err)
+			responses[i] = resp.(singleflightTestResponse)
+		}()
+	}
+	// 等待所有请求加入后再放行
+	for sf.shared.Load() < int64(len(responses)-1) {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+
+	if calls.Load() != 1 {
+		t.Fatalf("expected 1 upstream call, got %d", calls.Load())
+	}
+	// 调用方之间不共享响应数据
+	*responses[0].Parts[0] = "changed"
+	if got := *responses[1].Parts[0]; got != "part" {
+		t.Errorf("response data shared between callers: %q", got)
+	}
+	for i, resp := range responses {
+		if resp.Text != "hello" || resp.RequestID() != fmt.Sprintf("req-%d", i) {
+			t.Errorf("unexpected response %d: %+v", i, resp)
+		}
+		// 上游请求的执行结果复制到每个调用方的请求信息
+		if infos[i].Attempt != 2 {
+			t.Errorf("expected attempt 2 for caller %d, got %d", i, infos[i].Attempt)
+		}
+	}
+	// 不同的请求不合并
+	_, err := sf.Process(newSingleflightCtx(context.Background(), "req-x"), singleflightTestRequest{Prompt: "other"}, okMWHandler)
+	checks.NoError(t, err)
+	if sf.shared.Load() != int64(len(responses)-1) {
+		t.Errorf("expected different requests not to be shared")
+	}
+}
+
+func TestSingleflightMiddleware_Cancel(t *testing.T) {
+	var (
+		sf       = NewSingleflightMiddleware(SingleflightMiddlewareConfig{})
+		started  = make(chan struct{})
+		canceled = make(chan struct{})
+		handler  = func(ctx context.Context, request any) (response any, err error) {
+			close(started)
+			<-ctx.Done()
+			close(canceled)
+			return nil, ctx.Err()
+		}
+		request = singleflightTestRequest{Prompt: "hi"}
+	)
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	errs := make(chan error, 2)
+	go func() {
+		_, err := sf.Process(newSingleflightCtx(ctx1, "req-1"), request, handler)
+		errs <- err
+	}()
+	<-started
+	go func() {
+		_, err := sf.Process(newSingleflightCtx(ctx2, "req-2"), request, handler)
+		errs <- err
+	}()
+	for sf.shared.Load() < 1 {
+		time.Sleep(time.Millisecond)
+	}
+	// 发起方取消不影响其他调用方
+	cancel1()
+	checks.ErrorIs(t, <-errs, context.Canceled)
+	select {
+	case <-canceled:
+		t.Fatal("expected upstream request to continue while other callers are waiting")
+	case <-time.After(20 * time.Millisecond):
+	}
+	// 所有调用方都取消后取消上游请求
+	cancel2()
+	checks.ErrorIs(t, <-errs, context.Canceled)
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("expected upstream request to be canceled")
+	}
+}
+
+func TestSingleflightMiddleware_SkipMethods(t *testing.T) {
+	sf := NewSingleflightMiddleware(SingleflightMiddlewareConfig{})
+	ctx := SetRequestInfo(context.Background(), &RequestInfo{Method: "CreateChatCompletionStream"})
+	wantErr := errors.New("upstream")
+	_, err := sf.Process(ctx, nil, func(ctx context.Context, request any) (response any, err error) {
+		return nil, wantErr
+	})
+	checks.ErrorIs(t, err, wantErr)
+}
@@ -0,0 +1,96 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-15 10:26:08
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-08-05 11:14:02
+ * @Description: 流式传输指标统计
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package httpclient
+
+import (
+	"errors"
+	"time"
+)
+
+// TokenUsage token使用信息
+type TokenUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`     // 输入的token数量
+	CompletionTokens int `json:"completion_tokens"` // 输出的token数量
+	TotalTokens      int `json:"total_tokens"`      // 使用的token总数
+}
+
+// TokenUsageReporter 可报告token使用信息的响应或数据块
+type TokenUsageReporter interface {
+	TokenUsage() (usage TokenUsage, ok bool) // 获取token使用信息，ok 为 false 表示不包含使用信息
+}
+
+// StreamMetrics 流式传输指标
+type StreamMetrics struct {
+	TTFTMs                 int64       `json:"ttft_ms"`                    // 首个数据块耗时（从请求开始计算）
+	AvgInterTokenLatencyMs float64     `json:"avg_inter_token_latency_ms"` // 相邻数据块的平均间隔
+	MaxInterTokenLatencyMs int64       `json:"max_inter_token_latency_ms"` // 相邻数据块的最大间隔
+	ChunkCount             int         `json:"chunk_count"`                // 数据块数量
+	DurationMs             int64       `json:"duration_ms"`                // 传输总耗时（从请求开始计算）
+	Usage                  *TokenUsage `json:"usage,omitempty"`            // 最终的token使用信息
+	Closed                 bool        `json:"closed,omitempty"`           // 是否在结束前被调用方主动关闭
+}
+
+// StreamEndCallback 流式传输结束回调函数，err 为 nil 表示正常结束或被调用方主动关闭（metrics.Closed 为 true）
+type StreamEndCallback func(metrics StreamMetrics, err error)
+
+// TrackStream 包装流式数据接收函数，统计流式传输指标，流结束或出错时调用 onEnd（仅调用一次）
+// 调用方主动关闭流（ErrStreamClosed）不视为失败，onEnd 收到的 err 为 nil
+func TrackStream(start time.Time, next StreamRecvFunc, onEnd StreamEndCallback) (recv StreamRecvFunc) {
+	if start.IsZero() {
+		start = time.Now()
+	}
+
+	var (
+		metrics   StreamMetrics
+		last      time.Time
+		totalGaps time.Duration
+		ended     bool
+	)
+	return func() (chunk any, isFinished bool, err error) {
+		chunk, isFinished, err = next()
+		if ended {
+			return
+		}
+
+		now := time.Now()
+		if err == nil && !isFinished {
+			metrics.ChunkCount++
+			if last.IsZero() {
+				metrics.TTFTMs = now.Sub(start).Milliseconds()
+			} else {
+				gap := now.Sub(last)
+				totalGaps += gap
+				metrics.MaxInterTokenLatencyMs = max(metrics.MaxInterTokenLatencyMs, gap.Milliseconds())
+			}
+			last = now
+			// 保留最后一次出现的token使用信息
+			if reporter, ok := chunk.(TokenUsageReporter); ok {
+				if usage, ok := reporter.TokenUsage(); ok {
+					metrics.Usage = &usage
+				}
+			}
+			return
+		}
+		// 流结束或出错
+		ended = true
+		if metrics.ChunkCount > 1 {
+			metrics.AvgInterTokenLatencyMs = float64(totalGaps) / float64(time.Millisecond) / float64(metrics.ChunkCount-1)
+		}
+		metrics.DurationMs = now.Sub(start).Milliseconds()
+		endErr := err
+		if errors.Is(endErr, ErrStreamClosed) {
+			metrics.Closed, endErr = true, nil
+		}
+		if onEnd != nil {
+			onEnd(metrics, endErr)
+		}
+		return
+	}
+}
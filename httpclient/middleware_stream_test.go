@@ -0,0 +1,207 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-15 14:12:36
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-08-05 11:14:02
+ * @Description:
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/Mrzhouyl/go-aisdk/httpclient/test/checks"
+)
+
+type streamTestChunk struct {
+	Text  string      `json:"text"`
+	Usage *TokenUsage `json:"usage,omitempty"`
+}
+
+func (c streamTestChunk) TokenUsage() (usage TokenUsage, ok bool) {
+	if c.Usage == nil {
+		return
+	}
+	return *c.Usage, true
+}
+
+type streamTestEvent struct {
+	chunk      any
+	isFinished bool
+	err        error
+}
+
+// streamTestMiddleware 记录流式事件并修改数据块的流式中间件
+type streamTestMiddleware struct {
+	name   string
+	events []streamTestEvent
+}
+
+func (m *streamTestMiddleware) Process(ctx context.Context, request any, next MWHandler) (response any, err error) {
+	return next(ctx, request)
+}
+
+func (m *streamTestMiddleware) Name() (name string) {
+	return m.name
+}
+
+func (m *streamTestMiddleware) Priority() (priority int) {
+	return 0
+}
+
+func (m *streamTestMiddleware) WrapStream(ctx context.Context, next StreamRecvFunc) (recv StreamRecvFunc) {
+	return func() (chunk any, isFinished bool, err error) {
+		chunk, isFinished, err = next()
+		if c, ok := chunk.(streamTestChunk); ok && !isFinished && err == nil {
+			c.Text += m.name
+			chunk = c
+		}
+		m.events = append(m.events, streamTestEvent{chunk: chunk, isFinished: isFinished, err: err})
+		return
+	}
+}
+
+func newTestStream(body string) (stream *StreamReader[streamTestChunk]) {
+	return NewStreamReader[streamTestChunk](io.NopCloser(strings.NewReader(body)), nil, HTTPClientConfig{})
+}
+
+const streamTestBody = "data: {\"text\":\"a\"}\n\ndata: {\"text\":\"b\",\"usage\":{\"prompt_tokens\":1,\"completion_tokens\":2,\"total_tokens\":3}}\n\ndata: [DONE]\n\n"
+
+func TestChain_StreamMiddleware(t *testing.T) {
+	var (
+		outer = &streamTestMiddleware{name: "1"}
+		inner = &streamTestMiddleware{name: "2"}
+		chain = NewChain(outer, inner)
+	)
+	resp, err := chain.Execute(context.Background(), nil, func(ctx context.Context, request any) (response any, err error) {
+		return newTestStream(streamTestBody), nil
+	})
+	checks.NoErrorF(t, err)
+
+	stream := resp.(*StreamReader[streamTestChunk])
+	var texts []string
+	checks.NoError(t, stream.ForEach(func(chunk streamTestChunk, isFinished bool) (err error) {
+		if !isFinished {
+			texts = append(texts, chunk.Text)
+		}
+		return
+	}))
+	// 内层中间件先处理数据块
+	if strings.Join(texts, ",") != "a21,b21" {
+		t.Errorf("unexpected chunks: %v", texts)
+	}
+	for _, m := range []*streamTestMiddleware{outer, inner} {
+		if len(m.events) != 3 || !m.events[2].isFinished {
+			t.Errorf("middleware %s: expected 2 chunks and a finish event, got %+v", m.name, m.events)
+		}
+	}
+}
+
+func TestStreamReader_WrapRecvTerminalEvents(t *testing.T) {
+	t.Run("close before completion", func(t *testing.T) {
+		m := &streamTestMiddleware{name: "x"}
+		stream := newTestStream(streamTestBody)
+		stream.WrapRecv(func(next StreamRecvFunc) (recv StreamRecvFunc) {
+			return m.WrapStream(context.Background(), next)
+		})
+		_, _, err := stream.Recv()
+		checks.NoErrorF(t, err)
+		checks.NoError(t, stream.Close())
+		if len(m.events) != 2 || !errors.Is(m.events[1].err, ErrStreamClosed) {
+			t.Fatalf("expected ErrStreamClosed terminal event, got %+v", m.events)
+		}
+		// 结束事件只发送一次
+		_, _, err = stream.Recv()
+		checks.ErrorIs(t, err, ErrStreamClosed)
+		if len(m.events) != 2 {
+			t.Errorf("expected no more events after termination, got %+v", m.events)
+		}
+	})
+	t.Run("foreach handler error", func(t *testing.T) {
+		// 使用管道模拟尚未结束的流
+		pr, pw := io.Pipe()
+		go pw.Write([]byte("data: {\"text\":\"a\"}\n\n"))
+		m := &streamTestMiddleware{name: "x"}
+		stream := NewStreamReader[streamTestChunk](pr, nil, HTTPClientConfig{})
+		stream.WrapRecv(func(next StreamRecvFunc) (recv StreamRecvFunc) {
+			return m.WrapStream(context.Background(), next)
+		})
+		wantErr := errors.New("stop")
+		err := stream.ForEach(func(chunk streamTestChunk, isFinished bool) (err error) {
+			return wantErr
+		})
+		checks.ErrorIs(t, err, wantErr)
+		last := m.events[len(m.events)-1]
+		if !errors.Is(last.err, wantErr) {
+			t.Errorf("expected handler error as terminal event, got %+v", m.events)
+		}
+	})
+}
+
+func TestTrackStream(t *testing.T) {
+	var (
+		stream = newTestStream(streamTestBody)
+		got    StreamMetrics
+		ended  int
+	)
+	stream.WrapRecv(func(next StreamRecvFunc) (recv StreamRecvFunc) {
+		return TrackStream(stream.startTime, next, func(metrics StreamMetrics, err error) {
+			checks.NoError(t, err)
+			got = metrics
+			ended++
+		})
+	})
+	for {
+		_, finished, err := stream.Recv()
+		checks.NoErrorF(t, err)
+		if finished {
+			break
+		}
+	}
+	if ended != 1 || got.ChunkCount != 2 || got.Usage == nil || got.Usage.TotalTokens != 3 || got.Closed {
+		t.Errorf("unexpected stream metrics: %+v (ended: %d)", got, ended)
+	}
+
+	// 调用方主动关闭流不视为失败
+	stream = newTestStream(streamTestBody)
+	stream.WrapRecv(func(next StreamRecvFunc) (recv StreamRecvFunc) {
+		return TrackStream(stream.startTime, next, func(metrics StreamMetrics, err error) {
+			checks.NoError(t, err)
+			got = metrics
+		})
+	})
+	_, _, err := stream.Recv()
+	checks.NoErrorF(t, err)
+	checks.NoError(t, stream.Close())
+	if !got.Closed || got.ChunkCount != 1 {
+		t.Errorf("unexpected closed stream metrics: %+v", got)
+	}
+}
+
+func TestMetricsMiddleware_WrapStream(t *testing.T) {
+	var (
+		mm  = NewMetricsMiddleware(MetricsMiddlewareConfig{})
+		ctx = SetRequestInfo(context.Background(), &RequestInfo{Provider: "openai", Model: "gpt-4o", Method: "CreateChatCompletionStream"})
+	)
+	resp, err := NewChain(mm).Execute(ctx, nil, func(ctx context.Context, request any) (response any, err error) {
+		return newTestStream(streamTestBody), nil
+	})
+	checks.NoErrorF(t, err)
+	checks.NoError(t, resp.(*StreamReader[streamTestChunk]).ForEach(func(chunk streamTestChunk, isFinished bool) (err error) {
+		return
+	}))
+
+	metrics := mm.GetMetrics()
+	key := "openai:gpt-4o:CreateChatCompletionStream"
+	if metrics["stream_counts"].(map[string]int64)[key] != 1 ||
+		metrics["stream_chunks"].(map[string]int64)[key] != 2 ||
+		metrics["stream_completion_tokens"].(map[string]int64)[key] != 2 {
+		t.Errorf("unexpected stream metrics: %+v", metrics)
+	}
+}
@@ -0,0 +1,191 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-30 10:12:36
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-08-05 14:40:55
+ * @Description: 限流响应头解析
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package httpclient
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	headerRetryAfter                 = "Retry-After"                    // 重试等待时间（秒数或 HTTP 日期）
+	headerRetryAfterMs               = "Retry-After-Ms"                 // 重试等待时间（毫秒）
+	headerRateLimitLimitRequests     = "X-Ratelimit-Limit-Requests"     // 请求数限制
+	headerRateLimitLimitTokens       = "X-Ratelimit-Limit-Tokens"       // token 数限制
+	headerRateLimitRemainingRequests = "X-Ratelimit-Remaining-Requests" // 剩余请求数
+	headerRateLimitRemainingTokens   = "X-Ratelimit-Remaining-Tokens"   // 剩余 token 数
+	headerRateLimitResetRequests     = "X-Ratelimit-Reset-Requests"     // 请求数限制重置时间
+	headerRateLimitResetTokens       = "X-Ratelimit-Reset-Tokens"       // token 数限制重置时间
+)
+
+// rateLimitUnknown 未返回限制或剩余数量
+const rateLimitUnknown int64 = -1
+
+// RateLimitInfo 提供商返回的限流信息，数量未返回时为 -1
+type RateLimitInfo struct {
+	RetryAfter        time.Duration `json:"retry_after"`        // 建议的重试等待时间
+	LimitRequests     int64         `json:"limit_requests"`     // 请求数限制
+	LimitTokens       int64         `json:"limit_tokens"`       // token 数限制
+	RemainingRequests int64         `json:"remaining_requests"` // 剩余请求数
+	RemainingTokens   int64         `json:"remaining_tokens"`   // 剩余 token 数
+	ResetRequests     time.Duration `json:"reset_requests"`     // 请求数限制重置的剩余时间
+	ResetTokens       time.Duration `json:"reset_tokens"`       // token 数限制重置的剩余时间
+	ReceivedAt        time.Time     `json:"received_at"`        // 收到响应的时间
+	KeyPool           bool          `json:"key_pool"`           // 是否由提供商客户端在多个 API Key 之间按 API Key 冷却，为 true 时使用 PoolResumeAt 判断是否暂停整个提供商
+	PoolResumeAt      time.Time     `json:"pool_resume_at"`     // 所有 API Key 都在冷却时最早结束冷却的时间，仍有未冷却的 API Key 时为零值
+}
+
+// ParseRateLimitInfo 解析 Retry-After 和 x-ratelimit-* 响应头，没有相关响应头时返回 nil
+func ParseRateLimitInfo(header http.Header) (info *RateLimitInfo) {
+	if header == nil {
+		return nil
+	}
+	info = &RateLimitInfo{
+		LimitRequests:     rateLimitUnknown,
+		LimitTokens:       rateLimitUnknown,
+		RemainingRequests: rateLimitUnknown,
+		RemainingTokens:   rateLimitUnknown,
+		ReceivedAt:        time.Now(),
+	}
+	found := false
+	// 重试等待时间，优先使用毫秒精度的 Retry-After-Ms
+	if v := header.Get(headerRetryAfterMs); v != "" {
+		if ms, err := strconv.ParseFloat(v, 64); err == nil && ms > 0 {
+			info.RetryAfter, found = time.Duration(ms*float64(time.Millisecond)), true
+		}
+	}
+	if v := header.Get(headerRetryAfter); v != "" && info.RetryAfter == 0 {
+		if d, ok := parseRetryAfter(v, info.ReceivedAt); ok {
+			info.RetryAfter, found = d, true
+		}
+	}
+	// 限制和剩余数量
+	for _, v := range []struct {
+		key   string
+		value *int64
+	}{
+		{headerRateLimitLimitRequests, &info.LimitRequests},
+		{headerRateLimitLimitTokens, &info.LimitTokens},
+		{headerRateLimitRemainingRequests, &info.RemainingRequests},
+		{headerRateLimitRemainingTokens, &info.RemainingTokens},
+	} {
+		if n, err := strconv.ParseInt(strings.TrimSpace(header.Get(v.key)), 10, 64); err == nil && n >= 0 {
+			*v.value, found = n, true
+		}
+	}
+	// 重置时间
+	for _, v := range []struct {
+		key   string
+		value *time.Duration
+	}{
+		{headerRateLimitResetRequests, &info.ResetRequests},
+		{headerRateLimitResetTokens, &info.ResetTokens},
+	} {
+		if d, ok := parseResetDuration(header.Get(v.key), info.ReceivedAt); ok {
+			*v.value, found = d, true
+		}
+	}
+	if !found {
+		return nil
+	}
+	return
+}
+
+// RetryDelay 获取重试前需要等待的时间，优先使用 Retry-After，其次使用已耗尽的请求数或 token 数的重置时间，无法判断时返回 0
+func (i *RateLimitInfo) RetryDelay() (delay time.Duration) {
+	if i == nil {
+		return 0
+	}
+	if i.RetryAfter > 0 {
+		return i.RetryAfter
+	}
+	if i.RemainingRequests == 0 {
+		delay = i.ResetRequests
+	}
+	if i.RemainingTokens == 0 {
+		delay = max(delay, i.ResetTokens)
+	}
+	return
+}
+
+// ResumeAt 获取可以恢复请求的时间，不需要等待时返回零值
+func (i *RateLimitInfo) ResumeAt() (t time.Time) {
+	if delay := i.RetryDelay(); delay > 0 {
+		return i.ReceivedAt.Add(delay)
+	}
+	return
+}
+
+// PauseUntil 获取需要暂停整个提供商（或模型）请求的截止时间，不需要暂停时返回零值
+// 按 API Key 冷却时，仅在所有 API Key 都在冷却时暂停，避免单个 API Key 的额度耗尽阻塞其他正常的 API Key
+func (i *RateLimitInfo) PauseUntil() (t time.Time) {
+	if i == nil {
+		return
+	}
+	if i.KeyPool {
+		return i.PoolResumeAt
+	}
+	return i.ResumeAt()
+}
+
+// RetryAfter 获取错误响应中提供商建议的重试等待时间，没有时返回 0
+func RetryAfter(err error) (delay time.Duration) {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.RateLimit.RetryDelay()
+	}
+	var requestErr *RequestError
+	if errors.As(err, &requestErr) {
+		return requestErr.RateLimit.RetryDelay()
+	}
+	return 0
+}
+
+// parseRetryAfter 解析 Retry-After，支持秒数和 HTTP 日期
+func parseRetryAfter(value string, now time.Time) (delay time.Duration, ok bool) {
+	value = strings.TrimSpace(value)
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		return time.Duration(seconds * float64(time.Second)), seconds > 0
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return t.Sub(now), t.After(now)
+	}
+	return 0, false
+}
+
+// parseResetDuration 解析重置时间，支持 "6m0s"、"20ms" 这类时长、秒数和 RFC3339 时间
+func parseResetDuration(value string, now time.Time) (delay time.Duration, ok bool) {
+	if value = strings.TrimSpace(value); value == "" {
+		return 0, false
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return d, d >= 0
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		return time.Duration(seconds * float64(time.Second)), seconds >= 0
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return max(t.Sub(now), 0), true
+	}
+	return 0, false
+}
+
+// recordRateLimit 将最后一次响应的限流信息记录到请求信息中，请求失败时清空
+func (c *HTTPClient) recordRateLimit(request *http.Request, response *http.Response) {
+	requestInfo := GetRequestInfo(request.Context())
+	if response == nil {
+		requestInfo.RateLimit = nil
+		return
+	}
+	requestInfo.RateLimit = ParseRateLimitInfo(response.Header)
+}
@@ -0,0 +1,170 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-30 14:36:52
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-30 16:01:09
+ * @Description:
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package httpclient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Mrzhouyl/go-aisdk/httpclient/test/checks"
+)
+
+func TestParseRateLimitInfo(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name      string
+		header    map[string]string
+		wantNil   bool
+		wantDelay time.Duration
+		check     func(t *testing.T, info *RateLimitInfo)
+	}{
+		{
+			name:    "no rate limit headers",
+			header:  map[string]string{"Content-Type": "application/json"},
+			wantNil: true,
+		},
+		{
+			name: "openai request limit exhausted",
+			header: map[string]string{
+				"x-ratelimit-limit-requests":     "60",
+				"x-ratelimit-limit-tokens":       "150000",
+				"x-ratelimit-remaining-requests": "0",
+				"x-ratelimit-remaining-tokens":   "149984",
+				"x-ratelimit-reset-requests":     "1s",
+				"x-ratelimit-reset-tokens":       "6m0s",
+			},
+			wantDelay: time.Second,
+			check: func(t *testing.T, info *RateLimitInfo) {
+				if info.LimitRequests != 60 || info.LimitTokens != 150000 || info.RemainingTokens != 149984 || info.ResetTokens != 6*time.Minute {
+					t.Errorf("unexpected info: %+v", info)
+				}
+			},
+		},
+		{
+			name: "token limit exhausted",
+			header: map[string]string{
+				"x-ratelimit-remaining-requests": "10",
+				"x-ratelimit-remaining-tokens":   "0",
+				"x-ratelimit-reset-requests":     "20ms",
+				"x-ratelimit-reset-tokens":       "1.5",
+			},
+			wantDelay: 1500 * time.Millisecond,
+		},
+		{
+			name:      "remaining quota does not delay",
+			header:    map[string]string{"x-ratelimit-remaining-requests": "10", "x-ratelimit-reset-requests": "1s"},
+			wantDelay: 0,
+			check: func(t *testing.T, info *RateLimitInfo) {
+				if info.RemainingTokens != rateLimitUnknown || !info.ResumeAt().IsZero() {
+					t.Errorf("unexpected info: %+v", info)
+				}
+			},
+		},
+		{
+			name:      "retry after seconds",
+			header:    map[string]string{"Retry-After": "2", "x-ratelimit-remaining-requests": "0", "x-ratelimit-reset-requests": "1s"},
+			wantDelay: 2 * time.Second,
+		},
+		{
+			name:      "retry after milliseconds",
+			header:    map[string]string{"Retry-After": "2", "retry-after-ms": "150"},
+			wantDelay: 150 * time.Millisecond,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			for k, v := range tt.header {
+				header.Set(k, v)
+			}
+			info := ParseRateLimitInfo(header)
+			if tt.wantNil {
+				if info != nil {
+					t.Errorf("expected nil, got %+v", info)
+				}
+				return
+			}
+			if info == nil {
+				t.Fatal("expected rate limit info, got nil")
+			}
+			if got := info.RetryDelay(); got != tt.wantDelay {
+				t.Errorf("RetryDelay() = %s, want %s", got, tt.wantDelay)
+			}
+			if tt.check != nil {
+				tt.check(t, info)
+			}
+		})
+	}
+
+	// HTTP 日期格式的 Retry-After
+	header := http.Header{}
+	header.Set("Retry-After", now.Add(time.Minute).UTC().Format(http.TimeFormat))
+	if delay := ParseRateLimitInfo(header).RetryDelay(); delay < 58*time.Second || delay > time.Minute {
+		t.Errorf("RetryDelay() = %s, want about 1m", delay)
+	}
+}
+
+func TestHandleErrorResp_RateLimit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "3")
+		w.Header().Set("x-ratelimit-remaining-requests", "0")
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"error":{"message":"Rate limit reached","type":"requests","code":"rate_limit_exceeded"}}`))
+	}))
+	defer server.Close()
+
+	requestInfo := &RequestInfo{Provider: "openai"}
+	client := NewHTTPClient(server.URL)
+	req, err := client.NewRequest(SetRequestInfo(context.Background(), requestInfo), http.MethodPost, server.URL)
+	checks.NoError(t, err, "NewRequest() error")
+	_, err = client.SendRequestRaw(req)
+	checks.HasError(t, err, "expected rate limit error")
+	if delay := RetryAfter(err); delay != 3*time.Second {
+		t.Errorf("RetryAfter() = %s, want 3s", delay)
+	}
+	if requestInfo.RateLimit == nil || requestInfo.RateLimit.RemainingRequests != 0 {
+		t.Errorf("rate limit info not recorded on request info: %+v", requestInfo.RateLimit)
+	}
+}
+
+func TestRetryMiddleware_RetryAfter(t *testing.T) {
+	var (
+		ctx      = SetRequestInfo(context.Background(), &RequestInfo{Provider: "openai"})
+		attempts int
+	)
+	handler := func(retryAfter time.Duration) MWHandler {
+		attempts = 0
+		return func(ctx context.Context, request any) (response any, err error) {
+			if attempts++; attempts == 1 {
+				return nil, &APIError{HTTPStatusCode: 429, RateLimit: &RateLimitInfo{RetryAfter: retryAfter}}
+			}
+			return "ok", nil
+		}
+	}
+	// 使用提供商指定的等待时间，而不是 200ms 的基础延迟
+	retry := NewRetryMiddleware(RetryMiddlewareConfig{MaxAttempts: 1, Strategy: RetryStrategyFixed, BaseDelay: 200 * time.Millisecond, MaxDelay: time.Second})
+	start := time.Now()
+	_, err := retry.Process(ctx, nil, handler(20*time.Millisecond))
+	checks.NoError(t, err, "retry should succeed")
+	if elapsed := time.Since(start); attempts != 2 || elapsed >= 150*time.Millisecond {
+		t.Errorf("expected retry after 20ms, attempts = %d, elapsed = %s", attempts, elapsed)
+	}
+	// 等待时间不超过最大延迟时间
+	retry = NewRetryMiddleware(RetryMiddlewareConfig{MaxAttempts: 1, Strategy: RetryStrategyFixed, BaseDelay: 10 * time.Millisecond, MaxDelay: 30 * time.Millisecond})
+	start = time.Now()
+	_, err = retry.Process(ctx, nil, handler(time.Hour))
+	checks.NoError(t, err, "retry should succeed")
+	if elapsed := time.Since(start); elapsed >= time.Second {
+		t.Errorf("expected delay capped by MaxDelay, elapsed = %s", elapsed)
+	}
+}
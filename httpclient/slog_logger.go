@@ -0,0 +1,94 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-08-02 10:06:51
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-08-02 16:18:34
+ * @Description: log/slog 日志适配器
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"time"
+)
+
+// StructuredLogger 结构化日志接口（可选），日志器实现该接口后日志中间件以结构化属性记录日志，而不是格式化的字符串
+type StructuredLogger interface {
+	Logger
+	LogAttrs(ctx context.Context, level LogLevel, msg string, attrs ...slog.Attr) // 记录带结构化属性的日志
+}
+
+// SlogLogger 基于 log/slog 的日志实现，同时实现了 Logger 和 StructuredLogger 接口
+type SlogLogger struct {
+	logger *slog.Logger
+}
+
+// NewSlogLogger 创建基于 log/slog 的日志器，logger 为 nil 时使用 slog.Default()
+func NewSlogLogger(logger *slog.Logger) (l *SlogLogger) {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &SlogLogger{
+		logger: logger,
+	}
+}
+
+// Debug 调试日志
+func (l *SlogLogger) Debug(ctx context.Context, format string, args ...any) {
+	l.log(ctx, LogLevelDebug, fmt.Sprintf(format, args...))
+}
+
+// Info 信息日志
+func (l *SlogLogger) Info(ctx context.Context, format string, args ...any) {
+	l.log(ctx, LogLevelInfo, fmt.Sprintf(format, args...))
+}
+
+// Warn 警告日志
+func (l *SlogLogger) Warn(ctx context.Context, format string, args ...any) {
+	l.log(ctx, LogLevelWarn, fmt.Sprintf(format, args...))
+}
+
+// Error 错误日志
+func (l *SlogLogger) Error(ctx context.Context, format string, args ...any) {
+	l.log(ctx, LogLevelError, fmt.Sprintf(format, args...))
+}
+
+// LogAttrs 记录带结构化属性的日志
+func (l *SlogLogger) LogAttrs(ctx context.Context, level LogLevel, msg string, attrs ...slog.Attr) {
+	l.log(ctx, level, msg, attrs...)
+}
+
+// log 记录日志，调用位置记录为调用日志方法的位置
+func (l *SlogLogger) log(ctx context.Context, level LogLevel, msg string, attrs ...slog.Attr) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	slogLevel := level.slogLevel()
+	if !l.logger.Enabled(ctx, slogLevel) {
+		return
+	}
+	var pcs [1]uintptr
+	runtime.Callers(3, pcs[:]) // 跳过 runtime.Callers、本函数和调用者
+	record := slog.NewRecord(time.Now(), slogLevel, msg, pcs[0])
+	record.AddAttrs(attrs...)
+	_ = l.logger.Handler().Handle(ctx, record)
+}
+
+// slogLevel 获取对应的 slog 日志级别
+func (level LogLevel) slogLevel() (slogLevel slog.Level) {
+	switch level {
+	case LogLevelDebug:
+		return slog.LevelDebug
+	case LogLevelWarn:
+		return slog.LevelWarn
+	case LogLevelError:
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
@@ -0,0 +1,129 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-08-02 14:27:10
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-08-08 10:12:44
+ * @Description:
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/Mrzhouyl/go-aisdk/httpclient/test/checks"
+)
+
+// usageResponse 带token使用信息的响应
+type usageResponse struct {
+	APIKey string `json:"api_key"`
+	Text   string `json:"text"`
+}
+
+// TokenUsage 获取token使用信息
+func (r usageResponse) TokenUsage() (usage TokenUsage, ok bool) {
+	return TokenUsage{PromptTokens: 3, CompletionTokens: 2, TotalTokens: 5}, true
+}
+
+// decodeLogs 解析 JSON 格式的日志
+func decodeLogs(t *testing.T, buf *bytes.Buffer) (records []map[string]any) {
+	for line := range strings.SplitSeq(strings.TrimSpace(buf.String()), "\n") {
+		var record map[string]any
+		checks.NoError(t, json.Unmarshal([]byte(line), &record), "log line should be JSON")
+		records = append(records, record)
+	}
+	buf.Reset()
+	return
+}
+
+func TestLoggingMiddleware_Slog(t *testing.T) {
+	var (
+		buf    bytes.Buffer
+		logger = NewSlogLogger(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{AddSource: true})))
+		m      = NewLoggingMiddleware(LoggingMiddlewareConfig{
+			Logger:          logger,
+			LogRequest:      true,
+			LogResponse:     true,
+			LogError:        true,
+			SensitiveFields: []string{"api_key", "user"},
+		})
+		ctx = SetRequestInfo(context.Background(), &RequestInfo{
+			Provider:  "openai",
+			ModelType: "chat",
+			Model:     "gpt-4o",
+			Method:    "CreateChatCompletion",
+			RequestID: "req-1",
+			User:      "alice",
+		})
+	)
+
+	_, err := m.Process(ctx, map[string]any{"api_key": "sk-secret", "prompt": "hi"}, func(ctx context.Context, request any) (response any, err error) {
+		GetRequestInfo(ctx).Attempt = 1
+		return usageResponse{APIKey: "sk-secret", Text: "hello"}, nil
+	})
+	checks.NoError(t, err, "Process() should succeed")
+	records := decodeLogs(t, &buf)
+	if len(records) != 2 {
+		t.Fatalf("expected 2 log records, got %d", len(records))
+	}
+	start, end := records[0], records[1]
+	if start["msg"] != "request started" || start["level"] != "INFO" || start["request_id"] != "req-1" || start["provider"] != "openai" {
+		t.Errorf("unexpected start record: %v", start)
+	}
+	// 敏感字段同时适用于属性名和请求、响应中的字段
+	if start["user"] != "***" || start["request"].(map[string]any)["api_key"] != "***" || start["request"].(map[string]any)["prompt"] != "hi" {
+		t.Errorf("start record not sanitized: %v", start)
+	}
+	if end["msg"] != "request completed" || end["attempt"] != float64(1) || end["response"].(map[string]any)["api_key"] != "***" {
+		t.Errorf("unexpected end record: %v", end)
+	}
+	if usage, ok := end["usage"].(map[string]any); !ok || usage["prompt_tokens"] != float64(3) || usage["completion_tokens"] != float64(2) {
+		t.Errorf("unexpected usage: %v", end["usage"])
+	}
+	if _, ok := end["duration_ms"]; !ok {
+		t.Error("duration_ms missing")
+	}
+	// 调用位置为日志中间件，而不是适配器
+	if source, _ := end["source"].(map[string]any); !strings.HasSuffix(source["file"].(string), "middleware_logger.go") {
+		t.Errorf("unexpected source: %v", end["source"])
+	}
+
+	// 错误日志记录错误分类
+	_, err = m.Process(ctx, nil, func(ctx context.Context, request any) (response any, err error) {
+		return nil, &APIError{HTTPStatusCode: 429, Message: "rate limited"}
+	})
+	checks.HasError(t, err, "Process() should fail")
+	records = decodeLogs(t, &buf)
+	failed := records[len(records)-1]
+	if failed["msg"] != "request failed" || failed["level"] != "ERROR" || failed["error_kind"] != "rate_limit" {
+		t.Errorf("unexpected failed record: %v", failed)
+	}
+
+	// 流式响应只在流结束时记录一条结束日志
+	response, err := NewChain(m).Execute(ctx, nil, func(ctx context.Context, request any) (response any, err error) {
+		return newTestStream(streamTestBody), nil
+	})
+	checks.NoError(t, err, "Execute() should succeed")
+	if records = decodeLogs(t, &buf); len(records) != 1 || records[0]["msg"] != "request started" {
+		t.Fatalf("unexpected records at stream open: %v", records)
+	}
+	for _, err = range response.(*StreamReader[streamTestChunk]).All() {
+		checks.NoError(t, err, "stream should succeed")
+	}
+	if records = decodeLogs(t, &buf); len(records) != 1 || records[0]["msg"] != "stream completed" {
+		t.Errorf("unexpected records at stream end: %v", records)
+	}
+
+	// 格式化日志同样可以使用
+	logger.Warn(ctx, "provider %s does not support %s", "deepseek", "n")
+	records = decodeLogs(t, &buf)
+	if records[0]["msg"] != "provider deepseek does not support n" || records[0]["level"] != "WARN" {
+		t.Errorf("unexpected formatted record: %v", records[0])
+	}
+}
@@ -0,0 +1,77 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-17 10:12:47
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-17 11:48:05
+ * @Description: 流读取器的迭代器与通道接口
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"iter"
+)
+
+// errStopIteration 迭代被调用方提前终止
+var errStopIteration = errors.New("stream iteration stopped")
+
+// StreamResult 流式数据项，Err 不为 nil 时为最后一项
+type StreamResult[T Streamable] struct {
+	Response T     // 数据块
+	Err      error // 读取错误
+}
+
+// All 返回遍历流式数据的迭代器，遍历结束、出错或提前 break 后关闭流
+//
+//	for chunk, err := range stream.All() {
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+//
+// 出错时产出一次零值和错误后结束；数据间隔超时（StreamReturnIntervalTimeout）与空消息数量限制（EmptyMessagesLimit）同 ForEach
+func (stream *StreamReader[T]) All() (seq iter.Seq2[T, error]) {
+	return func(yield func(T, error) bool) {
+		err := stream.ForEach(func(response T, isFinished bool) (err error) {
+			if isFinished {
+				return
+			}
+			if !yield(response, nil) {
+				// 提前 break 视为关闭流
+				stream.abort(ErrStreamClosed)
+				return errStopIteration
+			}
+			return
+		})
+		if err != nil && !errors.Is(err, errStopIteration) {
+			var empty T
+			yield(empty, err)
+		}
+	}
+}
+
+// Chan 返回接收流式数据的通道，流结束、出错或 ctx 取消后关闭通道和流。出错时通道的最后一项包含错误，ctx 取消时直接关闭通道
+func (stream *StreamReader[T]) Chan(ctx context.Context) (ch <-chan StreamResult[T]) {
+	out := make(chan StreamResult[T])
+	go func() {
+		defer close(out)
+		// ctx 取消时关闭流，使阻塞中的读取立即返回
+		stop := context.AfterFunc(ctx, func() {
+			stream.Close()
+		})
+		defer stop()
+
+		for response, err := range stream.All() {
+			select {
+			case out <- StreamResult[T]{Response: response, Err: err}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
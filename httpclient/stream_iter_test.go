@@ -0,0 +1,126 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-17 11:05:39
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-17 11:50:16
+ * @Description:
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Mrzhouyl/go-aisdk/httpclient/test/checks"
+)
+
+// closeTrackingBody 记录是否已关闭的响应体
+type closeTrackingBody struct {
+	io.Reader
+	closed bool
+}
+
+func (b *closeTrackingBody) Close() (err error) {
+	b.closed = true
+	if c, ok := b.Reader.(io.Closer); ok {
+		return c.Close()
+	}
+	return
+}
+
+func TestStreamReader_All(t *testing.T) {
+	t.Run("complete", func(t *testing.T) {
+		body := &closeTrackingBody{Reader: strings.NewReader(streamTestBody)}
+		stream := NewStreamReader[streamTestChunk](body, nil, HTTPClientConfig{})
+		var texts []string
+		for chunk, err := range stream.All() {
+			checks.NoErrorF(t, err)
+			texts = append(texts, chunk.Text)
+		}
+		if strings.Join(texts, ",") != "a,b" || !body.closed {
+			t.Errorf("unexpected chunks: %v (closed: %v)", texts, body.closed)
+		}
+	})
+	t.Run("early break", func(t *testing.T) {
+		// 使用管道模拟尚未结束的流
+		pr, pw := io.Pipe()
+		defer pw.Close()
+		go pw.Write([]byte("data: {\"text\":\"a\"}\n\n"))
+		body := &closeTrackingBody{Reader: pr}
+		m := &streamTestMiddleware{name: "x"}
+		stream := NewStreamReader[streamTestChunk](body, nil, HTTPClientConfig{})
+		stream.WrapRecv(func(next StreamRecvFunc) (recv StreamRecvFunc) {
+			return m.WrapStream(context.Background(), next)
+		})
+		for range stream.All() {
+			break
+		}
+		if !body.closed {
+			t.Error("expected body to be closed after break")
+		}
+		last := m.events[len(m.events)-1]
+		if !errors.Is(last.err, ErrStreamClosed) {
+			t.Errorf("expected ErrStreamClosed terminal event, got %+v", m.events)
+		}
+	})
+	t.Run("interval timeout", func(t *testing.T) {
+		pr, pw := io.Pipe()
+		defer pw.Close()
+		stream := NewStreamReader[streamTestChunk](pr, nil, HTTPClientConfig{StreamReturnIntervalTimeout: 20 * time.Millisecond})
+		var errs []error
+		for _, err := range stream.All() {
+			errs = append(errs, err)
+		}
+		if len(errs) != 1 || !errors.Is(errs[0], ErrStreamReturnIntervalTimeout) {
+			t.Errorf("expected a single timeout error, got %v", errs)
+		}
+	})
+	t.Run("too many empty messages", func(t *testing.T) {
+		stream := NewStreamReader[streamTestChunk](io.NopCloser(strings.NewReader("\n\n\n\n")), nil, HTTPClientConfig{EmptyMessagesLimit: 2})
+		var errs []error
+		for _, err := range stream.All() {
+			errs = append(errs, err)
+		}
+		if len(errs) != 1 || !errors.Is(errs[0], ErrTooManyEmptyStreamMessages) {
+			t.Errorf("expected ErrTooManyEmptyStreamMessages, got %v", errs)
+		}
+	})
+}
+
+func TestStreamReader_Chan(t *testing.T) {
+	t.Run("complete", func(t *testing.T) {
+		var texts []string
+		for result := range newTestStream(streamTestBody).Chan(context.Background()) {
+			checks.NoErrorF(t, result.Err)
+			texts = append(texts, result.Response.Text)
+		}
+		if strings.Join(texts, ",") != "a,b" {
+			t.Errorf("unexpected chunks: %v", texts)
+		}
+	})
+	t.Run("context canceled", func(t *testing.T) {
+		// 使用管道模拟阻塞中的流
+		pr, pw := io.Pipe()
+		defer pw.Close()
+		go pw.Write([]byte("data: {\"text\":\"a\"}\n\n"))
+		ctx, cancel := context.WithCancel(context.Background())
+		ch := NewStreamReader[streamTestChunk](pr, nil, HTTPClientConfig{}).Chan(ctx)
+		result := <-ch
+		checks.NoErrorF(t, result.Err)
+		cancel()
+		select {
+		case _, ok := <-ch:
+			for ok {
+				_, ok = <-ch
+			}
+		case <-time.After(time.Second):
+			t.Fatal("channel not closed after context canceled")
+		}
+	})
+}
@@ -2,7 +2,7 @@
  * @Author: liusuxian 382185882@qq.com
  * @Date: 2025-05-28 18:00:38
  * @LastEditors: liusuxian 382185882@qq.com
- * @LastEditTime: 2025-07-07 22:13:49
+ * @LastEditTime: 2025-08-05 11:14:02
  * @Description:
  *
  * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
@@ -15,6 +15,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"sync"
 	"time"
 )
 
@@ -31,6 +32,20 @@ type Streamable any
 // StreamDataHandler 流式数据处理函数
 type StreamDataHandler[T Streamable] func(response T, isFinished bool) (err error)
 
+// StreamRecvHook 流式数据接收钩子函数，每次 Recv 结束后调用
+type StreamRecvHook[T Streamable] func(response T, isFinished bool, err error)
+
+// StreamRecvFunc 流式数据接收函数，chunk 为流读取器的数据类型
+type StreamRecvFunc func() (chunk any, isFinished bool, err error)
+
+// StreamRecvWrapper 流式数据接收函数包装器，可观察或修改每个数据块及最终错误
+type StreamRecvWrapper func(next StreamRecvFunc) (recv StreamRecvFunc)
+
+// WrappableStream 可包装接收函数的流
+type WrappableStream interface {
+	WrapRecv(wrapper StreamRecvWrapper) // 包装接收函数
+}
+
 // StreamReader 流读取器
 type StreamReader[T Streamable] struct {
 	emptyMessagesLimit          uint
@@ -44,6 +59,14 @@ type StreamReader[T Streamable] struct {
 	// 统计字段
 	startTime  time.Time
 	chunkCount int
+	// 接收函数包装
+	recvMu     sync.Mutex     // 保证 Recv 串行执行
+	recvFunc   StreamRecvFunc // 包装后的接收函数，为 nil 表示未包装
+	terminated bool           // 是否已向包装器发送结束事件（结束或出错）
+	abortMu    sync.Mutex     // 保护 abortErr
+	abortErr   error          // 中止原因，设置后读取失败时返回该错误
+	// 自定义数据源
+	source func() (response T, isFinished bool, err error) // 为 nil 表示从响应体读取
 	// 响应头
 	HttpHeader
 }
@@ -72,12 +95,18 @@ func (stream *StreamReader[T]) ForEach(handler StreamDataHandler[T]) (err error)
 		lineChan = make(chan T, 1)
 		errChan  = make(chan error, 1)
 		done     = make(chan struct{})
+		exited   = make(chan struct{})
 	)
-	defer stream.Close()
-	defer close(done)
-	defer stream.streamReturnIntervalTimer.Stop()
+	defer func() {
+		stream.streamReturnIntervalTimer.Stop()
+		close(done)
+		stream.Close()
+		// 等待读取协程退出，保证包装器在 ForEach 返回前收到结束事件
+		<-exited
+	}()
 
 	go func() {
+		defer close(exited)
 		for {
 			select {
 			case <-done:
@@ -92,7 +121,11 @@ func (stream *StreamReader[T]) ForEach(handler StreamDataHandler[T]) (err error)
 					errChan <- nil
 					return
 				}
-				lineChan <- resp
+				select {
+				case lineChan <- resp:
+				case <-done:
+					return
+				}
 			}
 		}
 	}()
@@ -100,8 +133,17 @@ func (stream *StreamReader[T]) ForEach(handler StreamDataHandler[T]) (err error)
 	for {
 		select {
 		case <-stream.streamReturnIntervalTimer.C:
+			stream.abort(ErrStreamReturnIntervalTimeout)
 			return ErrStreamReturnIntervalTimeout
 		case err = <-errChan:
+			// 先处理已接收但尚未处理的数据，避免丢失最后一个数据项
+			select {
+			case line := <-lineChan:
+				if e := handler(line, false); e != nil {
+					return e
+				}
+			default:
+			}
 			if err == nil {
 				var empty T
 				return handler(empty, true)
@@ -109,6 +151,7 @@ func (stream *StreamReader[T]) ForEach(handler StreamDataHandler[T]) (err error)
 			return
 		case line := <-lineChan:
 			if err = handler(line, false); err != nil {
+				stream.abort(err)
 				return
 			}
 			stream.streamReturnIntervalTimer.Reset(stream.streamReturnIntervalTimeout)
@@ -116,26 +159,158 @@ func (stream *StreamReader[T]) ForEach(handler StreamDataHandler[T]) (err error)
 	}
 }
 
+// NewStreamReader 通过响应体新建流读取器，可用于回放缓存等非网络数据源
+func NewStreamReader[T Streamable](body io.ReadCloser, header http.Header, config HTTPClientConfig) (stream *StreamReader[T]) {
+	if config.ResponseDecoder == nil {
+		config.ResponseDecoder = &DefaultResponseDecoder{}
+	}
+	if config.EmptyMessagesLimit == 0 {
+		config.EmptyMessagesLimit = defaultEmptyMessagesLimit
+	}
+	if config.StreamReturnIntervalTimeout <= 0 {
+		config.StreamReturnIntervalTimeout = defaultStreamReturnIntervalTimeout
+	}
+	if header == nil {
+		header = make(http.Header)
+	}
+	return &StreamReader[T]{
+		emptyMessagesLimit:          config.EmptyMessagesLimit,
+		reader:                      bufio.NewReader(body),
+		response:                    &http.Response{Body: body, Header: header},
+		streamReturnIntervalTimeout: config.StreamReturnIntervalTimeout,
+		errAccumulator:              NewErrorAccumulator(),
+		responseDecoder:             config.ResponseDecoder,
+		startTime:                   time.Now(),
+		HttpHeader:                  HttpHeader(header),
+	}
+}
+
+// funcStreamBody 自定义数据源的响应体，关闭时调用 closer
+type funcStreamBody struct {
+	closer io.Closer
+}
+
+// Read 实现 io.Reader 接口，自定义数据源没有原始数据
+func (b funcStreamBody) Read(p []byte) (n int, err error) {
+	return 0, io.EOF
+}
+
+// Close 实现 io.Closer 接口
+func (b funcStreamBody) Close() (err error) {
+	if b.closer == nil {
+		return
+	}
+	return b.closer.Close()
+}
+
+// NewFuncStreamReader 通过接收函数新建流读取器，可用于拼接多个流等自定义数据源，关闭流时调用 closer（可为 nil）
+func NewFuncStreamReader[T Streamable](recv func() (response T, isFinished bool, err error), closer io.Closer, header http.Header, config HTTPClientConfig) (stream *StreamReader[T]) {
+	stream = NewStreamReader[T](funcStreamBody{closer: closer}, header, config)
+	stream.source = recv
+	return
+}
+
+// AddRecvHook 添加接收钩子，钩子函数会在每次 Recv 结束后按添加顺序调用（非并发安全，需在开始读取前添加）
+func (stream *StreamReader[T]) AddRecvHook(hook StreamRecvHook[T]) {
+	if hook == nil {
+		return
+	}
+	stream.WrapRecv(func(next StreamRecvFunc) (recv StreamRecvFunc) {
+		return func() (chunk any, isFinished bool, err error) {
+			chunk, isFinished, err = next()
+			response, _ := chunk.(T)
+			hook(response, isFinished, err)
+			return
+		}
+	})
+}
+
+// WrapRecv 包装接收函数，后添加的包装器位于外层（非并发安全，需在开始读取前添加）
+func (stream *StreamReader[T]) WrapRecv(wrapper StreamRecvWrapper) {
+	if stream == nil || wrapper == nil {
+		return
+	}
+	next := stream.recvFunc
+	if next == nil {
+		next = stream.recvChunk
+	}
+	stream.recvFunc = wrapper(next)
+}
+
 // Recv 接收数据
 func (stream *StreamReader[T]) Recv() (response T, isFinished bool, err error) {
-	var (
-		processingStartTime = time.Now()
-		rawLine             []byte
-	)
+	stream.recvMu.Lock()
+	defer stream.recvMu.Unlock()
+
+	return stream.recvLocked()
+}
+
+// recvLocked 接收数据，已发送结束事件后不再经过包装器（调用方需持有 recvMu）
+func (stream *StreamReader[T]) recvLocked() (response T, isFinished bool, err error) {
+	recv := stream.recvFunc
+	if recv == nil || stream.terminated {
+		recv = stream.recvChunk
+	}
+
+	var chunk any
+	chunk, isFinished, err = recv()
+	response, _ = chunk.(T)
+	if isFinished || err != nil {
+		stream.terminated = true
+	}
+	return
+}
+
+// recvChunk 从响应体读取并解析一个数据块
+func (stream *StreamReader[T]) recvChunk() (chunk any, isFinished bool, err error) {
+	var response T
+	if stream.isFinished {
+		return response, true, nil
+	}
+	if err = stream.abortError(); err != nil {
+		return response, false, err
+	}
+
+	var processingStartTime = time.Now()
+	if stream.source != nil {
+		// 从自定义数据源读取
+		if response, isFinished, err = stream.source(); err != nil {
+			if abortErr := stream.abortError(); abortErr != nil {
+				err = abortErr
+			}
+			return response, false, err
+		}
+		if isFinished {
+			stream.isFinished = true
+			return response, true, nil
+		}
+		stream.updateStats(&response, processingStartTime)
+		return response, false, nil
+	}
+
+	var rawLine []byte
 	if rawLine, err = stream.processLines(); err != nil {
 		if stream.isFinished {
-			isFinished = true
-			err = nil
+			return response, true, nil
 		}
-		return
+		if abortErr := stream.abortError(); abortErr != nil {
+			err = abortErr
+		}
+		return response, false, err
 	}
 	// 解析数据
 	if err = stream.responseDecoder.Decode(bytes.NewReader(rawLine), &response); err != nil {
-		return
+		return response, false, err
 	}
 	// 更新统计信息
+	stream.updateStats(&response, processingStartTime)
+	return response, false, nil
+}
+
+// updateStats 更新统计信息
+func (stream *StreamReader[T]) updateStats(response *T, processingStartTime time.Time) {
 	stream.chunkCount++
-	if statsReceiver, ok := Streamable(&response).(StreamStatsReceiver); ok {
+	if statsReceiver, ok := Streamable(response).(StreamStatsReceiver); ok {
 		now := time.Now()
 		stats := StreamStats{
 			TotalDurationMs: now.Sub(stream.startTime).Milliseconds(),
@@ -146,7 +321,24 @@ func (stream *StreamReader[T]) Recv() (response T, isFinished bool, err error) {
 		}
 		statsReceiver.SetStreamStats(stats)
 	}
-	return
+}
+
+// abort 设置中止原因，仅第一次设置生效
+func (stream *StreamReader[T]) abort(err error) {
+	stream.abortMu.Lock()
+	defer stream.abortMu.Unlock()
+
+	if stream.abortErr == nil {
+		stream.abortErr = err
+	}
+}
+
+// abortError 获取中止原因
+func (stream *StreamReader[T]) abortError() (err error) {
+	stream.abortMu.Lock()
+	defer stream.abortMu.Unlock()
+
+	return stream.abortErr
 }
 
 // RecvRaw 接收原始数据
@@ -232,7 +424,16 @@ func (stream *StreamReader[T]) unmarshalError() (errResp map[string]any) {
 	return
 }
 
-// Close 关闭流
+// Close 关闭流，流尚未结束时包装器会收到 ErrStreamClosed（或 ForEach 的中止原因）作为结束事件，TrackStream 将其记录为主动关闭而不是失败
 func (stream *StreamReader[T]) Close() (err error) {
-	return stream.response.Body.Close()
+	stream.abort(ErrStreamClosed)
+	err = stream.response.Body.Close()
+	// 没有正在执行的 Recv 时直接发送结束事件，否则由正在执行的 Recv 返回中止原因
+	if stream.recvFunc != nil && stream.recvMu.TryLock() {
+		if !stream.terminated {
+			_, _, _ = stream.recvLocked()
+		}
+		stream.recvMu.Unlock()
+	}
+	return
 }
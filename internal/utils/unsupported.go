@@ -0,0 +1,74 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-28 10:16:42
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-28 11:37:25
+ * @Description: 检查提供商不支持的字段
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package utils
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// UnsupportedFields 获取对象中设置了非零值、但序列化时会因 providers 标签不包含当前提供商而被丢弃的字段路径
+//
+//	字段路径使用 JSON 字段名，如 `reasoning_effort`、`messages[0].multimodal_content`
+//	带有 `unsupported:"ignore"` 标签的字段不会被报告，用于当前提供商通过其他方式处理的字段（如 AliBL 的 stream 放在请求头中）
+//	不支持的结构体字段只报告该字段本身，不再检查其内部字段
+func (s *Serializer) UnsupportedFields(obj any) (fields []string) {
+	s.collectUnsupportedFields(reflect.ValueOf(obj), "", 0, &fields)
+	return
+}
+
+// collectUnsupportedFields 递归收集不支持的字段
+func (s *Serializer) collectUnsupportedFields(v reflect.Value, path string, depth int, fields *[]string) {
+	if depth > s.maxDepth {
+		return
+	}
+	// 处理指针和接口
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		t := v.Type()
+		for i := range t.NumField() {
+			field, fieldValue := t.Field(i), v.Field(i)
+			if !field.IsExported() || field.Tag.Get("json") == "-" {
+				continue
+			}
+			// 匿名嵌入字段的内部字段与外层字段处于同一层级
+			fieldPath := path
+			if !field.Anonymous {
+				if fieldPath = s.getJsonFieldName(field); path != "" {
+					fieldPath = path + "." + fieldPath
+				}
+			}
+			if !s.isSupported(field.Tag.Get("providers")) {
+				if !fieldValue.IsZero() && field.Tag.Get("unsupported") != "ignore" {
+					*fields = append(*fields, fieldPath)
+				}
+				continue
+			}
+			s.collectUnsupportedFields(fieldValue, fieldPath, depth+1, fields)
+		}
+	case reflect.Slice, reflect.Array:
+		// 元素为基本类型时无需检查（如 []byte 类型的文件内容）
+		switch v.Type().Elem().Kind() {
+		case reflect.Struct, reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Array:
+		default:
+			return
+		}
+		for i := range v.Len() {
+			s.collectUnsupportedFields(v.Index(i), fmt.Sprintf("%s[%d]", path, i), depth+1, fields)
+		}
+	}
+}
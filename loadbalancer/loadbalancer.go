@@ -2,7 +2,7 @@
  * @Author: liusuxian 382185882@qq.com
  * @Date: 2025-05-27 15:03:40
  * @LastEditors: liusuxian 382185882@qq.com
- * @LastEditTime: 2025-06-16 15:10:54
+ * @LastEditTime: 2025-08-05 14:40:55
  * @Description: 负载均衡器
  *
  * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
@@ -28,10 +28,11 @@ var (
 
 // APIKey API密钥
 type APIKey struct {
-	Key       string // 密钥
-	Times     uint32 // 请求次数
-	Available bool   // 是否可用
-	Weight    uint32 // 权重
+	Key           string    // 密钥
+	Times         uint32    // 请求次数
+	Available     bool      // 是否可用
+	Weight        uint32    // 权重
+	CoolDownUntil time.Time // 冷却截止时间，提供商的限流额度耗尽时设置，在此之前仅在没有其他可用APIKey时选择
 }
 
 // LoadBalancer 负载均衡器
@@ -63,22 +64,34 @@ func (lb *LoadBalancer) GetAPIKey() (apiKey *APIKey, err error) {
 	if len(lb.apiKeyList) == 0 {
 		return nil, errEmptyAPIKeyList
 	}
-	// 选择使用次数最少的APIKey
+	// 选择未冷却且使用次数最少的APIKey，全部冷却时选择最早结束冷却的APIKey
 	lb.mu.RLock()
 	var (
+		now            = time.Now()
 		selectedAPIKey *APIKey
+		coolingAPIKey  *APIKey
 		minScore       = math.MaxFloat64
 	)
 	for _, v := range lb.apiKeyList {
-		if v.Available {
-			score := float64(v.Times) / float64(v.Weight)
-			if score < minScore {
-				selectedAPIKey = v
-				minScore = score
+		if !v.Available {
+			continue
+		}
+		if v.CoolDownUntil.After(now) {
+			if coolingAPIKey == nil || v.CoolDownUntil.Before(coolingAPIKey.CoolDownUntil) {
+				coolingAPIKey = v
 			}
+			continue
+		}
+		score := float64(v.Times) / float64(v.Weight)
+		if score < minScore {
+			selectedAPIKey = v
+			minScore = score
 		}
 	}
 	lb.mu.RUnlock()
+	if selectedAPIKey == nil {
+		selectedAPIKey = coolingAPIKey
+	}
 	// 如果未找到可用的APIKey，则返回错误
 	if selectedAPIKey == nil {
 		return nil, errNoAPIKeyAvailable
@@ -108,6 +121,46 @@ func (lb *LoadBalancer) SetAvailability(key string, available bool) (err error)
 	return
 }
 
+// CoolDown 设置指定APIKey的冷却截止时间，用于提供商返回的限流额度耗尽时暂时避开该APIKey
+func (lb *LoadBalancer) CoolDown(key string, until time.Time) (err error) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	// 获取APIKey的索引
+	index := slices.IndexFunc(lb.apiKeyList, func(apiKey *APIKey) bool {
+		return apiKey.Key == key
+	})
+	// 如果APIKey不存在，则返回错误
+	if index == -1 {
+		return errAPIKeyNotFound
+	}
+	// 仅延长冷却时间，避免并发响应的旧信息覆盖
+	if until.After(lb.apiKeyList[index].CoolDownUntil) {
+		lb.apiKeyList[index].CoolDownUntil = until
+	}
+	return
+}
+
+// CoolingUntil 所有可用的APIKey都在冷却时返回最早结束冷却的时间，仍有未冷却的APIKey时返回零值
+func (lb *LoadBalancer) CoolingUntil() (until time.Time) {
+	lb.mu.RLock()
+	defer lb.mu.RUnlock()
+
+	now := time.Now()
+	for _, v := range lb.apiKeyList {
+		if !v.Available {
+			continue
+		}
+		if !v.CoolDownUntil.After(now) {
+			return time.Time{}
+		}
+		if until.IsZero() || v.CoolDownUntil.Before(until) {
+			until = v.CoolDownUntil
+		}
+	}
+	return
+}
+
 // RegisterAPIKey 注册新的APIKey
 func (lb *LoadBalancer) RegisterAPIKey(key string) (err error) {
 	lb.mu.Lock()
@@ -191,10 +244,11 @@ func (lb *LoadBalancer) GetAPIKeyList() (apiKeyList []*APIKey) {
 	for i, apiKey := range lb.apiKeyList {
 		// 深拷贝：创建新的APIKey对象
 		apiKeyList[i] = &APIKey{
-			Key:       apiKey.Key,
-			Times:     apiKey.Times,
-			Available: apiKey.Available,
-			Weight:    apiKey.Weight,
+			Key:           apiKey.Key,
+			Times:         apiKey.Times,
+			Available:     apiKey.Available,
+			Weight:        apiKey.Weight,
+			CoolDownUntil: apiKey.CoolDownUntil,
 		}
 	}
 	return
@@ -209,18 +263,24 @@ func (lb *LoadBalancer) GetStats() (stats map[string]any) {
 	var (
 		totalAPIKey     = len(lb.apiKeyList)
 		availableAPIKey = 0
+		coolingAPIKey   = 0
 		totalRequests   = uint32(0)
+		now             = time.Now()
 	)
 
 	for _, apiKey := range lb.apiKeyList {
 		if apiKey.Available {
 			availableAPIKey++
+			if apiKey.CoolDownUntil.After(now) {
+				coolingAPIKey++
+			}
 		}
 		totalRequests += apiKey.Times
 	}
 
 	stats["total_api_key"] = totalAPIKey
 	stats["available_api_key"] = availableAPIKey
+	stats["cooling_api_key"] = coolingAPIKey
 	stats["total_requests"] = totalRequests
 	return stats
 }
@@ -2,7 +2,7 @@
  * @Author: liusuxian 382185882@qq.com
  * @Date: 2025-05-27 21:30:00
  * @LastEditors: liusuxian 382185882@qq.com
- * @LastEditTime: 2025-06-16 15:12:45
+ * @LastEditTime: 2025-08-05 14:40:55
  * @Description: 负载均衡器测试
  *
  * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
@@ -12,6 +12,7 @@ package loadbalancer
 import (
 	"sync"
 	"testing"
+	"time"
 )
 
 // TestNewLoadBalancer tests creating load balancer
@@ -516,3 +517,43 @@ func BenchmarkSetAvailability(b *testing.B) {
 		lb.SetAvailability(key, available)
 	}
 }
+
+// TestCoolDown tests skipping API keys whose provider quota is exhausted
+func TestCoolDown(t *testing.T) {
+	lb := NewLoadBalancer([]string{"key1", "key2"})
+	if err := lb.CoolDown("key3", time.Now().Add(time.Hour)); err != errAPIKeyNotFound {
+		t.Errorf("expected error %v, got %v", errAPIKeyNotFound, err)
+	}
+
+	// Cooling key should not be selected
+	lb.CoolDown("key1", time.Now().Add(time.Hour))
+	for range 3 {
+		if apiKey, _ := lb.GetAPIKey(); apiKey.Key != "key2" {
+			t.Errorf("expected key2, got %s", apiKey.Key)
+		}
+	}
+	if stats := lb.GetStats(); stats["cooling_api_key"] != 1 {
+		t.Errorf("expected cooling API key count to be 1, got %v", stats["cooling_api_key"])
+	}
+
+	if until := lb.CoolingUntil(); !until.IsZero() {
+		t.Errorf("expected zero cooling until while key2 is available, got %v", until)
+	}
+
+	// When all keys are cooling, the one that resumes first is selected
+	resumeAt := time.Now().Add(time.Minute)
+	lb.CoolDown("key2", resumeAt)
+	if apiKey, err := lb.GetAPIKey(); err != nil || apiKey.Key != "key2" {
+		t.Errorf("expected key2, got %v, err = %v", apiKey, err)
+	}
+	if until := lb.CoolingUntil(); !until.Equal(resumeAt) {
+		t.Errorf("expected cooling until %v, got %v", resumeAt, until)
+	}
+
+	// Expired cool down is ignored
+	lb = NewLoadBalancer([]string{"key1"})
+	lb.CoolDown("key1", time.Now().Add(-time.Second))
+	if stats := lb.GetStats(); stats["cooling_api_key"] != 0 {
+		t.Errorf("expected cooling API key count to be 0, got %v", stats["cooling_api_key"])
+	}
+}
@@ -0,0 +1,278 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-23 09:46:15
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-08-07 10:21:46
+ * @Description: 多轮对话的消息管理，支持固定消息、按 token 预算裁剪和 JSON 序列化
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package memory
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/Mrzhouyl/go-aisdk/consts"
+	"github.com/Mrzhouyl/go-aisdk/models"
+	"github.com/Mrzhouyl/go-aisdk/tokenizer"
+)
+
+const (
+	defaultKeepLastN      = 10  // 默认保留的最近消息数
+	defaultSummaryReserve = 512 // 默认为摘要预留的 token 数
+)
+
+// Strategy 裁剪策略
+type Strategy string
+
+const (
+	// StrategySlidingWindow 滑动窗口，从最早的消息开始删除直到满足预算，系统消息需要固定才会保留
+	StrategySlidingWindow Strategy = "sliding_window"
+	// StrategyKeepSystemLastN 保留系统消息和最近 KeepLastN 条消息，仍超出预算时继续删除最早的非系统消息
+	StrategyKeepSystemLastN Strategy = "keep_system_last_n"
+	// StrategySummarize 将较早的消息通过 Summarizer 压缩为摘要，摘要作为系统消息插入到开头的系统消息之后
+	StrategySummarize Strategy = "summarize"
+)
+
+// TokenCounter token 计数函数
+type TokenCounter func(messages []models.ChatMessage) (n int, err error)
+
+// ConversationConfig 对话配置
+type ConversationConfig struct {
+	Provider       consts.Provider // 提供商，用于计算 token
+	Model          string          // 模型，用于计算 token
+	MaxTokens      int             // token 预算，小于等于 0 时不裁剪
+	Strategy       Strategy        // 裁剪策略，默认为 StrategySlidingWindow
+	KeepLastN      int             // StrategyKeepSystemLastN 保留的最近消息数，默认为 10
+	Summarizer     Summarizer      // StrategySummarize 使用的摘要生成器
+	SummaryReserve int             // StrategySummarize 为摘要预留的 token 数，默认为 512
+	Counter        TokenCounter    // token 计数函数，默认使用 tokenizer.CountChatTokens，词表未加载时估算
+}
+
+// entry 对话中的消息
+type entry struct {
+	message models.ChatMessage // 消息
+	pinned  bool               // 是否固定，固定的消息不会被裁剪
+	summary bool               // 是否是摘要
+}
+
+// Conversation 对话（并发安全）
+//
+// 裁剪时以"轮"为单位删除消息：包含工具调用的助手消息和其后的工具消息属于同一轮，不会被拆开；最后一轮始终保留
+type Conversation struct {
+	config    ConversationConfig
+	mu        sync.Mutex
+	entries   []entry
+	version   uint64     // 除追加以外的修改次数（固定、取消固定、反序列化），生成摘要期间发生修改时放弃本次裁剪
+	summaryMu sync.Mutex // 保证同一时刻只有一个摘要在生成
+}
+
+// NewConversation 新建对话
+func NewConversation(config ConversationConfig) (c *Conversation) {
+	// 设置默认值
+	if config.Strategy == "" {
+		config.Strategy = StrategySlidingWindow
+	}
+	if config.KeepLastN <= 0 {
+		config.KeepLastN = defaultKeepLastN
+	}
+	if config.SummaryReserve <= 0 {
+		config.SummaryReserve = defaultSummaryReserve
+	}
+	if config.Counter == nil {
+		provider, model := config.Provider, config.Model
+		config.Counter = func(messages []models.ChatMessage) (n int, err error) {
+			return tokenizer.CountChatTokens(provider, model, messages, tokenizer.WithEstimate())
+		}
+	}
+	return &Conversation{config: config}
+}
+
+// Append 追加消息
+func (c *Conversation) Append(messages ...models.ChatMessage) {
+	c.append(false, messages)
+}
+
+// AppendPinned 追加固定的消息
+func (c *Conversation) AppendPinned(messages ...models.ChatMessage) {
+	c.append(true, messages)
+}
+
+// append 追加消息
+func (c *Conversation) append(pinned bool, messages []models.ChatMessage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, message := range messages {
+		if message != nil {
+			c.entries = append(c.entries, entry{message: message, pinned: pinned})
+		}
+	}
+}
+
+// Pin 固定第 index 条消息
+func (c *Conversation) Pin(index int) (err error) {
+	return c.setPinned(index, true)
+}
+
+// Unpin 取消固定第 index 条消息
+func (c *Conversation) Unpin(index int) (err error) {
+	return c.setPinned(index, false)
+}
+
+// setPinned 设置消息是否固定
+func (c *Conversation) setPinned(index int, pinned bool) (err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if index < 0 || index >= len(c.entries) {
+		return fmt.Errorf("message index %d out of range [0, %d)", index, len(c.entries))
+	}
+	c.entries[index].pinned = pinned
+	c.version++
+	return
+}
+
+// IsPinned 判断第 index 条消息是否固定
+func (c *Conversation) IsPinned(index int) (pinned bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return index >= 0 && index < len(c.entries) && c.entries[index].pinned
+}
+
+// Len 获取消息数量
+func (c *Conversation) Len() (n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.entries)
+}
+
+// Messages 获取所有消息，用于设置 ChatRequest.Messages
+func (c *Conversation) Messages() (messages []models.ChatMessage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.messages()
+}
+
+// Tokens 计算所有消息的 token 数量
+func (c *Conversation) Tokens() (n int, err error) {
+	return c.config.Counter(c.Messages())
+}
+
+// messages 获取所有消息
+func (c *Conversation) messages() (messages []models.ChatMessage) {
+	messages = make([]models.ChatMessage, 0, len(c.entries))
+	for _, e := range c.entries {
+		messages = append(messages, e.message)
+	}
+	return
+}
+
+// entryJSON 消息的 JSON 格式
+type entryJSON struct {
+	Role    string          `json:"role"`              // 消息角色，用于还原消息类型
+	Pinned  bool            `json:"pinned,omitempty"`  // 是否固定
+	Summary bool            `json:"summary,omitempty"` // 是否是摘要
+	Message json.RawMessage `json:"message"`           // 消息内容
+}
+
+// conversationJSON 对话的 JSON 格式
+type conversationJSON struct {
+	Messages []entryJSON `json:"messages"`
+}
+
+// 以下类型与消息类型的字段相同但不包含 MarshalJSON 方法，用于保存消息的完整字段（消息的 MarshalJSON 会按提供商过滤字段）
+type (
+	systemMessage    models.SystemMessage
+	developerMessage models.DeveloperMessage
+	userMessage      models.UserMessage
+	assistantMessage models.AssistantMessage
+	toolMessage      models.ToolMessage
+)
+
+// MarshalJSON 序列化JSON
+func (c *Conversation) MarshalJSON() (b []byte, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	data := conversationJSON{Messages: make([]entryJSON, 0, len(c.entries))}
+	for i, e := range c.entries {
+		item := entryJSON{Pinned: e.pinned, Summary: e.summary}
+		switch m := e.message.(type) {
+		case *models.SystemMessage:
+			item.Role = "system"
+			item.Message, err = json.Marshal((*systemMessage)(m))
+		case *models.DeveloperMessage:
+			item.Role = "developer"
+			item.Message, err = json.Marshal((*developerMessage)(m))
+		case *models.UserMessage:
+			item.Role = "user"
+			item.Message, err = json.Marshal((*userMessage)(m))
+		case *models.AssistantMessage:
+			item.Role = "assistant"
+			item.Message, err = json.Marshal((*assistantMessage)(m))
+		case *models.ToolMessage:
+			item.Role = "tool"
+			item.Message, err = json.Marshal((*toolMessage)(m))
+		default:
+			err = fmt.Errorf("unsupported message type %T", e.message)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("marshal message %d failed: %w", i, err)
+		}
+		data.Messages = append(data.Messages, item)
+	}
+	return json.Marshal(data)
+}
+
+// UnmarshalJSON 反序列化JSON，替换当前的所有消息
+func (c *Conversation) UnmarshalJSON(b []byte) (err error) {
+	var data conversationJSON
+	if err = json.Unmarshal(b, &data); err != nil {
+		return
+	}
+	entries := make([]entry, 0, len(data.Messages))
+	for i, item := range data.Messages {
+		var message models.ChatMessage
+		switch item.Role {
+		case "system":
+			var m systemMessage
+			err = json.Unmarshal(item.Message, &m)
+			message = (*models.SystemMessage)(&m)
+		case "developer":
+			var m developerMessage
+			err = json.Unmarshal(item.Message, &m)
+			message = (*models.DeveloperMessage)(&m)
+		case "user":
+			var m userMessage
+			err = json.Unmarshal(item.Message, &m)
+			message = (*models.UserMessage)(&m)
+		case "assistant":
+			var m assistantMessage
+			err = json.Unmarshal(item.Message, &m)
+			message = (*models.AssistantMessage)(&m)
+		case "tool":
+			var m toolMessage
+			err = json.Unmarshal(item.Message, &m)
+			message = (*models.ToolMessage)(&m)
+		default:
+			err = fmt.Errorf("unsupported message role [%s]", item.Role)
+		}
+		if err != nil {
+			return fmt.Errorf("unmarshal message %d failed: %w", i, err)
+		}
+		entries = append(entries, entry{message: message, pinned: item.Pinned, summary: item.Summary})
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = entries
+	c.version++
+	return
+}
@@ -0,0 +1,286 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-23 14:40:19
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-08-07 10:21:46
+ * @Description:
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Mrzhouyl/go-aisdk/httpclient"
+	"github.com/Mrzhouyl/go-aisdk/models"
+)
+
+// countMessages 每条消息计 10 个 token
+func countMessages(messages []models.ChatMessage) (n int, err error) {
+	return len(messages) * 10, nil
+}
+
+// contents 获取消息的文本内容，工具调用显示为 call:<id>
+func contents(messages []models.ChatMessage) (result []string) {
+	for _, message := range messages {
+		switch m := message.(type) {
+		case *models.SystemMessage:
+			result = append(result, m.Content)
+		case *models.UserMessage:
+			result = append(result, m.Content)
+		case *models.AssistantMessage:
+			if len(m.ToolCalls) > 0 {
+				result = append(result, "call:"+m.ToolCalls[0].ID)
+				continue
+			}
+			result = append(result, m.Content)
+		case *models.ToolMessage:
+			result = append(result, "result:"+m.ToolCallID)
+		}
+	}
+	return
+}
+
+// history 生成 system、u1、a1、u2、a2、u3 六条消息
+func history() (messages []models.ChatMessage) {
+	return []models.ChatMessage{
+		&models.UserMessage{Content: "u1"},
+		&models.AssistantMessage{Content: "a1"},
+		&models.UserMessage{Content: "u2"},
+		&models.AssistantMessage{Content: "a2"},
+		&models.UserMessage{Content: "u3"},
+	}
+}
+
+func TestConversation_Trim(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      ConversationConfig
+		pinSystem   bool
+		pin         []int
+		messages    []models.ChatMessage
+		want        []string
+		wantRemoved int
+	}{
+		{
+			name:        "sliding window",
+			config:      ConversationConfig{MaxTokens: 40},
+			messages:    history(),
+			want:        []string{"a1", "u2", "a2", "u3"},
+			wantRemoved: 2,
+		},
+		{
+			name:        "sliding window pinned",
+			config:      ConversationConfig{MaxTokens: 40},
+			pinSystem:   true,
+			pin:         []int{2},
+			messages:    history(),
+			want:        []string{"system", "a1", "a2", "u3"},
+			wantRemoved: 2,
+		},
+		{
+			name:      "tool calls are not split",
+			config:    ConversationConfig{MaxTokens: 50},
+			pinSystem: true,
+			messages: []models.ChatMessage{
+				&models.UserMessage{Content: "u1"},
+				&models.AssistantMessage{ToolCalls: []models.ToolCalls{{ID: "c1"}, {ID: "c2"}}},
+				&models.ToolMessage{ToolCallID: "c1"},
+				&models.ToolMessage{ToolCallID: "c2"},
+				&models.AssistantMessage{Content: "a1"},
+				&models.UserMessage{Content: "u2"},
+			},
+			want:        []string{"system", "a1", "u2"},
+			wantRemoved: 4,
+		},
+		{
+			name:        "keep system last n",
+			config:      ConversationConfig{MaxTokens: 1000, Strategy: StrategyKeepSystemLastN, KeepLastN: 2},
+			pin:         []int{1},
+			messages:    history(),
+			want:        []string{"system", "u1", "a2", "u3"},
+			wantRemoved: 2,
+		},
+		{
+			name:        "keep system last n over budget",
+			config:      ConversationConfig{MaxTokens: 20, Strategy: StrategyKeepSystemLastN, KeepLastN: 3},
+			messages:    history(),
+			want:        []string{"system", "u3"},
+			wantRemoved: 4,
+		},
+		{
+			name:     "within budget",
+			config:   ConversationConfig{MaxTokens: 60},
+			messages: history(),
+			want:     []string{"system", "u1", "a1", "u2", "a2", "u3"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config.Counter = countMessages
+			c := NewConversation(tt.config)
+			if tt.pinSystem {
+				c.AppendPinned(&models.SystemMessage{Content: "system"})
+			} else {
+				c.Append(&models.SystemMessage{Content: "system"})
+			}
+			c.Append(tt.messages...)
+			for _, i := range tt.pin {
+				if err := c.Pin(i); err != nil {
+					t.Fatalf("Pin() error = %v", err)
+				}
+			}
+			removed, err := c.Trim(context.Background())
+			if err != nil {
+				t.Fatalf("Trim() error = %v", err)
+			}
+			if got := contents(c.Messages()); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Trim() messages = %v, want %v", got, tt.want)
+			}
+			if removed != tt.wantRemoved {
+				t.Errorf("Trim() removed = %d, want %d", removed, tt.wantRemoved)
+			}
+		})
+	}
+}
+
+// summaryClient 记录请求并返回固定摘要的客户端
+type summaryClient struct {
+	requests []models.ChatRequest
+}
+
+func (c *summaryClient) CreateChatCompletion(ctx context.Context, request models.ChatRequest, opts ...httpclient.HTTPClientOption) (response models.ChatResponse, err error) {
+	c.requests = append(c.requests, request)
+	response.Choices = []models.ChatChoice{{Message: &models.ChatCompletionMessage{Content: " summary" + string(rune('0'+len(c.requests))) + " "}}}
+	return
+}
+
+func TestConversation_Summarize(t *testing.T) {
+	client := &summaryClient{}
+	c := NewConversation(ConversationConfig{
+		MaxTokens:      40,
+		Strategy:       StrategySummarize,
+		SummaryReserve: 10,
+		Summarizer:     NewChatSummarizer(ChatSummarizerConfig{Client: client, Model: "cheap", MaxTokens: 100}),
+		Counter:        countMessages,
+	})
+	c.Append(&models.SystemMessage{Content: "system"})
+	c.Append(history()...)
+
+	if _, err := c.Trim(context.Background()); err != nil {
+		t.Fatalf("Trim() error = %v", err)
+	}
+	want := []string{"system", summaryPrefix + "summary1", "a2", "u3"}
+	if got := contents(c.Messages()); !reflect.DeepEqual(got, want) {
+		t.Fatalf("messages = %v, want %v", got, want)
+	}
+	transcript := client.requests[0].Messages[1].(*models.UserMessage).Content
+	if !strings.Contains(transcript, "user: u1\nassistant: a1\nuser: u2\n") || strings.Contains(transcript, "Previous summary") {
+		t.Errorf("unexpected summarizer input: %q", transcript)
+	}
+	if client.requests[0].Model != "cheap" || models.IntValue(client.requests[0].MaxCompletionTokens) != 100 {
+		t.Errorf("unexpected summarizer request: %+v", client.requests[0])
+	}
+
+	// 再次裁剪时合并已有的摘要
+	c.Append(&models.AssistantMessage{Content: "a3"}, &models.UserMessage{Content: "u4"})
+	if _, err := c.Trim(context.Background()); err != nil {
+		t.Fatalf("Trim() error = %v", err)
+	}
+	want = []string{"system", summaryPrefix + "summary2", "u4"}
+	if got := contents(c.Messages()); !reflect.DeepEqual(got, want) {
+		t.Fatalf("messages = %v, want %v", got, want)
+	}
+	if transcript = client.requests[1].Messages[1].(*models.UserMessage).Content; !strings.HasPrefix(transcript, "Previous summary:\nsummary1\n\n") {
+		t.Errorf("previous summary not passed: %q", transcript)
+	}
+
+	// 没有摘要生成器时返回错误
+	c = NewConversation(ConversationConfig{MaxTokens: 10, Strategy: StrategySummarize, Counter: countMessages})
+	c.Append(history()...)
+	if _, err := c.Trim(context.Background()); err == nil {
+		t.Error("expected error without summarizer")
+	}
+}
+
+func TestConversation_SummarizeUnlocked(t *testing.T) {
+	var (
+		c      *Conversation
+		modify func()
+	)
+	c = NewConversation(ConversationConfig{
+		MaxTokens:      40,
+		Strategy:       StrategySummarize,
+		SummaryReserve: 10,
+		Summarizer: SummarizerFunc(func(ctx context.Context, previous string, messages []models.ChatMessage) (summary string, err error) {
+			// 生成摘要时不持有锁，可以修改对话
+			modify()
+			return "summary", nil
+		}),
+		Counter: countMessages,
+	})
+	c.Append(&models.SystemMessage{Content: "system"})
+	c.Append(history()...)
+
+	// 期间追加的消息被保留
+	modify = func() { c.Append(&models.AssistantMessage{Content: "a3"}) }
+	if _, err := c.Trim(context.Background()); err != nil {
+		t.Fatalf("Trim() error = %v", err)
+	}
+	want := []string{"system", summaryPrefix + "summary", "a2", "u3", "a3"}
+	if got := contents(c.Messages()); !reflect.DeepEqual(got, want) {
+		t.Fatalf("messages = %v, want %v", got, want)
+	}
+
+	// 期间固定消息时放弃本次裁剪
+	c.Append(&models.UserMessage{Content: "u4"})
+	modify = func() { _ = c.Pin(2) }
+	if _, err := c.Trim(context.Background()); !errors.Is(err, errConversationModified) {
+		t.Fatalf("Trim() error = %v, want errConversationModified", err)
+	}
+	want = []string{"system", summaryPrefix + "summary", "a2", "u3", "a3", "u4"}
+	if got := contents(c.Messages()); !reflect.DeepEqual(got, want) || !c.IsPinned(2) {
+		t.Fatalf("messages = %v, want %v", got, want)
+	}
+}
+
+func TestConversation_JSON(t *testing.T) {
+	c := NewConversation(ConversationConfig{})
+	c.AppendPinned(&models.SystemMessage{Content: "system"})
+	c.Append(
+		&models.UserMessage{MultimodalContent: []models.ChatUserMsgPart{
+			{Type: models.ChatUserMsgPartTypeText, Text: "look"},
+			{Type: models.ChatUserMsgPartTypeImageURL, ImageURL: &models.ChatUserMsgImageURL{URL: "https://example.com/a.png"}},
+		}},
+		&models.AssistantMessage{ToolCalls: []models.ToolCalls{{
+			ID:       "c1",
+			Type:     models.ToolTypeFunction,
+			Function: &models.ToolCallsFunction{Name: "f", Arguments: `{"a":1}`},
+		}}, ReasoningContent: "think"},
+		&models.ToolMessage{Content: "ok", ToolCallID: "c1"},
+	)
+	data, err := json.Marshal(c)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	restored := NewConversation(ConversationConfig{})
+	if err = json.Unmarshal(data, restored); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if !reflect.DeepEqual(restored.Messages(), c.Messages()) {
+		t.Errorf("restored messages mismatch:\n%s", data)
+	}
+	if !restored.IsPinned(0) || restored.IsPinned(1) {
+		t.Error("pinned state not restored")
+	}
+	if err = json.Unmarshal([]byte(`{"messages":[{"role":"unknown","message":{}}]}`), restored); err == nil {
+		t.Error("expected error for unknown role")
+	}
+}
@@ -0,0 +1,139 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-23 13:52:30
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-23 16:20:48
+ * @Description: 对话摘要生成器
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package memory
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Mrzhouyl/go-aisdk/consts"
+	"github.com/Mrzhouyl/go-aisdk/httpclient"
+	"github.com/Mrzhouyl/go-aisdk/models"
+)
+
+// defaultSummaryPrompt 默认的摘要提示词
+const defaultSummaryPrompt = "You compress chat histories. Merge the previous summary (if any) and the new messages into a concise summary " +
+	"that keeps facts, decisions, user preferences, open questions and tool results needed to continue the conversation. " +
+	"Reply with the summary only, in the language of the conversation."
+
+// Client 聊天客户端，*aisdk.SDKClient 实现了该接口
+type Client interface {
+	CreateChatCompletion(ctx context.Context, request models.ChatRequest, opts ...httpclient.HTTPClientOption) (response models.ChatResponse, err error)
+}
+
+// Summarizer 摘要生成器，将已有的摘要和被裁剪的消息合并为新的摘要
+type Summarizer interface {
+	Summarize(ctx context.Context, previous string, messages []models.ChatMessage) (summary string, err error)
+}
+
+// SummarizerFunc 摘要生成函数
+type SummarizerFunc func(ctx context.Context, previous string, messages []models.ChatMessage) (summary string, err error)
+
+// Summarize 生成摘要
+func (f SummarizerFunc) Summarize(ctx context.Context, previous string, messages []models.ChatMessage) (summary string, err error) {
+	return f(ctx, previous, messages)
+}
+
+// ChatSummarizerConfig 通过聊天模型生成摘要的配置
+type ChatSummarizerConfig struct {
+	Client    Client                        // 聊天客户端
+	Provider  consts.Provider               // 提供商
+	Model     string                        // 模型，建议使用更便宜的模型
+	Prompt    string                        // 系统提示词，默认要求模型合并已有摘要和新消息
+	MaxTokens int                           // 摘要的最大 token 数，小于等于 0 时不限制
+	Opts      []httpclient.HTTPClientOption // 调用模型时的 HTTP 客户端选项
+}
+
+// ChatSummarizer 通过聊天模型生成摘要
+type ChatSummarizer struct {
+	config ChatSummarizerConfig
+}
+
+// NewChatSummarizer 新建通过聊天模型生成摘要的摘要生成器
+func NewChatSummarizer(config ChatSummarizerConfig) (s *ChatSummarizer) {
+	// 设置默认值
+	if config.Prompt == "" {
+		config.Prompt = defaultSummaryPrompt
+	}
+	return &ChatSummarizer{config: config}
+}
+
+// Summarize 生成摘要
+func (s *ChatSummarizer) Summarize(ctx context.Context, previous string, messages []models.ChatMessage) (summary string, err error) {
+	var content strings.Builder
+	if previous != "" {
+		fmt.Fprintf(&content, "Previous summary:\n%s\n\n", previous)
+	}
+	content.WriteString("New messages:\n")
+	content.WriteString(Transcript(messages))
+
+	request := models.ChatRequest{
+		Provider: s.config.Provider,
+		Model:    s.config.Model,
+		Messages: []models.ChatMessage{
+			&models.SystemMessage{Content: s.config.Prompt},
+			&models.UserMessage{Content: content.String()},
+		},
+	}
+	if s.config.MaxTokens > 0 {
+		request.MaxCompletionTokens = models.Int(s.config.MaxTokens)
+	}
+	var response models.ChatResponse
+	if response, err = s.config.Client.CreateChatCompletion(ctx, request, s.config.Opts...); err != nil {
+		return
+	}
+	if len(response.Choices) == 0 || response.Choices[0].Message == nil {
+		return "", fmt.Errorf("summarizer model returned no message")
+	}
+	return strings.TrimSpace(response.Choices[0].Message.Content), nil
+}
+
+// Transcript 将消息渲染为纯文本记录，用于生成摘要
+func Transcript(messages []models.ChatMessage) (text string) {
+	var b strings.Builder
+	for _, message := range messages {
+		switch m := message.(type) {
+		case *models.SystemMessage:
+			fmt.Fprintf(&b, "system: %s\n", m.Content)
+		case *models.DeveloperMessage:
+			fmt.Fprintf(&b, "developer: %s\n", m.Content)
+		case *models.UserMessage:
+			fmt.Fprintf(&b, "user: %s", m.Content)
+			for _, part := range m.MultimodalContent {
+				switch {
+				case part.Text != "":
+					fmt.Fprintf(&b, " %s", part.Text)
+				case part.ImageURL != nil:
+					b.WriteString(" [image]")
+				case part.InputAudio != nil:
+					b.WriteString(" [audio]")
+				case part.File != nil:
+					b.WriteString(" [file]")
+				case part.InputVideo != nil:
+					b.WriteString(" [video]")
+				}
+			}
+			b.WriteByte('\n')
+		case *models.AssistantMessage:
+			if m.Content != "" {
+				fmt.Fprintf(&b, "assistant: %s\n", m.Content)
+			}
+			for _, call := range m.ToolCalls {
+				if call.Function != nil {
+					fmt.Fprintf(&b, "assistant called tool %s(%s)\n", call.Function.Name, call.Function.Arguments)
+				}
+			}
+		case *models.ToolMessage:
+			fmt.Fprintf(&b, "tool result: %s\n", m.Content)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,246 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-23 11:08:52
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-08-07 10:21:46
+ * @Description: 按 token 预算裁剪对话
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/Mrzhouyl/go-aisdk/models"
+)
+
+// summaryPrefix 摘要消息的前缀
+const summaryPrefix = "Summary of the earlier conversation:\n"
+
+// turn 裁剪的最小单位，为 entries[start:end]
+type turn struct {
+	start int
+	end   int
+}
+
+var (
+	errConversationModified = errors.New("conversation was modified while summarizing") // 生成摘要期间对话被修改
+)
+
+// Trim 按配置的策略裁剪对话，使 token 数量不超过 MaxTokens，返回删除的消息数量
+//
+// 固定的消息和最后一轮不会被删除，因此裁剪后仍可能超出预算；StrategySummarize 调用模型生成摘要时不持有锁，
+// 期间追加的消息会保留，期间固定、取消固定消息或反序列化时放弃本次裁剪并返回错误
+func (c *Conversation) Trim(ctx context.Context) (removed int, err error) {
+	if c.config.Strategy == StrategySummarize {
+		return c.summarize(ctx)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.config.MaxTokens <= 0 || len(c.entries) == 0 {
+		return
+	}
+	before := len(c.entries)
+	switch c.config.Strategy {
+	case StrategySlidingWindow:
+		err = c.dropOldest(c.config.MaxTokens, c.removable(true))
+	case StrategyKeepSystemLastN:
+		c.dropBeforeLastN()
+		err = c.dropOldest(c.config.MaxTokens, c.removable(false))
+	default:
+		err = fmt.Errorf("unsupported memory strategy [%s]", c.config.Strategy)
+	}
+	return before - len(c.entries), err
+}
+
+// turns 将消息划分为轮，包含工具调用的助手消息和其后的工具消息属于同一轮
+func (c *Conversation) turns() (turns []turn) {
+	for i := 0; i < len(c.entries); {
+		end := i + 1
+		if m, ok := c.entries[i].message.(*models.AssistantMessage); ok && len(m.ToolCalls) > 0 {
+			for end < len(c.entries) && isToolMessage(c.entries[end].message) {
+				end++
+			}
+		}
+		turns = append(turns, turn{start: i, end: end})
+		i = end
+	}
+	return
+}
+
+// removable 返回判断一轮是否可以删除的函数，includeSystem 为 false 时系统消息不可删除；固定的消息、摘要和最后一轮不可删除
+func (c *Conversation) removable(includeSystem bool) (fn func(t turn, last bool) bool) {
+	return func(t turn, last bool) bool {
+		if last {
+			return false
+		}
+		for _, e := range c.entries[t.start:t.end] {
+			if e.pinned || e.summary || (!includeSystem && isSystemMessage(e.message)) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// dropOldest 从最早的一轮开始删除，直到 token 数量不超过预算或没有可删除的轮
+func (c *Conversation) dropOldest(budget int, removable func(t turn, last bool) bool) (err error) {
+	for {
+		var n int
+		if n, err = c.config.Counter(c.messages()); err != nil || n <= budget {
+			return
+		}
+		turns := c.turns()
+		i := slices.IndexFunc(turns, func(t turn) bool {
+			return removable(t, t.end == len(c.entries))
+		})
+		if i < 0 {
+			return
+		}
+		c.entries = slices.Delete(c.entries, turns[i].start, turns[i].end)
+	}
+}
+
+// dropBeforeLastN 删除最近 KeepLastN 条消息之前的非系统消息，保留的范围向前扩展到整轮
+func (c *Conversation) dropBeforeLastN() {
+	turns := c.turns()
+	removable := c.removable(false)
+	kept := 0
+	for i := len(turns) - 1; i >= 0; i-- {
+		t := turns[i]
+		if kept < c.config.KeepLastN {
+			kept += t.end - t.start
+			continue
+		}
+		if removable(t, t.end == len(c.entries)) {
+			c.entries = slices.Delete(c.entries, t.start, t.end)
+		}
+	}
+}
+
+// summaryPlan 摘要裁剪计划
+type summaryPlan struct {
+	entries  []entry              // 删除最早的若干轮和已有摘要后剩余的消息
+	size     int                  // 生成计划时的消息数量，之后追加的消息在应用计划时保留
+	dropped  []models.ChatMessage // 需要压缩为摘要的消息
+	previous string               // 已有的摘要
+	version  uint64               // 生成计划时的修改次数
+}
+
+// summarize 将最早的若干轮压缩为摘要，使剩余消息和摘要不超过预算，调用模型时不持有锁
+func (c *Conversation) summarize(ctx context.Context) (removed int, err error) {
+	if c.config.Summarizer == nil {
+		return 0, fmt.Errorf("memory strategy [%s] requires a summarizer", StrategySummarize)
+	}
+	c.summaryMu.Lock()
+	defer c.summaryMu.Unlock()
+
+	// 在锁内生成裁剪计划
+	c.mu.Lock()
+	plan, err := c.planSummary()
+	c.mu.Unlock()
+	if err != nil || plan == nil {
+		return
+	}
+	// 生成摘要
+	var summary string
+	if summary, err = c.config.Summarizer.Summarize(ctx, plan.previous, plan.dropped); err != nil {
+		return 0, fmt.Errorf("summarize conversation failed: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.version != plan.version || len(c.entries) < plan.size {
+		return 0, errConversationModified
+	}
+	before := len(c.entries)
+	entries := append(plan.entries, c.entries[plan.size:]...)
+	// 摘要插入到开头的系统消息之后
+	at := slices.IndexFunc(entries, func(e entry) bool { return !isSystemMessage(e.message) })
+	if at < 0 {
+		at = len(entries)
+	}
+	c.entries = slices.Insert(entries, at, entry{
+		message: &models.SystemMessage{Content: summaryPrefix + summary},
+		summary: true,
+	})
+	return before - len(c.entries), nil
+}
+
+// planSummary 生成摘要裁剪计划，不超出预算或没有可删除的轮时返回 nil（调用方需持有锁）
+func (c *Conversation) planSummary() (plan *summaryPlan, err error) {
+	if c.config.MaxTokens <= 0 || len(c.entries) == 0 {
+		return
+	}
+	var n int
+	if n, err = c.config.Counter(c.messages()); err != nil || n <= c.config.MaxTokens {
+		return
+	}
+	// 在副本上删除最早的轮，直到为摘要预留出空间
+	original := c.entries
+	defer func() { c.entries = original }()
+	c.entries = slices.Clone(original)
+	removable := c.removable(false)
+	var dropped []models.ChatMessage
+	budget := max(c.config.MaxTokens-c.config.SummaryReserve, 0)
+	for {
+		if n, err = c.config.Counter(c.messages()); err != nil {
+			return
+		}
+		if n <= budget {
+			break
+		}
+		turns := c.turns()
+		i := slices.IndexFunc(turns, func(t turn) bool {
+			return removable(t, t.end == len(c.entries))
+		})
+		if i < 0 {
+			break
+		}
+		for _, e := range c.entries[turns[i].start:turns[i].end] {
+			dropped = append(dropped, e.message)
+		}
+		c.entries = slices.Delete(c.entries, turns[i].start, turns[i].end)
+	}
+	if len(dropped) == 0 {
+		return
+	}
+
+	// 删除已有的摘要，新的摘要包含已有摘要的内容
+	plan = &summaryPlan{
+		size:    len(original),
+		dropped: dropped,
+		version: c.version,
+	}
+	if i := slices.IndexFunc(c.entries, func(e entry) bool { return e.summary }); i >= 0 {
+		if m, ok := c.entries[i].message.(*models.SystemMessage); ok {
+			plan.previous = strings.TrimPrefix(m.Content, summaryPrefix)
+		}
+		c.entries = slices.Delete(c.entries, i, i+1)
+	}
+	plan.entries = c.entries
+	return
+}
+
+// isSystemMessage 是否是系统消息或开发者消息
+func isSystemMessage(message models.ChatMessage) (ok bool) {
+	switch message.(type) {
+	case *models.SystemMessage, *models.DeveloperMessage:
+		return true
+	}
+	return false
+}
+
+// isToolMessage 是否是工具消息
+func isToolMessage(message models.ChatMessage) (ok bool) {
+	_, ok = message.(*models.ToolMessage)
+	return
+}
@@ -0,0 +1,281 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-08-01 10:21:16
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-08-09 11:48:36
+ * @Description: Prometheus 指标收集器
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package metrics
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/Mrzhouyl/go-aisdk/httpclient"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// 指标标签
+var (
+	requestLabels = []string{"provider", "model", "method"}           // 请求标签
+	statusLabels  = []string{"provider", "model", "method", "status"} // 请求结果标签，status 为 success 或 error，流式传输被调用方主动关闭时为 closed
+	tokenLabels   = []string{"provider", "model", "method", "type"}   // token 标签，type 为 input 或 output
+	errorLabels   = []string{"provider", "model", "method", "kind"}   // 错误标签，kind 为错误分类
+)
+
+// PrometheusCollectorConfig Prometheus 指标收集器配置
+type PrometheusCollectorConfig struct {
+	Namespace       string                // 指标名称前缀，默认 "aisdk"
+	Registerer      prometheus.Registerer // 注册指标的注册器，如 prometheus.DefaultRegisterer，默认创建新的注册表
+	Gatherer        prometheus.Gatherer   // 采集指标的采集器，用于 Handler 和 GetMetrics，默认使用 Registerer（同时实现了 prometheus.Gatherer 时），否则创建新的注册表
+	ConstLabels     prometheus.Labels     // 所有指标的固定标签，如服务名称、实例
+	DurationBuckets []float64             // 请求耗时直方图的桶（秒）
+	TTFTBuckets     []float64             // 流式传输首个数据块耗时直方图的桶（秒）
+	TokenBuckets    []float64             // 单次请求 token 数直方图的桶
+}
+
+// PrometheusCollector Prometheus 指标收集器，实现了 httpclient.MetricsCollector、httpclient.StreamMetricsCollector 和 httpclient.UsageMetricsCollector 接口
+type PrometheusCollector struct {
+	namespace       string
+	registerer      prometheus.Registerer
+	gatherer        prometheus.Gatherer
+	requestsTotal   *prometheus.CounterVec   // 请求总数
+	inFlight        *prometheus.GaugeVec     // 当前活跃请求数
+	requestDuration *prometheus.HistogramVec // 请求耗时（包含所有重试）
+	retriesTotal    *prometheus.CounterVec   // 重试总次数
+	errorsTotal     *prometheus.CounterVec   // 按错误分类统计的错误数
+	tokensTotal     *prometheus.CounterVec   // token 总数
+	tokens          *prometheus.HistogramVec // 单次请求的 token 数
+	streamsTotal    *prometheus.CounterVec   // 结束的流式传输数
+	streamTTFT      *prometheus.HistogramVec // 流式传输首个数据块耗时
+	streamChunks    *prometheus.CounterVec   // 流式传输数据块总数
+}
+
+// NewPrometheusCollector 创建 Prometheus 指标收集器
+func NewPrometheusCollector(config PrometheusCollectorConfig) (c *PrometheusCollector) {
+	// 设置默认值
+	if config.Namespace == "" {
+		config.Namespace = "aisdk"
+	}
+	if config.Registerer == nil {
+		config.Registerer = prometheus.NewRegistry()
+	}
+	if config.Gatherer == nil {
+		if gatherer, ok := config.Registerer.(prometheus.Gatherer); ok {
+			config.Gatherer = gatherer
+		} else {
+			config.Gatherer = prometheus.NewRegistry()
+		}
+	}
+	if len(config.DurationBuckets) == 0 {
+		config.DurationBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 20, 30, 60, 120}
+	}
+	if len(config.TTFTBuckets) == 0 {
+		config.TTFTBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2, 5, 10, 20}
+	}
+	if len(config.TokenBuckets) == 0 {
+		config.TokenBuckets = prometheus.ExponentialBuckets(16, 4, 8)
+	}
+
+	c = &PrometheusCollector{
+		namespace:  config.Namespace,
+		registerer: config.Registerer,
+		gatherer:   config.Gatherer,
+		requestsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: config.Namespace, Name: "requests_total", Help: "Total number of requests.", ConstLabels: config.ConstLabels,
+		}, statusLabels),
+		inFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: config.Namespace, Name: "requests_in_flight", Help: "Number of requests currently in flight.", ConstLabels: config.ConstLabels,
+		}, requestLabels),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: config.Namespace, Name: "request_duration_seconds", Help: "Request duration in seconds, including retries.", ConstLabels: config.ConstLabels,
+			Buckets: config.DurationBuckets,
+		}, statusLabels),
+		retriesTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: config.Namespace, Name: "retries_total", Help: "Total number of retries.", ConstLabels: config.ConstLabels,
+		}, requestLabels),
+		errorsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: config.Namespace, Name: "errors_total", Help: "Total number of errors by kind.", ConstLabels: config.ConstLabels,
+		}, errorLabels),
+		tokensTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: config.Namespace, Name: "tokens_total", Help: "Total number of input and output tokens.", ConstLabels: config.ConstLabels,
+		}, tokenLabels),
+		tokens: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: config.Namespace, Name: "request_tokens", Help: "Number of input and output tokens per request.", ConstLabels: config.ConstLabels,
+			Buckets: config.TokenBuckets,
+		}, tokenLabels),
+		streamsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: config.Namespace, Name: "streams_total", Help: "Total number of finished streams.", ConstLabels: config.ConstLabels,
+		}, statusLabels),
+		streamTTFT: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: config.Namespace, Name: "stream_time_to_first_token_seconds", Help: "Time to first stream chunk in seconds, measured from request start.", ConstLabels: config.ConstLabels,
+			Buckets: config.TTFTBuckets,
+		}, requestLabels),
+		streamChunks: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: config.Namespace, Name: "stream_chunks_total", Help: "Total number of stream chunks.", ConstLabels: config.ConstLabels,
+		}, requestLabels),
+	}
+	config.Registerer.MustRegister(c.collectors()...)
+	return
+}
+
+// RecordRequestStart 记录请求开始
+func (c *PrometheusCollector) RecordRequestStart(provider, modelType, model, method string) {
+	c.inFlight.WithLabelValues(provider, model, method).Inc()
+}
+
+// RecordRequestComplete 记录请求完成
+func (c *PrometheusCollector) RecordRequestComplete(provider, modelType, model, method string, durationMs int64, success bool) {
+	status := statusLabel(success)
+	c.inFlight.WithLabelValues(provider, model, method).Dec()
+	c.requestsTotal.WithLabelValues(provider, model, method, status).Inc()
+	c.requestDuration.WithLabelValues(provider, model, method, status).Observe(float64(durationMs) / 1000)
+}
+
+// RecordError 记录错误
+func (c *PrometheusCollector) RecordError(provider, modelType, model, method, errorType string) {
+	c.errorsTotal.WithLabelValues(provider, model, method, errorType).Inc()
+}
+
+// RecordRetry 记录重试
+func (c *PrometheusCollector) RecordRetry(provider, modelType, model, method string, retryCount int) {
+	c.retriesTotal.WithLabelValues(provider, model, method).Add(float64(retryCount))
+}
+
+// RecordUsage 记录非流式响应的token使用信息
+func (c *PrometheusCollector) RecordUsage(provider, modelType, model, method string, usage httpclient.TokenUsage) {
+	c.recordTokens(provider, model, method, usage)
+}
+
+// RecordStream 记录流式传输结束
+func (c *PrometheusCollector) RecordStream(provider, modelType, model, method string, metrics httpclient.StreamMetrics, success bool) {
+	// 调用方主动关闭的流单独记录状态
+	status := statusLabel(success)
+	if metrics.Closed {
+		status = "closed"
+	}
+	c.streamsTotal.WithLabelValues(provider, model, method, status).Inc()
+	c.streamChunks.WithLabelValues(provider, model, method).Add(float64(metrics.ChunkCount))
+	if metrics.ChunkCount > 0 {
+		c.streamTTFT.WithLabelValues(provider, model, method).Observe(float64(metrics.TTFTMs) / 1000)
+	}
+	if metrics.Usage != nil {
+		c.recordTokens(provider, model, method, *metrics.Usage)
+	}
+}
+
+// GetMetrics 获取本收集器的指标数据，键为指标名称，值为各标签组合（按标签名排序后以 ":" 连接标签值）对应的值，直方图记录为 _count 和 _sum
+func (c *PrometheusCollector) GetMetrics() (metrics map[string]any) {
+	metrics = make(map[string]any)
+	families, err := c.gatherer.Gather()
+	if err != nil {
+		return
+	}
+	for _, family := range families {
+		// 跳过注册表中的其他指标
+		if !strings.HasPrefix(family.GetName(), c.namespace+"_") {
+			continue
+		}
+		switch family.GetType() {
+		case dto.MetricType_COUNTER, dto.MetricType_GAUGE:
+			values := make(map[string]float64, len(family.GetMetric()))
+			for _, m := range family.GetMetric() {
+				values[labelKey(m)] = m.GetCounter().GetValue() + m.GetGauge().GetValue()
+			}
+			metrics[family.GetName()] = values
+		case dto.MetricType_HISTOGRAM:
+			var (
+				counts = make(map[string]float64, len(family.GetMetric()))
+				sums   = make(map[string]float64, len(family.GetMetric()))
+			)
+			for _, m := range family.GetMetric() {
+				key := labelKey(m)
+				counts[key] = float64(m.GetHistogram().GetSampleCount())
+				sums[key] = m.GetHistogram().GetSampleSum()
+			}
+			metrics[family.GetName()+"_count"] = counts
+			metrics[family.GetName()+"_sum"] = sums
+		}
+	}
+	return
+}
+
+// Reset 重置指标数据
+func (c *PrometheusCollector) Reset() {
+	c.requestsTotal.Reset()
+	c.inFlight.Reset()
+	c.requestDuration.Reset()
+	c.retriesTotal.Reset()
+	c.errorsTotal.Reset()
+	c.tokensTotal.Reset()
+	c.tokens.Reset()
+	c.streamsTotal.Reset()
+	c.streamTTFT.Reset()
+	c.streamChunks.Reset()
+}
+
+// Registerer 获取注册指标的注册器
+func (c *PrometheusCollector) Registerer() (registerer prometheus.Registerer) {
+	return c.registerer
+}
+
+// Gatherer 获取采集指标的采集器
+func (c *PrometheusCollector) Gatherer() (gatherer prometheus.Gatherer) {
+	return c.gatherer
+}
+
+// Handler 获取以文本格式暴露指标的 HTTP 处理器，可挂载到 /metrics
+func (c *PrometheusCollector) Handler() (handler http.Handler) {
+	return promhttp.HandlerFor(c.gatherer, promhttp.HandlerOpts{})
+}
+
+// recordTokens 记录输入和输出 token 数
+func (c *PrometheusCollector) recordTokens(provider, model, method string, usage httpclient.TokenUsage) {
+	for _, v := range []struct {
+		typ    string
+		tokens int
+	}{
+		{"input", usage.PromptTokens},
+		{"output", usage.CompletionTokens},
+	} {
+		c.tokensTotal.WithLabelValues(provider, model, method, v.typ).Add(float64(v.tokens))
+		c.tokens.WithLabelValues(provider, model, method, v.typ).Observe(float64(v.tokens))
+	}
+}
+
+// collectors 获取所有指标
+func (c *PrometheusCollector) collectors() (collectors []prometheus.Collector) {
+	return []prometheus.Collector{
+		c.requestsTotal,
+		c.inFlight,
+		c.requestDuration,
+		c.retriesTotal,
+		c.errorsTotal,
+		c.tokensTotal,
+		c.tokens,
+		c.streamsTotal,
+		c.streamTTFT,
+		c.streamChunks,
+	}
+}
+
+// statusLabel 获取请求结果标签
+func statusLabel(success bool) (status string) {
+	if success {
+		return "success"
+	}
+	return "error"
+}
+
+// labelKey 获取标签值以 ":" 连接的键
+func labelKey(m *dto.Metric) (key string) {
+	values := make([]string, 0, len(m.GetLabel()))
+	for _, label := range m.GetLabel() {
+		values = append(values, label.GetValue())
+	}
+	return strings.Join(values, ":")
+}
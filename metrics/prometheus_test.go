@@ -0,0 +1,208 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-08-01 14:52:03
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-08-09 11:48:36
+ * @Description:
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package metrics
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/Mrzhouyl/go-aisdk/consts"
+	"github.com/Mrzhouyl/go-aisdk/httpclient"
+	"github.com/Mrzhouyl/go-aisdk/internal/utils"
+	"github.com/Mrzhouyl/go-aisdk/models"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// execute 通过监控中间件执行请求
+func execute(m *httpclient.MetricsMiddleware, handler httpclient.MWHandler) (response any, err error) {
+	ctx := httpclient.SetRequestInfo(context.Background(), &httpclient.RequestInfo{
+		Provider:  string(consts.OpenAI),
+		ModelType: string(consts.ChatModel),
+		Model:     consts.OpenAIGPT4o,
+		Method:    "CreateChatCompletion",
+	})
+	return httpclient.NewChain(m).Execute(ctx, nil, func(ctx context.Context, request any) (response any, err error) {
+		requestInfo := httpclient.GetRequestInfo(ctx)
+		requestInfo.TotalDurationMs = 1500
+		if response, err = handler(ctx, request); err == nil {
+			requestInfo.IsSuccess = true
+		}
+		return
+	})
+}
+
+func TestPrometheusCollector(t *testing.T) {
+	collector := NewPrometheusCollector(PrometheusCollectorConfig{ConstLabels: prometheus.Labels{"service": "test"}})
+	m := httpclient.NewMetricsMiddleware(httpclient.MetricsMiddlewareConfig{Collector: collector})
+
+	// 成功的非流式请求，经过一次重试
+	_, err := execute(m, func(ctx context.Context, request any) (response any, err error) {
+		httpclient.GetRequestInfo(ctx).Attempt = 1
+		return models.ChatResponse{ChatBaseResponse: models.ChatBaseResponse{Usage: &models.ChatUsage{PromptTokens: 10, CompletionTokens: 4}}}, nil
+	})
+	if err != nil {
+		t.Fatalf("execute() error = %v", err)
+	}
+	// 被限流的请求
+	if _, err = execute(m, func(ctx context.Context, request any) (response any, err error) {
+		return nil, &httpclient.APIError{HTTPStatusCode: 429, Provider: string(consts.OpenAI)}
+	}); err == nil {
+		t.Fatal("expected error but got nil")
+	}
+	// 流式传输请求
+	body := "data: {\"choices\":[{\"delta\":{\"content\":\"hi\"}}]}\n\n" +
+		"data: {\"choices\":[],\"usage\":{\"prompt_tokens\":5,\"completion_tokens\":3}}\n\n" +
+		"data: [DONE]\n\n"
+	response, err := execute(m, func(ctx context.Context, request any) (response any, err error) {
+		return models.ChatResponseStream{StreamReader: httpclient.NewStreamReader[models.ChatBaseResponse](
+			io.NopCloser(strings.NewReader(body)), nil, httpclient.HTTPClientConfig{ResponseDecoder: utils.NewDeserializer("", true)},
+		)}, nil
+	})
+	if err != nil {
+		t.Fatalf("execute() error = %v", err)
+	}
+	stream := response.(models.ChatResponseStream)
+	for {
+		_, isFinished, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("Recv() error = %v", err)
+		}
+		if isFinished {
+			break
+		}
+	}
+
+	// 以文本格式暴露指标
+	recorder := httptest.NewRecorder()
+	collector.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if recorder.Code != http.StatusOK || !strings.HasPrefix(recorder.Header().Get("Content-Type"), "text/plain") {
+		t.Fatalf("unexpected response: %d %s", recorder.Code, recorder.Header().Get("Content-Type"))
+	}
+	labels := `method="CreateChatCompletion",model="gpt-4o",provider="openai",service="test"`
+	for _, want := range []string{
+		`aisdk_requests_total{` + labels + `,status="success"} 2`,
+		`aisdk_requests_total{` + labels + `,status="error"} 1`,
+		`aisdk_requests_in_flight{` + labels + `} 0`,
+		`aisdk_request_duration_seconds_count{` + labels + `,status="success"} 2`,
+		`aisdk_request_duration_seconds_bucket{` + labels + `,status="error",le="2.5"} 1`,
+		`aisdk_retries_total{` + labels + `} 1`,
+		`aisdk_errors_total{kind="rate_limit",` + labels + `} 1`,
+		`aisdk_tokens_total{` + labels + `,type="input"} 15`,
+		`aisdk_tokens_total{` + labels + `,type="output"} 7`,
+		`aisdk_streams_total{` + labels + `,status="success"} 1`,
+		`aisdk_stream_time_to_first_token_seconds_count{` + labels + `} 1`,
+		`aisdk_stream_chunks_total{` + labels + `} 2`,
+	} {
+		if !strings.Contains(recorder.Body.String(), want+"\n") {
+			t.Errorf("metrics output missing %q", want)
+		}
+	}
+	if t.Failed() {
+		t.Log(recorder.Body.String())
+	}
+
+	// GetMetrics 与默认指标收集器一样返回 map，重置后清空
+	metrics := m.GetMetrics()
+	if got := metrics["aisdk_errors_total"].(map[string]float64)["rate_limit:CreateChatCompletion:gpt-4o:openai:test"]; got != 1 {
+		t.Errorf("GetMetrics() errors = %v", metrics["aisdk_errors_total"])
+	}
+	collector.Reset()
+	if got := collector.GetMetrics(); len(got) != 0 {
+		t.Errorf("metrics not reset: %v", got)
+	}
+}
+
+func TestPrometheusCollector_StreamError(t *testing.T) {
+	collector := NewPrometheusCollector(PrometheusCollectorConfig{})
+	m := httpclient.NewMetricsMiddleware(httpclient.MetricsMiddlewareConfig{Collector: collector})
+
+	// 输出两个数据块后传输中断
+	body := "data: {\"choices\":[{\"delta\":{\"content\":\"he\"}}]}\n\n" +
+		"data: {\"choices\":[{\"delta\":{\"content\":\"llo\"}}]}\n\n"
+	response, err := execute(m, func(ctx context.Context, request any) (response any, err error) {
+		reader := io.MultiReader(strings.NewReader(body), iotest.ErrReader(io.ErrUnexpectedEOF))
+		return models.ChatResponseStream{StreamReader: httpclient.NewStreamReader[models.ChatBaseResponse](
+			io.NopCloser(reader), nil, httpclient.HTTPClientConfig{ResponseDecoder: utils.NewDeserializer("", true)},
+		)}, nil
+	})
+	if err != nil {
+		t.Fatalf("execute() error = %v", err)
+	}
+	var (
+		key      = "CreateChatCompletion:gpt-4o:openai"
+		requests = func() (metrics map[string]float64) {
+			metrics, _ = collector.GetMetrics()["aisdk_requests_total"].(map[string]float64)
+			return
+		}
+	)
+	// 流打开后请求仍在进行中，尚未记录请求完成
+	if got := collector.GetMetrics()["aisdk_requests_in_flight"].(map[string]float64)[key]; got != 1 {
+		t.Errorf("requests in flight = %v, want 1", got)
+	}
+	if got := requests(); len(got) != 0 {
+		t.Errorf("request recorded before stream end: %v", got)
+	}
+	stream := response.(models.ChatResponseStream)
+	for {
+		_, _, err = stream.Recv()
+		if err != nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("Recv() error = %v", err)
+	}
+
+	// 流结束时记录一次失败的请求，耗时包含整个流式传输
+	metrics := collector.GetMetrics()
+	if got := requests(); len(got) != 1 || got[key+":error"] != 1 {
+		t.Errorf("requests total = %v, want one error", got)
+	}
+	if got := metrics["aisdk_requests_in_flight"].(map[string]float64)[key]; got != 0 {
+		t.Errorf("requests in flight = %v, want 0", got)
+	}
+	if count, sum := metrics["aisdk_request_duration_seconds_count"].(map[string]float64)[key+":error"], metrics["aisdk_request_duration_seconds_sum"].(map[string]float64)[key+":error"]; count != 1 || sum < 0.04 || sum >= 1.5 {
+		t.Errorf("request duration count = %v, sum = %v", count, sum)
+	}
+	if got := metrics["aisdk_streams_total"].(map[string]float64)[key+":error"]; got != 1 {
+		t.Errorf("streams total = %v", metrics["aisdk_streams_total"])
+	}
+}
+
+func TestPrometheusCollector_Registerer(t *testing.T) {
+	// 注册器不是采集器时，通过 Gatherer 采集指标
+	registry := prometheus.NewRegistry()
+	collector := NewPrometheusCollector(PrometheusCollectorConfig{
+		Registerer: prometheus.WrapRegistererWith(prometheus.Labels{"env": "test"}, registry),
+		Gatherer:   registry,
+	})
+	collector.RecordRequestStart(string(consts.OpenAI), string(consts.ChatModel), consts.OpenAIGPT4o, "CreateChatCompletion")
+	if got := collector.GetMetrics()["aisdk_requests_in_flight"].(map[string]float64)["test:CreateChatCompletion:gpt-4o:openai"]; got != 1 {
+		t.Errorf("GetMetrics() in flight = %v", collector.GetMetrics()["aisdk_requests_in_flight"])
+	}
+	recorder := httptest.NewRecorder()
+	collector.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if !strings.Contains(recorder.Body.String(), `aisdk_requests_in_flight{env="test"`) {
+		t.Errorf("Handler() body = %s", recorder.Body.String())
+	}
+	// 注册器同时是采集器时，默认使用注册器采集指标
+	registry = prometheus.NewRegistry()
+	if collector = NewPrometheusCollector(PrometheusCollectorConfig{Registerer: registry}); collector.Gatherer() != registry {
+		t.Errorf("Gatherer() = %v, want the registerer", collector.Gatherer())
+	}
+}
@@ -2,14 +2,23 @@
  * @Author: liusuxian 382185882@qq.com
  * @Date: 2025-06-02 04:49:05
  * @LastEditors: liusuxian 382185882@qq.com
- * @LastEditTime: 2025-06-17 18:56:58
+ * @LastEditTime: 2025-08-03 17:08:36
  * @Description:
  *
  * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
  */
 package aisdk
 
-import "github.com/Mrzhouyl/go-aisdk/httpclient"
+import (
+	"maps"
+
+	"github.com/Mrzhouyl/go-aisdk/budget"
+	"github.com/Mrzhouyl/go-aisdk/cache"
+	"github.com/Mrzhouyl/go-aisdk/cost"
+	"github.com/Mrzhouyl/go-aisdk/httpclient"
+	"github.com/Mrzhouyl/go-aisdk/redaction"
+	"github.com/Mrzhouyl/go-aisdk/tracing"
+)
 
 // WithMiddleware 添加中间件
 func WithMiddleware(m httpclient.Middleware) (opt SDKClientOption) {
@@ -39,6 +48,76 @@ func WithRetry(config httpclient.RetryMiddlewareConfig) (opt SDKClientOption) {
 	}
 }
 
+// WithRateLimit 添加限流中间件
+func WithRateLimit(config httpclient.RateLimitMiddlewareConfig) (opt SDKClientOption) {
+	return func(c *clientOption) {
+		c.middlewares = append(c.middlewares, httpclient.NewRateLimitMiddleware(config))
+	}
+}
+
+// WithCircuitBreaker 添加熔断中间件
+func WithCircuitBreaker(config httpclient.CircuitBreakerMiddlewareConfig) (opt SDKClientOption) {
+	return func(c *clientOption) {
+		c.middlewares = append(c.middlewares, httpclient.NewCircuitBreakerMiddleware(config))
+	}
+}
+
+// WithSingleflight 添加请求合并中间件
+func WithSingleflight(config httpclient.SingleflightMiddlewareConfig) (opt SDKClientOption) {
+	return func(c *clientOption) {
+		c.middlewares = append(c.middlewares, httpclient.NewSingleflightMiddleware(config))
+	}
+}
+
+// WithCache 添加缓存中间件
+func WithCache(config cache.CacheMiddlewareConfig) (opt SDKClientOption) {
+	return func(c *clientOption) {
+		c.middlewares = append(c.middlewares, cache.NewCacheMiddleware(config))
+	}
+}
+
+// WithSemanticCache 添加语义缓存中间件
+func WithSemanticCache(config cache.SemanticCacheMiddlewareConfig) (opt SDKClientOption) {
+	return func(c *clientOption) {
+		c.middlewares = append(c.middlewares, cache.NewSemanticCacheMiddleware(config))
+	}
+}
+
+// WithCost 添加费用统计中间件，未设置价格表时使用客户端的模型目录（包含配置文件中的价格覆盖）
+func WithCost(config cost.CostMiddlewareConfig) (opt SDKClientOption) {
+	return func(c *clientOption) {
+		if config.Prices == nil && c.catalog != nil {
+			config.Prices = c.catalog
+		}
+		c.middlewares = append(c.middlewares, cost.NewCostMiddleware(config))
+	}
+}
+
+// WithBudget 添加预算中间件，未设置模型目录时使用客户端的模型目录（包含配置文件中的价格覆盖）
+func WithBudget(config budget.BudgetMiddlewareConfig) (opt SDKClientOption) {
+	return func(c *clientOption) {
+		if config.Catalog == nil {
+			config.Catalog = c.catalog
+		}
+		c.middlewares = append(c.middlewares, budget.NewBudgetMiddleware(config))
+	}
+}
+
+// WithRedaction 添加敏感信息脱敏中间件，请求发出前将提示词中的敏感信息替换为占位符，并在响应中还原
+func WithRedaction(config redaction.RedactionMiddlewareConfig) (opt SDKClientOption) {
+	return func(c *clientOption) {
+		c.middlewares = append(c.middlewares, redaction.NewRedactionMiddleware(config))
+	}
+}
+
+// WithTracing 添加链路追踪中间件，同时添加为每次重试尝试创建子 span 的中间件
+func WithTracing(config tracing.TracingMiddlewareConfig) (opt SDKClientOption) {
+	return func(c *clientOption) {
+		m := tracing.NewTracingMiddleware(config)
+		c.middlewares = append(c.middlewares, m, m.AttemptMiddleware())
+	}
+}
+
 // WithDefaultMiddlewares 添加默认中间件（日志、监控、重试）
 func WithDefaultMiddlewares() (opt SDKClientOption) {
 	return func(c *clientOption) {
@@ -50,12 +129,22 @@ func WithDefaultMiddlewares() (opt SDKClientOption) {
 	}
 }
 
-// GetMetrics 获取指标数据（如果启用了监控中间件）
+// metricsProvider 提供指标数据的中间件
+type metricsProvider interface {
+	GetMetrics() (metrics map[string]any)
+}
+
+// GetMetrics 获取指标数据（如果启用了监控中间件、熔断中间件、限流中间件、缓存中间件、语义缓存中间件、请求合并中间件、费用统计中间件、预算中间件）
 func (c *SDKClient) GetMetrics() (metrics map[string]any) {
 	for _, mw := range c.middlewareChain.GetMiddlewares() {
-		if metricsMiddleware, ok := mw.(*httpclient.MetricsMiddleware); ok {
-			return metricsMiddleware.GetMetrics()
+		mp, ok := mw.(metricsProvider)
+		if !ok {
+			continue
+		}
+		if metrics == nil {
+			metrics = make(map[string]any)
 		}
+		maps.Copy(metrics, mp.GetMetrics())
 	}
 	return
 }
@@ -2,7 +2,7 @@
  * @Author: liusuxian 382185882@qq.com
  * @Date: 2025-04-15 18:42:36
  * @LastEditors: liusuxian 382185882@qq.com
- * @LastEditTime: 2025-07-03 22:36:41
+ * @LastEditTime: 2025-07-28 16:58:31
  * @Description:
  *
  * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
@@ -724,11 +724,11 @@ type ChatRequest struct {
 	// 是否流式传输响应（AliBL支持该参数，但不会被序列化，会放到请求头中）
 	//
 	// 提供商支持: OpenAI | DeepSeek | AliBL
-	Stream *bool `json:"stream,omitempty" providers:"openai,deepseek"`
-	// 流式传输选项
+	Stream *bool `json:"stream,omitempty" providers:"openai,deepseek" unsupported:"ignore"`
+	// 流式传输选项（AliBL流式传输始终返回用量信息，忽略该参数）
 	//
 	// 提供商支持: OpenAI | DeepSeek
-	StreamOptions *ChatStreamOptions `json:"stream_options,omitempty" providers:"openai,deepseek"`
+	StreamOptions *ChatStreamOptions `json:"stream_options,omitempty" providers:"openai,deepseek" unsupported:"ignore"`
 	// 采样温度，介于 0 和 2 之间（AliBL取值范围：[0,2)）。更高的值，会使输出更随机，而更低的值，会使其更加集中和确定
 	//
 	// 提供商支持: OpenAI | DeepSeek | AliBL
@@ -960,6 +960,50 @@ type ChatUsage struct {
 	PromptTokensDetails     *PromptTokensDetails     `json:"prompt_tokens_details,omitempty"`     // prompt tokens 的详细信息
 }
 
+// NormalizedUsage 归一化的用量信息，各字段在不同提供商下含义相同，各类 token 数量包含在输入或输出 token 总数中
+type NormalizedUsage struct {
+	InputTokens       int `json:"input_tokens"`        // 输入 token 总数
+	CachedInputTokens int `json:"cached_input_tokens"` // 命中缓存的输入 token 数
+	AudioInputTokens  int `json:"audio_input_tokens"`  // 音频输入 token 数
+	ImageInputTokens  int `json:"image_input_tokens"`  // 图像输入 token 数
+	VideoInputTokens  int `json:"video_input_tokens"`  // 视频输入 token 数
+	OutputTokens      int `json:"output_tokens"`       // 输出 token 总数
+	ReasoningTokens   int `json:"reasoning_tokens"`    // 推理 token 数
+	AudioOutputTokens int `json:"audio_output_tokens"` // 音频输出 token 数
+	TotalTokens       int `json:"total_tokens"`        // token 总数
+}
+
+// Normalized 获取归一化的用量信息
+//
+// 命中缓存的 token 数取 PromptTokensDetails.CachedTokens（OpenAI、AliBL）和 PromptCacheHitTokens（DeepSeek）中的较大值；
+// 没有 prompt_tokens 时使用 DeepSeek 的命中与未命中缓存的 token 数之和；没有 total_tokens（如 AliBL 多模态输入）时使用输入与输出之和
+func (u *ChatUsage) Normalized() (n NormalizedUsage) {
+	if u == nil {
+		return
+	}
+	n.InputTokens = u.PromptTokens
+	if n.InputTokens == 0 {
+		n.InputTokens = u.PromptCacheHitTokens + u.PromptCacheMissTokens
+	}
+	n.CachedInputTokens = u.PromptCacheHitTokens
+	if d := u.PromptTokensDetails; d != nil {
+		n.CachedInputTokens = max(n.CachedInputTokens, d.CachedTokens)
+		n.AudioInputTokens = d.AudioTokens
+		n.ImageInputTokens = d.ImageTokens
+		n.VideoInputTokens = d.VideoTokens
+	}
+	n.OutputTokens = u.CompletionTokens
+	if d := u.CompletionTokensDetails; d != nil {
+		n.ReasoningTokens = d.ReasoningTokens
+		n.AudioOutputTokens = d.AudioTokens
+	}
+	n.TotalTokens = u.TotalTokens
+	if n.TotalTokens == 0 {
+		n.TotalTokens = n.InputTokens + n.OutputTokens
+	}
+	return
+}
+
 // ChatBaseResponse 聊天响应基础信息
 type ChatBaseResponse struct {
 	provider   string // 用于反序列化数据时，处理差异化数据
@@ -991,6 +1035,19 @@ func (c *ChatBaseResponse) SetStreamStats(stats httpclient.StreamStats) {
 	c.StreamStats = &stats
 }
 
+// TokenUsage 获取token使用信息
+func (c ChatBaseResponse) TokenUsage() (usage httpclient.TokenUsage, ok bool) {
+	if c.Usage == nil {
+		return
+	}
+	n := c.Usage.Normalized()
+	return httpclient.TokenUsage{
+		PromptTokens:     n.InputTokens,
+		CompletionTokens: n.OutputTokens,
+		TotalTokens:      n.TotalTokens,
+	}, true
+}
+
 // UnmarshalJSON 反序列化JSON
 func (c *ChatBaseResponse) UnmarshalJSON(data []byte) (err error) {
 	switch consts.Provider(c.provider) {
@@ -1153,6 +1210,16 @@ func (c *ChatBaseResponse) unmarshalAliBL(data []byte) (err error) {
 			c.Usage.PromptTokensDetails.ImageTokens = tmpUsage.InputTokensDetails.ImageTokens
 			c.Usage.PromptTokensDetails.VideoTokens = tmpUsage.InputTokensDetails.VideoTokens
 		}
+		// 多模态输入时 image_tokens、video_tokens、audio_tokens 位于 usage 的顶层
+		if tmpUsage.ImageTokens > 0 || tmpUsage.VideoTokens > 0 || tmpUsage.AudioTokens > 0 {
+			if c.Usage.PromptTokensDetails == nil {
+				c.Usage.PromptTokensDetails = &PromptTokensDetails{}
+			}
+			details := c.Usage.PromptTokensDetails
+			details.ImageTokens = max(details.ImageTokens, tmpUsage.ImageTokens)
+			details.VideoTokens = max(details.VideoTokens, tmpUsage.VideoTokens)
+			details.AudioTokens = max(details.AudioTokens, tmpUsage.AudioTokens)
+		}
 	}
 	return
 }
@@ -1163,7 +1230,7 @@ type ChatResponse struct {
 	httpclient.HttpHeader
 }
 
-// ChatResponseStream 流式传输的聊天响应
+// ChatResponseStream 流式传输的聊天响应，可通过 ForEach、All（range-over-func）或 Chan 读取
 type ChatResponseStream struct {
 	*httpclient.StreamReader[ChatBaseResponse]
 }
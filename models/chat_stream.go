@@ -0,0 +1,296 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-16 09:37:45
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-08-09 11:05:13
+ * @Description: 流式聊天响应累加器，将流式数据块重建为完整的聊天响应
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package models
+
+import (
+	"errors"
+	"slices"
+
+	"github.com/Mrzhouyl/go-aisdk/httpclient"
+)
+
+// ChatStreamMode 流式数据块的内容模式
+type ChatStreamMode int
+
+const (
+	ChatStreamModeAuto        ChatStreamMode = iota // 默认模式，按增量处理（SDK 发起的 AliBL 流式请求总是开启增量输出）
+	ChatStreamModeIncremental                       // 增量模式，每个数据块只包含新增的内容
+	ChatStreamModeCumulative                        // 全量模式，每个数据块包含截至目前的全部内容（AliBL 关闭增量输出时需要显式指定）
+)
+
+// ChatStreamChunkHandler 流式数据块处理函数
+type ChatStreamChunkHandler func(chunk ChatBaseResponse) (err error)
+
+// CollectOption 读取流式响应的选项
+type CollectOption func(o *collectOption)
+
+// collectOption 读取流式响应的选项
+type collectOption struct {
+	mode ChatStreamMode // 流式数据块的内容模式
+}
+
+// WithCollectMode 设置流式数据块的内容模式，默认为 ChatStreamModeAuto。AliBL 关闭增量输出时需要设置为 ChatStreamModeCumulative
+func WithCollectMode(mode ChatStreamMode) (opt CollectOption) {
+	return func(o *collectOption) {
+		o.mode = mode
+	}
+}
+
+// chatStreamChoice 单个 choice 的累加状态
+type chatStreamChoice struct {
+	choice    ChatChoice             // 累加结果
+	message   *ChatCompletionMessage // 累加的消息
+	toolCalls []*ToolCalls           // 累加的工具调用，按出现顺序排列
+}
+
+// ChatStreamAccumulator 流式聊天响应累加器，按 choice 索引合并文本内容、推理内容、工具调用参数和用量信息（非并发安全）
+type ChatStreamAccumulator struct {
+	Mode     ChatStreamMode            // 流式数据块的内容模式
+	response ChatBaseResponse          // 累加结果
+	choices  map[int]*chatStreamChoice // 各 choice 的累加状态，键为 choice 索引
+	count    int                       // 已累加的数据块数量
+}
+
+// NewChatStreamAccumulator 创建流式聊天响应累加器
+func NewChatStreamAccumulator() (a *ChatStreamAccumulator) {
+	return &ChatStreamAccumulator{
+		Mode:    ChatStreamModeAuto,
+		choices: make(map[int]*chatStreamChoice),
+	}
+}
+
+// Add 累加一个数据块
+func (a *ChatStreamAccumulator) Add(chunk ChatBaseResponse) {
+	if a.choices == nil {
+		a.choices = make(map[int]*chatStreamChoice)
+	}
+	a.count++
+	// 基础信息取最新的非空值
+	if a.response.provider == "" {
+		a.response.provider = chunk.provider
+	}
+	if chunk.ID != "" {
+		a.response.ID = chunk.ID
+	}
+	if chunk.Created != 0 {
+		a.response.Created = chunk.Created
+	}
+	if chunk.Model != "" {
+		a.response.Model = chunk.Model
+	}
+	if chunk.ServiceTier != "" {
+		a.response.ServiceTier = chunk.ServiceTier
+	}
+	if chunk.SystemFingerprint != "" {
+		a.response.SystemFingerprint = chunk.SystemFingerprint
+	}
+	if chunk.Usage != nil {
+		a.response.Usage = chunk.Usage
+	}
+	if chunk.StreamStats != nil {
+		a.response.StreamStats = chunk.StreamStats
+	}
+	// 合并 choices
+	for _, choice := range chunk.Choices {
+		a.addChoice(choice)
+	}
+}
+
+// Count 获取已累加的数据块数量
+func (a *ChatStreamAccumulator) Count() (n int) {
+	return a.count
+}
+
+// Response 获取累加后的聊天响应，choices 按索引排序，消息位于 Message 字段
+func (a *ChatStreamAccumulator) Response() (response ChatBaseResponse) {
+	response = a.response
+	response.Object = "chat.completion"
+	indexes := make([]int, 0, len(a.choices))
+	for index := range a.choices {
+		indexes = append(indexes, index)
+	}
+	slices.Sort(indexes)
+
+	response.Choices = make([]ChatChoice, 0, len(indexes))
+	for _, index := range indexes {
+		state := a.choices[index]
+		choice := state.choice
+		message := *state.message
+		message.ToolCalls = nil
+		for _, toolCall := range state.toolCalls {
+			tc := *toolCall
+			if toolCall.Function != nil {
+				fn := *toolCall.Function
+				tc.Function = &fn
+			}
+			message.ToolCalls = append(message.ToolCalls, tc)
+		}
+		choice.Message = &message
+		choice.Delta = nil
+		response.Choices = append(response.Choices, choice)
+	}
+	return
+}
+
+// addChoice 合并单个 choice
+func (a *ChatStreamAccumulator) addChoice(choice ChatChoice) {
+	state, ok := a.choices[choice.Index]
+	if !ok {
+		state = &chatStreamChoice{
+			choice:  ChatChoice{Index: choice.Index},
+			message: &ChatCompletionMessage{},
+		}
+		a.choices[choice.Index] = state
+	}
+	if choice.FinishReason != "" && choice.FinishReason != ChatFinishReasonNull {
+		state.choice.FinishReason = choice.FinishReason
+	}
+	if choice.LogProbs != nil {
+		if state.choice.LogProbs == nil {
+			state.choice.LogProbs = &ChatLogProbs{}
+		}
+		state.choice.LogProbs.Content = append(state.choice.LogProbs.Content, choice.LogProbs.Content...)
+		state.choice.LogProbs.Refusal = append(state.choice.LogProbs.Refusal, choice.LogProbs.Refusal...)
+	}
+	// 流式数据块的内容位于 Delta 字段，兼容内容位于 Message 字段的数据块
+	delta := choice.Delta
+	if delta == nil {
+		delta = choice.Message
+	}
+	if delta == nil {
+		return
+	}
+	// 全量模式下用最新的内容覆盖已累加的内容
+	if a.Mode == ChatStreamModeCumulative {
+		a.replaceMessage(state, delta)
+		return
+	}
+	a.appendMessage(state, delta)
+}
+
+// appendMessage 增量合并消息
+func (a *ChatStreamAccumulator) appendMessage(state *chatStreamChoice, delta *ChatCompletionMessage) {
+	msg := state.message
+	if delta.Role != "" {
+		msg.Role = delta.Role
+	}
+	msg.Content += delta.Content
+	msg.ReasoningContent += delta.ReasoningContent
+	msg.Refusal += delta.Refusal
+	if len(delta.Annotations) > 0 {
+		msg.Annotations = delta.Annotations
+	}
+	if delta.Audio != nil {
+		if msg.Audio == nil {
+			msg.Audio = &ChatAudioOutput{}
+		}
+		msg.Audio.Data += delta.Audio.Data
+		msg.Audio.Transcript += delta.Audio.Transcript
+		if delta.Audio.ID != "" {
+			msg.Audio.ID = delta.Audio.ID
+		}
+		if delta.Audio.ExpiresAt != 0 {
+			msg.Audio.ExpiresAt = delta.Audio.ExpiresAt
+		}
+	}
+	// 按索引合并工具调用参数，同一索引出现新的工具ID时视为新的工具调用
+	for _, toolCall := range delta.ToolCalls {
+		var target *ToolCalls
+		for i := len(state.toolCalls) - 1; i >= 0; i-- {
+			if state.toolCalls[i].Index == toolCall.Index {
+				target = state.toolCalls[i]
+				break
+			}
+		}
+		if target == nil || (toolCall.ID != "" && target.ID != "" && toolCall.ID != target.ID) {
+			target = &ToolCalls{Index: toolCall.Index}
+			state.toolCalls = append(state.toolCalls, target)
+		}
+		if toolCall.ID != "" {
+			target.ID = toolCall.ID
+		}
+		if toolCall.Type != "" {
+			target.Type = toolCall.Type
+		}
+		if toolCall.Function != nil {
+			if target.Function == nil {
+				target.Function = &ToolCallsFunction{}
+			}
+			if toolCall.Function.Name != "" {
+				target.Function.Name = toolCall.Function.Name
+			}
+			target.Function.Arguments += toolCall.Function.Arguments
+		}
+	}
+}
+
+// replaceMessage 全量覆盖消息，空字段保留已累加的内容
+func (a *ChatStreamAccumulator) replaceMessage(state *chatStreamChoice, delta *ChatCompletionMessage) {
+	msg := state.message
+	if delta.Role != "" {
+		msg.Role = delta.Role
+	}
+	if delta.Content != "" {
+		msg.Content = delta.Content
+	}
+	if delta.ReasoningContent != "" {
+		msg.ReasoningContent = delta.ReasoningContent
+	}
+	if delta.Refusal != "" {
+		msg.Refusal = delta.Refusal
+	}
+	if len(delta.Annotations) > 0 {
+		msg.Annotations = delta.Annotations
+	}
+	if delta.Audio != nil {
+		audio := *delta.Audio
+		msg.Audio = &audio
+	}
+	if len(delta.ToolCalls) > 0 {
+		state.toolCalls = make([]*ToolCalls, 0, len(delta.ToolCalls))
+		for _, toolCall := range delta.ToolCalls {
+			tc := toolCall
+			if toolCall.Function != nil {
+				fn := *toolCall.Function
+				tc.Function = &fn
+			}
+			state.toolCalls = append(state.toolCalls, &tc)
+		}
+	}
+}
+
+// Collect 读取整个流并重建为完整的聊天响应，读取结束后关闭流。onChunk 不为 nil 时对每个数据块调用，返回错误时停止读取。可通过 WithCollectMode 指定数据块的内容模式
+func (s ChatResponseStream) Collect(onChunk ChatStreamChunkHandler, opts ...CollectOption) (response ChatResponse, err error) {
+	if s.StreamReader == nil {
+		return response, errors.New("chat response stream is nil")
+	}
+
+	o := &collectOption{mode: ChatStreamModeAuto}
+	for _, opt := range opts {
+		opt(o)
+	}
+	accumulator := NewChatStreamAccumulator()
+	accumulator.Mode = o.mode
+	err = s.ForEach(func(chunk ChatBaseResponse, isFinished bool) (e error) {
+		if isFinished {
+			return
+		}
+		accumulator.Add(chunk)
+		if onChunk != nil {
+			return onChunk(chunk)
+		}
+		return
+	})
+	response = ChatResponse{
+		ChatBaseResponse: accumulator.Response(),
+		HttpHeader:       httpclient.HttpHeader(s.Header().Clone()),
+	}
+	return
+}
@@ -0,0 +1,141 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-16 14:05:21
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-08-09 11:05:13
+ * @Description:
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package models
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/Mrzhouyl/go-aisdk/httpclient"
+	"github.com/Mrzhouyl/go-aisdk/internal/utils"
+)
+
+func newTestChatStream(provider, body string) (stream ChatResponseStream) {
+	return ChatResponseStream{
+		StreamReader: httpclient.NewStreamReader[ChatBaseResponse](io.NopCloser(strings.NewReader(body)), nil, httpclient.HTTPClientConfig{
+			ResponseDecoder: utils.NewDeserializer(provider, true),
+		}),
+	}
+}
+
+func TestChatResponseStream_Collect(t *testing.T) {
+	body := strings.Join([]string{
+		`data: {"id":"1","model":"gpt-4o","choices":[{"index":0,"delta":{"role":"assistant","reasoning_content":"think"}}]}`,
+		`data: {"id":"1","choices":[{"index":0,"delta":{"content":"Hel"}}]}`,
+		`data: {"id":"1","choices":[{"index":0,"delta":{"content":"lo","tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"ci"}}]}}]}`,
+		`data: {"id":"1","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"ty\":\"SH\"}"}},{"index":1,"id":"call_2","type":"function","function":{"name":"get_time","arguments":"{}"}}]}}]}`,
+		`data: {"id":"1","choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`,
+		`data: {"id":"1","choices":[],"usage":{"prompt_tokens":5,"completion_tokens":7,"total_tokens":12}}`,
+		`data: [DONE]`,
+	}, "\n\n") + "\n\n"
+
+	var chunks int
+	resp, err := newTestChatStream("openai", body).Collect(func(chunk ChatBaseResponse) (err error) {
+		chunks++
+		return
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if chunks != 6 {
+		t.Errorf("expected 6 chunks, got %d", chunks)
+	}
+	if len(resp.Choices) != 1 {
+		t.Fatalf("expected 1 choice, got %d", len(resp.Choices))
+	}
+	choice := resp.Choices[0]
+	msg := choice.Message
+	if msg.Role != "assistant" || msg.Content != "Hello" || msg.ReasoningContent != "think" || choice.FinishReason != ChatFinishReasonToolCalls {
+		t.Errorf("unexpected message: %+v (finish reason: %s)", msg, choice.FinishReason)
+	}
+	if len(msg.ToolCalls) != 2 ||
+		msg.ToolCalls[0].ID != "call_1" || msg.ToolCalls[0].Function.Arguments != `{"city":"SH"}` ||
+		msg.ToolCalls[1].Function.Name != "get_time" || msg.ToolCalls[1].Function.Arguments != "{}" {
+		t.Errorf("unexpected tool calls: %+v", msg.ToolCalls)
+	}
+	if resp.Usage == nil || resp.Usage.TotalTokens != 12 || resp.ID != "1" || resp.Model != "gpt-4o" {
+		t.Errorf("unexpected response: %+v", resp.ChatBaseResponse)
+	}
+}
+
+func TestChatResponseStream_CollectWithMode(t *testing.T) {
+	body := strings.Join([]string{
+		`data: {"id":"1","choices":[{"index":0,"delta":{"role":"assistant","content":"Hel"}}]}`,
+		`data: {"id":"1","choices":[{"index":0,"delta":{"role":"assistant","content":"Hello"},"finish_reason":"stop"}]}`,
+		`data: [DONE]`,
+	}, "\n\n") + "\n\n"
+
+	tests := []struct {
+		name string
+		opts []CollectOption
+		want string
+	}{
+		{name: "default", want: "HelHello"},
+		{name: "cumulative", opts: []CollectOption{WithCollectMode(ChatStreamModeCumulative)}, want: "Hello"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := newTestChatStream("openai", body).Collect(nil, tt.opts...)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if msg := resp.Choices[0].Message; msg.Content != tt.want || msg.Role != "assistant" {
+				t.Errorf("got %+v, want content %q", msg, tt.want)
+			}
+		})
+	}
+}
+
+func TestChatStreamAccumulator_AliBL(t *testing.T) {
+	newChunk := func(reasoning, content string) (chunk ChatBaseResponse) {
+		chunk.SetProvider("alibl")
+		chunk.Choices = []ChatChoice{{Delta: &ChatCompletionMessage{ReasoningContent: reasoning, Content: content}}}
+		return
+	}
+
+	tests := []struct {
+		name   string
+		mode   ChatStreamMode
+		chunks []ChatBaseResponse
+		want   ChatCompletionMessage
+	}{
+		{
+			name:   "cumulative",
+			mode:   ChatStreamModeCumulative,
+			chunks: []ChatBaseResponse{newChunk("a", ""), newChunk("ab", ""), newChunk("ab", "x"), newChunk("ab", "xyz")},
+			want:   ChatCompletionMessage{ReasoningContent: "ab", Content: "xyz"},
+		},
+		{
+			name:   "incremental",
+			chunks: []ChatBaseResponse{newChunk("a", ""), newChunk("b", ""), newChunk("", "x"), newChunk("", "yz")},
+			want:   ChatCompletionMessage{ReasoningContent: "ab", Content: "xyz"},
+		},
+		{
+			// 增量内容恰好以已累加的内容开头时不会被误判为全量内容
+			name:   "incremental with repeated prefix",
+			chunks: []ChatBaseResponse{newChunk("", "ha"), newChunk("", "haha")},
+			want:   ChatCompletionMessage{Content: "hahaha"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewChatStreamAccumulator()
+			a.Mode = tt.mode
+			for _, chunk := range tt.chunks {
+				a.Add(chunk)
+			}
+			msg := a.Response().Choices[0].Message
+			if msg.ReasoningContent != tt.want.ReasoningContent || msg.Content != tt.want.Content {
+				t.Errorf("got %+v, want %+v", msg, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-27 14:12:36
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-27 15:48:02
+ * @Description:
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package models
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestChatUsage_Normalized(t *testing.T) {
+	tests := []struct {
+		fixture  string
+		provider string
+		want     NormalizedUsage
+	}{
+		{
+			fixture:  "openai_cached.json",
+			provider: "openai",
+			want:     NormalizedUsage{InputTokens: 2006, CachedInputTokens: 1920, OutputTokens: 8, TotalTokens: 2014},
+		},
+		{
+			fixture:  "openai_reasoning.json",
+			provider: "openai",
+			want:     NormalizedUsage{InputTokens: 15, OutputTokens: 210, ReasoningTokens: 192, TotalTokens: 225},
+		},
+		{
+			fixture:  "openai_audio.json",
+			provider: "openai",
+			want:     NormalizedUsage{InputTokens: 112, AudioInputTokens: 89, OutputTokens: 256, AudioOutputTokens: 198, TotalTokens: 368},
+		},
+		{
+			fixture:  "deepseek_reasoner.json",
+			provider: "deepseek",
+			want:     NormalizedUsage{InputTokens: 1280, CachedInputTokens: 1024, OutputTokens: 512, ReasoningTokens: 400, TotalTokens: 1792},
+		},
+		{
+			fixture:  "deepseek_chat.json",
+			provider: "deepseek",
+			want:     NormalizedUsage{InputTokens: 640, CachedInputTokens: 576, OutputTokens: 10, TotalTokens: 650},
+		},
+		{
+			fixture:  "alibl_text.json",
+			provider: "alibl",
+			want:     NormalizedUsage{InputTokens: 3019, CachedInputTokens: 2048, OutputTokens: 124, ReasoningTokens: 96, TotalTokens: 3143},
+		},
+		{
+			fixture:  "alibl_vision.json",
+			provider: "alibl",
+			want:     NormalizedUsage{InputTokens: 1262, ImageInputTokens: 1250, OutputTokens: 15, TotalTokens: 1277},
+		},
+		{
+			fixture:  "alibl_audio.json",
+			provider: "alibl",
+			want:     NormalizedUsage{InputTokens: 105, AudioInputTokens: 90, OutputTokens: 17, TotalTokens: 122},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", "usage", tt.fixture))
+			if err != nil {
+				t.Fatalf("read fixture: %v", err)
+			}
+			var resp ChatBaseResponse
+			resp.SetProvider(tt.provider)
+			if err = json.Unmarshal(data, &resp); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			if got := resp.Usage.Normalized(); got != tt.want {
+				t.Errorf("Normalized() = %+v, want %+v", got, tt.want)
+			}
+			usage, ok := resp.TokenUsage()
+			if !ok || usage.PromptTokens != tt.want.InputTokens || usage.CompletionTokens != tt.want.OutputTokens || usage.TotalTokens != tt.want.TotalTokens {
+				t.Errorf("TokenUsage() = %+v, %v", usage, ok)
+			}
+		})
+	}
+
+	// 只有 DeepSeek 缓存命中与未命中的 token 数时
+	usage := &ChatUsage{PromptCacheHitTokens: 30, PromptCacheMissTokens: 70, CompletionTokens: 5}
+	if got := usage.Normalized(); got.InputTokens != 100 || got.CachedInputTokens != 30 || got.TotalTokens != 105 {
+		t.Errorf("Normalized() = %+v", got)
+	}
+	if got := (*ChatUsage)(nil).Normalized(); got != (NormalizedUsage{}) {
+		t.Errorf("nil Normalized() = %+v", got)
+	}
+}
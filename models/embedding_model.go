@@ -0,0 +1,78 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-13 09:42:16
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-13 10:05:37
+ * @Description:
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package models
+
+import (
+	"github.com/Mrzhouyl/go-aisdk/consts"
+	"github.com/Mrzhouyl/go-aisdk/httpclient"
+	"github.com/Mrzhouyl/go-aisdk/internal/utils"
+)
+
+// EmbeddingEncodingFormat 嵌入向量编码格式
+type EmbeddingEncodingFormat string
+
+const (
+	// 浮点数数组
+	//
+	// 提供商支持: OpenAI
+	EmbeddingEncodingFormatFloat EmbeddingEncodingFormat = "float"
+)
+
+// EmbeddingRequest 创建嵌入向量请求
+type EmbeddingRequest struct {
+	UserInfo
+	Provider consts.Provider `json:"provider,omitempty"` // 提供商
+	// 要嵌入的文本列表
+	//
+	// 提供商支持: OpenAI
+	Input []string `json:"input,omitempty" providers:"openai"`
+	// 模型名称
+	//
+	// 提供商支持: OpenAI
+	Model string `json:"model,omitempty" providers:"openai"`
+	// 输出嵌入向量的维度，仅 text-embedding-3 及更高版本的模型支持
+	//
+	// 提供商支持: OpenAI
+	Dimensions int `json:"dimensions,omitempty" providers:"openai"`
+	// 嵌入向量编码格式
+	//
+	// 提供商支持: OpenAI
+	EncodingFormat EmbeddingEncodingFormat `json:"encoding_format,omitempty" providers:"openai"`
+}
+
+// MarshalJSON 序列化JSON
+func (r EmbeddingRequest) MarshalJSON() (b []byte, err error) {
+	provider := r.Provider.String()
+	// 序列化JSON
+	r.Provider = ""
+	return utils.NewSerializer(provider).Serialize(r)
+}
+
+// EmbeddingData 嵌入向量数据
+type EmbeddingData struct {
+	Object    string    `json:"object,omitempty"`    // 对象类型，总是为 embedding
+	Embedding []float32 `json:"embedding,omitempty"` // 嵌入向量
+	Index     int       `json:"index"`               // 嵌入向量在输入列表中的索引
+}
+
+// EmbeddingUsage 嵌入向量的token使用信息
+type EmbeddingUsage struct {
+	PromptTokens int `json:"prompt_tokens,omitempty"` // 输入的token数量
+	TotalTokens  int `json:"total_tokens,omitempty"`  // 使用的token总数
+}
+
+// EmbeddingResponse 创建嵌入向量响应
+type EmbeddingResponse struct {
+	Object string          `json:"object,omitempty"` // 对象类型，总是为 list
+	Data   []EmbeddingData `json:"data,omitempty"`   // 嵌入向量列表
+	Model  string          `json:"model,omitempty"`  // 使用的模型名称
+	Usage  *EmbeddingUsage `json:"usage,omitempty"`  // 嵌入向量的token使用信息
+	httpclient.HttpHeader
+}
@@ -2,7 +2,7 @@
  * @Author: liusuxian 382185882@qq.com
  * @Date: 2025-04-15 18:42:36
  * @LastEditors: liusuxian 382185882@qq.com
- * @LastEditTime: 2025-07-03 15:17:08
+ * @LastEditTime: 2025-07-28 16:58:31
  * @Description:
  *
  * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
@@ -117,10 +117,10 @@ type ChatUserMsgInputVideo struct {
 
 // ChatUserMsgPart 多模态内容
 type ChatUserMsgPart struct {
-	// 内容类型
+	// 内容类型（AliBL根据内容字段区分类型，忽略该参数）
 	//
 	// 提供商支持: OpenAI
-	Type ChatUserMsgPartType `json:"type,omitempty" providers:"openai"`
+	Type ChatUserMsgPartType `json:"type,omitempty" providers:"openai" unsupported:"ignore"`
 	// 文本内容
 	//
 	// 提供商支持: OpenAI | AliBL
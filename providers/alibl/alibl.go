@@ -2,7 +2,7 @@
  * @Author: liusuxian 382185882@qq.com
  * @Date: 2025-06-25 12:31:10
  * @LastEditors: liusuxian 382185882@qq.com
- * @LastEditTime: 2025-07-07 20:57:51
+ * @LastEditTime: 2025-07-29 15:26:40
  * @Description: AliBL服务提供商实现，采用单例模式，在包导入时自动注册到提供商工厂
  *
  * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
@@ -13,6 +13,7 @@ import (
 	"github.com/Mrzhouyl/go-aisdk/conf"
 	"github.com/Mrzhouyl/go-aisdk/consts"
 	"github.com/Mrzhouyl/go-aisdk/core"
+	"github.com/Mrzhouyl/go-aisdk/httpclient"
 	"github.com/Mrzhouyl/go-aisdk/loadbalancer"
 )
 
@@ -217,6 +218,7 @@ func init() {
 		},
 	}
 	core.RegisterProvider(consts.AliBL, aliblService)
+	httpclient.RegisterErrorRules(string(consts.AliBL), errorRules...)
 }
 
 // GetSupportedModels 获取支持的模型
@@ -0,0 +1,47 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-29 14:15:44
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-29 14:15:44
+ * @Description: AliBL错误码映射规则
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package alibl
+
+import (
+	"github.com/Mrzhouyl/go-aisdk/httpclient"
+)
+
+// errorRules AliBL错误码映射规则，同时包含 DashScope 和 OpenAI 兼容模式的错误码，参考 https://help.aliyun.com/zh/model-studio/error-code
+var errorRules = []httpclient.ErrorRule{
+	// 认证和权限
+	{Code: "InvalidApiKey", Kind: httpclient.ErrorKindAuth},
+	{Code: "invalid_api_key", Kind: httpclient.ErrorKindAuth},
+	{Code: "AccessDenied", Kind: httpclient.ErrorKindPermission},
+	{Code: "Model.AccessDenied", Kind: httpclient.ErrorKindPermission},
+	{Code: "Workspace.AccessDenied", Kind: httpclient.ErrorKindPermission},
+	// 限流和额度
+	{Code: "Throttling", Kind: httpclient.ErrorKindRateLimit},
+	{Code: "limit_requests", Kind: httpclient.ErrorKindRateLimit},
+	{Code: "Arrearage", Kind: httpclient.ErrorKindQuotaExhausted},
+	{Code: "AllocationQuota", Kind: httpclient.ErrorKindQuotaExhausted},
+	{Code: "insufficient_quota", Kind: httpclient.ErrorKindQuotaExhausted},
+	// 内容审核
+	{Code: "DataInspectionFailed", Kind: httpclient.ErrorKindContentFiltered},
+	{Code: "data_inspection_failed", Kind: httpclient.ErrorKindContentFiltered},
+	// 请求参数
+	{Message: "Range of input length", Kind: httpclient.ErrorKindContextLengthExceeded},
+	{Message: "Model not exist", Kind: httpclient.ErrorKindModelNotFound},
+	{Code: "model_not_found", Kind: httpclient.ErrorKindModelNotFound},
+	{Code: "InvalidParameter", Kind: httpclient.ErrorKindInvalidRequest},
+	{Code: "invalid_parameter_error", Kind: httpclient.ErrorKindInvalidRequest},
+	// 服务端错误
+	{Code: "RequestTimeOut", Kind: httpclient.ErrorKindTimeout},
+	{Code: "InternalError", Kind: httpclient.ErrorKindServerError},
+	{Code: "internal_error", Kind: httpclient.ErrorKindServerError},
+	{Code: "SystemError", Kind: httpclient.ErrorKindServerError},
+	{Code: "ModelServiceFailed", Kind: httpclient.ErrorKindServerError},
+	{Code: "ModelUnavailable", Kind: httpclient.ErrorKindServerError},
+	{Code: "ServiceUnavailable", Kind: httpclient.ErrorKindServerError},
+}
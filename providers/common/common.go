@@ -2,7 +2,7 @@
  * @Author: liusuxian 382185882@qq.com
  * @Date: 2025-06-20 01:15:31
  * @LastEditors: liusuxian 382185882@qq.com
- * @LastEditTime: 2025-07-03 15:36:16
+ * @LastEditTime: 2025-08-05 14:40:55
  * @Description:
  *
  * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
@@ -81,6 +81,7 @@ func ExecuteRequest(ctx context.Context, erc *ExecuteRequestContext) (err error)
 	}
 	// 发送请求
 	err = hc.SendRequest(req, erc.Response)
+	coolDownAPIKey(ctx, erc.LB, apiKey)
 	return
 }
 
@@ -112,5 +113,20 @@ func ExecuteStreamRequest[T httpclient.Streamable](ctx context.Context, erc *Exe
 		return
 	}
 	// 发送流式请求
-	return httpclient.SendRequestStream[T](hc, req)
+	stream, err = httpclient.SendRequestStream[T](hc, req)
+	coolDownAPIKey(ctx, erc.LB, apiKey)
+	return
+}
+
+// coolDownAPIKey 提供商返回的限流额度耗尽时，在额度重置前让负载均衡器优先选择其他APIKey
+// 并记录所有APIKey的冷却状态，限流中间件仅在所有APIKey都冷却时暂停整个提供商的请求
+func coolDownAPIKey(ctx context.Context, lb *loadbalancer.LoadBalancer, apiKey *loadbalancer.APIKey) {
+	rateLimit := httpclient.GetRequestInfo(ctx).RateLimit
+	if rateLimit == nil {
+		return
+	}
+	if resumeAt := rateLimit.ResumeAt(); !resumeAt.IsZero() {
+		_ = lb.CoolDown(apiKey.Key, resumeAt)
+	}
+	rateLimit.KeyPool, rateLimit.PoolResumeAt = true, lb.CoolingUntil()
 }
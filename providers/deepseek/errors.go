@@ -0,0 +1,23 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-29 13:58:31
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-29 13:58:31
+ * @Description: DeepSeek错误码映射规则
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package deepseek
+
+import (
+	"github.com/Mrzhouyl/go-aisdk/httpclient"
+)
+
+// errorRules DeepSeek错误码映射规则，DeepSeek 的错误码不区分具体原因，按错误信息匹配，其余按 HTTP 状态码判断，参考 https://api-docs.deepseek.com/quick_start/error_codes
+var errorRules = []httpclient.ErrorRule{
+	{Message: "Insufficient Balance", Kind: httpclient.ErrorKindQuotaExhausted},
+	{Message: "Content Exists Risk", Kind: httpclient.ErrorKindContentFiltered},
+	{Message: "Authentication Fails", Kind: httpclient.ErrorKindAuth},
+	{Message: "maximum context length", Kind: httpclient.ErrorKindContextLengthExceeded},
+	{Message: "Model Not Exist", Kind: httpclient.ErrorKindModelNotFound},
+}
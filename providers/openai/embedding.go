@@ -0,0 +1,41 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-13 10:08:51
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-13 10:12:04
+ * @Description:
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package openai
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/Mrzhouyl/go-aisdk/consts"
+	"github.com/Mrzhouyl/go-aisdk/httpclient"
+	"github.com/Mrzhouyl/go-aisdk/models"
+	"github.com/Mrzhouyl/go-aisdk/providers/common"
+)
+
+const (
+	apiEmbeddings = "/embeddings"
+)
+
+// CreateEmbeddings 创建嵌入向量
+func (s *openAIProvider) CreateEmbeddings(ctx context.Context, request models.EmbeddingRequest, opts ...httpclient.HTTPClientOption) (response models.EmbeddingResponse, err error) {
+	err = common.ExecuteRequest(ctx, &common.ExecuteRequestContext{
+		Provider: consts.OpenAI,
+		Method:   http.MethodPost,
+		BaseURL:  s.providerConfig.BaseURL,
+		ApiPath:  apiEmbeddings,
+		Opts:     opts,
+		LB:       s.lb,
+		Response: &response,
+		ReqSetters: []httpclient.RequestOption{
+			httpclient.WithBody(request),
+		},
+	})
+	return
+}
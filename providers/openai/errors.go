@@ -0,0 +1,30 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-29 13:42:05
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-29 13:42:05
+ * @Description: OpenAI错误码映射规则
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package openai
+
+import (
+	"github.com/Mrzhouyl/go-aisdk/httpclient"
+)
+
+// errorRules OpenAI错误码映射规则，参考 https://platform.openai.com/docs/guides/error-codes
+var errorRules = []httpclient.ErrorRule{
+	{Code: "invalid_api_key", Kind: httpclient.ErrorKindAuth},
+	{Code: "invalid_authentication", Kind: httpclient.ErrorKindAuth},
+	{Code: "unsupported_country_region_territory", Kind: httpclient.ErrorKindPermission},
+	{Code: "insufficient_quota", Kind: httpclient.ErrorKindQuotaExhausted},
+	{Code: "billing_hard_limit_reached", Kind: httpclient.ErrorKindQuotaExhausted},
+	{Code: "rate_limit_exceeded", Kind: httpclient.ErrorKindRateLimit},
+	{Code: "context_length_exceeded", Kind: httpclient.ErrorKindContextLengthExceeded},
+	{Code: "string_above_max_length", Kind: httpclient.ErrorKindContextLengthExceeded},
+	{Code: "content_filter", Kind: httpclient.ErrorKindContentFiltered},
+	{Code: "content_policy_violation", Kind: httpclient.ErrorKindContentFiltered},
+	{Code: "model_not_found", Kind: httpclient.ErrorKindModelNotFound},
+	{Code: "server_error", Kind: httpclient.ErrorKindServerError},
+}
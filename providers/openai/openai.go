@@ -2,7 +2,7 @@
  * @Author: liusuxian 382185882@qq.com
  * @Date: 2025-04-10 13:56:55
  * @LastEditors: liusuxian 382185882@qq.com
- * @LastEditTime: 2025-07-07 20:59:08
+ * @LastEditTime: 2025-07-29 15:26:40
  * @Description: OpenAI服务提供商实现，采用单例模式，在包导入时自动注册到提供商工厂
  *
  * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
@@ -158,6 +158,7 @@ func init() {
 		},
 	}
 	core.RegisterProvider(consts.OpenAI, openaiService)
+	httpclient.RegisterErrorRules(string(consts.OpenAI), errorRules...)
 }
 
 // GetSupportedModels 获取支持的模型
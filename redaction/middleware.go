@@ -0,0 +1,328 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-08-03 10:35:48
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-08-04 17:46:13
+ * @Description: 敏感信息脱敏中间件，请求发出前将提示词中的敏感信息替换为占位符，并在响应中还原
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package redaction
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/Mrzhouyl/go-aisdk/httpclient"
+	"github.com/Mrzhouyl/go-aisdk/models"
+)
+
+// RedactionMiddlewareConfig 敏感信息脱敏中间件配置
+type RedactionMiddlewareConfig struct {
+	Patterns       []Pattern // 匹配规则，默认使用 DefaultPatterns()
+	CustomPatterns []Pattern // 自定义匹配规则，在 Patterns 之后匹配
+	DisableRestore bool      // 是否不还原响应中的占位符
+}
+
+// RedactionMiddleware 敏感信息脱敏中间件，将聊天消息文本中的邮箱、电话号码、身份证号码、信用卡号等替换为占位符（如 [EMAIL_1]），
+// 并将模型响应（包括流式传输的增量内容）中的占位符还原为原始值
+type RedactionMiddleware struct {
+	config   RedactionMiddlewareConfig
+	patterns []Pattern
+}
+
+// NewRedactionMiddleware 创建敏感信息脱敏中间件
+func NewRedactionMiddleware(config RedactionMiddlewareConfig) (m *RedactionMiddleware) {
+	// 设置默认值
+	if config.Patterns == nil {
+		config.Patterns = DefaultPatterns()
+	}
+	patterns := make([]Pattern, 0, len(config.Patterns)+len(config.CustomPatterns))
+	for _, pattern := range slices.Concat(config.Patterns, config.CustomPatterns) {
+		if pattern.Regexp == nil {
+			continue
+		}
+		// 占位符名称只包含大写字母、数字和下划线
+		pattern.Name = placeholderNameRegexp.ReplaceAllString(strings.ToUpper(pattern.Name), "_")
+		if pattern.Name == "" {
+			pattern.Name = "REDACTED"
+		}
+		patterns = append(patterns, pattern)
+	}
+	return &RedactionMiddleware{
+		config:   config,
+		patterns: patterns,
+	}
+}
+
+// placeholderNameRegexp 占位符名称中不允许的字符
+var placeholderNameRegexp = regexp.MustCompile(`[^A-Z0-9_]+`)
+
+// Process 处理请求
+func (m *RedactionMiddleware) Process(ctx context.Context, request any, next httpclient.MWHandler) (response any, err error) {
+	chatReq, ok := request.(models.ChatRequest)
+	if !ok {
+		return next(ctx, request)
+	}
+	v := newVault(m.patterns)
+	chatReq.Messages = v.redactMessages(chatReq.Messages)
+	// 执行下一个处理器
+	if response, err = next(ctx, chatReq); err != nil || m.config.DisableRestore || len(v.originals) == 0 {
+		return
+	}
+	// 还原响应中的占位符
+	switch resp := response.(type) {
+	case models.ChatResponse:
+		resp.Choices = v.restoreChoices(resp.Choices)
+		response = resp
+	case httpclient.WrappableStream:
+		resp.WrapRecv(func(next httpclient.StreamRecvFunc) (recv httpclient.StreamRecvFunc) {
+			return v.restoreStream(next)
+		})
+	}
+	return
+}
+
+// Name 返回中间件名称
+func (m *RedactionMiddleware) Name() (name string) {
+	return "redaction"
+}
+
+// Priority 返回中间件优先级
+func (m *RedactionMiddleware) Priority() (priority int) {
+	return 3 // 脱敏中间件位于最外层，其他中间件（缓存、日志、链路追踪）只能看到脱敏后的内容
+}
+
+// redactMessages 脱敏消息列表，返回新的消息列表，不修改原消息
+func (v *vault) redactMessages(messages []models.ChatMessage) (redacted []models.ChatMessage) {
+	redacted = make([]models.ChatMessage, len(messages))
+	for i, message := range messages {
+		switch msg := message.(type) {
+		case *models.SystemMessage:
+			c := *msg
+			c.Content = v.redact(c.Content)
+			redacted[i] = &c
+		case *models.DeveloperMessage:
+			c := *msg
+			c.Content = v.redact(c.Content)
+			redacted[i] = &c
+		case *models.UserMessage:
+			c := *msg
+			c.Content = v.redact(c.Content)
+			c.MultimodalContent = slices.Clone(c.MultimodalContent)
+			for j := range c.MultimodalContent {
+				c.MultimodalContent[j].Text = v.redact(c.MultimodalContent[j].Text)
+			}
+			redacted[i] = &c
+		case *models.AssistantMessage:
+			c := *msg
+			c.Content = v.redact(c.Content)
+			c.MultimodalContent = slices.Clone(c.MultimodalContent)
+			for j := range c.MultimodalContent {
+				c.MultimodalContent[j].Text = v.redact(c.MultimodalContent[j].Text)
+			}
+			c.ToolCalls = v.redactToolCalls(c.ToolCalls)
+			redacted[i] = &c
+		case *models.ToolMessage:
+			c := *msg
+			c.Content = v.redact(c.Content)
+			redacted[i] = &c
+		default:
+			redacted[i] = message
+		}
+	}
+	return
+}
+
+// redactToolCalls 脱敏历史消息中的工具调用参数，返回新的工具调用列表，不修改原工具调用
+func (v *vault) redactToolCalls(toolCalls []models.ToolCalls) (redacted []models.ToolCalls) {
+	redacted = slices.Clone(toolCalls)
+	for i := range redacted {
+		if redacted[i].Function == nil {
+			continue
+		}
+		function := *redacted[i].Function
+		function.Arguments = v.redact(function.Arguments)
+		redacted[i].Function = &function
+	}
+	return
+}
+
+// restoreChoices 还原非流式响应中的占位符，返回新的选择列表，不修改原响应（原响应可能已被缓存）
+func (v *vault) restoreChoices(choices []models.ChatChoice) (restored []models.ChatChoice) {
+	restored = slices.Clone(choices)
+	for i := range restored {
+		if restored[i].Message == nil {
+			continue
+		}
+		message := *restored[i].Message
+		message.Content = v.restore(message.Content)
+		message.ReasoningContent = v.restore(message.ReasoningContent)
+		message.Refusal = v.restore(message.Refusal)
+		message.ToolCalls = v.restoreToolCalls(message.ToolCalls)
+		restored[i].Message = &message
+	}
+	return
+}
+
+// restoreToolCalls 还原工具调用参数中的占位符
+func (v *vault) restoreToolCalls(toolCalls []models.ToolCalls) (restored []models.ToolCalls) {
+	restored = slices.Clone(toolCalls)
+	for i := range restored {
+		if restored[i].Function == nil {
+			continue
+		}
+		function := *restored[i].Function
+		function.Arguments = v.restore(function.Arguments)
+		restored[i].Function = &function
+	}
+	return
+}
+
+// streamBuffer 流式传输中单个选择尚未输出的内容，占位符可能被拆分到多个数据块中
+type streamBuffer struct {
+	content   string
+	reasoning string
+	arguments map[int]string // 工具调用索引 -> 尚未输出的工具调用参数
+}
+
+// streamEnd 流结束事件，输出剩余内容后再返回
+type streamEnd struct {
+	chunk      any
+	isFinished bool
+	err        error
+}
+
+// restoreStream 包装流式数据接收函数，还原增量内容中的占位符
+// 以可能被截断的占位符结尾的内容会等待后续数据块，在选择结束或流结束时输出
+func (v *vault) restoreStream(next httpclient.StreamRecvFunc) (recv httpclient.StreamRecvFunc) {
+	var (
+		buffers = make(map[int]*streamBuffer)
+		last    models.ChatBaseResponse // 最后一个数据块，用于构造输出剩余内容的数据块
+		end     *streamEnd              // 等待返回的结束事件
+	)
+	return func() (chunk any, isFinished bool, err error) {
+		if end != nil {
+			chunk, isFinished, err, end = end.chunk, end.isFinished, end.err, nil
+			return
+		}
+		if chunk, isFinished, err = next(); err != nil || isFinished {
+			// 没有收到结束原因时，在返回结束事件前输出剩余内容，主动关闭的流不再被读取，无需输出
+			if !errors.Is(err, httpclient.ErrStreamClosed) {
+				if flushed, ok := v.flushStream(buffers, last); ok {
+					end = &streamEnd{chunk: chunk, isFinished: isFinished, err: err}
+					return flushed, false, nil
+				}
+			}
+			return
+		}
+		c, ok := chunk.(models.ChatBaseResponse)
+		if !ok {
+			return
+		}
+		last = c
+		c.Choices = slices.Clone(c.Choices)
+		for i := range c.Choices {
+			choice := &c.Choices[i]
+			flush := choice.FinishReason != "" && choice.FinishReason != models.ChatFinishReasonNull
+			// 结束数据块可能没有增量信息，仍需输出剩余内容
+			if choice.Delta == nil && !flush {
+				continue
+			}
+			buf, ok := buffers[choice.Index]
+			if !ok {
+				buf = &streamBuffer{arguments: make(map[int]string)}
+				buffers[choice.Index] = buf
+			}
+			var delta models.ChatCompletionMessage
+			if choice.Delta != nil {
+				delta = *choice.Delta
+			}
+			delta.Content = v.restoreDelta(&buf.content, delta.Content, flush)
+			delta.ReasoningContent = v.restoreDelta(&buf.reasoning, delta.ReasoningContent, flush)
+			delta.Refusal = v.restore(delta.Refusal)
+			delta.ToolCalls = v.restoreToolCallDeltas(buf.arguments, delta.ToolCalls, flush)
+			if choice.Delta != nil || !deltaEmpty(delta) {
+				choice.Delta = &delta
+			}
+		}
+		chunk = c
+		return
+	}
+}
+
+// flushStream 构造输出所有选择剩余内容的数据块，没有剩余内容时返回 false
+func (v *vault) flushStream(buffers map[int]*streamBuffer, last models.ChatBaseResponse) (chunk models.ChatBaseResponse, ok bool) {
+	chunk = last
+	chunk.Choices, chunk.Usage, chunk.StreamStats = nil, nil, nil
+	for _, index := range slices.Sorted(maps.Keys(buffers)) {
+		buf := buffers[index]
+		delta := models.ChatCompletionMessage{
+			Content:          v.restoreDelta(&buf.content, "", true),
+			ReasoningContent: v.restoreDelta(&buf.reasoning, "", true),
+			ToolCalls:        v.restoreToolCallDeltas(buf.arguments, nil, true),
+		}
+		if deltaEmpty(delta) {
+			continue
+		}
+		chunk.Choices = append(chunk.Choices, models.ChatChoice{Index: index, Delta: &delta})
+	}
+	return chunk, len(chunk.Choices) > 0
+}
+
+// deltaEmpty 增量信息是否没有需要输出的内容
+func deltaEmpty(delta models.ChatCompletionMessage) (empty bool) {
+	return delta.Content == "" && delta.ReasoningContent == "" && len(delta.ToolCalls) == 0
+}
+
+// restoreToolCallDeltas 还原增量工具调用参数中的占位符，pending 为各工具调用等待输出的参数
+// 选择结束时，本数据块中没有出现的工具调用的剩余参数以新的增量输出
+func (v *vault) restoreToolCallDeltas(pending map[int]string, toolCalls []models.ToolCalls, flush bool) (restored []models.ToolCalls) {
+	restored = slices.Clone(toolCalls)
+	for i := range restored {
+		if restored[i].Function == nil {
+			continue
+		}
+		function := *restored[i].Function
+		arguments := pending[restored[i].Index]
+		function.Arguments = v.restoreDelta(&arguments, function.Arguments, flush)
+		pending[restored[i].Index] = arguments
+		restored[i].Function = &function
+	}
+	if !flush {
+		return
+	}
+	for _, index := range slices.Sorted(maps.Keys(pending)) {
+		if arguments := pending[index]; arguments != "" {
+			restored = append(restored, models.ToolCalls{Index: index, Function: &models.ToolCallsFunction{Arguments: v.restore(arguments)}})
+		}
+		delete(pending, index)
+	}
+	return
+}
+
+// restoreDelta 还原增量内容中的占位符，pending 为上一个数据块中等待输出的内容
+func (v *vault) restoreDelta(pending *string, delta string, flush bool) (restored string) {
+	text := *pending + delta
+	if text == "" {
+		return ""
+	}
+	*pending = ""
+	if !flush {
+		if suffix := v.pendingSuffix(text); suffix != "" {
+			text, *pending = text[:len(text)-len(suffix)], suffix
+		}
+	}
+	return v.restore(text)
+}
+
+// DefaultRedactionConfig 默认敏感信息脱敏配置
+func DefaultRedactionConfig() (config RedactionMiddlewareConfig) {
+	return RedactionMiddlewareConfig{
+		Patterns: DefaultPatterns(),
+	}
+}
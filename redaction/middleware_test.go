@@ -0,0 +1,243 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-08-03 14:48:22
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-08-04 17:46:13
+ * @Description:
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package redaction
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/Mrzhouyl/go-aisdk/httpclient"
+	"github.com/Mrzhouyl/go-aisdk/internal/utils"
+	"github.com/Mrzhouyl/go-aisdk/models"
+)
+
+func TestVault(t *testing.T) {
+	v := newVault(DefaultPatterns())
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"mail alice@example.com or bob@test.cn", "mail [EMAIL_1] or [EMAIL_2]"},
+		{"again alice@example.com", "again [EMAIL_1]"},
+		{"call 13812345678 or +1 415-555-2671", "call [PHONE_1] or [PHONE_2]"},
+		{"id 11010519491231002X", "id [ID_NUMBER_1]"},
+		{"id 110105194912310021 has a bad checksum", "id 110105194912310021 has a bad checksum"},
+		{"card 4111 1111 1111 1111", "card [CREDIT_CARD_1]"},
+		{"card 4111111111111112 fails luhn", "card 4111111111111112 fails luhn"},
+		{"order 20250803 is fine", "order 20250803 is fine"},
+	}
+	for _, tt := range tests {
+		if got := v.redact(tt.input); got != tt.want {
+			t.Errorf("redact(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+	if got := v.restore("[EMAIL_1], [CREDIT_CARD_1] and [UNKNOWN_1]"); got != "alice@example.com, 4111 1111 1111 1111 and [UNKNOWN_1]" {
+		t.Errorf("restore() = %q", got)
+	}
+}
+
+// chatRequest 创建包含敏感信息的聊天请求
+func chatRequest() (request models.ChatRequest) {
+	return models.ChatRequest{
+		Messages: []models.ChatMessage{
+			&models.SystemMessage{Content: "Ticket owner: EMP-00042"},
+			&models.UserMessage{MultimodalContent: []models.ChatUserMsgPart{{Type: models.ChatUserMsgPartTypeText, Text: "Email alice@example.com about order 123"}}},
+			&models.AssistantMessage{ToolCalls: []models.ToolCalls{{ID: "call_1", Function: &models.ToolCallsFunction{Name: "lookup", Arguments: `{"email":"alice@example.com"}`}}}},
+			&models.ToolMessage{Content: `{"phone":"13812345678"}`, ToolCallID: "call_1"},
+		},
+	}
+}
+
+func TestRedactionMiddleware(t *testing.T) {
+	m := NewRedactionMiddleware(RedactionMiddlewareConfig{
+		CustomPatterns: []Pattern{{Name: "employee id", Regexp: regexp.MustCompile(`EMP-\d{5}`)}},
+	})
+	request := chatRequest()
+	var sent models.ChatRequest
+	response, err := m.Process(context.Background(), request, func(ctx context.Context, req any) (any, error) {
+		sent = req.(models.ChatRequest)
+		return models.ChatResponse{ChatBaseResponse: models.ChatBaseResponse{Choices: []models.ChatChoice{{
+			Message: &models.ChatCompletionMessage{
+				Content:   "I emailed [EMAIL_1] for [EMPLOYEE_ID_1].",
+				ToolCalls: []models.ToolCalls{{Function: &models.ToolCallsFunction{Name: "sms", Arguments: `{"to":"[PHONE_1]"}`}}},
+			},
+		}}}}, nil
+	})
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	// 发出的请求只包含占位符
+	b, _ := json.Marshal(sent.Messages)
+	for _, secret := range []string{"alice@example.com", "13812345678", "EMP-00042"} {
+		if strings.Contains(string(b), secret) {
+			t.Errorf("sent request contains %q: %s", secret, b)
+		}
+	}
+	if got := sent.Messages[0].(*models.SystemMessage).Content; got != "Ticket owner: [EMPLOYEE_ID_1]" {
+		t.Errorf("system message = %q", got)
+	}
+	if got := sent.Messages[2].(*models.AssistantMessage).ToolCalls[0].Function.Arguments; got != `{"email":"[EMAIL_1]"}` {
+		t.Errorf("assistant tool call arguments = %q", got)
+	}
+	if got := sent.Messages[3].(*models.ToolMessage); got.Content != `{"phone":"[PHONE_1]"}` || got.ToolCallID != "call_1" {
+		t.Errorf("tool message = %+v", got)
+	}
+	// 调用方的消息不被修改
+	if got := request.Messages[1].(*models.UserMessage).MultimodalContent[0].Text; got != "Email alice@example.com about order 123" {
+		t.Errorf("original message modified: %q", got)
+	}
+	if got := request.Messages[2].(*models.AssistantMessage).ToolCalls[0].Function.Arguments; got != `{"email":"alice@example.com"}` {
+		t.Errorf("original tool call modified: %q", got)
+	}
+	// 响应中的占位符被还原
+	message := response.(models.ChatResponse).Choices[0].Message
+	if message.Content != "I emailed alice@example.com for EMP-00042." || message.ToolCalls[0].Function.Arguments != `{"to":"13812345678"}` {
+		t.Errorf("response not restored: %q %q", message.Content, message.ToolCalls[0].Function.Arguments)
+	}
+}
+
+func TestRedactionMiddleware_Stream(t *testing.T) {
+	m := NewRedactionMiddleware(DefaultRedactionConfig())
+	// 占位符被拆分到多个数据块中
+	body := "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Sure, [EMA\"}}]}\n\n" +
+		"data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"IL_1] and [\"}}]}\n\n" +
+		"data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"PHONE_1\"}}]}\n\n" +
+		"data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"] [note\"}}]}\n\n" +
+		"data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\" [PHO\"},\"finish_reason\":\"stop\"}]}\n\n" +
+		"data: [DONE]\n\n"
+	response, err := m.Process(context.Background(), models.ChatRequest{
+		Messages: []models.ChatMessage{&models.UserMessage{Content: "Contact alice@example.com or 13812345678"}},
+	}, func(ctx context.Context, req any) (any, error) {
+		return models.ChatResponseStream{StreamReader: httpclient.NewStreamReader[models.ChatBaseResponse](
+			io.NopCloser(strings.NewReader(body)), nil, httpclient.HTTPClientConfig{ResponseDecoder: utils.NewDeserializer("", true)},
+		)}, nil
+	})
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	var (
+		stream = response.(models.ChatResponseStream)
+		deltas []string
+	)
+	for {
+		item, isFinished, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("Recv() error = %v", err)
+		}
+		if isFinished {
+			break
+		}
+		deltas = append(deltas, item.Choices[0].Delta.Content)
+	}
+	if got := strings.Join(deltas, ""); got != "Sure, alice@example.com and 13812345678 [note [PHO" {
+		t.Errorf("restored content = %q, deltas = %q", got, deltas)
+	}
+	// 截断的占位符不会单独输出
+	if deltas[0] != "Sure, " || deltas[2] != "" {
+		t.Errorf("partial placeholder emitted: %q", deltas)
+	}
+}
+
+func TestRedactionMiddleware_StreamToolCalls(t *testing.T) {
+	m := NewRedactionMiddleware(DefaultRedactionConfig())
+	// 工具调用参数中的占位符被拆分到多个数据块中
+	body := `data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"sms","arguments":"{\"to\":\"[PHO"}}]}}]}` + "\n\n" +
+		`data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"NE_1]\"}"}}]}}]}` + "\n\n" +
+		`data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":1,"id":"call_2","type":"function","function":{"name":"mail","arguments":"{\"to\":\"[EMAIL_"}}]}}]}` + "\n\n" +
+		`data: {"choices":[{"index":0,"delta":{"content":""},"finish_reason":"tool_calls"}]}` + "\n\n" +
+		"data: [DONE]\n\n"
+	response, err := m.Process(context.Background(), models.ChatRequest{
+		Messages: []models.ChatMessage{&models.UserMessage{Content: "Text 13812345678 and mail alice@example.com"}},
+	}, func(ctx context.Context, req any) (any, error) {
+		return models.ChatResponseStream{StreamReader: httpclient.NewStreamReader[models.ChatBaseResponse](
+			io.NopCloser(strings.NewReader(body)), nil, httpclient.HTTPClientConfig{ResponseDecoder: utils.NewDeserializer("", true)},
+		)}, nil
+	})
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	var (
+		stream    = response.(models.ChatResponseStream)
+		arguments = make(map[int][]string)
+	)
+	for {
+		item, isFinished, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("Recv() error = %v", err)
+		}
+		if isFinished {
+			break
+		}
+		for _, toolCall := range item.Choices[0].Delta.ToolCalls {
+			arguments[toolCall.Index] = append(arguments[toolCall.Index], toolCall.Function.Arguments)
+		}
+	}
+	if got := arguments[0]; strings.Join(got, "") != `{"to":"13812345678"}` || got[0] != `{"to":"` {
+		t.Errorf("tool call 0 arguments = %q", got)
+	}
+	// 选择结束时输出剩余的参数
+	if got := strings.Join(arguments[1], ""); got != `{"to":"[EMAIL_` {
+		t.Errorf("tool call 1 arguments = %q", arguments[1])
+	}
+}
+
+func TestRedactionMiddleware_StreamFlush(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"no finish reason", "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Mail [EMAIL_1] or [EMA\"}}]}\n\n" +
+			"data: [DONE]\n\n"},
+		{"finish without delta", "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Mail [EMAIL_1] or [EMA\"}}]}\n\n" +
+			"data: {\"choices\":[{\"index\":0,\"finish_reason\":\"stop\"}]}\n\n" +
+			"data: [DONE]\n\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewRedactionMiddleware(DefaultRedactionConfig())
+			response, err := m.Process(context.Background(), models.ChatRequest{
+				Messages: []models.ChatMessage{&models.UserMessage{Content: "Contact alice@example.com"}},
+			}, func(ctx context.Context, req any) (any, error) {
+				return models.ChatResponseStream{StreamReader: httpclient.NewStreamReader[models.ChatBaseResponse](
+					io.NopCloser(strings.NewReader(tt.body)), nil, httpclient.HTTPClientConfig{ResponseDecoder: utils.NewDeserializer("", true)},
+				)}, nil
+			})
+			if err != nil {
+				t.Fatalf("Process() error = %v", err)
+			}
+			var (
+				stream  = response.(models.ChatResponseStream)
+				content strings.Builder
+			)
+			for {
+				item, isFinished, err := stream.Recv()
+				if err != nil {
+					t.Fatalf("Recv() error = %v", err)
+				}
+				if isFinished {
+					break
+				}
+				for _, choice := range item.Choices {
+					if choice.Delta != nil {
+						content.WriteString(choice.Delta.Content)
+					}
+				}
+			}
+			// 等待中的内容在流结束前输出
+			if got := content.String(); got != "Mail alice@example.com or [EMA" {
+				t.Errorf("restored content = %q", got)
+			}
+		})
+	}
+}
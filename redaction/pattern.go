@@ -0,0 +1,159 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-08-03 10:12:05
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-08-03 16:40:27
+ * @Description: 敏感信息匹配规则和占位符
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package redaction
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Pattern 敏感信息匹配规则
+type Pattern struct {
+	Name     string                       // 名称，用于生成占位符，如 EMAIL 生成 [EMAIL_1]
+	Regexp   *regexp.Regexp               // 匹配规则
+	Validate func(match string) (ok bool) // 校验匹配结果（可选），返回 false 时不替换，如校验信用卡号
+}
+
+// 内置匹配规则
+var (
+	// PatternEmail 邮箱地址
+	PatternEmail = Pattern{
+		Name:   "EMAIL",
+		Regexp: regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`),
+	}
+	// PatternIDNumber 中国居民身份证号码，校验末位校验码
+	PatternIDNumber = Pattern{
+		Name:     "ID_NUMBER",
+		Regexp:   regexp.MustCompile(`\b\d{17}[\dXx]\b`),
+		Validate: validIDNumber,
+	}
+	// PatternCreditCard 信用卡号，数字之间可以有空格或连字符，使用 Luhn 算法校验
+	PatternCreditCard = Pattern{
+		Name:     "CREDIT_CARD",
+		Regexp:   regexp.MustCompile(`\b\d(?:[ \-]?\d){12,18}\b`),
+		Validate: validLuhn,
+	}
+	// PatternPhone 电话号码，包括中国大陆手机号和带分隔符的国际号码
+	PatternPhone = Pattern{
+		Name:   "PHONE",
+		Regexp: regexp.MustCompile(`\b1[3-9]\d{9}\b|(?:\+\d{1,3}[ \-]?)?(?:\(\d{1,4}\)[ \-]?)?\b\d{2,4}[ \-.]\d{3,4}[ \-.]\d{3,4}\b`),
+	}
+)
+
+// DefaultPatterns 默认匹配规则，按顺序替换，身份证号码和信用卡号先于电话号码匹配
+func DefaultPatterns() (patterns []Pattern) {
+	return []Pattern{PatternEmail, PatternIDNumber, PatternCreditCard, PatternPhone}
+}
+
+// placeholderRegexp 占位符的格式
+var placeholderRegexp = regexp.MustCompile(`\[[A-Z0-9_]+_\d+\]`)
+
+// vault 单次请求的占位符和原始值映射
+type vault struct {
+	patterns     []Pattern
+	placeholders map[string]string // 原始值 -> 占位符
+	originals    map[string]string // 占位符 -> 原始值
+	counts       map[string]int    // 规则名称 -> 已生成的占位符数量
+}
+
+// newVault 创建占位符映射
+func newVault(patterns []Pattern) (v *vault) {
+	return &vault{
+		patterns:     patterns,
+		placeholders: make(map[string]string),
+		originals:    make(map[string]string),
+		counts:       make(map[string]int),
+	}
+}
+
+// redact 将文本中的敏感信息替换为占位符，相同的原始值使用相同的占位符
+func (v *vault) redact(text string) (redacted string) {
+	if text == "" {
+		return text
+	}
+	for _, pattern := range v.patterns {
+		text = pattern.Regexp.ReplaceAllStringFunc(text, func(match string) string {
+			if pattern.Validate != nil && !pattern.Validate(match) {
+				return match
+			}
+			if placeholder, ok := v.placeholders[match]; ok {
+				return placeholder
+			}
+			v.counts[pattern.Name]++
+			placeholder := fmt.Sprintf("[%s_%d]", pattern.Name, v.counts[pattern.Name])
+			v.placeholders[match] = placeholder
+			v.originals[placeholder] = match
+			return placeholder
+		})
+	}
+	return text
+}
+
+// restore 将文本中的占位符还原为原始值
+func (v *vault) restore(text string) (restored string) {
+	if len(v.originals) == 0 || !strings.Contains(text, "[") {
+		return text
+	}
+	return placeholderRegexp.ReplaceAllStringFunc(text, func(placeholder string) string {
+		if original, ok := v.originals[placeholder]; ok {
+			return original
+		}
+		return placeholder
+	})
+}
+
+// pendingSuffix 获取文本末尾可能是被截断的占位符的部分，流式传输时需要等待后续数据块
+func (v *vault) pendingSuffix(text string) (suffix string) {
+	i := strings.LastIndexByte(text, '[')
+	if i < 0 || strings.IndexByte(text[i:], ']') >= 0 {
+		return ""
+	}
+	suffix = text[i:]
+	for placeholder := range v.originals {
+		if strings.HasPrefix(placeholder, suffix) {
+			return suffix
+		}
+	}
+	return ""
+}
+
+// validIDNumber 校验身份证号码的校验码（ISO 7064 MOD 11-2）
+func validIDNumber(id string) (ok bool) {
+	weights := [17]int{7, 9, 10, 5, 8, 4, 2, 1, 6, 3, 7, 9, 10, 5, 8, 4, 2}
+	sum := 0
+	for i, w := range weights {
+		sum += int(id[i]-'0') * w
+	}
+	return strings.ToUpper(id[17:]) == string("10X98765432"[sum%11])
+}
+
+// validLuhn 使用 Luhn 算法校验卡号
+func validLuhn(number string) (ok bool) {
+	var (
+		sum    int
+		double bool
+	)
+	for i := len(number) - 1; i >= 0; i-- {
+		c := number[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if double {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
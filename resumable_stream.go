@@ -0,0 +1,417 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-18 09:41:12
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-08-06 16:08:27
+ * @Description: 可断点续传的流式聊天，传输中断后通过前缀续写重新连接并拼接为一个连续的流
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package aisdk
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"io"
+	"math"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/Mrzhouyl/go-aisdk/consts"
+	"github.com/Mrzhouyl/go-aisdk/httpclient"
+	"github.com/Mrzhouyl/go-aisdk/models"
+)
+
+// prefixProviders 支持对话前缀续写（AssistantMessage.Prefix）的提供商，值表示是否支持思维链前缀（AssistantMessage.ReasoningContent）
+var prefixProviders = map[consts.Provider]bool{
+	consts.DeepSeek: true,
+	consts.AliBL:    false,
+}
+
+// ResumableStreamReconnectFunc 重新连接回调函数，attempt 从 1 开始，err 为导致重新连接的错误
+type ResumableStreamReconnectFunc func(attempt int, err error)
+
+// ResumableStreamConfig 可断点续传的流式聊天配置
+type ResumableStreamConfig struct {
+	MaxReconnects int                          // 最大重新连接次数
+	RetryDelay    time.Duration                // 重新连接前的等待时间
+	ChunkTimeout  time.Duration                // 等待单个数据块的超时时间，超时视为传输中断，为 0 表示不限制
+	ShouldResume  func(err error) (ok bool)    // 判断错误是否可以续传，默认仅续传传输层错误
+	OnReconnect   ResumableStreamReconnectFunc // 重新连接回调函数
+}
+
+// DefaultResumableStreamConfig 默认可断点续传的流式聊天配置
+func DefaultResumableStreamConfig() (config ResumableStreamConfig) {
+	return ResumableStreamConfig{
+		MaxReconnects: 3,
+		RetryDelay:    500 * time.Millisecond,
+		ChunkTimeout:  15 * time.Second,
+		ShouldResume:  isTransportError,
+	}
+}
+
+// CreateResumableChatCompletionStream 创建可断点续传的流式聊天
+//
+// 传输层错误导致流中断后自动重新发起请求：尚未输出内容时直接重发原请求；已输出部分内容时，对支持前缀续写的提供商（DeepSeek 需配置 beta 地址、AliBL）
+// 将已输出的内容作为 assistant 前缀发送，续写的内容拼接到同一个流中，使用方看到的是一个连续的流。已输出工具调用或多个 choice 时不续传
+func (c *SDKClient) CreateResumableChatCompletionStream(
+	ctx context.Context,
+	request models.ChatRequest,
+	config ResumableStreamConfig,
+	opts ...httpclient.HTTPClientOption,
+) (response models.ChatResponseStream, err error) {
+	return newResumableStream(ctx, request, config, func(ctx context.Context, request models.ChatRequest) (stream models.ChatResponseStream, err error) {
+		return c.CreateChatCompletionStream(ctx, request, opts...)
+	})
+}
+
+// resumableStreamConnectFunc 建立流式聊天连接的函数
+type resumableStreamConnectFunc func(ctx context.Context, request models.ChatRequest) (stream models.ChatResponseStream, err error)
+
+// resumableResult 单次接收数据的结果
+type resumableResult struct {
+	chunk      models.ChatBaseResponse
+	isFinished bool
+	err        error
+}
+
+// resumableConn 单个连接，设置了 ChunkTimeout 时由一个读取协程持续接收数据
+type resumableConn struct {
+	stream    models.ChatResponseStream
+	results   chan resumableResult // 读取协程接收的数据，未设置 ChunkTimeout 时为 nil
+	stop      chan struct{}        // 关闭连接时关闭该通道，结束读取协程
+	closeOnce sync.Once
+}
+
+// newResumableConn 新建连接，设置了 ChunkTimeout 时启动读取协程
+func newResumableConn(stream models.ChatResponseStream, chunkTimeout time.Duration) (c *resumableConn) {
+	c = &resumableConn{stream: stream, stop: make(chan struct{})}
+	if chunkTimeout > 0 {
+		c.results = make(chan resumableResult)
+		go c.read()
+	}
+	return
+}
+
+// read 持续接收数据直到流结束、出错或连接关闭
+func (c *resumableConn) read() {
+	for {
+		chunk, isFinished, err := c.stream.Recv()
+		select {
+		case c.results <- resumableResult{chunk: chunk, isFinished: isFinished, err: err}:
+		case <-c.stop:
+			return
+		}
+		if isFinished || err != nil {
+			return
+		}
+	}
+}
+
+// close 关闭连接并结束读取协程，可以重复调用
+func (c *resumableConn) close() (err error) {
+	c.closeOnce.Do(func() {
+		close(c.stop)
+		err = c.stream.Close()
+	})
+	return
+}
+
+// resumableStream 可断点续传的流式聊天
+type resumableStream struct {
+	ctx          context.Context
+	request      models.ChatRequest
+	config       ResumableStreamConfig
+	connect      resumableStreamConnectFunc
+	mu           sync.Mutex        // 保护 current 和 closed
+	current      *resumableConn    // 当前连接
+	closed       bool              // 是否已关闭
+	done         chan struct{}     // 关闭时关闭该通道
+	id           string            // 第一个数据块的ID，续写的数据块沿用该ID
+	content      strings.Builder   // 已输出的内容
+	reasoning    strings.Builder   // 已输出的推理内容
+	hasToolCalls bool              // 是否已输出工具调用
+	multiChoice  bool              // 是否已输出多个 choice
+	reconnects   int               // 已重新连接的次数
+	usage        *models.ChatUsage // 之前各个连接的用量之和
+	connUsage    *models.ChatUsage // 当前连接最近一次返回的用量
+}
+
+// newResumableStream 新建可断点续传的流式聊天
+func newResumableStream(
+	ctx context.Context,
+	request models.ChatRequest,
+	config ResumableStreamConfig,
+	connect resumableStreamConnectFunc,
+) (response models.ChatResponseStream, err error) {
+	// 设置默认值
+	if config.MaxReconnects < 0 {
+		config.MaxReconnects = 0
+	}
+	if config.ShouldResume == nil {
+		config.ShouldResume = isTransportError
+	}
+	// 建立第一个连接，失败时直接返回
+	var current models.ChatResponseStream
+	if current, err = connect(ctx, request); err != nil {
+		return
+	}
+
+	s := &resumableStream{
+		ctx:     ctx,
+		request: request,
+		config:  config,
+		connect: connect,
+		current: newResumableConn(current, config.ChunkTimeout),
+		done:    make(chan struct{}),
+	}
+	response = models.ChatResponseStream{
+		StreamReader: httpclient.NewFuncStreamReader(s.recv, s, current.Header(), httpclient.HTTPClientConfig{
+			// 数据块间隔由 ChunkTimeout 控制，重新连接期间不触发外层的间隔超时
+			StreamReturnIntervalTimeout: math.MaxInt64,
+		}),
+	}
+	return
+}
+
+// recv 接收数据，传输中断时重新连接
+func (s *resumableStream) recv() (chunk models.ChatBaseResponse, isFinished bool, err error) {
+	for {
+		if chunk, isFinished, err = s.recvCurrent(); err == nil {
+			if !isFinished {
+				s.record(&chunk)
+			}
+			return
+		}
+		// 重新连接，连接失败时继续判断是否可以续传
+		for {
+			if !s.canResume(err) {
+				return chunk, false, err
+			}
+			s.reconnects++
+			if s.config.OnReconnect != nil {
+				s.config.OnReconnect(s.reconnects, err)
+			}
+			if err = s.reconnect(); err == nil {
+				break
+			}
+		}
+	}
+}
+
+// recvCurrent 从当前连接接收数据，超过 ChunkTimeout 时关闭当前连接并返回超时错误
+func (s *resumableStream) recvCurrent() (chunk models.ChatBaseResponse, isFinished bool, err error) {
+	s.mu.Lock()
+	current := s.current
+	s.mu.Unlock()
+
+	if current.results == nil {
+		return current.stream.Recv()
+	}
+
+	timer := time.NewTimer(s.config.ChunkTimeout)
+	defer timer.Stop()
+	select {
+	case r := <-current.results:
+		return r.chunk, r.isFinished, r.err
+	case <-current.stop:
+		return chunk, false, httpclient.ErrStreamClosed
+	case <-timer.C:
+		current.close()
+		return chunk, false, httpclient.ErrStreamReturnIntervalTimeout
+	}
+}
+
+// record 记录已输出的内容，续写的数据块沿用第一个数据块的ID，用量为之前各个连接与当前连接的用量之和
+func (s *resumableStream) record(chunk *models.ChatBaseResponse) {
+	if s.id == "" {
+		s.id = chunk.ID
+	} else if chunk.ID != "" {
+		chunk.ID = s.id
+	}
+	if chunk.Usage != nil {
+		s.connUsage = chunk.Usage
+		if s.usage != nil {
+			chunk.Usage = addChatUsage(s.usage, chunk.Usage)
+		}
+	}
+	for _, choice := range chunk.Choices {
+		if choice.Index != 0 {
+			s.multiChoice = true
+		}
+		delta := choice.Delta
+		if delta == nil {
+			delta = choice.Message
+		}
+		if delta == nil {
+			continue
+		}
+		s.content.WriteString(delta.Content)
+		s.reasoning.WriteString(delta.ReasoningContent)
+		if len(delta.ToolCalls) > 0 {
+			s.hasToolCalls = true
+		}
+	}
+}
+
+// canResume 判断是否可以续传
+func (s *resumableStream) canResume(err error) (ok bool) {
+	if s.reconnects >= s.config.MaxReconnects || s.ctx.Err() != nil || s.isClosed() || !s.config.ShouldResume(err) {
+		return false
+	}
+	// 尚未输出内容时可以直接重发原请求
+	if s.content.Len() == 0 && s.reasoning.Len() == 0 && !s.hasToolCalls {
+		return true
+	}
+	if s.hasToolCalls || s.multiChoice {
+		return false
+	}
+	supportsReasoning, ok := prefixProviders[s.request.Provider]
+	if !ok {
+		return false
+	}
+	return s.reasoning.Len() == 0 || supportsReasoning
+}
+
+// reconnect 关闭当前连接并建立新的连接
+func (s *resumableStream) reconnect() (err error) {
+	s.mu.Lock()
+	current := s.current
+	s.mu.Unlock()
+	current.close()
+	// 中断的连接已产生的用量计入之前各个连接的用量
+	if s.connUsage != nil {
+		if s.usage == nil {
+			s.usage = &models.ChatUsage{}
+		}
+		s.usage = addChatUsage(s.usage, s.connUsage)
+		s.connUsage = nil
+	}
+	// 等待一段时间后重新连接
+	if s.config.RetryDelay > 0 {
+		timer := time.NewTimer(s.config.RetryDelay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-s.ctx.Done():
+			return s.ctx.Err()
+		case <-s.done:
+			return httpclient.ErrStreamClosed
+		}
+	}
+
+	var next models.ChatResponseStream
+	if next, err = s.connect(s.ctx, s.resumeRequest()); err != nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		next.Close()
+		return httpclient.ErrStreamClosed
+	}
+	s.current = newResumableConn(next, s.config.ChunkTimeout)
+	return
+}
+
+// resumeRequest 构建续写请求，已输出的内容作为 assistant 前缀，原请求最后一条消息为前缀时合并到新的前缀中
+func (s *resumableStream) resumeRequest() (request models.ChatRequest) {
+	request = s.request
+	if s.content.Len() == 0 && s.reasoning.Len() == 0 {
+		return
+	}
+
+	var (
+		messages  = slices.Clone(request.Messages)
+		content   = s.content.String()
+		reasoning = s.reasoning.String()
+	)
+	if n := len(messages); n > 0 {
+		if last, ok := messages[n-1].(*models.AssistantMessage); ok && models.BoolValue(last.Prefix) {
+			content = last.Content + content
+			reasoning = last.ReasoningContent + reasoning
+			messages = messages[:n-1]
+		}
+	}
+	request.Messages = append(messages, &models.AssistantMessage{
+		Content:          content,
+		ReasoningContent: reasoning,
+		Prefix:           models.Bool(true),
+	})
+	return
+}
+
+// isClosed 是否已关闭
+func (s *resumableStream) isClosed() (closed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.closed
+}
+
+// Close 关闭当前连接，实现 io.Closer 接口
+func (s *resumableStream) Close() (err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return
+	}
+	s.closed = true
+	close(s.done)
+	return s.current.close()
+}
+
+// addChatUsage 计算两次请求的用量之和
+func addChatUsage(a, b *models.ChatUsage) (sum *models.ChatUsage) {
+	sum = &models.ChatUsage{
+		CompletionTokens:      a.CompletionTokens + b.CompletionTokens,
+		PromptTokens:          a.PromptTokens + b.PromptTokens,
+		PromptCacheHitTokens:  a.PromptCacheHitTokens + b.PromptCacheHitTokens,
+		PromptCacheMissTokens: a.PromptCacheMissTokens + b.PromptCacheMissTokens,
+		TotalTokens:           a.TotalTokens + b.TotalTokens,
+	}
+	if a.CompletionTokensDetails != nil || b.CompletionTokensDetails != nil {
+		x, y := cmp.Or(a.CompletionTokensDetails, &models.CompletionTokensDetails{}), cmp.Or(b.CompletionTokensDetails, &models.CompletionTokensDetails{})
+		sum.CompletionTokensDetails = &models.CompletionTokensDetails{
+			TextTokens:               x.TextTokens + y.TextTokens,
+			AudioTokens:              x.AudioTokens + y.AudioTokens,
+			ReasoningTokens:          x.ReasoningTokens + y.ReasoningTokens,
+			AcceptedPredictionTokens: x.AcceptedPredictionTokens + y.AcceptedPredictionTokens,
+			RejectedPredictionTokens: x.RejectedPredictionTokens + y.RejectedPredictionTokens,
+		}
+	}
+	if a.PromptTokensDetails != nil || b.PromptTokensDetails != nil {
+		x, y := cmp.Or(a.PromptTokensDetails, &models.PromptTokensDetails{}), cmp.Or(b.PromptTokensDetails, &models.PromptTokensDetails{})
+		sum.PromptTokensDetails = &models.PromptTokensDetails{
+			AudioTokens:  x.AudioTokens + y.AudioTokens,
+			CachedTokens: x.CachedTokens + y.CachedTokens,
+			TextTokens:   x.TextTokens + y.TextTokens,
+			ImageTokens:  x.ImageTokens + y.ImageTokens,
+			VideoTokens:  x.VideoTokens + y.VideoTokens,
+		}
+	}
+	return
+}
+
+// isTransportError 判断是否为传输层错误（连接中断、读取超时等）
+func isTransportError(err error) (ok bool) {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, httpclient.ErrStreamClosed) {
+		return false
+	}
+	var netErr net.Error
+	return errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, httpclient.ErrStreamReturnIntervalTimeout) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNABORTED) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.As(err, &netErr)
+}
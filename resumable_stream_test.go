@@ -0,0 +1,148 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-18 14:37:26
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-08-06 16:08:27
+ * @Description:
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package aisdk
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/Mrzhouyl/go-aisdk/consts"
+	"github.com/Mrzhouyl/go-aisdk/httpclient"
+	"github.com/Mrzhouyl/go-aisdk/internal/utils"
+	"github.com/Mrzhouyl/go-aisdk/models"
+)
+
+// newTestResumableConnect 按顺序返回预设响应体的连接函数，body 以 "!" 结尾时模拟传输中断
+func newTestResumableConnect(provider consts.Provider, bodies []string, requests *[]models.ChatRequest) (connect resumableStreamConnectFunc) {
+	return func(ctx context.Context, request models.ChatRequest) (stream models.ChatResponseStream, err error) {
+		body := bodies[len(*requests)]
+		*requests = append(*requests, request)
+		var reader io.Reader = strings.NewReader(body)
+		if data, ok := strings.CutSuffix(body, "!"); ok {
+			reader = io.MultiReader(strings.NewReader(data), iotest.ErrReader(io.ErrUnexpectedEOF))
+		}
+		stream = models.ChatResponseStream{
+			StreamReader: httpclient.NewStreamReader[models.ChatBaseResponse](io.NopCloser(reader), nil, httpclient.HTTPClientConfig{
+				ResponseDecoder: utils.NewDeserializer(string(provider), true),
+			}),
+		}
+		return
+	}
+}
+
+func TestResumableStream(t *testing.T) {
+	var (
+		first   = "data: {\"id\":\"a\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Hel\"}}]}\n\n!"
+		second  = "data: {\"id\":\"b\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"lo\"}}]}\n\ndata: [DONE]\n\n"
+		request = models.ChatRequest{Model: "deepseek-chat", Messages: []models.ChatMessage{&models.UserMessage{Content: "hi"}}}
+		config  = ResumableStreamConfig{MaxReconnects: 2}
+		collect = func(stream models.ChatResponseStream) (ids, content string, err error) {
+			for chunk, e := range stream.All() {
+				if e != nil {
+					return ids, content, e
+				}
+				ids += chunk.ID
+				content += chunk.Choices[0].Delta.Content
+			}
+			return
+		}
+	)
+
+	t.Run("resume with prefix", func(t *testing.T) {
+		var requests []models.ChatRequest
+		request.Provider = consts.DeepSeek
+		stream, err := newResumableStream(context.Background(), request, config, newTestResumableConnect(consts.DeepSeek, []string{first, second}, &requests))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		ids, content, err := collect(stream)
+		if err != nil || content != "Hello" || ids != "aa" {
+			t.Fatalf("got ids %q, content %q, err %v", ids, content, err)
+		}
+		if len(requests) != 2 || len(requests[1].Messages) != 2 {
+			t.Fatalf("unexpected requests: %+v", requests)
+		}
+		prefix, ok := requests[1].Messages[1].(*models.AssistantMessage)
+		if !ok || prefix.Content != "Hel" || !models.BoolValue(prefix.Prefix) {
+			t.Errorf("unexpected prefix message: %+v", requests[1].Messages[1])
+		}
+		if len(request.Messages) != 1 {
+			t.Errorf("original request was modified: %+v", request.Messages)
+		}
+	})
+	t.Run("prefix not supported", func(t *testing.T) {
+		var requests []models.ChatRequest
+		request.Provider = consts.OpenAI
+		stream, err := newResumableStream(context.Background(), request, config, newTestResumableConnect(consts.OpenAI, []string{first, second}, &requests))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, _, err = collect(stream); !errors.Is(err, io.ErrUnexpectedEOF) || len(requests) != 1 {
+			t.Errorf("expected no reconnect, got err %v after %d requests", err, len(requests))
+		}
+	})
+	t.Run("max reconnects", func(t *testing.T) {
+		var (
+			requests []models.ChatRequest
+			attempts []int
+		)
+		request.Provider = consts.DeepSeek
+		config := config
+		config.OnReconnect = func(attempt int, err error) {
+			attempts = append(attempts, attempt)
+		}
+		stream, err := newResumableStream(context.Background(), request, config, newTestResumableConnect(consts.DeepSeek, []string{first, first, first}, &requests))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		_, content, err := collect(stream)
+		if !errors.Is(err, io.ErrUnexpectedEOF) || content != "HelHelHel" || len(requests) != 3 || len(attempts) != 2 {
+			t.Errorf("got content %q, err %v after %d requests (attempts: %v)", content, err, len(requests), attempts)
+		}
+		prefix := requests[2].Messages[1].(*models.AssistantMessage)
+		if prefix.Content != "HelHel" {
+			t.Errorf("unexpected prefix content: %q", prefix.Content)
+		}
+	})
+	t.Run("usage across reconnects", func(t *testing.T) {
+		var (
+			requests []models.ChatRequest
+			first    = "data: {\"id\":\"a\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Hel\"}}],\"usage\":{\"prompt_tokens\":5,\"completion_tokens\":1,\"total_tokens\":6}}\n\n!"
+			second   = "data: {\"id\":\"b\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"lo\"}}]}\n\n" +
+				"data: {\"id\":\"b\",\"choices\":[],\"usage\":{\"prompt_tokens\":6,\"completion_tokens\":1,\"total_tokens\":7,\"prompt_tokens_details\":{\"cached_tokens\":5}}}\n\n" +
+				"data: [DONE]\n\n"
+			usage *models.ChatUsage
+		)
+		request.Provider = consts.DeepSeek
+		config := config
+		config.ChunkTimeout = time.Second
+		stream, err := newResumableStream(context.Background(), request, config, newTestResumableConnect(consts.DeepSeek, []string{first, second}, &requests))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for chunk, err := range stream.All() {
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if chunk.Usage != nil {
+				usage = chunk.Usage
+			}
+		}
+		// 最后的用量包含中断的连接已产生的用量
+		if usage == nil || usage.PromptTokens != 11 || usage.CompletionTokens != 2 || usage.TotalTokens != 13 || usage.PromptTokensDetails.CachedTokens != 5 {
+			t.Errorf("unexpected usage: %+v", usage)
+		}
+	})
+}
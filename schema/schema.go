@@ -0,0 +1,470 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-20 09:28:51
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-08-08 16:14:55
+ * @Description: 通过反射 Go 类型生成 JSON Schema
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	errStrictMapType   = "map type %s is not supported in strict mode"       // 严格模式不支持 map 类型
+	errStrictInterface = "interface type %s is not supported in strict mode" // 严格模式不支持 interface 类型
+)
+
+var (
+	timeType          = reflect.TypeFor[time.Time]()
+	jsonMarshalerType = reflect.TypeFor[json.Marshaler]()
+	rawMessageType    = reflect.TypeFor[json.RawMessage]()
+	nullSchema        = map[string]any{"type": "null"}
+)
+
+// Config 生成配置
+type Config struct {
+	// 严格模式，生成兼容 OpenAI Structured Outputs 的 Schema：所有对象设置 additionalProperties 为 false，所有字段均为必填，
+	// 可选字段（指针、omitempty 或 required:"false"）通过 anyOf 与 null 组合表示可为空，不支持 map 和 interface 类型
+	Strict bool
+}
+
+// For 生成类型 T 的 JSON Schema
+func For[T any](config Config) (schema map[string]any, err error) {
+	return Generate(reflect.TypeFor[T](), config)
+}
+
+// Generate 生成 JSON Schema，v 为 reflect.Type 或任意值
+//
+// 支持的字段标签：
+//   - json: 字段名称，"-" 表示忽略，omitempty 表示非必填
+//   - description: 字段描述
+//   - enum: 枚举值，以逗号分隔，按字段类型解析
+//   - minimum/maximum: 数值的最小值/最大值
+//   - required: "true" 或 "false"，覆盖由 omitempty 推断的必填属性
+//
+// 嵌套结构体会内联展开，递归类型放在 $defs 中并通过 $ref 引用
+func Generate(v any, config Config) (schema map[string]any, err error) {
+	t, ok := v.(reflect.Type)
+	if !ok {
+		t = reflect.TypeOf(v)
+	}
+	if t == nil {
+		return nil, fmt.Errorf("cannot generate schema for nil")
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	g := &generator{
+		config:    config,
+		root:      t,
+		recursive: make(map[reflect.Type]bool),
+		defNames:  make(map[reflect.Type]string),
+		defs:      make(map[string]any),
+	}
+	g.findRecursive(t, make(map[reflect.Type]bool))
+	if schema, err = g.schemaOf(t, true); err != nil {
+		return nil, err
+	}
+	if len(g.defs) > 0 {
+		schema["$defs"] = g.defs
+	}
+	return
+}
+
+// generator Schema 生成器
+type generator struct {
+	config    Config
+	root      reflect.Type          // 根类型，递归引用根类型时使用 "#"
+	recursive map[reflect.Type]bool // 递归类型
+	defNames  map[reflect.Type]string
+	defs      map[string]any
+}
+
+// findRecursive 找出递归引用自身的结构体类型
+func (g *generator) findRecursive(t reflect.Type, visiting map[reflect.Type]bool) {
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Array, reflect.Map:
+		g.findRecursive(t.Elem(), visiting)
+	case reflect.Struct:
+		if t == timeType {
+			return
+		}
+		if visiting[t] {
+			g.recursive[t] = true
+			return
+		}
+		visiting[t] = true
+		for i := range t.NumField() {
+			g.findRecursive(t.Field(i).Type, visiting)
+		}
+		delete(visiting, t)
+	}
+}
+
+// schemaOf 生成类型的 Schema，isRoot 表示是否为根类型
+func (g *generator) schemaOf(t reflect.Type, isRoot bool) (schema map[string]any, err error) {
+	if t == rawMessageType {
+		if g.config.Strict {
+			return nil, fmt.Errorf(errStrictInterface, t)
+		}
+		return map[string]any{}, nil
+	}
+	if t == timeType {
+		return map[string]any{"type": "string", "format": "date-time"}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return g.schemaOf(t.Elem(), isRoot)
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return map[string]any{"type": "integer"}, nil
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}, nil
+	case reflect.String:
+		return map[string]any{"type": "string"}, nil
+	case reflect.Slice, reflect.Array:
+		// []byte 按 encoding/json 的规则序列化为 base64 字符串
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "contentEncoding": "base64"}, nil
+		}
+		var items map[string]any
+		if items, err = g.schemaOf(t.Elem(), false); err != nil {
+			return
+		}
+		schema = map[string]any{"type": "array", "items": items}
+		if t.Kind() == reflect.Array {
+			schema["minItems"] = t.Len()
+			schema["maxItems"] = t.Len()
+		}
+		return
+	case reflect.Map:
+		if g.config.Strict {
+			return nil, fmt.Errorf(errStrictMapType, t)
+		}
+		if t.Key().Kind() != reflect.String && !t.Key().Implements(reflect.TypeFor[fmt.Stringer]()) && !isIntegerKind(t.Key().Kind()) {
+			return nil, fmt.Errorf("unsupported map key type %s", t.Key())
+		}
+		var values map[string]any
+		if values, err = g.schemaOf(t.Elem(), false); err != nil {
+			return
+		}
+		return map[string]any{"type": "object", "additionalProperties": values}, nil
+	case reflect.Interface:
+		if g.config.Strict {
+			return nil, fmt.Errorf(errStrictInterface, t)
+		}
+		return map[string]any{}, nil
+	case reflect.Struct:
+		if t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType) {
+			if g.config.Strict {
+				return nil, fmt.Errorf("type %s implements json.Marshaler and is not supported in strict mode", t)
+			}
+			return map[string]any{}, nil
+		}
+		if !isRoot && g.recursive[t] {
+			return g.refOf(t)
+		}
+		return g.objectOf(t)
+	}
+	return nil, fmt.Errorf("unsupported type %s", t)
+}
+
+// refOf 生成递归类型的引用，类型定义放在 $defs 中
+func (g *generator) refOf(t reflect.Type) (schema map[string]any, err error) {
+	if t == g.root {
+		return map[string]any{"$ref": "#"}, nil
+	}
+	name, ok := g.defNames[t]
+	if !ok {
+		name = g.defName(t)
+		g.defNames[t] = name
+		// 先占位，避免递归生成时重复进入
+		g.defs[name] = nil
+		var def map[string]any
+		if def, err = g.objectOf(t); err != nil {
+			return
+		}
+		g.defs[name] = def
+	}
+	return map[string]any{"$ref": "#/$defs/" + name}, nil
+}
+
+// defName 生成 $defs 中的类型名称，名称冲突时加上包路径
+func (g *generator) defName(t reflect.Type) (name string) {
+	name = t.Name()
+	if name == "" {
+		name = "Anonymous"
+	}
+	if _, ok := g.defs[name]; !ok {
+		return
+	}
+	base := strings.NewReplacer("/", "_", ".", "_").Replace(t.PkgPath()) + "_" + name
+	name = base
+	for i := 2; ; i++ {
+		if _, ok := g.defs[name]; !ok {
+			return
+		}
+		name = fmt.Sprintf("%s_%d", base, i)
+	}
+}
+
+// objectOf 生成结构体的 Schema
+func (g *generator) objectOf(t reflect.Type) (schema map[string]any, err error) {
+	var (
+		properties = make(map[string]any)
+		required   = make([]string, 0)
+	)
+	if err = g.addFields(t, properties, &required); err != nil {
+		return
+	}
+	schema = map[string]any{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 || g.config.Strict {
+		schema["required"] = required
+	}
+	if g.config.Strict {
+		schema["additionalProperties"] = false
+	}
+	return
+}
+
+// structField 展开匿名嵌入结构体后的字段
+type structField struct {
+	field  reflect.StructField // 字段
+	owner  reflect.Type        // 字段所在的结构体
+	name   string              // JSON 名称
+	opts   string              // json 标签中名称之后的选项
+	index  []int               // 字段的索引序列，长度为嵌入的层级
+	tagged bool                // json 标签是否指定了名称
+}
+
+// typeFields 按 encoding/json 的规则获取结构体的字段：展开匿名嵌入的结构体，同名字段中层级最浅的生效，
+// 层级相同时指定了 json 名称的字段生效，仍然冲突时全部忽略；结果按字段的索引序列排序
+func typeFields(t reflect.Type) (fields []structField) {
+	type embedded struct {
+		typ   reflect.Type
+		index []int
+	}
+	var (
+		next    = []embedded{{typ: t}}
+		visited = make(map[reflect.Type]bool)
+		all     []structField
+	)
+	for len(next) > 0 {
+		current := next
+		next = nil
+		for _, e := range current {
+			// 同一类型只展开一次，浅层的优先
+			if visited[e.typ] {
+				continue
+			}
+			visited[e.typ] = true
+			for i := range e.typ.NumField() {
+				field := e.typ.Field(i)
+				jsonTag := field.Tag.Get("json")
+				if jsonTag == "-" {
+					continue
+				}
+				name, opts, _ := strings.Cut(jsonTag, ",")
+				index := append(slices.Clone(e.index), i)
+				// 展开匿名嵌入的结构体
+				if field.Anonymous && name == "" {
+					ft := field.Type
+					if ft.Kind() == reflect.Pointer {
+						ft = ft.Elem()
+					}
+					if ft.Kind() == reflect.Struct {
+						next = append(next, embedded{typ: ft, index: index})
+						continue
+					}
+				}
+				if !field.IsExported() {
+					continue
+				}
+				sf := structField{field: field, owner: e.typ, name: name, opts: opts, index: index, tagged: name != ""}
+				if sf.name == "" {
+					sf.name = field.Name
+				}
+				all = append(all, sf)
+			}
+		}
+	}
+
+	// 按名称分组，选出生效的字段
+	byName := make(map[string][]structField)
+	for _, sf := range all {
+		byName[sf.name] = append(byName[sf.name], sf)
+	}
+	for _, sf := range all {
+		candidates := byName[sf.name]
+		if len(candidates) == 0 {
+			continue
+		}
+		delete(byName, sf.name)
+		if dominant, ok := dominantField(candidates); ok {
+			fields = append(fields, dominant)
+		}
+	}
+	slices.SortFunc(fields, func(a, b structField) int {
+		return slices.Compare(a.index, b.index)
+	})
+	return
+}
+
+// dominantField 获取同名字段中生效的字段，没有唯一生效的字段时返回 false
+func dominantField(candidates []structField) (sf structField, ok bool) {
+	depth := len(candidates[0].index)
+	for _, c := range candidates {
+		depth = min(depth, len(c.index))
+	}
+	var shallowest []structField
+	for _, c := range candidates {
+		if len(c.index) == depth {
+			shallowest = append(shallowest, c)
+		}
+	}
+	if len(shallowest) == 1 {
+		return shallowest[0], true
+	}
+	var tagged []structField
+	for _, c := range shallowest {
+		if c.tagged {
+			tagged = append(tagged, c)
+		}
+	}
+	if len(tagged) == 1 {
+		return tagged[0], true
+	}
+	return
+}
+
+// addFields 添加结构体字段，匿名嵌入的结构体字段按 encoding/json 的规则展开
+func (g *generator) addFields(t reflect.Type, properties map[string]any, required *[]string) (err error) {
+	for _, sf := range typeFields(t) {
+		var prop map[string]any
+		if prop, err = g.fieldSchema(sf.field); err != nil {
+			return fmt.Errorf("field %s.%s: %w", sf.owner.Name(), sf.field.Name, err)
+		}
+		// 推断是否必填
+		isRequired := !strings.Contains(sf.opts, "omitempty") && !strings.Contains(sf.opts, "omitzero")
+		if v, ok := sf.field.Tag.Lookup("required"); ok {
+			if isRequired, err = strconv.ParseBool(v); err != nil {
+				return fmt.Errorf("field %s.%s: invalid required tag %q: %w", sf.owner.Name(), sf.field.Name, v, err)
+			}
+		}
+		switch {
+		case g.config.Strict:
+			// 严格模式下所有字段均为必填，可选字段可为空
+			if !isRequired || sf.field.Type.Kind() == reflect.Pointer {
+				prop = nullable(prop)
+			}
+			*required = append(*required, sf.name)
+		case isRequired:
+			*required = append(*required, sf.name)
+		}
+		properties[sf.name] = prop
+	}
+	return
+}
+
+// fieldSchema 生成字段的 Schema 并应用 description、enum、minimum、maximum 标签
+func (g *generator) fieldSchema(field reflect.StructField) (schema map[string]any, err error) {
+	if schema, err = g.schemaOf(field.Type, false); err != nil {
+		return
+	}
+	// 复制一份再添加约束，避免修改共享的 Schema
+	schema = maps.Clone(schema)
+
+	elem := field.Type
+	for elem.Kind() == reflect.Pointer {
+		elem = elem.Elem()
+	}
+	if v := field.Tag.Get("enum"); v != "" {
+		var values []any
+		if values, err = parseEnum(elem, v); err != nil {
+			return
+		}
+		schema["enum"] = values
+	}
+	for _, key := range []string{"minimum", "maximum"} {
+		if v, ok := field.Tag.Lookup(key); ok {
+			var n float64
+			if n, err = strconv.ParseFloat(v, 64); err != nil {
+				return nil, fmt.Errorf("invalid %s tag %q: %w", key, v, err)
+			}
+			schema[key] = n
+		}
+	}
+	if v := field.Tag.Get("description"); v != "" {
+		schema["description"] = v
+	}
+	return
+}
+
+// parseEnum 按字段类型解析枚举值
+func parseEnum(t reflect.Type, tag string) (values []any, err error) {
+	for _, s := range strings.Split(tag, ",") {
+		s = strings.TrimSpace(s)
+		var value any
+		switch {
+		case t.Kind() == reflect.String:
+			value = s
+		case isIntegerKind(t.Kind()):
+			if value, err = strconv.ParseInt(s, 10, 64); err != nil {
+				return nil, fmt.Errorf("invalid enum value %q: %w", s, err)
+			}
+		case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+			if value, err = strconv.ParseFloat(s, 64); err != nil {
+				return nil, fmt.Errorf("invalid enum value %q: %w", s, err)
+			}
+		case t.Kind() == reflect.Bool:
+			if value, err = strconv.ParseBool(s); err != nil {
+				return nil, fmt.Errorf("invalid enum value %q: %w", s, err)
+			}
+		default:
+			return nil, fmt.Errorf("enum is not supported for type %s", t)
+		}
+		values = append(values, value)
+	}
+	return
+}
+
+// nullable 通过 anyOf 组合 null 表示可为空
+func nullable(schema map[string]any) (result map[string]any) {
+	result = map[string]any{"anyOf": []any{schema, nullSchema}}
+	// 描述放在外层
+	if description, ok := schema["description"]; ok {
+		inner := maps.Clone(schema)
+		delete(inner, "description")
+		result["anyOf"] = []any{inner, nullSchema}
+		result["description"] = description
+	}
+	return
+}
+
+// isIntegerKind 是否为整数类型
+func isIntegerKind(kind reflect.Kind) (ok bool) {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	}
+	return false
+}
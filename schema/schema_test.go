@@ -0,0 +1,198 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-20 14:03:12
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-08-08 16:14:55
+ * @Description:
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package schema
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+type testAddress struct {
+	City string `json:"city" description:"城市"`
+}
+
+type testBase struct {
+	ID string `json:"id"`
+}
+
+type testPerson struct {
+	testBase
+	Name     string            `json:"name" description:"姓名"`
+	Age      int               `json:"age,omitempty" minimum:"0" maximum:"150"`
+	Gender   string            `json:"gender" enum:"male,female"`
+	Level    int               `json:"level" enum:"1,2,3" required:"false"`
+	Address  *testAddress      `json:"address,omitempty"`
+	Tags     []string          `json:"tags"`
+	Birthday time.Time         `json:"birthday"`
+	Extra    map[string]string `json:"extra,omitempty"`
+	Ignored  string            `json:"-"`
+	private  string
+}
+
+type testShadowBase struct {
+	Name string `json:"name"`
+	Code int
+}
+
+type testShadowOther struct {
+	Code string
+}
+
+// testShadow 嵌入的字段在外层字段之前，外层字段生效；同一层级冲突的 Code 字段被忽略
+type testShadow struct {
+	testShadowBase
+	testShadowOther
+	Name int `json:"name"`
+}
+
+type testInvalidRequired struct {
+	Name string `json:"name" required:"yes"`
+}
+
+type testStrictPerson struct {
+	Name    string       `json:"name"`
+	Age     *int         `json:"age" description:"年龄"`
+	Address *testAddress `json:"address,omitempty"`
+}
+
+type testNode struct {
+	Value    int         `json:"value"`
+	Children []*testNode `json:"children,omitempty"`
+}
+
+type testTree struct {
+	Root *testNode `json:"root"`
+}
+
+// toJSON 将 Schema 序列化为 JSON 字符串，用于比较（map 的键按字母顺序排列）
+func toJSON(t *testing.T, v any) (s string) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("marshal schema failed: %v", err)
+	}
+	return string(b)
+}
+
+func TestGenerate(t *testing.T) {
+	tests := []struct {
+		name    string
+		v       any
+		config  Config
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "tags",
+			v:    testPerson{},
+			want: `{"properties":{` +
+				`"address":{"properties":{"city":{"description":"城市","type":"string"}},"required":["city"],"type":"object"},` +
+				`"age":{"maximum":150,"minimum":0,"type":"integer"},` +
+				`"birthday":{"format":"date-time","type":"string"},` +
+				`"extra":{"additionalProperties":{"type":"string"},"type":"object"},` +
+				`"gender":{"enum":["male","female"],"type":"string"},` +
+				`"id":{"type":"string"},` +
+				`"level":{"enum":[1,2,3],"type":"integer"},` +
+				`"name":{"description":"姓名","type":"string"},` +
+				`"tags":{"items":{"type":"string"},"type":"array"}},` +
+				`"required":["id","name","gender","tags","birthday"],"type":"object"}`,
+		},
+		{
+			name:   "strict",
+			v:      &testStrictPerson{},
+			config: Config{Strict: true},
+			want: `{"additionalProperties":false,"properties":{` +
+				`"address":{"anyOf":[{"additionalProperties":false,"properties":{"city":{"description":"城市","type":"string"}},"required":["city"],"type":"object"},{"type":"null"}]},` +
+				`"age":{"anyOf":[{"type":"integer"},{"type":"null"}],"description":"年龄"},` +
+				`"name":{"type":"string"}},` +
+				`"required":["name","age","address"],"type":"object"}`,
+		},
+		{
+			name:    "strict map",
+			v:       testPerson{},
+			config:  Config{Strict: true},
+			wantErr: true,
+		},
+		{
+			name: "shadowed fields",
+			v:    testShadow{},
+			want: `{"properties":{"name":{"type":"integer"}},"required":["name"],"type":"object"}`,
+		},
+		{
+			name:    "invalid required tag",
+			v:       testInvalidRequired{},
+			wantErr: true,
+		},
+		{
+			name: "recursive root",
+			v:    testNode{},
+			want: `{"properties":{"children":{"items":{"$ref":"#"},"type":"array"},"value":{"type":"integer"}},"required":["value"],"type":"object"}`,
+		},
+		{
+			name: "recursive defs",
+			v:    testTree{},
+			want: `{"$defs":{"testNode":{"properties":{"children":{"items":{"$ref":"#/$defs/testNode"},"type":"array"},"value":{"type":"integer"}},"required":["value"],"type":"object"}},` +
+				`"properties":{"root":{"$ref":"#/$defs/testNode"}},"required":["root"],"type":"object"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Generate(tt.v, tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Generate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if s := toJSON(t, got); s != tt.want {
+				t.Errorf("Generate() mismatch:\ngot:  %s\nwant: %s", s, tt.want)
+			}
+		})
+	}
+}
+
+func TestFor(t *testing.T) {
+	got, err := For[[]testAddress](Config{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := `{"items":{"properties":{"city":{"description":"城市","type":"string"}},"required":["city"],"type":"object"},"type":"array"}`
+	if s := toJSON(t, got); s != want {
+		t.Errorf("For() mismatch:\ngot:  %s\nwant: %s", s, want)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	strict, _ := For[testStrictPerson](Config{Strict: true})
+	tree, _ := For[testTree](Config{})
+	tests := []struct {
+		name    string
+		schema  map[string]any
+		data    string
+		wantErr string
+	}{
+		{name: "valid", schema: strict, data: `{"name":"a","age":null,"address":{"city":"SH"}}`},
+		{name: "missing required", schema: strict, data: `{"name":"a","age":1}`, wantErr: `$: missing required property "address"`},
+		{name: "unexpected property", schema: strict, data: `{"name":"a","age":1,"address":null,"x":1}`, wantErr: `$: unexpected property "x"`},
+		{name: "nullable mismatch", schema: strict, data: `{"name":"a","age":"1","address":null}`, wantErr: `$.age: value does not match any of the allowed schemas`},
+		{name: "recursive ref", schema: tree, data: `{"root":{"value":1,"children":[{"value":1.5}]}}`, wantErr: `$.root.children[0].value: expected integer, got number`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.schema, []byte(tt.data))
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+				t.Errorf("Validate() error = %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+}
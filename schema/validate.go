@@ -0,0 +1,234 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-21 09:47:33
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-21 15:20:18
+ * @Description: 按 JSON Schema 校验 JSON 数据（支持 Generate 生成的关键字子集）
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Validate 按 JSON Schema 校验 JSON 数据，返回的错误包含出错位置（如 $.items[0].city）
+//
+// 支持的关键字：type、properties、required、additionalProperties、items、minItems、maxItems、enum、minimum、maximum、anyOf、$ref（"#" 和 "#/$defs/..."）
+func Validate(schema map[string]any, data []byte) (err error) {
+	var value any
+	if err = json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+	v := &validator{root: schema}
+	return v.validate(schema, value, "$")
+}
+
+// validator 校验器
+type validator struct {
+	root map[string]any // 根 Schema，用于解析 $ref
+}
+
+// validate 校验单个值
+func (v *validator) validate(schema map[string]any, value any, path string) (err error) {
+	if ref, ok := schema["$ref"].(string); ok {
+		var resolved map[string]any
+		if resolved, err = v.resolve(ref); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		return v.validate(resolved, value, path)
+	}
+	if anyOf, ok := schema["anyOf"].([]any); ok {
+		for _, item := range anyOf {
+			if s, ok := item.(map[string]any); ok && v.validate(s, value, path) == nil {
+				return nil
+			}
+		}
+		return fmt.Errorf("%s: value does not match any of the allowed schemas", path)
+	}
+	if enum, ok := schema["enum"].([]any); ok && !containsValue(enum, value) {
+		return fmt.Errorf("%s: value %v is not one of %v", path, value, enum)
+	}
+
+	typ, _ := schema["type"].(string)
+	switch typ {
+	case "object":
+		return v.validateObject(schema, value, path)
+	case "array":
+		return v.validateArray(schema, value, path)
+	case "string":
+		if _, ok := value.(string); !ok {
+			return typeError(path, typ, value)
+		}
+	case "integer", "number":
+		n, ok := value.(float64)
+		if !ok || (typ == "integer" && n != math.Trunc(n)) {
+			return typeError(path, typ, value)
+		}
+		if minimum, ok := toFloat(schema["minimum"]); ok && n < minimum {
+			return fmt.Errorf("%s: %v is less than minimum %v", path, n, minimum)
+		}
+		if maximum, ok := toFloat(schema["maximum"]); ok && n > maximum {
+			return fmt.Errorf("%s: %v is greater than maximum %v", path, n, maximum)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return typeError(path, typ, value)
+		}
+	case "null":
+		if value != nil {
+			return typeError(path, typ, value)
+		}
+	}
+	return
+}
+
+// validateObject 校验对象
+func (v *validator) validateObject(schema map[string]any, value any, path string) (err error) {
+	object, ok := value.(map[string]any)
+	if !ok {
+		return typeError(path, "object", value)
+	}
+	for _, name := range toStrings(schema["required"]) {
+		if _, ok := object[name]; !ok {
+			return fmt.Errorf("%s: missing required property %q", path, name)
+		}
+	}
+	properties, _ := schema["properties"].(map[string]any)
+	for name, item := range object {
+		if prop, ok := properties[name].(map[string]any); ok {
+			if err = v.validate(prop, item, path+"."+name); err != nil {
+				return
+			}
+			continue
+		}
+		switch additional := schema["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				return fmt.Errorf("%s: unexpected property %q", path, name)
+			}
+		case map[string]any:
+			if err = v.validate(additional, item, path+"."+name); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+// validateArray 校验数组
+func (v *validator) validateArray(schema map[string]any, value any, path string) (err error) {
+	array, ok := value.([]any)
+	if !ok {
+		return typeError(path, "array", value)
+	}
+	if minItems, ok := toFloat(schema["minItems"]); ok && float64(len(array)) < minItems {
+		return fmt.Errorf("%s: expected at least %v items, got %d", path, minItems, len(array))
+	}
+	if maxItems, ok := toFloat(schema["maxItems"]); ok && float64(len(array)) > maxItems {
+		return fmt.Errorf("%s: expected at most %v items, got %d", path, maxItems, len(array))
+	}
+	items, _ := schema["items"].(map[string]any)
+	if items == nil {
+		return
+	}
+	for i, item := range array {
+		if err = v.validate(items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// resolve 解析 $ref
+func (v *validator) resolve(ref string) (schema map[string]any, err error) {
+	if ref == "#" {
+		return v.root, nil
+	}
+	name, ok := strings.CutPrefix(ref, "#/$defs/")
+	if !ok {
+		return nil, fmt.Errorf("unsupported $ref %q", ref)
+	}
+	defs, _ := v.root["$defs"].(map[string]any)
+	if schema, ok = defs[name].(map[string]any); !ok {
+		return nil, fmt.Errorf("undefined $ref %q", ref)
+	}
+	return
+}
+
+// typeError 类型错误
+func typeError(path, typ string, value any) (err error) {
+	return fmt.Errorf("%s: expected %s, got %s", path, typ, jsonType(value))
+}
+
+// jsonType 获取 JSON 值的类型名称
+func jsonType(value any) (typ string) {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// containsValue 判断枚举值中是否包含该值，数值按大小比较
+func containsValue(enum []any, value any) (ok bool) {
+	for _, item := range enum {
+		if a, ok := toFloat(item); ok {
+			if b, ok := toFloat(value); ok && a == b {
+				return true
+			}
+			continue
+		}
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// toFloat 将数值转换为 float64
+func toFloat(value any) (n float64, ok bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// toStrings 将 required 转换为字符串切片，兼容 []string 和 []any
+func toStrings(value any) (result []string) {
+	switch v := value.(type) {
+	case []string:
+		return v
+	case []any:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				result = append(result, s)
+			}
+		}
+	}
+	return
+}
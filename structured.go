@@ -0,0 +1,257 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-21 10:15:40
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-21 16:37:12
+ * @Description: 结构化输出，根据 Go 类型生成 JSON Schema 并将模型回复解析为该类型
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package aisdk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/Mrzhouyl/go-aisdk/consts"
+	"github.com/Mrzhouyl/go-aisdk/errors"
+	"github.com/Mrzhouyl/go-aisdk/httpclient"
+	"github.com/Mrzhouyl/go-aisdk/models"
+	"github.com/Mrzhouyl/go-aisdk/schema"
+)
+
+const (
+	defaultStructuredName = "response" // 默认的响应格式名称
+	structuredWrapKey     = "result"   // 根类型不是对象时包装使用的字段名
+)
+
+// ChatCompletionClient 聊天客户端，*SDKClient 实现了该接口
+type ChatCompletionClient interface {
+	CreateChatCompletion(ctx context.Context, request models.ChatRequest, opts ...httpclient.HTTPClientOption) (response models.ChatResponse, err error)
+}
+
+// StructuredValidator 结构化输出的自定义校验，T 或 *T 实现该接口时在解析后调用，校验失败视为输出不合法
+type StructuredValidator interface {
+	Validate() (err error)
+}
+
+// StructuredOption 结构化输出选项
+type StructuredOption func(o *structuredOption)
+
+// structuredOption 结构化输出选项
+type structuredOption struct {
+	name        string                        // 响应格式名称
+	description string                        // 响应格式描述
+	strict      bool                          // 是否启用严格模式
+	maxRetries  int                           // 输出不合法时重新提示模型的最大次数
+	httpOpts    []httpclient.HTTPClientOption // 调用模型时的 HTTP 客户端选项
+}
+
+// WithStructuredName 设置响应格式的名称和描述
+func WithStructuredName(name, description string) (opt StructuredOption) {
+	return func(o *structuredOption) {
+		o.name = name
+		o.description = description
+	}
+}
+
+// WithStructuredStrict 设置是否启用严格模式，默认启用，类型不支持严格模式（如包含 map）时自动关闭
+func WithStructuredStrict(strict bool) (opt StructuredOption) {
+	return func(o *structuredOption) {
+		o.strict = strict
+	}
+}
+
+// WithStructuredRetries 设置输出不合法时将错误反馈给模型并重新生成的最大次数，默认为 0
+func WithStructuredRetries(maxRetries int) (opt StructuredOption) {
+	return func(o *structuredOption) {
+		o.maxRetries = max(maxRetries, 0)
+	}
+}
+
+// WithStructuredHTTPOptions 设置调用模型时的 HTTP 客户端选项
+func WithStructuredHTTPOptions(opts ...httpclient.HTTPClientOption) (opt StructuredOption) {
+	return func(o *structuredOption) {
+		o.httpOpts = append(o.httpOpts, opts...)
+	}
+}
+
+// CreateStructured 创建结构化输出的聊天，根据 T 生成 JSON Schema，调用模型并将回复解析为 T
+//
+// 支持 json_schema 的提供商（OpenAI）通过 ResponseFormat 传递 Schema，其余提供商（DeepSeek、AliBL）使用 json_object 并将 Schema 写入系统提示词。
+// 回复中的 markdown 代码块会被去除；模型拒绝时返回 errors.ErrRefusal；输出不合法时返回 errors.ErrInvalidStructuredOutput，
+// 设置 WithStructuredRetries 后会将错误反馈给模型重新生成
+func CreateStructured[T any](
+	ctx context.Context,
+	client ChatCompletionClient,
+	request models.ChatRequest,
+	opts ...StructuredOption,
+) (result T, response models.ChatResponse, err error) {
+	o := &structuredOption{name: defaultStructuredName, strict: true}
+	for _, opt := range opts {
+		opt(o)
+	}
+	// 生成 Schema，类型不支持严格模式时关闭严格模式
+	var s map[string]any
+	if s, err = schema.For[T](schema.Config{Strict: o.strict}); err != nil && o.strict {
+		o.strict = false
+		s, err = schema.For[T](schema.Config{})
+	}
+	if err != nil {
+		return
+	}
+	// 根类型不是对象时包装为对象
+	wrapped := s["type"] != "object"
+	if wrapped {
+		s = wrapStructuredSchema(s, o.strict)
+	}
+	request = applyStructuredFormat(request, s, o)
+
+	for attempt := 0; ; attempt++ {
+		if response, err = client.CreateChatCompletion(ctx, request, o.httpOpts...); err != nil {
+			return
+		}
+		if len(response.Choices) == 0 || response.Choices[0].Message == nil {
+			err = fmt.Errorf("model returned no message: %w", errors.ErrInvalidStructuredOutput)
+			return
+		}
+		message := response.Choices[0].Message
+		if message.Refusal != "" {
+			err = fmt.Errorf("%s: %w", message.Refusal, errors.ErrRefusal)
+			return
+		}
+
+		var validateErr error
+		if result, validateErr = decodeStructured[T](message.Content, s, wrapped); validateErr == nil {
+			return
+		}
+		if attempt >= o.maxRetries {
+			err = fmt.Errorf("%w: %w", errors.ErrInvalidStructuredOutput, validateErr)
+			return
+		}
+		// 将错误反馈给模型重新生成
+		request.Messages = append(slices.Clip(request.Messages),
+			&models.AssistantMessage{Content: message.Content},
+			&models.UserMessage{Content: fmt.Sprintf(
+				"The previous response is invalid: %v. Respond again with only a JSON value that matches the schema.", validateErr)},
+		)
+	}
+}
+
+// wrapStructuredSchema 将非对象的 Schema 包装为对象
+func wrapStructuredSchema(s map[string]any, strict bool) (wrapped map[string]any) {
+	// $defs 需要位于根 Schema
+	inner := maps.Clone(s)
+	delete(inner, "$defs")
+	wrapped = map[string]any{
+		"type":       "object",
+		"properties": map[string]any{structuredWrapKey: inner},
+		"required":   []string{structuredWrapKey},
+	}
+	if defs, ok := s["$defs"]; ok {
+		wrapped["$defs"] = defs
+	}
+	if strict {
+		wrapped["additionalProperties"] = false
+	}
+	return
+}
+
+// applyStructuredFormat 设置响应格式，不支持 json_schema 的提供商将 Schema 写入系统提示词
+func applyStructuredFormat(request models.ChatRequest, s map[string]any, o *structuredOption) (result models.ChatRequest) {
+	result = request
+	if supportsJSONSchema(request.Provider) {
+		result.ResponseFormat = &models.ChatResponseFormat{
+			Type: models.ChatResponseFormatTypeJSONSchema,
+			JSONSchema: &models.ChatResponseFormatJSONSchema{
+				Name:        o.name,
+				Description: o.description,
+				Schema:      s,
+				Strict:      models.Bool(o.strict),
+			},
+		}
+		return
+	}
+
+	b, _ := json.MarshalIndent(s, "", "  ")
+	prompt := fmt.Sprintf("Respond only with a JSON object that conforms to the following JSON Schema, without any other text.\n```json\n%s\n```", b)
+	if o.description != "" {
+		prompt = o.description + "\n" + prompt
+	}
+	result.ResponseFormat = &models.ChatResponseFormat{Type: models.ChatResponseFormatTypeJSONObject}
+	// 追加到已有的系统消息，否则在开头插入系统消息
+	result.Messages = slices.Clone(request.Messages)
+	if len(result.Messages) > 0 {
+		if system, ok := result.Messages[0].(*models.SystemMessage); ok {
+			merged := *system
+			merged.Content = strings.TrimSpace(merged.Content + "\n\n" + prompt)
+			result.Messages[0] = &merged
+			return
+		}
+	}
+	result.Messages = slices.Insert(result.Messages, 0, models.ChatMessage(&models.SystemMessage{Content: prompt}))
+	return
+}
+
+// supportsJSONSchema 判断提供商是否支持 json_schema 响应格式，以 ChatResponseFormat.JSONSchema 的 providers 标签为准
+func supportsJSONSchema(provider consts.Provider) (ok bool) {
+	field, _ := reflect.TypeFor[models.ChatResponseFormat]().FieldByName("JSONSchema")
+	return slices.Contains(strings.Split(field.Tag.Get("providers"), ","), string(provider))
+}
+
+// decodeStructured 去除代码块后按 Schema 校验并解析回复内容
+func decodeStructured[T any](content string, s map[string]any, wrapped bool) (result T, err error) {
+	data := []byte(extractJSON(content))
+	if err = schema.Validate(s, data); err != nil {
+		return
+	}
+	if wrapped {
+		var envelope map[string]json.RawMessage
+		if err = json.Unmarshal(data, &envelope); err != nil {
+			return
+		}
+		data = envelope[structuredWrapKey]
+	}
+	if err = json.Unmarshal(data, &result); err != nil {
+		return
+	}
+	// 自定义校验
+	var v any = result
+	if _, ok := v.(StructuredValidator); !ok {
+		v = &result
+	}
+	if validator, ok := v.(StructuredValidator); ok {
+		err = validator.Validate()
+	}
+	return
+}
+
+// extractJSON 提取回复中的 JSON，去除 markdown 代码块和前后的说明文字
+func extractJSON(content string) (s string) {
+	s = strings.TrimSpace(content)
+	if rest, ok := strings.CutPrefix(s, "```"); ok {
+		// 去除语言标识
+		if i := strings.IndexByte(rest, '\n'); i >= 0 {
+			rest = rest[i+1:]
+		}
+		if i := strings.LastIndex(rest, "```"); i >= 0 {
+			rest = rest[:i]
+		}
+		s = strings.TrimSpace(rest)
+	}
+	if json.Valid([]byte(s)) {
+		return
+	}
+	// 截取第一个 { 或 [ 到最后一个 } 或 ] 之间的内容
+	start := strings.IndexAny(s, "{[")
+	end := strings.LastIndexAny(s, "}]")
+	if start >= 0 && end > start && json.Valid([]byte(s[start:end+1])) {
+		return s[start : end+1]
+	}
+	return
+}
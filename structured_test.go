@@ -0,0 +1,113 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-21 14:48:09
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-21 16:35:27
+ * @Description:
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package aisdk
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/Mrzhouyl/go-aisdk/consts"
+	"github.com/Mrzhouyl/go-aisdk/errors"
+	"github.com/Mrzhouyl/go-aisdk/httpclient"
+	"github.com/Mrzhouyl/go-aisdk/models"
+)
+
+// fakeChatClient 按顺序返回预设回复的客户端
+type fakeChatClient struct {
+	replies  []*models.ChatCompletionMessage
+	requests []models.ChatRequest
+}
+
+func (c *fakeChatClient) CreateChatCompletion(ctx context.Context, request models.ChatRequest, opts ...httpclient.HTTPClientOption) (response models.ChatResponse, err error) {
+	message := c.replies[len(c.requests)]
+	c.requests = append(c.requests, request)
+	response.Choices = []models.ChatChoice{{Message: message}}
+	return
+}
+
+type testWeather struct {
+	City    string `json:"city" description:"城市"`
+	Celsius int    `json:"celsius" minimum:"-100" maximum:"100"`
+}
+
+func (w testWeather) Validate() (err error) {
+	if w.City == "unknown" {
+		return fmt.Errorf("city is unknown")
+	}
+	return
+}
+
+func TestCreateStructured(t *testing.T) {
+	request := models.ChatRequest{Messages: []models.ChatMessage{&models.UserMessage{Content: "weather?"}}}
+
+	t.Run("json schema with retry", func(t *testing.T) {
+		client := &fakeChatClient{replies: []*models.ChatCompletionMessage{
+			{Content: `{"city":"unknown","celsius":20}`},
+			{Content: "```json\n{\"city\":\"SH\",\"celsius\":25}\n```"},
+		}}
+		request := request
+		request.Provider = consts.OpenAI
+		result, _, err := CreateStructured[testWeather](context.Background(), client, request, WithStructuredRetries(1))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result.City != "SH" || result.Celsius != 25 {
+			t.Errorf("unexpected result: %+v", result)
+		}
+		format := client.requests[0].ResponseFormat
+		if format == nil || format.Type != models.ChatResponseFormatTypeJSONSchema || !models.BoolValue(format.JSONSchema.Strict) {
+			t.Fatalf("unexpected response format: %+v", format)
+		}
+		// 第二次请求包含上次的回复和错误反馈
+		if msgs := client.requests[1].Messages; len(msgs) != 3 || !strings.Contains(msgs[2].(*models.UserMessage).Content, "city is unknown") {
+			t.Errorf("unexpected retry messages: %+v", msgs)
+		}
+		if len(request.Messages) != 1 {
+			t.Errorf("original request was modified: %+v", request.Messages)
+		}
+	})
+	t.Run("system prompt fallback", func(t *testing.T) {
+		client := &fakeChatClient{replies: []*models.ChatCompletionMessage{
+			{Content: `Here you go: {"result":[{"city":"SH","celsius":25}]}`},
+		}}
+		request := request
+		request.Provider = consts.DeepSeek
+		result, _, err := CreateStructured[[]testWeather](context.Background(), client, request)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(result) != 1 || result[0].City != "SH" {
+			t.Errorf("unexpected result: %+v", result)
+		}
+		sent := client.requests[0]
+		if sent.ResponseFormat.Type != models.ChatResponseFormatTypeJSONObject || len(sent.Messages) != 2 {
+			t.Fatalf("unexpected request: %+v", sent)
+		}
+		if system, ok := sent.Messages[0].(*models.SystemMessage); !ok || !strings.Contains(system.Content, `"celsius"`) {
+			t.Errorf("expected schema in system prompt, got %+v", sent.Messages[0])
+		}
+	})
+	t.Run("invalid output", func(t *testing.T) {
+		client := &fakeChatClient{replies: []*models.ChatCompletionMessage{{Content: `{"city":"SH","celsius":500}`}}}
+		_, _, err := CreateStructured[testWeather](context.Background(), client, request)
+		if !errors.IsInvalidStructuredOutputError(err) || !strings.Contains(err.Error(), "$.celsius") {
+			t.Errorf("expected ErrInvalidStructuredOutput, got %v", err)
+		}
+	})
+	t.Run("refusal", func(t *testing.T) {
+		client := &fakeChatClient{replies: []*models.ChatCompletionMessage{{Refusal: "I can't help with that"}}}
+		_, _, err := CreateStructured[testWeather](context.Background(), client, request, WithStructuredRetries(3))
+		if !errors.IsRefusalError(err) || len(client.requests) != 1 {
+			t.Errorf("expected ErrRefusal without retry, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,101 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-22 15:12:04
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-22 16:40:57
+ * @Description: 根据提供商返回的 Usage 校准 token 计数
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package tokenizer
+
+import (
+	"math"
+	"sync"
+
+	"github.com/Mrzhouyl/go-aisdk/consts"
+)
+
+// CalibratorConfig 校准器配置
+type CalibratorConfig struct {
+	Alpha    float64 // 指数移动平均的平滑系数，取值范围 (0, 1]，越大越偏向最近的样本
+	MinRatio float64 // 校准系数的下限
+	MaxRatio float64 // 校准系数的上限
+}
+
+// DefaultCalibratorConfig 默认校准器配置
+func DefaultCalibratorConfig() (config CalibratorConfig) {
+	return CalibratorConfig{
+		Alpha:    0.2,
+		MinRatio: 0.5,
+		MaxRatio: 2,
+	}
+}
+
+// Calibrator 校准器（并发安全），按提供商和模型记录实际 prompt token 与计数结果的比值，用于修正后续的计数
+//
+//	estimated, _ := tokenizer.CountChatTokens(provider, model, messages)
+//	response, _ := client.CreateChatCompletion(ctx, request)
+//	calibrator.Observe(provider, model, estimated, response.Usage.PromptTokens)
+type Calibrator struct {
+	config CalibratorConfig
+	mu     sync.RWMutex
+	ratios map[string]float64 // 键为 provider/model
+}
+
+// NewCalibrator 新建校准器
+func NewCalibrator(config CalibratorConfig) (c *Calibrator) {
+	// 设置默认值
+	defaults := DefaultCalibratorConfig()
+	if config.Alpha <= 0 || config.Alpha > 1 {
+		config.Alpha = defaults.Alpha
+	}
+	if config.MinRatio <= 0 {
+		config.MinRatio = defaults.MinRatio
+	}
+	if config.MaxRatio <= 0 {
+		config.MaxRatio = defaults.MaxRatio
+	}
+	return &Calibrator{
+		config: config,
+		ratios: make(map[string]float64),
+	}
+}
+
+// Observe 记录一次计数结果和提供商返回的实际 token 数量
+func (c *Calibrator) Observe(provider consts.Provider, model string, estimated, actual int) {
+	if estimated <= 0 || actual <= 0 {
+		return
+	}
+	ratio := min(max(float64(actual)/float64(estimated), c.config.MinRatio), c.config.MaxRatio)
+	key := calibrationKey(provider, model)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if prev, ok := c.ratios[key]; ok {
+		ratio = prev + c.config.Alpha*(ratio-prev)
+	}
+	c.ratios[key] = ratio
+}
+
+// Ratio 获取校准系数，没有样本时为 1
+func (c *Calibrator) Ratio(provider consts.Provider, model string) (ratio float64) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if ratio, ok := c.ratios[calibrationKey(provider, model)]; ok {
+		return ratio
+	}
+	return 1
+}
+
+// Adjust 按校准系数修正计数结果
+func (c *Calibrator) Adjust(provider consts.Provider, model string, estimated int) (n int) {
+	return int(math.Round(float64(estimated) * c.Ratio(provider, model)))
+}
+
+// calibrationKey 校准系数的键
+func calibrationKey(provider consts.Provider, model string) (key string) {
+	return provider.String() + "/" + model
+}
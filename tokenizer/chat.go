@@ -0,0 +1,480 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-22 13:26:48
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-08-06 14:36:51
+ * @Description: 聊天消息的 token 计数，包含消息格式开销、图像和工具定义
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package tokenizer
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/Mrzhouyl/go-aisdk/consts"
+	"github.com/Mrzhouyl/go-aisdk/errors"
+	"github.com/Mrzhouyl/go-aisdk/models"
+	_ "golang.org/x/image/webp"
+)
+
+// defaultImageSize 无法获取图像尺寸（如远程 URL）时假定的尺寸
+const defaultImageSize = 1024
+
+// ImageSizeFunc 获取图像尺寸，ok 为 false 时使用默认尺寸 1024x1024
+type ImageSizeFunc func(url string) (width, height int, ok bool)
+
+// CountOption 计数选项
+type CountOption func(o *countOption)
+
+// countOption 计数选项
+type countOption struct {
+	tools      []models.ChatTool // 工具定义
+	imageSize  ImageSizeFunc     // 获取图像尺寸
+	calibrator *Calibrator       // 校准器
+	estimate   bool              // 词表未加载时是否估算
+}
+
+// WithTools 计入工具定义的 token
+func WithTools(tools ...models.ChatTool) (opt CountOption) {
+	return func(o *countOption) {
+		o.tools = append(o.tools, tools...)
+	}
+}
+
+// WithImageSize 设置获取图像尺寸的函数，默认只解析 base64 编码的 data URL
+func WithImageSize(fn ImageSizeFunc) (opt CountOption) {
+	return func(o *countOption) {
+		o.imageSize = fn
+	}
+}
+
+// WithCalibrator 使用校准器按实际 Usage 修正计数结果
+func WithCalibrator(c *Calibrator) (opt CountOption) {
+	return func(o *countOption) {
+		o.calibrator = c
+	}
+}
+
+// WithEstimate 模型的词表未加载时使用 EstimateTokens 估算，而不是返回 errors.ErrEncodingNotFound
+func WithEstimate() (opt CountOption) {
+	return func(o *countOption) {
+		o.estimate = true
+	}
+}
+
+// EncodingForModel 获取模型使用的编码名称
+func EncodingForModel(provider consts.Provider, model string) (name string) {
+	switch provider {
+	case consts.OpenAI:
+		if strings.HasPrefix(model, "gpt-4o") || strings.HasPrefix(model, "gpt-4.") {
+			return O200KBase
+		}
+		for _, prefix := range []string{"gpt-4", "gpt-3.5", "text-embedding-"} {
+			if strings.HasPrefix(model, prefix) {
+				return CL100KBase
+			}
+		}
+		return O200KBase
+	case consts.DeepSeek:
+		return DeepSeekV3
+	case consts.AliBL:
+		return Qwen
+	}
+	return CL100KBase
+}
+
+// CountTokens 计算文本的 token 数量，模型的词表未加载时返回 errors.ErrEncodingNotFound，设置 WithEstimate 时使用 EstimateTokens 估算
+func CountTokens(provider consts.Provider, model, text string, opts ...CountOption) (n int, err error) {
+	o := &countOption{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	var count func(text string) int
+	if count, err = counterFor(provider, model, o.estimate); err != nil {
+		return
+	}
+	return count(text), nil
+}
+
+// EstimateTokens 在没有词表时估算文本的 token 数量：中日韩等非 ASCII 字符按每个字符 1 个 token，ASCII 字符按每 4 个字符 1 个 token
+func EstimateTokens(text string) (n int) {
+	ascii := 0
+	for _, c := range text {
+		if c < unicode.MaxASCII {
+			ascii++
+			continue
+		}
+		n++
+	}
+	return n + (ascii+3)/4
+}
+
+// counterFor 获取模型的计数函数，estimate 为 true 时词表未加载使用 EstimateTokens 估算
+func counterFor(provider consts.Provider, model string, estimate bool) (count func(text string) int, err error) {
+	e, err := GetEncoding(EncodingForModel(provider, model))
+	if err != nil {
+		if estimate && errors.IsEncodingNotFoundError(err) {
+			return EstimateTokens, nil
+		}
+		return
+	}
+	return e.Count, nil
+}
+
+// chatFormat 对话模板的 token 开销
+type chatFormat struct {
+	perMessage  int // 每条消息的固定开销（角色、分隔符）
+	perName     int // 设置参与者名称时的额外开销
+	perToolCall int // 每个工具调用的固定开销
+	priming     int // 回复前缀的开销
+}
+
+// chatFormats 各提供商的对话模板开销
+var chatFormats = map[consts.Provider]chatFormat{
+	// <|start|>{role/name}\n{content}<|end|>\n，回复以 <|start|>assistant<|message|> 开头
+	consts.OpenAI: {perMessage: 3, perName: 1, perToolCall: 3, priming: 3},
+	// <｜begin▁of▁sentence｜>{system}<｜User｜>{content}<｜Assistant｜>{content}<｜end▁of▁sentence｜>
+	consts.DeepSeek: {perMessage: 1, perToolCall: 4, priming: 2},
+	// <|im_start|>{role}\n{content}<|im_end|>\n，回复以 <|im_start|>assistant\n 开头
+	consts.AliBL: {perMessage: 5, perToolCall: 4, priming: 3},
+}
+
+// CountChatTokens 计算聊天消息作为 prompt 的 token 数量，包含对话模板的开销、图像（按提供商的切片规则）和工具定义（WithTools）
+//
+// 模型的词表未加载时返回 errors.ErrEncodingNotFound，设置 WithEstimate 时使用 EstimateTokens 估算；无法获取尺寸的图像按 1024x1024 计算；音频、文件和视频不计入。
+// 由于提供商未公开完整的模板，结果与实际 Usage 可能存在少量偏差，可以通过 WithCalibrator 修正
+func CountChatTokens(provider consts.Provider, model string, messages []models.ChatMessage, opts ...CountOption) (n int, err error) {
+	o := &countOption{imageSize: dataURLImageSize}
+	for _, opt := range opts {
+		opt(o)
+	}
+	var count func(text string) int
+	if count, err = counterFor(provider, model, o.estimate); err != nil {
+		return
+	}
+	format, ok := chatFormats[provider]
+	if !ok {
+		format = chatFormats[consts.OpenAI]
+	}
+
+	for _, message := range messages {
+		n += format.perMessage
+		switch m := message.(type) {
+		case *models.SystemMessage:
+			n += count(m.Content) + nameTokens(format, count, m.Name)
+		case *models.DeveloperMessage:
+			n += count(m.Content) + nameTokens(format, count, m.Name)
+		case *models.UserMessage:
+			n += count(m.Content) + nameTokens(format, count, m.Name)
+			for _, part := range m.MultimodalContent {
+				n += count(part.Text)
+				if part.ImageURL != nil {
+					n += imageTokens(provider, model, part.ImageURL, o.imageSize)
+				}
+			}
+		case *models.AssistantMessage:
+			n += count(m.Content) + count(m.Refusal) + nameTokens(format, count, m.Name)
+			for _, part := range m.MultimodalContent {
+				n += count(part.Text) + count(part.Refusal)
+			}
+			for _, call := range m.ToolCalls {
+				n += format.perToolCall
+				if call.Function != nil {
+					n += count(call.Function.Name) + count(call.Function.Arguments)
+				}
+			}
+		case *models.ToolMessage:
+			n += count(m.Content)
+		default:
+			// 未知的消息类型按序列化后的 JSON 计算
+			var b []byte
+			if b, err = json.Marshal(message); err != nil {
+				return 0, fmt.Errorf("marshal chat message failed: %w", err)
+			}
+			n += count(string(b))
+		}
+	}
+	if len(messages) > 0 {
+		n += format.priming
+	}
+	if len(o.tools) > 0 {
+		n += toolTokens(provider, o.tools, messages, count)
+	}
+	if o.calibrator != nil {
+		n = o.calibrator.Adjust(provider, model, n)
+	}
+	return
+}
+
+// nameTokens 参与者名称的 token 数量
+func nameTokens(format chatFormat, count func(text string) int, name string) (n int) {
+	if name == "" {
+		return 0
+	}
+	return count(name) + format.perName
+}
+
+// imageTokens 图像的 token 数量
+func imageTokens(provider consts.Provider, model string, imageURL *models.ChatUserMsgImageURL, imageSize ImageSizeFunc) (n int) {
+	width, height, ok := imageSize(imageURL.URL)
+	if !ok || width <= 0 || height <= 0 {
+		width, height = defaultImageSize, defaultImageSize
+	}
+	switch provider {
+	case consts.AliBL:
+		maxPixels := qwenMaxPixels
+		if imageURL.MaxPixels != nil && *imageURL.MaxPixels > 0 {
+			maxPixels = *imageURL.MaxPixels
+		}
+		return QwenImageTokens(width, height, maxPixels)
+	case consts.DeepSeek:
+		// DeepSeek 的聊天模型不支持图像输入
+		return 0
+	}
+	return OpenAIImageTokens(model, width, height, imageURL.Detail)
+}
+
+// openAIImageCost OpenAI 图像的基础 token 和每个 512px 切片的 token
+type openAIImageCost struct {
+	base int // 基础 token
+	tile int // 每个切片的 token
+}
+
+// openAIImageCosts 按模型前缀匹配的图像 token 开销，未匹配时为 {85, 170}
+var openAIImageCosts = []struct {
+	prefix string
+	cost   openAIImageCost
+}{
+	{prefix: "gpt-4o-mini", cost: openAIImageCost{base: 2833, tile: 5667}},
+	{prefix: "o1", cost: openAIImageCost{base: 75, tile: 150}},
+	{prefix: "o3", cost: openAIImageCost{base: 75, tile: 150}},
+	{prefix: "computer-use", cost: openAIImageCost{base: 65, tile: 129}},
+}
+
+// OpenAIImageTokens 计算 OpenAI 图像的 token 数量
+//
+// detail 为 low 时只计基础 token；否则先等比缩放到 2048x2048 以内，再将短边缩放到 768，按 512px 切片数计算
+func OpenAIImageTokens(model string, width, height int, detail models.ChatUserMsgImageURLDetail) (n int) {
+	cost := openAIImageCost{base: 85, tile: 170}
+	for _, item := range openAIImageCosts {
+		if strings.HasPrefix(model, item.prefix) {
+			cost = item.cost
+			break
+		}
+	}
+	if detail == models.ChatUserMsgImageURLDetailLow {
+		return cost.base
+	}
+
+	w, h := float64(width), float64(height)
+	if scale := 2048 / max(w, h); scale < 1 {
+		w, h = w*scale, h*scale
+	}
+	if scale := 768 / min(w, h); scale < 1 {
+		w, h = w*scale, h*scale
+	}
+	tiles := int(math.Ceil(w/512)) * int(math.Ceil(h/512))
+	return cost.base + cost.tile*tiles
+}
+
+const (
+	qwenPatchSize = 28             // 每个 token 对应 28x28 像素
+	qwenMinPixels = 4 * 28 * 28    // 默认的最小像素
+	qwenMaxPixels = 1280 * 28 * 28 // 默认的最大像素
+	qwenVisionTag = 2              // <|vision_start|> 和 <|vision_end|>
+)
+
+// QwenImageTokens 计算通义千问 VL 模型图像的 token 数量，图像缩放到像素数在 [min_pixels, maxPixels] 之间后每 28x28 像素对应 1 个 token
+func QwenImageTokens(width, height, maxPixels int) (n int) {
+	w, h := roundToPatch(float64(width)), roundToPatch(float64(height))
+	if pixels := w * h; pixels > float64(maxPixels) {
+		scale := math.Sqrt(float64(width*height) / float64(maxPixels))
+		w = max(qwenPatchSize, math.Floor(float64(width)/scale/qwenPatchSize)*qwenPatchSize)
+		h = max(qwenPatchSize, math.Floor(float64(height)/scale/qwenPatchSize)*qwenPatchSize)
+	} else if pixels < qwenMinPixels {
+		scale := math.Sqrt(float64(qwenMinPixels) / float64(width*height))
+		w = math.Ceil(float64(width)*scale/qwenPatchSize) * qwenPatchSize
+		h = math.Ceil(float64(height)*scale/qwenPatchSize) * qwenPatchSize
+	}
+	return int(w/qwenPatchSize)*int(h/qwenPatchSize) + qwenVisionTag
+}
+
+// roundToPatch 将边长四舍五入为 28 的整数倍
+func roundToPatch(size float64) (rounded float64) {
+	return max(qwenPatchSize, math.Round(size/qwenPatchSize)*qwenPatchSize)
+}
+
+// dataURLImageSize 从 base64 编码的 data URL 中解析图像尺寸
+func dataURLImageSize(url string) (width, height int, ok bool) {
+	_, data, found := strings.Cut(url, ";base64,")
+	if !found || !strings.HasPrefix(url, "data:") {
+		return
+	}
+	b, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(b))
+	if err != nil {
+		return
+	}
+	return config.Width, config.Height, true
+}
+
+// toolTokens 工具定义的 token 数量
+func toolTokens(provider consts.Provider, tools []models.ChatTool, messages []models.ChatMessage, count func(text string) int) (n int) {
+	switch provider {
+	case consts.AliBL:
+		return count(qwenToolsPrompt(tools))
+	case consts.DeepSeek:
+		var b strings.Builder
+		for _, tool := range tools {
+			data, _ := json.Marshal(tool)
+			b.Write(data)
+			b.WriteByte('\n')
+		}
+		return count(b.String())
+	}
+	// OpenAI 将工具定义渲染为 TypeScript 命名空间插入系统消息，已有系统消息时复用其开销
+	n = count(openAIToolsPrompt(tools)) + 9
+	if slices.ContainsFunc(messages, func(m models.ChatMessage) bool {
+		_, ok := m.(*models.SystemMessage)
+		return ok
+	}) {
+		n -= 4
+	}
+	return
+}
+
+// openAIToolsPrompt 渲染 OpenAI 的工具定义
+//
+//	namespace functions {
+//
+//	// 函数描述
+//	type get_weather = (_: {
+//	// 城市
+//	city: string,
+//	unit?: "c" | "f",
+//	}) => any;
+//
+//	} // namespace functions
+func openAIToolsPrompt(tools []models.ChatTool) (prompt string) {
+	var b strings.Builder
+	b.WriteString("namespace functions {\n\n")
+	for _, tool := range tools {
+		if tool.Function == nil {
+			continue
+		}
+		if tool.Function.Description != "" {
+			fmt.Fprintf(&b, "// %s\n", tool.Function.Description)
+		}
+		properties, _ := tool.Function.Parameters["properties"].(map[string]any)
+		if len(properties) == 0 {
+			fmt.Fprintf(&b, "type %s = () => any;\n\n", tool.Function.Name)
+			continue
+		}
+		fmt.Fprintf(&b, "type %s = (_: {\n", tool.Function.Name)
+		writeTSProperties(&b, tool.Function.Parameters, 0)
+		b.WriteString("}) => any;\n\n")
+	}
+	b.WriteString("} // namespace functions")
+	return b.String()
+}
+
+// writeTSProperties 将对象的属性渲染为 TypeScript 字段，属性按名称排序
+func writeTSProperties(b *strings.Builder, schema map[string]any, indent int) {
+	properties, _ := schema["properties"].(map[string]any)
+	required := make(map[string]bool)
+	switch v := schema["required"].(type) {
+	case []string:
+		for _, name := range v {
+			required[name] = true
+		}
+	case []any:
+		for _, name := range v {
+			if s, ok := name.(string); ok {
+				required[s] = true
+			}
+		}
+	}
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	prefix := strings.Repeat("  ", indent)
+	for _, name := range names {
+		prop, _ := properties[name].(map[string]any)
+		if description, _ := prop["description"].(string); description != "" {
+			fmt.Fprintf(b, "%s// %s\n", prefix, description)
+		}
+		optional := "?"
+		if required[name] {
+			optional = ""
+		}
+		fmt.Fprintf(b, "%s%s%s: %s,\n", prefix, name, optional, tsType(prop, indent))
+	}
+}
+
+// tsType 将 JSON Schema 转换为 TypeScript 类型
+func tsType(schema map[string]any, indent int) (typ string) {
+	if enum, ok := schema["enum"].([]any); ok && len(enum) > 0 {
+		values := make([]string, 0, len(enum))
+		for _, v := range enum {
+			b, _ := json.Marshal(v)
+			values = append(values, string(b))
+		}
+		return strings.Join(values, " | ")
+	}
+	switch schema["type"] {
+	case "string":
+		return "string"
+	case "integer", "number":
+		return "number"
+	case "boolean":
+		return "boolean"
+	case "null":
+		return "null"
+	case "array":
+		if items, ok := schema["items"].(map[string]any); ok {
+			return tsType(items, indent) + "[]"
+		}
+		return "any[]"
+	case "object":
+		var b strings.Builder
+		b.WriteString("{\n")
+		writeTSProperties(&b, schema, indent+1)
+		b.WriteString(strings.Repeat("  ", indent) + "}")
+		return b.String()
+	}
+	return "any"
+}
+
+// qwenToolsPrompt 渲染通义千问对话模板中的工具定义
+func qwenToolsPrompt(tools []models.ChatTool) (prompt string) {
+	var b strings.Builder
+	b.WriteString("\n\n# Tools\n\nYou may call one or more functions to assist with the user query.\n\n" +
+		"You are provided with function signatures within <tools></tools> XML tags:\n<tools>")
+	for _, tool := range tools {
+		data, _ := json.Marshal(tool)
+		b.WriteByte('\n')
+		b.Write(data)
+	}
+	b.WriteString("\n</tools>\n\nFor each function call, return a json object with function name and arguments within " +
+		"<tool_call></tool_call> XML tags:\n<tool_call>\n{\"name\": <function-name>, \"arguments\": <args-json-object>}\n</tool_call>")
+	return b.String()
+}
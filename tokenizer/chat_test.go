@@ -0,0 +1,210 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-22 16:12:37
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-08-06 14:36:51
+ * @Description:
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package tokenizer
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/png"
+	"testing"
+	"testing/fstest"
+
+	"github.com/Mrzhouyl/go-aisdk/consts"
+	"github.com/Mrzhouyl/go-aisdk/errors"
+	"github.com/Mrzhouyl/go-aisdk/models"
+)
+
+// testDataURL 生成指定尺寸的 PNG data URL
+func testDataURL(t *testing.T, width, height int) (url string) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatalf("encode png failed: %v", err)
+	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+func TestCountChatTokens(t *testing.T) {
+	weatherTool := models.ChatTool{
+		Type: models.ToolTypeFunction,
+		Function: &models.ChatToolFunction{
+			Name:        "get_weather",
+			Description: "Get weather",
+			Parameters: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"city": map[string]any{"type": "string", "description": "城市"},
+					"unit": map[string]any{"type": "string", "enum": []any{"c", "f"}},
+				},
+				"required": []string{"city"},
+			},
+		},
+	}
+	// 词表未加载，设置 WithEstimate 后使用 EstimateTokens 估算
+	tests := []struct {
+		name     string
+		provider consts.Provider
+		messages []models.ChatMessage
+		opts     []CountOption
+		want     int
+	}{
+		{
+			name:     "openai messages",
+			provider: consts.OpenAI,
+			messages: []models.ChatMessage{
+				&models.SystemMessage{Content: "You are helpful"},
+				&models.UserMessage{Content: "Hi", Name: "bob"},
+				&models.AssistantMessage{ToolCalls: []models.ToolCalls{{
+					ID:       "call_1",
+					Type:     models.ToolTypeFunction,
+					Function: &models.ToolCallsFunction{Name: "get_weather", Arguments: `{"city":"SH"}`},
+				}}},
+				&models.ToolMessage{Content: "sunny", ToolCallID: "call_1"},
+			},
+			// (3+4) + (3+1+1+1) + (3+3+3+4) + (3+2) + 3
+			want: 34,
+		},
+		{
+			name:     "openai images",
+			provider: consts.OpenAI,
+			messages: []models.ChatMessage{
+				&models.UserMessage{MultimodalContent: []models.ChatUserMsgPart{
+					{Type: models.ChatUserMsgPartTypeText, Text: "what"},
+					{Type: models.ChatUserMsgPartTypeImageURL, ImageURL: &models.ChatUserMsgImageURL{URL: testDataURL(t, 100, 50)}},
+					{Type: models.ChatUserMsgPartTypeImageURL, ImageURL: &models.ChatUserMsgImageURL{
+						URL:    "https://example.com/a.png",
+						Detail: models.ChatUserMsgImageURLDetailLow,
+					}},
+				}},
+			},
+			// 3 + 1 + (85+170) + 85 + 3
+			want: 347,
+		},
+		{
+			name:     "openai tools",
+			provider: consts.OpenAI,
+			messages: []models.ChatMessage{&models.SystemMessage{Content: "You are helpful"}},
+			opts:     []CountOption{WithTools(weatherTool)},
+			want:     7 + 3 + EstimateTokens(openAIToolsPrompt([]models.ChatTool{weatherTool})) + 9 - 4,
+		},
+		{
+			name:     "deepseek messages",
+			provider: consts.DeepSeek,
+			messages: []models.ChatMessage{
+				&models.SystemMessage{Content: "You are helpful"},
+				&models.UserMessage{Content: "你好"},
+			},
+			// (1+4) + (1+2) + 2
+			want: 10,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := CountChatTokens(tt.provider, "gpt-4o", tt.messages, append(tt.opts, WithEstimate())...)
+			if err != nil {
+				t.Fatalf("CountChatTokens() error = %v", err)
+			}
+			if n != tt.want {
+				t.Errorf("CountChatTokens() = %d, want %d", n, tt.want)
+			}
+		})
+	}
+}
+
+func TestCountChatTokens_EncodingNotFound(t *testing.T) {
+	// 词表未加载且未设置 WithEstimate 时返回错误，而不是静默估算
+	if _, err := CountChatTokens(consts.OpenAI, "gpt-4o", []models.ChatMessage{&models.UserMessage{Content: "Hi"}}); !errors.IsEncodingNotFoundError(err) {
+		t.Errorf("CountChatTokens() error = %v, want ErrEncodingNotFound", err)
+	}
+	if _, err := CountTokens(consts.OpenAI, "gpt-4", "Hi"); !errors.IsEncodingNotFoundError(err) {
+		t.Errorf("CountTokens() error = %v, want ErrEncodingNotFound", err)
+	}
+	if n, err := CountTokens(consts.OpenAI, "gpt-4", "Hi", WithEstimate()); err != nil || n != 1 {
+		t.Errorf("CountTokens() = %d, %v, want 1", n, err)
+	}
+}
+
+func TestCountChatTokensWithEncoding(t *testing.T) {
+	useTestVocab(t, fstest.MapFS{"qwen.tiktoken": {Data: []byte(testTiktoken())}})
+
+	n, err := CountChatTokens(consts.AliBL, "qwen-vl-max", []models.ChatMessage{
+		&models.UserMessage{MultimodalContent: []models.ChatUserMsgPart{
+			{Text: "hello"},
+			{ImageURL: &models.ChatUserMsgImageURL{URL: testDataURL(t, 280, 280)}},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("CountChatTokens() error = %v", err)
+	}
+	// 5 + 1 + (10*10+2) + 3
+	if n != 111 {
+		t.Errorf("CountChatTokens() = %d, want 111", n)
+	}
+}
+
+func TestOpenAIToolsPrompt(t *testing.T) {
+	prompt := openAIToolsPrompt([]models.ChatTool{{
+		Type: models.ToolTypeFunction,
+		Function: &models.ChatToolFunction{
+			Name:        "get_weather",
+			Description: "Get weather",
+			Parameters: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"city": map[string]any{"type": "string", "description": "城市"},
+					"unit": map[string]any{"type": "string", "enum": []any{"c", "f"}},
+					"days": map[string]any{"type": "array", "items": map[string]any{"type": "integer"}},
+				},
+				"required": []any{"city"},
+			},
+		},
+	}})
+	want := "namespace functions {\n\n// Get weather\ntype get_weather = (_: {\n// 城市\ncity: string,\ndays?: number[],\nunit?: \"c\" | \"f\",\n}) => any;\n\n} // namespace functions"
+	if prompt != want {
+		t.Errorf("openAIToolsPrompt() mismatch:\ngot:  %q\nwant: %q", prompt, want)
+	}
+}
+
+func TestImageTokens(t *testing.T) {
+	tests := []struct {
+		name string
+		got  int
+		want int
+	}{
+		{name: "openai square", got: OpenAIImageTokens("gpt-4o", 1024, 1024, models.ChatUserMsgImageURLDetailHigh), want: 765},
+		{name: "openai large", got: OpenAIImageTokens("gpt-4.1", 2048, 4096, models.ChatUserMsgImageURLDetailAuto), want: 1105},
+		{name: "openai low", got: OpenAIImageTokens("gpt-4o", 4096, 4096, models.ChatUserMsgImageURLDetailLow), want: 85},
+		{name: "openai mini low", got: OpenAIImageTokens("gpt-4o-mini", 512, 512, models.ChatUserMsgImageURLDetailLow), want: 2833},
+		{name: "qwen max pixels", got: QwenImageTokens(1024, 1024, qwenMaxPixels), want: 1227},
+		{name: "qwen min pixels", got: QwenImageTokens(10, 10, qwenMaxPixels), want: 6},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s: got %d, want %d", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestCalibrator(t *testing.T) {
+	c := NewCalibrator(CalibratorConfig{})
+	if n := c.Adjust(consts.OpenAI, "gpt-4o", 100); n != 100 {
+		t.Errorf("Adjust() without samples = %d, want 100", n)
+	}
+	c.Observe(consts.OpenAI, "gpt-4o", 100, 120)
+	c.Observe(consts.OpenAI, "gpt-4o", 100, 100)
+	if n := c.Adjust(consts.OpenAI, "gpt-4o", 100); n != 116 {
+		t.Errorf("Adjust() = %d, want 116", n)
+	}
+	// 超出上限的比值被截断
+	c.Observe(consts.DeepSeek, "deepseek-chat", 10, 100)
+	if ratio := c.Ratio(consts.DeepSeek, "deepseek-chat"); ratio != 2 {
+		t.Errorf("Ratio() = %v, want 2", ratio)
+	}
+}
@@ -0,0 +1,158 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-22 09:31:06
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-22 17:02:43
+ * @Description: BPE 编码器
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package tokenizer
+
+import (
+	"math"
+	"strings"
+)
+
+// mergePair 合并对
+type mergePair struct {
+	left  string
+	right string
+}
+
+// Encoding BPE 编码器（并发安全）
+type Encoding struct {
+	name    string            // 编码名称
+	encoder map[string]int    // token（原始字节）到ID的映射
+	decoder map[int]string    // ID到 token（原始字节）的映射
+	merges  map[mergePair]int // 合并规则的优先级，为 nil 时使用合并结果的 rank（tiktoken 格式）
+	split   SplitFunc         // 预分词函数
+	special map[string]int    // 特殊 token
+	cache   *pieceCache       // 分片编码缓存
+}
+
+// newEncoding 新建 BPE 编码器，ranks 为 token（原始字节）到 rank 的映射，rank 越小越先合并；merges 不为 nil 时按合并规则的顺序合并（HuggingFace 格式）
+func newEncoding(name string, ranks map[string]int, merges []mergePair, split SplitFunc, special map[string]int) (e *Encoding) {
+	e = &Encoding{
+		name:    name,
+		encoder: ranks,
+		decoder: make(map[int]string, len(ranks)+len(special)),
+		split:   split,
+		special: special,
+		cache:   newPieceCache(),
+	}
+	for token, id := range ranks {
+		e.decoder[id] = token
+	}
+	for token, id := range special {
+		e.decoder[id] = token
+	}
+	if merges != nil {
+		e.merges = make(map[mergePair]int, len(merges))
+		for i, pair := range merges {
+			if _, ok := e.merges[pair]; !ok {
+				e.merges[pair] = i
+			}
+		}
+	}
+	if e.split == nil {
+		e.split = SplitCL100K
+	}
+	return
+}
+
+// Name 获取编码名称
+func (e *Encoding) Name() (name string) {
+	return e.name
+}
+
+// VocabSize 获取词表大小（包含特殊 token）
+func (e *Encoding) VocabSize() (size int) {
+	return len(e.decoder)
+}
+
+// Encode 将文本编码为 token ID，特殊 token 按普通文本处理
+func (e *Encoding) Encode(text string) (ids []int) {
+	for _, piece := range e.split(text) {
+		ids = append(ids, e.encodePiece(piece)...)
+	}
+	return
+}
+
+// Count 计算文本的 token 数量
+func (e *Encoding) Count(text string) (n int) {
+	for _, piece := range e.split(text) {
+		n += len(e.encodePiece(piece))
+	}
+	return
+}
+
+// Decode 将 token ID 解码为文本，未知的 ID 会被忽略
+func (e *Encoding) Decode(ids []int) (text string) {
+	var b strings.Builder
+	for _, id := range ids {
+		b.WriteString(e.decoder[id])
+	}
+	return b.String()
+}
+
+// encodePiece 编码单个预分词分片
+func (e *Encoding) encodePiece(piece string) (ids []int) {
+	if id, ok := e.encoder[piece]; ok {
+		return []int{id}
+	}
+	if ids, ok := e.cache.get(piece); ok {
+		return ids
+	}
+	for _, token := range e.bytePairMerge(piece) {
+		if id, ok := e.encoder[token]; ok {
+			ids = append(ids, id)
+			continue
+		}
+		// 词表中没有的 token 按字节编码
+		for i := range len(token) {
+			if id, ok := e.encoder[token[i:i+1]]; ok {
+				ids = append(ids, id)
+			}
+		}
+	}
+	e.cache.put(piece, ids)
+	return
+}
+
+// bytePairMerge 按优先级反复合并相邻的 token，直到无法合并
+func (e *Encoding) bytePairMerge(piece string) (tokens []string) {
+	// bounds[i] 为第 i 个 token 的起始位置
+	bounds := make([]int, 0, len(piece)+1)
+	for i := range len(piece) + 1 {
+		bounds = append(bounds, i)
+	}
+	for len(bounds) > 2 {
+		best, bestRank := -1, math.MaxInt
+		for i := 0; i+2 < len(bounds); i++ {
+			if rank, ok := e.pairRank(piece[bounds[i]:bounds[i+1]], piece[bounds[i+1]:bounds[i+2]]); ok && rank < bestRank {
+				best, bestRank = i, rank
+			}
+		}
+		if best < 0 {
+			break
+		}
+		bounds = append(bounds[:best+1], bounds[best+2:]...)
+	}
+
+	tokens = make([]string, 0, len(bounds)-1)
+	for i := 0; i+1 < len(bounds); i++ {
+		tokens = append(tokens, piece[bounds[i]:bounds[i+1]])
+	}
+	return
+}
+
+// pairRank 获取相邻 token 合并的优先级
+func (e *Encoding) pairRank(left, right string) (rank int, ok bool) {
+	if e.merges != nil {
+		rank, ok = e.merges[mergePair{left: left, right: right}]
+		return
+	}
+	rank, ok = e.encoder[left+right]
+	return
+}
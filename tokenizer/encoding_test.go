@@ -0,0 +1,185 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-22 15:40:12
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-22 17:05:26
+ * @Description:
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package tokenizer
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/Mrzhouyl/go-aisdk/errors"
+)
+
+// testMerges 测试词表中单字节之外的 token，rank 从 256 开始
+var testMerges = []string{"he", "ll", "hell", "hello", " w"}
+
+// testTiktoken 生成 tiktoken 格式的测试词表
+func testTiktoken() (data string) {
+	var b strings.Builder
+	for i := range 256 {
+		fmt.Fprintf(&b, "%s %d\n", base64.StdEncoding.EncodeToString([]byte{byte(i)}), i)
+	}
+	for i, token := range testMerges {
+		fmt.Fprintf(&b, "%s %d\n", base64.StdEncoding.EncodeToString([]byte(token)), 256+i)
+	}
+	return b.String()
+}
+
+// testHuggingFace 生成 tokenizer.json 格式的测试词表，"he" 的 ID 较小但 "el" 的合并规则在前
+func testHuggingFace() (data string) {
+	encoder := make(map[byte]rune, 256)
+	for r, b := range byteLevelDecoder {
+		encoder[b] = r
+	}
+	vocab := make(map[string]int)
+	for i := range 256 {
+		vocab[string(encoder[byte(i)])] = i
+	}
+	vocab["he"], vocab["el"], vocab["Ġw"] = 256, 257, 258
+	b, _ := json.Marshal(map[string]any{
+		"added_tokens": []map[string]any{{"id": 259, "content": "<｜end▁of▁sentence｜>"}},
+		"model":        map[string]any{"type": "BPE", "vocab": vocab, "merges": []string{"e l", "h e", "Ġ w"}},
+	})
+	return string(b)
+}
+
+// useTestVocab 添加测试词表来源，测试结束后恢复注册表
+func useTestVocab(t *testing.T, files fstest.MapFS) {
+	registry.mu.Lock()
+	sources := registry.sources
+	registry.mu.Unlock()
+	t.Cleanup(func() {
+		registry.mu.Lock()
+		defer registry.mu.Unlock()
+		for name := range files {
+			delete(registry.encodings, strings.TrimSuffix(strings.TrimSuffix(name, ".tiktoken"), ".json"))
+		}
+		registry.sources = sources
+	})
+	AddVocabFS(files)
+}
+
+func TestEncoding(t *testing.T) {
+	e, err := LoadEncoding(CL100KBase, strings.NewReader(testTiktoken()))
+	if err != nil {
+		t.Fatalf("LoadEncoding() error = %v", err)
+	}
+	tests := []struct {
+		text string
+		want []int
+	}{
+		{text: "hello", want: []int{259}},
+		{text: "hello world", want: []int{259, 260, 'o', 'r', 'l', 'd'}},
+		{text: "xhello", want: []int{'x', 259}},
+		{text: "你", want: []int{0xE4, 0xBD, 0xA0}},
+	}
+	for _, tt := range tests {
+		ids := e.Encode(tt.text)
+		if !reflect.DeepEqual(ids, tt.want) {
+			t.Errorf("Encode(%q) = %v, want %v", tt.text, ids, tt.want)
+		}
+		if n := e.Count(tt.text); n != len(tt.want) {
+			t.Errorf("Count(%q) = %d, want %d", tt.text, n, len(tt.want))
+		}
+		if text := e.Decode(ids); text != tt.text {
+			t.Errorf("Decode(%v) = %q, want %q", ids, text, tt.text)
+		}
+	}
+	if _, err = LoadEncoding("unknown", strings.NewReader(testTiktoken())); err == nil {
+		t.Error("expected error for unknown encoding")
+	}
+	if _, err = LoadEncoding(CL100KBase, strings.NewReader("bad line")); err == nil {
+		t.Error("expected error for invalid tiktoken data")
+	}
+}
+
+func TestLoadHuggingFace(t *testing.T) {
+	e, err := LoadEncoding(DeepSeekV3, strings.NewReader(testHuggingFace()))
+	if err != nil {
+		t.Fatalf("LoadEncoding() error = %v", err)
+	}
+	// 按合并规则的顺序合并，"el" 先于 "he"
+	if ids := e.Encode("hel world"); !reflect.DeepEqual(ids, []int{'h', 257, 258, 'o', 'r', 'l', 'd'}) {
+		t.Errorf("Encode() = %v", ids)
+	}
+	if e.VocabSize() != 260 {
+		t.Errorf("VocabSize() = %d, want 260", e.VocabSize())
+	}
+}
+
+func TestGetEncoding(t *testing.T) {
+	useTestVocab(t, fstest.MapFS{"qwen.tiktoken": {Data: []byte(testTiktoken())}})
+
+	e, err := GetEncoding(Qwen)
+	if err != nil {
+		t.Fatalf("GetEncoding() error = %v", err)
+	}
+	if again, _ := GetEncoding(Qwen); again != e {
+		t.Error("expected cached encoding")
+	}
+	// Qwen 的数字逐位切分
+	if n := e.Count("hello 2025"); n != 6 {
+		t.Errorf("Count() = %d, want 6", n)
+	}
+	if _, err = GetEncoding(O200KBase); !errors.IsEncodingNotFoundError(err) {
+		t.Errorf("expected ErrEncodingNotFound, got %v", err)
+	}
+}
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		name  string
+		split SplitFunc
+		text  string
+		want  []string
+	}{
+		{
+			name:  "cl100k",
+			split: SplitCL100K,
+			text:  "Hello world's 123456 !!!\n\n  x",
+			want:  []string{"Hello", " world", "'s", " ", "123", "456", " !!!\n\n", " ", " x"},
+		},
+		{
+			name:  "cl100k trailing spaces",
+			split: SplitCL100K,
+			text:  "a \n b  ",
+			want:  []string{"a", " \n", " b", "  "},
+		},
+		{
+			name:  "o200k",
+			split: SplitO200K,
+			text:  "HelloWorld's CAPS Test 1234 a/b\n",
+			want:  []string{"Hello", "World's", " CAPS", " Test", " ", "123", "4", " a", "/b", "\n"},
+		},
+		{
+			name:  "qwen",
+			split: SplitQwen,
+			text:  "abc 2024",
+			want:  []string{"abc", " ", "2", "0", "2", "4"},
+		},
+		{
+			name:  "deepseek",
+			split: SplitDeepSeek,
+			text:  "Hello世界12345,abc 你好",
+			want:  []string{"Hello", "世界", "123", "45", ",abc", " ", "你好"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.split(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("split(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
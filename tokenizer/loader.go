@@ -0,0 +1,328 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-22 10:42:17
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-22 16:55:09
+ * @Description: 词表加载，支持 tiktoken 格式（*.tiktoken）和 HuggingFace 格式（tokenizer.json）
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package tokenizer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Mrzhouyl/go-aisdk/errors"
+)
+
+const (
+	CL100KBase = "cl100k_base" // OpenAI GPT-4、GPT-3.5 系列
+	O200KBase  = "o200k_base"  // OpenAI GPT-4o、o 系列及之后的模型
+	Qwen       = "qwen"        // 通义千问系列
+	DeepSeekV3 = "deepseek_v3" // DeepSeek-V3、DeepSeek-R1
+)
+
+// VocabDirEnv 词表目录的环境变量，查找词表时作为最后一个目录
+const VocabDirEnv = "AISDK_TOKENIZER_DIR"
+
+// vocabExts 词表文件的扩展名，按顺序查找 <name>.tiktoken 和 <name>.json
+var vocabExts = []string{".tiktoken", ".json"}
+
+// splitFuncs 各编码的预分词规则
+var splitFuncs = map[string]SplitFunc{
+	CL100KBase: SplitCL100K,
+	O200KBase:  SplitO200K,
+	Qwen:       SplitQwen,
+	DeepSeekV3: SplitDeepSeek,
+}
+
+// specialTokens tiktoken 格式的词表不包含特殊 token，需要单独指定
+var specialTokens = map[string]map[string]int{
+	CL100KBase: {
+		"<|endoftext|>":   100257,
+		"<|fim_prefix|>":  100258,
+		"<|fim_middle|>":  100259,
+		"<|fim_suffix|>":  100260,
+		"<|endofprompt|>": 100276,
+	},
+	O200KBase: {
+		"<|endoftext|>":   199999,
+		"<|endofprompt|>": 200018,
+	},
+	Qwen: {
+		"<|endoftext|>": 151643,
+		"<|im_start|>":  151644,
+		"<|im_end|>":    151645,
+	},
+}
+
+// registry 编码注册表
+var registry = struct {
+	mu        sync.RWMutex
+	encodings map[string]*Encoding // 已加载的编码
+	sources   []fs.FS              // 词表来源
+}{
+	encodings: make(map[string]*Encoding),
+}
+
+// RegisterEncoding 注册编码，已存在同名编码时覆盖
+func RegisterEncoding(e *Encoding) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	registry.encodings[e.name] = e
+}
+
+// AddVocabFS 添加词表来源，可以是 embed.FS，查找时按添加顺序在根目录下查找 <name>.tiktoken 或 <name>.json
+//
+//	//go:embed vocab/*.tiktoken
+//	var vocab embed.FS
+//
+//	sub, _ := fs.Sub(vocab, "vocab")
+//	tokenizer.AddVocabFS(sub)
+func AddVocabFS(fsys fs.FS) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	registry.sources = append(registry.sources, fsys)
+}
+
+// AddVocabDir 添加本地词表目录
+func AddVocabDir(dir string) {
+	AddVocabFS(os.DirFS(dir))
+}
+
+// GetEncoding 获取编码，未注册时从词表来源中加载，找不到词表时返回 errors.ErrEncodingNotFound
+func GetEncoding(name string) (e *Encoding, err error) {
+	registry.mu.RLock()
+	e, ok := registry.encodings[name]
+	sources := registry.sources
+	registry.mu.RUnlock()
+	if ok {
+		return
+	}
+
+	if dir := os.Getenv(VocabDirEnv); dir != "" {
+		sources = append(sources[:len(sources):len(sources)], os.DirFS(dir))
+	}
+	for _, fsys := range sources {
+		for _, ext := range vocabExts {
+			var f fs.File
+			if f, err = fsys.Open(name + ext); err != nil {
+				continue
+			}
+			e, err = LoadEncoding(name, f)
+			f.Close()
+			if err != nil {
+				return nil, fmt.Errorf("load tokenizer encoding [%s] failed: %w", name, err)
+			}
+			RegisterEncoding(e)
+			return
+		}
+	}
+	return nil, errors.WrapEncodingNotFound(name)
+}
+
+// LoadEncodingFile 从本地文件加载编码
+func LoadEncodingFile(name, path string) (e *Encoding, err error) {
+	var f *os.File
+	if f, err = os.Open(path); err != nil {
+		return
+	}
+	defer f.Close()
+	return LoadEncoding(name, f)
+}
+
+// LoadEncoding 加载编码，自动识别 tiktoken 格式和 HuggingFace 的 tokenizer.json 格式，预分词规则和特殊 token 由 name 决定
+func LoadEncoding(name string, r io.Reader) (e *Encoding, err error) {
+	split, ok := splitFuncs[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown tokenizer encoding [%s]", name)
+	}
+	var data []byte
+	if data, err = io.ReadAll(r); err != nil {
+		return
+	}
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		var (
+			ranks   map[string]int
+			merges  []mergePair
+			special map[string]int
+		)
+		if ranks, merges, special, err = parseHuggingFace(data); err != nil {
+			return
+		}
+		return newEncoding(name, ranks, merges, split, special), nil
+	}
+
+	var ranks map[string]int
+	if ranks, err = parseTiktoken(data); err != nil {
+		return
+	}
+	return newEncoding(name, ranks, nil, split, specialTokens[name]), nil
+}
+
+// parseTiktoken 解析 tiktoken 格式，每行为 base64 编码的 token 和 rank
+func parseTiktoken(data []byte) (ranks map[string]int, err error) {
+	ranks = make(map[string]int)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		token, rankStr, ok := strings.Cut(text, " ")
+		if !ok {
+			return nil, fmt.Errorf("invalid tiktoken line %d", line)
+		}
+		var (
+			b    []byte
+			rank int
+		)
+		if b, err = base64.StdEncoding.DecodeString(token); err != nil {
+			return nil, fmt.Errorf("invalid tiktoken line %d: %w", line, err)
+		}
+		if rank, err = strconv.Atoi(rankStr); err != nil {
+			return nil, fmt.Errorf("invalid tiktoken line %d: %w", line, err)
+		}
+		ranks[string(b)] = rank
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(ranks) == 0 {
+		return nil, fmt.Errorf("empty tiktoken vocabulary")
+	}
+	return
+}
+
+// hfTokenizer HuggingFace tokenizer.json 中用到的字段
+type hfTokenizer struct {
+	Model struct {
+		Type   string          `json:"type"`
+		Vocab  map[string]int  `json:"vocab"`
+		Merges json.RawMessage `json:"merges"`
+	} `json:"model"`
+	AddedTokens []struct {
+		ID      int    `json:"id"`
+		Content string `json:"content"`
+	} `json:"added_tokens"`
+}
+
+// parseHuggingFace 解析字节级 BPE 的 tokenizer.json，词表中的 token 由字节映射字符还原为原始字节
+func parseHuggingFace(data []byte) (ranks map[string]int, merges []mergePair, special map[string]int, err error) {
+	var t hfTokenizer
+	if err = json.Unmarshal(data, &t); err != nil {
+		return
+	}
+	if t.Model.Type != "" && t.Model.Type != "BPE" {
+		err = fmt.Errorf("unsupported tokenizer model type [%s]", t.Model.Type)
+		return
+	}
+	if len(t.Model.Vocab) == 0 {
+		err = fmt.Errorf("empty tokenizer vocabulary")
+		return
+	}
+
+	ranks = make(map[string]int, len(t.Model.Vocab))
+	for token, id := range t.Model.Vocab {
+		ranks[decodeByteLevel(token)] = id
+	}
+	// merges 有 ["a b"] 和 [["a","b"]] 两种格式
+	var pairs [][2]string
+	if err = json.Unmarshal(t.Model.Merges, &pairs); err != nil {
+		var lines []string
+		if err = json.Unmarshal(t.Model.Merges, &lines); err != nil {
+			err = fmt.Errorf("invalid tokenizer merges: %w", err)
+			return
+		}
+		pairs = make([][2]string, 0, len(lines))
+		for _, line := range lines {
+			if left, right, ok := strings.Cut(line, " "); ok {
+				pairs = append(pairs, [2]string{left, right})
+			}
+		}
+	}
+	merges = make([]mergePair, 0, len(pairs))
+	for _, pair := range pairs {
+		merges = append(merges, mergePair{left: decodeByteLevel(pair[0]), right: decodeByteLevel(pair[1])})
+	}
+	special = make(map[string]int, len(t.AddedTokens))
+	for _, token := range t.AddedTokens {
+		special[token.Content] = token.ID
+		delete(ranks, token.Content)
+	}
+	return
+}
+
+// byteLevelDecoder 字节级 BPE 中可见字符到原始字节的映射（GPT-2 bytes_to_unicode 的逆映射）
+var byteLevelDecoder = func() (m map[rune]byte) {
+	m = make(map[rune]byte, 256)
+	n := 0
+	for b := range 256 {
+		if b >= '!' && b <= '~' || b >= 0xA1 && b <= 0xAC || b >= 0xAE && b <= 0xFF {
+			m[rune(b)] = byte(b)
+			continue
+		}
+		m[rune(256+n)] = byte(b)
+		n++
+	}
+	return
+}()
+
+// decodeByteLevel 将字节级 BPE 的 token 还原为原始字节，包含映射外字符时原样返回
+func decodeByteLevel(token string) (raw string) {
+	b := make([]byte, 0, len(token))
+	for _, c := range token {
+		v, ok := byteLevelDecoder[c]
+		if !ok {
+			return token
+		}
+		b = append(b, v)
+	}
+	return string(b)
+}
+
+// pieceCacheSize 分片编码缓存的最大条目数，超出后清空
+const pieceCacheSize = 8192
+
+// pieceCache 分片编码缓存（并发安全）
+type pieceCache struct {
+	mu    sync.RWMutex
+	items map[string][]int
+}
+
+// newPieceCache 新建分片编码缓存
+func newPieceCache() (c *pieceCache) {
+	return &pieceCache{items: make(map[string][]int)}
+}
+
+// get 获取缓存
+func (c *pieceCache) get(piece string) (ids []int, ok bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	ids, ok = c.items[piece]
+	return
+}
+
+// put 写入缓存
+func (c *pieceCache) put(piece string, ids []int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.items) >= pieceCacheSize {
+		clear(c.items)
+	}
+	c.items[piece] = ids
+}
@@ -0,0 +1,294 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-22 09:58:21
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-22 16:48:35
+ * @Description: 预分词，按各编码的正则规则将文本切分为分片（Go 的 regexp 不支持 (?!\S) 等前瞻语法，因此手工实现）
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package tokenizer
+
+import "unicode"
+
+// SplitFunc 预分词函数，将文本切分为按 BPE 独立编码的分片
+type SplitFunc func(text string) (pieces []string)
+
+// matchFunc 在位置 i 尝试匹配，返回匹配结束的位置，未匹配时返回 -1
+type matchFunc func(r []rune, i int) (end int)
+
+// SplitCL100K cl100k_base 的预分词规则
+//
+//	(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+(?!\S)|\s+
+func SplitCL100K(text string) (pieces []string) {
+	return scan(text, func(r []rune, i int) (end int) {
+		return matchCL100K(r, i, 3)
+	}, false)
+}
+
+// SplitQwen Qwen 的预分词规则，与 cl100k_base 相同，但数字逐位切分
+//
+//	(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}| ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+(?!\S)|\s+
+func SplitQwen(text string) (pieces []string) {
+	return scan(text, func(r []rune, i int) (end int) {
+		return matchCL100K(r, i, 1)
+	}, false)
+}
+
+// SplitO200K o200k_base 的预分词规则
+//
+//	[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]*[\p{Ll}\p{Lm}\p{Lo}\p{M}]+(?i:'s|'t|'re|'ve|'m|'ll|'d)?|
+//	[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]+[\p{Ll}\p{Lm}\p{Lo}\p{M}]*(?i:'s|'t|'re|'ve|'m|'ll|'d)?|
+//	\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n/]*|\s*[\r\n]+|\s+(?!\S)|\s+
+func SplitO200K(text string) (pieces []string) {
+	return scan(text, matchO200K, false)
+}
+
+// SplitDeepSeek DeepSeek-V3 的预分词规则，依次按数字、中日文字符和通用规则切分，未匹配的部分也作为分片保留
+//
+//	\p{N}{1,3}
+//	[一-龥\x{3040}-ゟ゠-ヿ]+
+//	[!"#$%&'()*+,\-./:;<=>?@\[\\\]^_`{|}~][A-Za-z]+|[^\r\n\p{L}\p{P}\p{S}]?[\p{L}\p{M}]+| ?[\p{P}\p{S}]+[\r\n]*|\s*[\r\n]+|\s+(?!\S)|\s+
+func SplitDeepSeek(text string) (pieces []string) {
+	pieces = []string{text}
+	for _, match := range []matchFunc{matchDigits(3), matchCJK, matchDeepSeek} {
+		var next []string
+		for _, piece := range pieces {
+			next = append(next, scan(piece, match, true)...)
+		}
+		pieces = next
+	}
+	return
+}
+
+// scan 从左到右依次匹配，keepGaps 为 true 时未匹配的部分也作为分片保留，否则丢弃
+func scan(text string, match matchFunc, keepGaps bool) (pieces []string) {
+	r := []rune(text)
+	gap := -1
+	for i := 0; i < len(r); {
+		end := match(r, i)
+		if end <= i {
+			if gap < 0 {
+				gap = i
+			}
+			i++
+			continue
+		}
+		if gap >= 0 && keepGaps {
+			pieces = append(pieces, string(r[gap:i]))
+		}
+		gap = -1
+		pieces = append(pieces, string(r[i:end]))
+		i = end
+	}
+	if gap >= 0 && keepGaps {
+		pieces = append(pieces, string(r[gap:]))
+	}
+	return
+}
+
+// matchCL100K cl100k_base 规则的单次匹配，maxDigits 为数字分片的最大长度
+func matchCL100K(r []rune, i, maxDigits int) (end int) {
+	if end = matchContraction(r, i); end > 0 {
+		return
+	}
+	// [^\r\n\p{L}\p{N}]?\p{L}+
+	start := i
+	if isWordPrefix(r[i]) && i+1 < len(r) && unicode.IsLetter(r[i+1]) {
+		start = i + 1
+	}
+	if end = runWhile(r, start, unicode.IsLetter); end > start {
+		return
+	}
+	if end = matchDigits(maxDigits)(r, i); end > 0 {
+		return
+	}
+	if end = matchPunct(r, i, isNewline); end > 0 {
+		return
+	}
+	return matchWhitespace(r, i)
+}
+
+// matchO200K o200k_base 规则的单次匹配
+func matchO200K(r []rune, i int) (end int) {
+	starts := []int{i}
+	if isWordPrefix(r[i]) && i+1 < len(r) {
+		starts = []int{i + 1, i}
+	}
+	// [\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]*[\p{Ll}\p{Lm}\p{Lo}\p{M}]+
+	for _, start := range starts {
+		upperEnd := runWhile(r, start, isUpperLike)
+		for p := upperEnd; p >= start; p-- {
+			if end = runWhile(r, p, isLowerLike); end > p {
+				return matchOptionalContraction(r, end)
+			}
+		}
+	}
+	// [\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]+[\p{Ll}\p{Lm}\p{Lo}\p{M}]*
+	for _, start := range starts {
+		if upperEnd := runWhile(r, start, isUpperLike); upperEnd > start {
+			return matchOptionalContraction(r, runWhile(r, upperEnd, isLowerLike))
+		}
+	}
+	if end = matchDigits(3)(r, i); end > 0 {
+		return
+	}
+	if end = matchPunct(r, i, func(c rune) bool { return isNewline(c) || c == '/' }); end > 0 {
+		return
+	}
+	return matchWhitespace(r, i)
+}
+
+// matchDeepSeek DeepSeek-V3 通用规则的单次匹配
+func matchDeepSeek(r []rune, i int) (end int) {
+	// [!"#$%&'()*+,\-./:;<=>?@\[\\\]^_`{|}~][A-Za-z]+
+	if isASCIIPunct(r[i]) {
+		if end = runWhile(r, i+1, isASCIILetter); end > i+1 {
+			return
+		}
+	}
+	// [^\r\n\p{L}\p{P}\p{S}]?[\p{L}\p{M}]+
+	isLetterOrMark := func(c rune) bool { return unicode.IsLetter(c) || unicode.IsMark(c) }
+	if !isNewline(r[i]) && !unicode.IsLetter(r[i]) && !unicode.IsPunct(r[i]) && !unicode.IsSymbol(r[i]) {
+		if end = runWhile(r, i+1, isLetterOrMark); end > i+1 {
+			return
+		}
+	}
+	if end = runWhile(r, i, isLetterOrMark); end > i {
+		return
+	}
+	// ?[\p{P}\p{S}]+[\r\n]*
+	start := i
+	if r[i] == ' ' {
+		start = i + 1
+	}
+	if end = runWhile(r, start, func(c rune) bool { return unicode.IsPunct(c) || unicode.IsSymbol(c) }); end > start {
+		return runWhile(r, end, isNewline)
+	}
+	return matchWhitespace(r, i)
+}
+
+// matchContraction 匹配英文缩写 (?i:'s|'t|'re|'ve|'m|'ll|'d)
+func matchContraction(r []rune, i int) (end int) {
+	if r[i] != '\'' || i+1 >= len(r) {
+		return -1
+	}
+	next := unicode.ToLower(r[i+1])
+	switch next {
+	case 's', 't', 'm', 'd':
+		return i + 2
+	}
+	if i+2 < len(r) {
+		switch string([]rune{next, unicode.ToLower(r[i+2])}) {
+		case "re", "ve", "ll":
+			return i + 3
+		}
+	}
+	return -1
+}
+
+// matchOptionalContraction 匹配可选的英文缩写
+func matchOptionalContraction(r []rune, i int) (end int) {
+	if i < len(r) {
+		if end = matchContraction(r, i); end > 0 {
+			return
+		}
+	}
+	return i
+}
+
+// matchDigits 匹配 \p{N}{1,max}
+func matchDigits(max int) (match matchFunc) {
+	return func(r []rune, i int) (end int) {
+		end = i
+		for end < len(r) && end-i < max && unicode.IsNumber(r[end]) {
+			end++
+		}
+		if end == i {
+			return -1
+		}
+		return
+	}
+}
+
+// matchCJK 匹配 [一-龥\x{3040}-ゟ゠-ヿ]+
+func matchCJK(r []rune, i int) (end int) {
+	return runWhile(r, i, func(c rune) bool {
+		return c >= 0x4E00 && c <= 0x9FA5 || c >= 0x3040 && c <= 0x30FF
+	})
+}
+
+// matchPunct 匹配 ?[^\s\p{L}\p{N}]+ 及其后的结尾字符
+func matchPunct(r []rune, i int, trailing func(c rune) bool) (end int) {
+	start := i
+	if r[i] == ' ' {
+		start = i + 1
+	}
+	if end = runWhile(r, start, isPunctLike); end == start {
+		return -1
+	}
+	return runWhile(r, end, trailing)
+}
+
+// matchWhitespace 匹配 \s*[\r\n]+|\s+(?!\S)|\s+
+func matchWhitespace(r []rune, i int) (end int) {
+	spaceEnd := runWhile(r, i, unicode.IsSpace)
+	if spaceEnd == i {
+		return -1
+	}
+	// \s*[\r\n]+：到最后一个换行符为止
+	for j := spaceEnd - 1; j >= i; j-- {
+		if isNewline(r[j]) {
+			return j + 1
+		}
+	}
+	// \s+(?!\S)：保留最后一个空白字符与后面的单词合并
+	if spaceEnd < len(r) && spaceEnd-i > 1 {
+		return spaceEnd - 1
+	}
+	return spaceEnd
+}
+
+// runWhile 从位置 i 开始连续满足条件的字符的结束位置
+func runWhile(r []rune, i int, fn func(c rune) bool) (end int) {
+	end = i
+	for end < len(r) && fn(r[end]) {
+		end++
+	}
+	return
+}
+
+// isNewline 是否是 \r 或 \n
+func isNewline(c rune) (ok bool) {
+	return c == '\r' || c == '\n'
+}
+
+// isWordPrefix 是否匹配 [^\r\n\p{L}\p{N}]
+func isWordPrefix(c rune) (ok bool) {
+	return !isNewline(c) && !unicode.IsLetter(c) && !unicode.IsNumber(c)
+}
+
+// isPunctLike 是否匹配 [^\s\p{L}\p{N}]
+func isPunctLike(c rune) (ok bool) {
+	return !unicode.IsSpace(c) && !unicode.IsLetter(c) && !unicode.IsNumber(c)
+}
+
+// isUpperLike 是否匹配 [\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]
+func isUpperLike(c rune) (ok bool) {
+	return unicode.In(c, unicode.Lu, unicode.Lt, unicode.Lm, unicode.Lo, unicode.M)
+}
+
+// isLowerLike 是否匹配 [\p{Ll}\p{Lm}\p{Lo}\p{M}]
+func isLowerLike(c rune) (ok bool) {
+	return unicode.In(c, unicode.Ll, unicode.Lm, unicode.Lo, unicode.M)
+}
+
+// isASCIILetter 是否匹配 [A-Za-z]
+func isASCIILetter(c rune) (ok bool) {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// isASCIIPunct 是否是 ASCII 标点或符号
+func isASCIIPunct(c rune) (ok bool) {
+	return c > ' ' && c < unicode.MaxASCII && !isASCIILetter(c) && (c < '0' || c > '9')
+}
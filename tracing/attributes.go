@@ -0,0 +1,137 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-31 10:15:42
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-08-05 11:14:02
+ * @Description: span 属性
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package tracing
+
+import (
+	"github.com/Mrzhouyl/go-aisdk/cost"
+	"github.com/Mrzhouyl/go-aisdk/httpclient"
+	"github.com/Mrzhouyl/go-aisdk/models"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// GenAI 语义约定属性
+const (
+	attrOperationName         = "gen_ai.operation.name"               // 操作名称
+	attrSystem                = "gen_ai.system"                       // 提供商
+	attrRequestModel          = "gen_ai.request.model"                // 请求的模型
+	attrRequestMaxTokens      = "gen_ai.request.max_tokens"           // 最大生成 token 数
+	attrRequestTemperature    = "gen_ai.request.temperature"          // 采样温度
+	attrRequestTopP           = "gen_ai.request.top_p"                // 核采样概率
+	attrRequestFrequency      = "gen_ai.request.frequency_penalty"    // 频率惩罚
+	attrRequestPresence       = "gen_ai.request.presence_penalty"     // 存在惩罚
+	attrRequestStopSequences  = "gen_ai.request.stop_sequences"       // 停止序列
+	attrRequestSeed           = "gen_ai.request.seed"                 // 随机种子
+	attrRequestChoiceCount    = "gen_ai.request.choice.count"         // 生成的选择数量
+	attrResponseID            = "gen_ai.response.id"                  // 响应ID
+	attrResponseModel         = "gen_ai.response.model"               // 生成响应的模型
+	attrResponseFinishReasons = "gen_ai.response.finish_reasons"      // 各选择的结束原因
+	attrUsageInputTokens      = "gen_ai.usage.input_tokens"           // 输入 token 数
+	attrUsageOutputTokens     = "gen_ai.usage.output_tokens"          // 输出 token 数
+	attrEventContent          = "content"                             // 事件中的内容
+	attrEventIndex            = "index"                               // 事件中的选择索引
+	attrEventFinishReason     = "finish_reason"                       // 事件中的结束原因
+	attrErrorType             = "error.type"                          // 错误类型
+	attrHTTPRequestMethod     = "http.request.method"                 // HTTP 请求方法
+	attrHTTPResponseStatus    = "http.response.status_code"           // HTTP 响应状态码
+	attrURLFull               = "url.full"                            // 请求地址
+	attrServerAddress         = "server.address"                      // 服务地址
+	attrServerPort            = "server.port"                         // 服务端口
+	attrRequestID             = "aisdk.request_id"                    // SDK 请求ID
+	attrCacheHit              = "aisdk.cache_hit"                     // 是否命中缓存
+	attrAttempts              = "aisdk.retry.attempts"                // 总尝试次数
+	attrAttempt               = "aisdk.retry.attempt"                 // 第几次尝试
+	attrStreamTTFT            = "aisdk.stream.time_to_first_token_ms" // 流式传输首个数据块耗时（毫秒）
+	attrStreamChunks          = "aisdk.stream.chunks"                 // 流式传输数据块数量
+	attrStreamClosed          = "aisdk.stream.closed"                 // 流式传输是否在结束前被调用方主动关闭
+)
+
+// requestAttributes 获取请求的 span 属性
+func requestAttributes(requestInfo *httpclient.RequestInfo, operation string, request any) (attrs []attribute.KeyValue) {
+	attrs = []attribute.KeyValue{
+		attribute.String(attrOperationName, operation),
+		attribute.String(attrSystem, requestInfo.Provider),
+		attribute.String(attrRequestModel, requestInfo.Model),
+		attribute.String(attrRequestID, requestInfo.RequestID),
+	}
+	chatReq, ok := request.(models.ChatRequest)
+	if !ok {
+		return
+	}
+	if chatReq.MaxCompletionTokens != nil {
+		attrs = append(attrs, attribute.Int(attrRequestMaxTokens, *chatReq.MaxCompletionTokens))
+	}
+	if chatReq.Temperature != nil {
+		attrs = append(attrs, attribute.Float64(attrRequestTemperature, float64(*chatReq.Temperature)))
+	}
+	if chatReq.TopP != nil {
+		attrs = append(attrs, attribute.Float64(attrRequestTopP, float64(*chatReq.TopP)))
+	}
+	if chatReq.FrequencyPenalty != nil {
+		attrs = append(attrs, attribute.Float64(attrRequestFrequency, float64(*chatReq.FrequencyPenalty)))
+	}
+	if chatReq.PresencePenalty != nil {
+		attrs = append(attrs, attribute.Float64(attrRequestPresence, float64(*chatReq.PresencePenalty)))
+	}
+	if len(chatReq.Stop) > 0 {
+		attrs = append(attrs, attribute.StringSlice(attrRequestStopSequences, chatReq.Stop))
+	}
+	if chatReq.Seed != nil {
+		attrs = append(attrs, attribute.Int(attrRequestSeed, *chatReq.Seed))
+	}
+	if chatReq.N != nil {
+		attrs = append(attrs, attribute.Int(attrRequestChoiceCount, *chatReq.N))
+	}
+	return
+}
+
+// responseAttributes 获取响应的 span 属性
+func responseAttributes(id, model string) (attrs []attribute.KeyValue) {
+	if id != "" {
+		attrs = append(attrs, attribute.String(attrResponseID, id))
+	}
+	if model != "" {
+		attrs = append(attrs, attribute.String(attrResponseModel, model))
+	}
+	return
+}
+
+// usageAttributes 获取用量的 span 属性
+func usageAttributes(usage cost.Usage) (attrs []attribute.KeyValue) {
+	return []attribute.KeyValue{
+		attribute.Int(attrUsageInputTokens, usage.InputTokens),
+		attribute.Int(attrUsageOutputTokens, usage.OutputTokens),
+	}
+}
+
+// messageContent 获取消息的角色和文本内容
+func messageContent(message models.ChatMessage) (role, content string) {
+	switch msg := message.(type) {
+	case *models.SystemMessage:
+		return "system", msg.Content
+	case *models.DeveloperMessage:
+		return "system", msg.Content
+	case *models.UserMessage:
+		content = msg.Content
+		for _, part := range msg.MultimodalContent {
+			content += part.Text
+		}
+		return "user", content
+	case *models.AssistantMessage:
+		content = msg.Content
+		for _, part := range msg.MultimodalContent {
+			content += part.Text
+		}
+		return "assistant", content
+	case *models.ToolMessage:
+		return "tool", msg.Content
+	default:
+		return "unknown", ""
+	}
+}
@@ -0,0 +1,350 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-31 10:08:27
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-08-07 15:12:38
+ * @Description: OpenTelemetry 链路追踪中间件，遵循 GenAI 语义约定
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package tracing
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Mrzhouyl/go-aisdk/consts"
+	"github.com/Mrzhouyl/go-aisdk/cost"
+	"github.com/Mrzhouyl/go-aisdk/httpclient"
+	"github.com/Mrzhouyl/go-aisdk/models"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName 链路追踪的 instrumentation 名称
+const instrumentationName = "github.com/Mrzhouyl/go-aisdk/tracing"
+
+// RedactFunc 内容脱敏函数，role 为 system、user、assistant、tool 等角色，返回空字符串表示不记录该内容
+type RedactFunc func(ctx context.Context, role, content string) (redacted string)
+
+// TracingMiddlewareConfig 链路追踪中间件配置
+type TracingMiddlewareConfig struct {
+	TracerProvider trace.TracerProvider          // 链路追踪提供者，默认使用 otel.GetTracerProvider()
+	Propagator     propagation.TextMapPropagator // 注入到 HTTP 请求头的上下文传播器，默认使用 W3C Trace Context 和 Baggage
+	CaptureContent bool                          // 是否以 span 事件记录提示词和生成内容，默认不记录
+	Redact         RedactFunc                    // 记录内容前的脱敏函数，仅在 CaptureContent 为 true 时生效
+}
+
+// TracingMiddleware 链路追踪中间件，每次请求创建一个 span，HTTP 往返创建子 span 并将链路上下文注入到请求头
+type TracingMiddleware struct {
+	config  TracingMiddlewareConfig
+	tracer  trace.Tracer
+	attempt *AttemptMiddleware
+}
+
+// AttemptMiddleware 重试尝试链路追踪中间件，位于重试中间件内层，每次尝试创建一个子 span
+type AttemptMiddleware struct {
+	tracer trace.Tracer
+}
+
+// NewTracingMiddleware 创建链路追踪中间件
+func NewTracingMiddleware(config TracingMiddlewareConfig) (m *TracingMiddleware) {
+	// 设置默认值
+	if config.TracerProvider == nil {
+		config.TracerProvider = otel.GetTracerProvider()
+	}
+	if config.Propagator == nil {
+		config.Propagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+	}
+	tracer := config.TracerProvider.Tracer(instrumentationName)
+	return &TracingMiddleware{
+		config:  config,
+		tracer:  tracer,
+		attempt: &AttemptMiddleware{tracer: tracer},
+	}
+}
+
+// Process 处理请求
+func (m *TracingMiddleware) Process(ctx context.Context, request any, next httpclient.MWHandler) (response any, err error) {
+	var (
+		requestInfo = httpclient.GetRequestInfo(ctx)
+		operation   = operationName(requestInfo)
+		start       = time.Now()
+		span        trace.Span
+	)
+	ctx, span = m.tracer.Start(ctx, spanName(operation, requestInfo.Model),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithTimestamp(start),
+		trace.WithAttributes(requestAttributes(requestInfo, operation, request)...),
+	)
+	if m.config.CaptureContent {
+		m.recordPrompt(ctx, span, request)
+	}
+	// 每次 HTTP 往返创建子 span 并注入链路上下文
+	ctx = httpclient.WithRoundTripInterceptor(ctx, m.roundTrip)
+	// 执行下一个处理器
+	if response, err = next(ctx, request); err != nil {
+		endWithError(span, err)
+		return
+	}
+	span.SetAttributes(attribute.Bool(attrCacheHit, requestInfo.CacheHit), attribute.Int(attrAttempts, requestInfo.Attempt+1))
+	// 流式传输在结束时结束 span
+	if stream, ok := response.(httpclient.WrappableStream); ok {
+		stream.WrapRecv(func(next httpclient.StreamRecvFunc) (recv httpclient.StreamRecvFunc) {
+			return m.wrapStream(ctx, span, start, next)
+		})
+		return
+	}
+	m.recordResponse(ctx, span, request, response)
+	span.End()
+	return
+}
+
+// Name 返回中间件名称
+func (m *TracingMiddleware) Name() (name string) {
+	return "otel"
+}
+
+// Priority 返回中间件优先级
+func (m *TracingMiddleware) Priority() (priority int) {
+	return 5 // 链路追踪中间件位于脱敏中间件（优先级 3）内层，span 覆盖其余中间件的耗时，只记录脱敏后的内容
+}
+
+// AttemptMiddleware 获取重试尝试链路追踪中间件，需要与链路追踪中间件一起添加
+func (m *TracingMiddleware) AttemptMiddleware() (attempt *AttemptMiddleware) {
+	return m.attempt
+}
+
+// Process 处理请求
+func (m *AttemptMiddleware) Process(ctx context.Context, request any, next httpclient.MWHandler) (response any, err error) {
+	requestInfo := httpclient.GetRequestInfo(ctx)
+	ctx, span := m.tracer.Start(ctx, spanName(operationName(requestInfo), requestInfo.Model)+" attempt",
+		trace.WithAttributes(attribute.Int(attrAttempt, requestInfo.Attempt+1)),
+	)
+	if response, err = next(ctx, request); err != nil {
+		endWithError(span, err)
+		return
+	}
+	span.End()
+	return
+}
+
+// Name 返回中间件名称
+func (m *AttemptMiddleware) Name() (name string) {
+	return "otel_attempt"
+}
+
+// Priority 返回中间件优先级
+func (m *AttemptMiddleware) Priority() (priority int) {
+	return 21 // 位于重试中间件内层，每次尝试都会执行
+}
+
+// roundTrip 为 HTTP 往返创建子 span，并将链路上下文注入到请求头
+func (m *TracingMiddleware) roundTrip(req *http.Request, next httpclient.RoundTripFunc) (resp *http.Response, err error) {
+	ctx, span := m.tracer.Start(req.Context(), req.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String(attrHTTPRequestMethod, req.Method),
+			attribute.String(attrURLFull, req.URL.Redacted()),
+			attribute.String(attrServerAddress, req.URL.Hostname()),
+			attribute.Int(attrServerPort, serverPort(req)),
+		),
+	)
+	defer span.End()
+
+	req = req.WithContext(ctx)
+	m.config.Propagator.Inject(ctx, propagation.HeaderCarrier(req.Header))
+	if resp, err = next(req); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		span.SetAttributes(attribute.String(attrErrorType, errorType(err)))
+		return
+	}
+	span.SetAttributes(attribute.Int(attrHTTPResponseStatus, resp.StatusCode))
+	if resp.StatusCode >= http.StatusBadRequest {
+		span.SetStatus(codes.Error, resp.Status)
+		span.SetAttributes(attribute.String(attrErrorType, strconv.Itoa(resp.StatusCode)))
+	}
+	return
+}
+
+// recordResponse 记录非流式响应的属性和内容
+func (m *TracingMiddleware) recordResponse(ctx context.Context, span trace.Span, request, response any) {
+	if usage, missing, _, ok := cost.ResponseUsage(request, response); ok && !missing {
+		span.SetAttributes(usageAttributes(usage)...)
+	}
+	chatResp, ok := response.(models.ChatResponse)
+	if !ok {
+		return
+	}
+	span.SetAttributes(responseAttributes(chatResp.ID, chatResp.Model)...)
+	var (
+		finishReasons = make([]string, 0, len(chatResp.Choices))
+		contents      = make([]string, 0, len(chatResp.Choices))
+	)
+	for _, choice := range chatResp.Choices {
+		finishReasons = append(finishReasons, string(choice.FinishReason))
+		if choice.Message != nil {
+			contents = append(contents, choice.Message.Content)
+		} else {
+			contents = append(contents, "")
+		}
+	}
+	span.SetAttributes(attribute.StringSlice(attrResponseFinishReasons, finishReasons))
+	if m.config.CaptureContent {
+		m.recordChoices(ctx, span, finishReasons, contents)
+	}
+}
+
+// wrapStream 包装流式数据接收函数，记录首个数据块耗时、结束原因和用量，流结束或出错时结束 span
+func (m *TracingMiddleware) wrapStream(ctx context.Context, span trace.Span, start time.Time, next httpclient.StreamRecvFunc) (recv httpclient.StreamRecvFunc) {
+	var (
+		chunks        int
+		usage         *models.ChatUsage
+		id, model     string
+		finishReasons []string
+		contents      []string
+		ended         bool
+	)
+	return func() (chunk any, isFinished bool, err error) {
+		chunk, isFinished, err = next()
+		if ended {
+			return
+		}
+		if c, ok := chunk.(models.ChatBaseResponse); ok && err == nil && !isFinished {
+			if chunks++; chunks == 1 {
+				span.SetAttributes(attribute.Int64(attrStreamTTFT, time.Since(start).Milliseconds()))
+			}
+			id, model = cmp.Or(c.ID, id), cmp.Or(c.Model, model)
+			if c.Usage != nil {
+				usage = c.Usage
+			}
+			for _, choice := range c.Choices {
+				for len(finishReasons) <= choice.Index {
+					finishReasons, contents = append(finishReasons, ""), append(contents, "")
+				}
+				if choice.FinishReason != "" {
+					finishReasons[choice.Index] = string(choice.FinishReason)
+				}
+				if m.config.CaptureContent && choice.Delta != nil {
+					contents[choice.Index] += choice.Delta.Content
+				}
+			}
+			return
+		}
+		// 流结束或出错
+		ended = true
+		span.SetAttributes(responseAttributes(id, model)...)
+		span.SetAttributes(attribute.Int(attrStreamChunks, chunks), attribute.StringSlice(attrResponseFinishReasons, finishReasons))
+		if usage != nil {
+			span.SetAttributes(usageAttributes(cost.ChatUsage(usage))...)
+		}
+		if m.config.CaptureContent {
+			m.recordChoices(ctx, span, finishReasons, contents)
+		}
+		// 调用方主动关闭流不视为失败
+		if errors.Is(err, httpclient.ErrStreamClosed) {
+			span.SetAttributes(attribute.Bool(attrStreamClosed, true))
+			span.End()
+			return
+		}
+		if err != nil {
+			endWithError(span, err)
+			return
+		}
+		span.End()
+		return
+	}
+}
+
+// recordPrompt 以 span 事件记录提示词
+func (m *TracingMiddleware) recordPrompt(ctx context.Context, span trace.Span, request any) {
+	chatReq, ok := request.(models.ChatRequest)
+	if !ok {
+		return
+	}
+	for _, message := range chatReq.Messages {
+		role, content := messageContent(message)
+		if content = m.redact(ctx, role, content); content == "" {
+			continue
+		}
+		span.AddEvent("gen_ai."+role+".message", trace.WithAttributes(attribute.String(attrEventContent, content)))
+	}
+}
+
+// recordChoices 以 span 事件记录生成内容
+func (m *TracingMiddleware) recordChoices(ctx context.Context, span trace.Span, finishReasons, contents []string) {
+	for i, content := range contents {
+		if content = m.redact(ctx, "assistant", content); content == "" {
+			continue
+		}
+		span.AddEvent("gen_ai.choice", trace.WithAttributes(
+			attribute.Int(attrEventIndex, i),
+			attribute.String(attrEventFinishReason, finishReasons[i]),
+			attribute.String(attrEventContent, content),
+		))
+	}
+}
+
+// redact 对内容脱敏
+func (m *TracingMiddleware) redact(ctx context.Context, role, content string) (redacted string) {
+	if content == "" || m.config.Redact == nil {
+		return content
+	}
+	return m.config.Redact(ctx, role, content)
+}
+
+// endWithError 记录错误并结束 span
+func endWithError(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+	span.SetAttributes(attribute.String(attrErrorType, errorType(err)))
+	span.End()
+}
+
+// errorType 获取错误类型，使用 SDK 的错误分类，无法识别时返回 "_OTHER"
+func errorType(err error) (typ string) {
+	if kind := httpclient.ClassifyError(err); kind != httpclient.ErrorKindUnknown {
+		return string(kind)
+	}
+	return "_OTHER"
+}
+
+// operationName 获取 GenAI 操作名称
+func operationName(requestInfo *httpclient.RequestInfo) (operation string) {
+	switch consts.ModelType(requestInfo.ModelType) {
+	case consts.ChatModel:
+		return "chat"
+	case consts.EmbedModel:
+		return "embeddings"
+	default:
+		return requestInfo.Method
+	}
+}
+
+// spanName 获取 span 名称，格式为 "{操作名称} {模型}"
+func spanName(operation, model string) (name string) {
+	if model == "" || model == "unknown" {
+		return operation
+	}
+	return fmt.Sprintf("%s %s", operation, model)
+}
+
+// serverPort 获取请求的服务端口
+func serverPort(req *http.Request) (port int) {
+	if port, err := strconv.Atoi(req.URL.Port()); err == nil {
+		return port
+	}
+	if req.URL.Scheme == "http" {
+		return 80
+	}
+	return 443
+}
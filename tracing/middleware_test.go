@@ -0,0 +1,236 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-31 15:02:36
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-07-31 17:19:51
+ * @Description:
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package tracing
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Mrzhouyl/go-aisdk/consts"
+	"github.com/Mrzhouyl/go-aisdk/httpclient"
+	"github.com/Mrzhouyl/go-aisdk/internal/utils"
+	"github.com/Mrzhouyl/go-aisdk/models"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// newTestTracing 创建使用内存导出器的链路追踪中间件
+func newTestTracing(config TracingMiddlewareConfig) (m *TracingMiddleware, exporter *tracetest.InMemoryExporter) {
+	exporter = tracetest.NewInMemoryExporter()
+	config.TracerProvider = sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	return NewTracingMiddleware(config), exporter
+}
+
+// chatContext 创建聊天请求的上下文
+func chatContext() (ctx context.Context) {
+	return httpclient.SetRequestInfo(context.Background(), &httpclient.RequestInfo{
+		Provider:  string(consts.OpenAI),
+		ModelType: string(consts.ChatModel),
+		Model:     consts.OpenAIGPT4o,
+		Method:    "CreateChatCompletion",
+		RequestID: "req-1",
+	})
+}
+
+// findSpan 按名称查找 span
+func findSpan(t *testing.T, spans tracetest.SpanStubs, name string) (span tracetest.SpanStub) {
+	t.Helper()
+	for _, s := range spans {
+		if s.Name == name {
+			return s
+		}
+	}
+	t.Fatalf("span %q not found in %d spans", name, len(spans))
+	return
+}
+
+// attr 获取 span 属性
+func attr(span tracetest.SpanStub, key string) (value attribute.Value) {
+	for _, kv := range span.Attributes {
+		if string(kv.Key) == key {
+			return kv.Value
+		}
+	}
+	return
+}
+
+// redactEmail 将内容中的邮箱地址替换为占位符
+func redactEmail(ctx context.Context, role, content string) (redacted string) {
+	return strings.ReplaceAll(content, "alice@example.com", "[EMAIL]")
+}
+
+func TestTracingMiddleware(t *testing.T) {
+	var (
+		calls       int
+		traceparent []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = append(traceparent, r.Header.Get("traceparent"))
+		if calls++; calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"error":{"message":"overloaded","type":"server_error"}}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"chatcmpl-1","model":"gpt-4o-2024-08-06","choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"Sent to alice@example.com"}}],"usage":{"prompt_tokens":12,"completion_tokens":5,"total_tokens":17}}`))
+	}))
+	defer server.Close()
+
+	m, exporter := newTestTracing(TracingMiddlewareConfig{CaptureContent: true, Redact: redactEmail})
+	chain := httpclient.NewChain(
+		m,
+		httpclient.NewRetryMiddleware(httpclient.RetryMiddlewareConfig{MaxAttempts: 1, Strategy: httpclient.RetryStrategyFixed, BaseDelay: time.Millisecond}),
+		m.AttemptMiddleware(),
+	)
+	client := httpclient.NewHTTPClientWithConfig(httpclient.HTTPClientConfig{
+		BaseURL:         server.URL,
+		HTTPClient:      httpclient.NewDefaultHTTPDoer(time.Second),
+		ResponseDecoder: utils.NewDeserializer(string(consts.OpenAI), false),
+	})
+	request := models.ChatRequest{
+		Model:               consts.OpenAIGPT4o,
+		Messages:            []models.ChatMessage{&models.UserMessage{Content: "Email alice@example.com"}},
+		MaxCompletionTokens: models.Int(100),
+	}
+	_, err := chain.Execute(chatContext(), request, func(ctx context.Context, req any) (resp any, err error) {
+		httpReq, err := client.NewRequest(ctx, http.MethodPost, server.URL)
+		if err != nil {
+			return
+		}
+		var chatResp models.ChatResponse
+		if err = client.SendRequest(httpReq, &chatResp); err != nil {
+			return
+		}
+		return chatResp, nil
+	})
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 5 {
+		t.Fatalf("expected 1 request span, 2 attempt spans and 2 round trip spans, got %d", len(spans))
+	}
+	root := findSpan(t, spans, "chat gpt-4o")
+	if root.SpanKind != trace.SpanKindClient || root.Status.Code == codes.Error {
+		t.Errorf("unexpected root span kind %s status %v", root.SpanKind, root.Status)
+	}
+	for key, want := range map[string]attribute.Value{
+		"gen_ai.system":                  attribute.StringValue("openai"),
+		"gen_ai.request.model":           attribute.StringValue("gpt-4o"),
+		"gen_ai.request.max_tokens":      attribute.IntValue(100),
+		"gen_ai.response.model":          attribute.StringValue("gpt-4o-2024-08-06"),
+		"gen_ai.usage.input_tokens":      attribute.IntValue(12),
+		"gen_ai.usage.output_tokens":     attribute.IntValue(5),
+		"gen_ai.response.finish_reasons": attribute.StringSliceValue([]string{"stop"}),
+		"aisdk.retry.attempts":           attribute.IntValue(2),
+	} {
+		if got := attr(root, key); got != want {
+			t.Errorf("attribute %s = %v, want %v", key, got.Emit(), want.Emit())
+		}
+	}
+	// 提示词和生成内容经过脱敏后记录
+	if len(root.Events) != 2 || root.Events[0].Name != "gen_ai.user.message" || root.Events[1].Name != "gen_ai.choice" {
+		t.Fatalf("unexpected events: %+v", root.Events)
+	}
+	for _, event := range root.Events {
+		for _, kv := range event.Attributes {
+			if kv.Key == "content" && strings.Contains(kv.Value.AsString(), "alice@example.com") {
+				t.Errorf("event %s content not redacted: %s", event.Name, kv.Value.AsString())
+			}
+		}
+	}
+
+	// 每次尝试和 HTTP 往返都是子 span，HTTP 往返的 span 上下文传播到请求头
+	var attempts, roundTrips int
+	for _, span := range spans {
+		switch span.Name {
+		case "chat gpt-4o attempt":
+			attempts++
+			if span.Parent.SpanID() != root.SpanContext.SpanID() {
+				t.Errorf("attempt span parent = %s, want root", span.Parent.SpanID())
+			}
+		case http.MethodPost:
+			if span.Parent.TraceID() != root.SpanContext.TraceID() {
+				t.Errorf("round trip span is not in the request trace")
+			}
+			if want := "00-" + span.SpanContext.TraceID().String() + "-" + span.SpanContext.SpanID().String() + "-01"; traceparent[roundTrips] != want {
+				t.Errorf("traceparent = %q, want %q", traceparent[roundTrips], want)
+			}
+			roundTrips++
+		}
+	}
+	if attempts != 2 || roundTrips != 2 {
+		t.Errorf("attempt spans = %d, round trip spans = %d, want 2 and 2", attempts, roundTrips)
+	}
+	if first := findSpan(t, spans, "chat gpt-4o attempt"); first.Status.Code != codes.Error || attr(first, "error.type").AsString() != "server_error" {
+		t.Errorf("failed attempt status = %v, error.type = %s", first.Status, attr(first, "error.type").AsString())
+	}
+}
+
+func TestTracingMiddleware_Stream(t *testing.T) {
+	m, exporter := newTestTracing(TracingMiddlewareConfig{})
+	body := "data: {\"id\":\"chatcmpl-2\",\"model\":\"gpt-4o\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"hi\"}}]}\n\n" +
+		"data: {\"choices\":[{\"index\":0,\"delta\":{},\"finish_reason\":\"length\"}]}\n\n" +
+		"data: {\"choices\":[],\"usage\":{\"prompt_tokens\":7,\"completion_tokens\":2}}\n\n" +
+		"data: [DONE]\n\n"
+	response, err := httpclient.NewChain(m).Execute(chatContext(), models.ChatRequest{}, func(ctx context.Context, req any) (any, error) {
+		time.Sleep(5 * time.Millisecond)
+		return models.ChatResponseStream{StreamReader: httpclient.NewStreamReader[models.ChatBaseResponse](
+			io.NopCloser(strings.NewReader(body)), nil, httpclient.HTTPClientConfig{ResponseDecoder: utils.NewDeserializer("", true)},
+		)}, nil
+	})
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	// 流结束前不导出 span
+	if len(exporter.GetSpans()) != 0 {
+		t.Fatal("span should end when the stream ends")
+	}
+	stream := response.(models.ChatResponseStream)
+	for {
+		_, isFinished, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("Recv() error = %v", err)
+		}
+		if isFinished {
+			break
+		}
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(spans))
+	}
+	span := spans[0]
+	if ttft := attr(span, "aisdk.stream.time_to_first_token_ms").AsInt64(); ttft < 5 {
+		t.Errorf("time to first token = %dms, want >= 5ms", ttft)
+	}
+	if got := attr(span, "gen_ai.usage.input_tokens").AsInt64(); got != 7 {
+		t.Errorf("input tokens = %d, want 7", got)
+	}
+	if got := attr(span, "gen_ai.response.finish_reasons").AsStringSlice(); len(got) != 1 || got[0] != "length" {
+		t.Errorf("finish reasons = %v, want [length]", got)
+	}
+	if got := attr(span, "gen_ai.response.id").AsString(); got != "chatcmpl-2" {
+		t.Errorf("response id = %s, want chatcmpl-2", got)
+	}
+	if len(span.Events) != 0 {
+		t.Errorf("content should not be captured by default, got %d events", len(span.Events))
+	}
+}
@@ -0,0 +1,126 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-28 11:52:08
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-08-07 14:05:13
+ * @Description: 请求参数预检
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package aisdk
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Mrzhouyl/go-aisdk/consts"
+	"github.com/Mrzhouyl/go-aisdk/core"
+	"github.com/Mrzhouyl/go-aisdk/errors"
+	"github.com/Mrzhouyl/go-aisdk/httpclient"
+	"github.com/Mrzhouyl/go-aisdk/internal/utils"
+	"github.com/Mrzhouyl/go-aisdk/models"
+)
+
+// ValidationMode 请求参数预检模式
+type ValidationMode int
+
+const (
+	ValidationOff    ValidationMode = iota // 不检查（默认）
+	ValidationWarn                         // 记录警告日志后继续请求
+	ValidationStrict                       // 返回 errors.ErrUnsupportedParameter，不发送请求
+)
+
+// ValidationConfig 请求参数预检配置
+type ValidationConfig struct {
+	Mode   ValidationMode    // 预检模式
+	Logger httpclient.Logger // ValidationWarn 模式下记录警告的日志器，默认输出到标准输出
+}
+
+// DefaultValidationConfig 默认请求参数预检配置，默认不检查，需要时通过 WithValidation 开启
+func DefaultValidationConfig() (config ValidationConfig) {
+	return ValidationConfig{
+		Mode: ValidationOff,
+	}
+}
+
+// WithValidation 设置请求参数预检，请求发送前检查提供商不支持（序列化时会被丢弃）的参数和模型特性不支持的输入
+func WithValidation(config ValidationConfig) (opt SDKClientOption) {
+	return func(c *clientOption) {
+		if config.Mode == ValidationWarn && config.Logger == nil {
+			config.Logger = httpclient.NewDefaultLogger(httpclient.LogLevelWarn)
+		}
+		c.validation = config
+	}
+}
+
+// validateRequest 检查请求参数，ValidationStrict 模式下存在问题时返回 errors.ErrUnsupportedParameter
+func (c *SDKClient) validateRequest(ctx context.Context, modelInfo models.ModelInfo, method string, request any) (err error) {
+	if c.validation.Mode == ValidationOff || request == nil {
+		return
+	}
+	var ps core.ProviderService
+	if ps = core.GetProvider(modelInfo.Provider); ps == nil {
+		// 由最终处理函数返回提供商不支持的错误
+		return
+	}
+	// 不支持的模型由最终处理函数返回错误
+	feature, ok := ps.GetSupportedModels()[modelInfo.ModelType][modelInfo.Model]
+	if !ok {
+		return
+	}
+	issues := unsupportedIssues(modelInfo.Provider, feature, method, request)
+	if len(issues) == 0 {
+		return
+	}
+	if c.validation.Mode == ValidationStrict {
+		return errors.WrapUnsupportedParameter(modelInfo.Provider, modelInfo.Model, issues)
+	}
+	if c.validation.Logger != nil {
+		c.validation.Logger.Warn(ctx, "[%s] provider [%s] model [%s] does not support: %s",
+			method, modelInfo.Provider, modelInfo.Model, strings.Join(issues, "; "))
+	}
+	return
+}
+
+// unsupportedIssues 获取请求中提供商或模型不支持的参数说明
+func unsupportedIssues(provider consts.Provider, feature consts.ModelFeature, method string, request any) (issues []string) {
+	// 序列化时会被丢弃的参数
+	for _, field := range utils.NewSerializer(provider.String()).UnsupportedFields(request) {
+		issues = append(issues, fmt.Sprintf("%s (ignored by %s)", field, provider))
+	}
+	chatReq, isChat := request.(models.ChatRequest)
+	if !isChat {
+		return
+	}
+	// 仅支持流式传输的模型
+	if feature.IsStreamingOnly() && method == "CreateChatCompletion" {
+		issues = append(issues, "non-streaming call (model only supports streaming, use CreateChatCompletionStream)")
+	}
+	// 非多模态模型的图像、音频、视频和文件输入
+	if !feature.IsMultimodal() {
+		for i, message := range chatReq.Messages {
+			userMsg, ok := message.(*models.UserMessage)
+			if !ok {
+				continue
+			}
+			for j, part := range userMsg.MultimodalContent {
+				var kind string
+				switch {
+				case part.ImageURL != nil:
+					kind = "image_url"
+				case part.InputAudio != nil:
+					kind = "input_audio"
+				case part.InputVideo != nil:
+					kind = "input_video"
+				case part.File != nil:
+					kind = "file"
+				default:
+					continue
+				}
+				issues = append(issues, fmt.Sprintf("messages[%d].multimodal_content[%d].%s (model is not multimodal)", i, j, kind))
+			}
+		}
+	}
+	return
+}
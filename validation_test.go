@@ -0,0 +1,147 @@
+/*
+ * @Author: liusuxian 382185882@qq.com
+ * @Date: 2025-07-28 16:30:12
+ * @LastEditors: liusuxian 382185882@qq.com
+ * @LastEditTime: 2025-08-07 14:05:13
+ * @Description:
+ *
+ * Copyright (c) 2025 by liusuxian email: 382185882@qq.com, All Rights Reserved.
+ */
+package aisdk
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/Mrzhouyl/go-aisdk/consts"
+	"github.com/Mrzhouyl/go-aisdk/errors"
+	"github.com/Mrzhouyl/go-aisdk/models"
+)
+
+func TestUnsupportedIssues(t *testing.T) {
+	imageMessage := &models.UserMessage{MultimodalContent: []models.ChatUserMsgPart{
+		{Type: models.ChatUserMsgPartTypeText, Text: "describe"},
+		{Type: models.ChatUserMsgPartTypeImageURL, ImageURL: &models.ChatUserMsgImageURL{URL: "https://example.com/a.png"}},
+	}}
+	tests := []struct {
+		name     string
+		provider consts.Provider
+		feature  consts.ModelFeature
+		method   string
+		request  models.ChatRequest
+		want     []string
+	}{
+		{
+			name:     "reasoning effort to deepseek",
+			provider: consts.DeepSeek,
+			method:   "CreateChatCompletion",
+			request: models.ChatRequest{
+				Messages:        []models.ChatMessage{&models.UserMessage{Content: "hi"}},
+				ReasoningEffort: models.ChatReasoningEffortTypeHigh,
+				Temperature:     models.Float32(0.2),
+			},
+			want: []string{"reasoning_effort (ignored by deepseek)"},
+		},
+		{
+			name:     "logit bias and stream to alibl",
+			provider: consts.AliBL,
+			feature:  consts.ModelFeatureMultimodal,
+			method:   "CreateChatCompletionStream",
+			request: models.ChatRequest{
+				Messages:      []models.ChatMessage{imageMessage},
+				LogitBias:     map[string]int{"50256": -100},
+				Stream:        models.Bool(true),
+				StreamOptions: &models.ChatStreamOptions{IncludeUsage: models.Bool(true)},
+			},
+			want: []string{"logit_bias (ignored by alibl)"},
+		},
+		{
+			name:     "nested fields",
+			provider: consts.DeepSeek,
+			method:   "CreateChatCompletion",
+			request: models.ChatRequest{
+				Messages: []models.ChatMessage{
+					&models.UserMessage{Content: "hi"},
+					&models.AssistantMessage{Content: "hello", Refusal: "no"},
+				},
+				StreamOptions: &models.ChatStreamOptions{IncludeUsage: models.Bool(true)},
+			},
+			want: []string{"messages[1].refusal (ignored by deepseek)"},
+		},
+		{
+			name:     "image to non-multimodal model",
+			provider: consts.OpenAI,
+			method:   "CreateChatCompletion",
+			request:  models.ChatRequest{Messages: []models.ChatMessage{imageMessage}},
+			want:     []string{"messages[0].multimodal_content[1].image_url (model is not multimodal)"},
+		},
+		{
+			name:     "non-streaming call to streaming only model",
+			provider: consts.AliBL,
+			feature:  consts.ModelFeatureReasoningStream,
+			method:   "CreateChatCompletion",
+			request:  models.ChatRequest{Messages: []models.ChatMessage{&models.UserMessage{Content: "hi"}}},
+			want:     []string{"non-streaming call (model only supports streaming, use CreateChatCompletionStream)"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := unsupportedIssues(tt.provider, tt.feature, tt.method, tt.request); !slices.Equal(got, tt.want) {
+				t.Errorf("unsupportedIssues() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// recordLogger 记录警告日志的日志器
+type recordLogger struct {
+	warnings []string
+}
+
+func (l *recordLogger) Debug(ctx context.Context, format string, args ...any) {}
+func (l *recordLogger) Info(ctx context.Context, format string, args ...any)  {}
+func (l *recordLogger) Warn(ctx context.Context, format string, args ...any) {
+	l.warnings = append(l.warnings, fmt.Sprintf(format, args...))
+}
+func (l *recordLogger) Error(ctx context.Context, format string, args ...any) {}
+
+func TestValidateRequest(t *testing.T) {
+	var (
+		modelInfo = models.ModelInfo{Provider: consts.DeepSeek, ModelType: consts.ChatModel, Model: consts.DeepSeekChat}
+		request   = models.ChatRequest{
+			Messages:        []models.ChatMessage{&models.UserMessage{Content: "hi"}},
+			ReasoningEffort: models.ChatReasoningEffortTypeLow,
+		}
+		logger = &recordLogger{}
+	)
+
+	c := &SDKClient{validation: ValidationConfig{Mode: ValidationStrict}}
+	err := c.validateRequest(context.Background(), modelInfo, "CreateChatCompletion", request)
+	if !errors.IsUnsupportedParameterError(err) {
+		t.Fatalf("expected ErrUnsupportedParameter, got %v", err)
+	}
+
+	c.validation = ValidationConfig{Mode: ValidationWarn, Logger: logger}
+	if err = c.validateRequest(context.Background(), modelInfo, "CreateChatCompletion", request); err != nil {
+		t.Fatalf("validateRequest() error = %v", err)
+	}
+	if len(logger.warnings) != 1 {
+		t.Errorf("expected 1 warning, got %q", logger.warnings)
+	}
+
+	// 默认关闭预检，关闭预检和未知模型时不检查
+	if mode := DefaultValidationConfig().Mode; mode != ValidationOff {
+		t.Errorf("default validation mode = %d, want ValidationOff", mode)
+	}
+	c.validation.Mode = ValidationOff
+	if err = c.validateRequest(context.Background(), modelInfo, "CreateChatCompletion", request); err != nil || len(logger.warnings) != 1 {
+		t.Errorf("validation off: err = %v, warnings = %q", err, logger.warnings)
+	}
+	c.validation.Mode = ValidationStrict
+	modelInfo.Model = "unknown"
+	if err = c.validateRequest(context.Background(), modelInfo, "CreateChatCompletion", request); err != nil {
+		t.Errorf("unknown model: err = %v", err)
+	}
+}